@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessLogMasksAPIKeys(t *testing.T) {
+	var logged bytes.Buffer
+	defaultWriter := gin.DefaultWriter
+	gin.DefaultWriter = &logged
+	t.Cleanup(func() { gin.DefaultWriter = defaultWriter })
+
+	const apiKey = "s3cr3t-key-0123456789"
+	server := newTestServer(t, "auth:\n  enabled: true\n  api_keys:\n    "+apiKey+": team\nlog:\n  redact_query_params: [token]\n")
+	putObject(t, server, "a.txt", "a")
+
+	requests := map[string]map[string]string{
+		"/download/default/a.txt":                           {"X-API-Key": apiKey},
+		"/download/default/a.txt?api_key=" + apiKey:         nil,
+		"/download/default/a.txt?token=private-token-value": {"X-API-Key": apiKey},
+	}
+	for target, headers := range requests {
+		logged.Reset()
+		if rec := serve(server, http.MethodGet, target, nil, headers); rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body)
+		}
+		line := logged.String()
+		if strings.Contains(line, apiKey) || strings.Contains(line, "private-token-value") {
+			t.Errorf("GET %s logged a secret: %s", target, line)
+		}
+		if !strings.Contains(line, "key=s3cr***") {
+			t.Errorf("GET %s logged no masked key: %s", target, line)
+		}
+	}
+}
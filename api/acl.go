@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// setACL handles requests to make an object publicly readable straight from
+// the provider with ?acl=public-read, or private again with ?acl=private. On
+// backends that can only change a whole bucket, ?scope=bucket must be added
+// to confirm that every object in it is affected.
+func (s *Server) setACL(c *gin.Context) {
+	store := s.storageFor(c)
+	aclStore, ok := store.(storage.ACLStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "ACLs are not supported by this storage backend"})
+		return
+	}
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+
+	acl := c.Query("acl")
+	if acl != storage.ACLPrivate && acl != storage.ACLPublicRead {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid acl %q, expected %s or %s", acl, storage.ACLPrivate, storage.ACLPublicRead)})
+		return
+	}
+	scope := store.Capabilities().ACLScope
+	if scope == storage.ACLScopeBucket {
+		if keyPrefixed(c) {
+			return
+		}
+		if c.Query("scope") != storage.ACLScopeBucket {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "This backend can only change the access of the whole bucket; add scope=bucket to change it for every object in it",
+				"scope": scope,
+			})
+			return
+		}
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if err := aclStore.SetACL(ctx, bucket, object, acl); err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to set ACL: %v", err)})
+		return
+	}
+
+	response := gin.H{
+		"message": "ACL updated successfully",
+		"bucket":  bucket,
+		"object":  object,
+		"acl":     acl,
+		"scope":   scope,
+	}
+	if acl == storage.ACLPublicRead {
+		response["public_url"] = aclStore.PublicURL(bucket, object)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/example/file-service/config"
+)
+
+// AdminMiddleware checks the X-Admin-Key header against auth.admin_key. The
+// admin endpoints are disabled when no admin key is configured.
+func (s *Server) AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := s.config().Auth.AdminKey
+		if adminKey == "" {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Admin endpoints are not configured"})
+			c.Abort()
+			return
+		}
+
+		key := c.GetHeader("X-Admin-Key")
+		if key == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin key is required"})
+			c.Abort()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin key"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// reloadConfig handles requests to re-read the configuration file. Settings
+// that are safe to change at runtime are swapped in as a whole; changes to the
+// port, the request limit, the audit log, the metadata cache or the storage
+// backends are kept at their current values and reported as requiring a restart.
+func (s *Server) reloadConfig(c *gin.Context) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	next, err := config.LoadConfig(viper.ConfigFileUsed())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to reload config: %v", err)})
+		return
+	}
+
+	current := s.config()
+	restart := restartOnlyChanges(current, next)
+
+	// The backends and the request limiter were created at startup, so keep serving them as configured then
+	next.Server.Port = current.Server.Port
+	next.Server.MaxConcurrentRequests = current.Server.MaxConcurrentRequests
+	next.Log.Audit = current.Log.Audit
+	next.Server.MetadataCache = current.Server.MetadataCache
+	next.Server.S3API.PathPrefix = current.Server.S3API.PathPrefix
+	next.Storage = current.Storage
+	next.Storages = current.Storages
+	next.DefaultStorage = current.DefaultStorage
+
+	if err := next.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to reload config: %v", err)})
+		return
+	}
+
+	applied := runtimeChanges(current, next)
+	s.cfg.Store(next)
+	setLogLevel(next.Log.Level)
+
+	log.Printf("Reloaded config: applied %v, requires restart %v", applied, restart)
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Configuration reloaded",
+		"applied":          applied,
+		"requires_restart": restart,
+	})
+}
+
+// setLogLevel switches gin between debug and release mode
+func setLogLevel(level string) {
+	if level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+}
+
+// runtimeChanges lists the settings that differ between two configurations
+// and take effect without a restart. API keys are summarized rather than
+// listed so they don't end up in the log.
+func runtimeChanges(old, next *config.Config) []string {
+	changes := make([]string, 0)
+	changed := func(key string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, key)
+		}
+	}
+
+	changed("auth.enabled", old.Auth.Enabled, next.Auth.Enabled)
+	added, removed := 0, 0
+	for key := range next.Auth.APIKeys {
+		if _, ok := old.Auth.APIKeys[key]; !ok {
+			added++
+		}
+	}
+	for key := range old.Auth.APIKeys {
+		if _, ok := next.Auth.APIKeys[key]; !ok {
+			removed++
+		}
+	}
+	if added > 0 || removed > 0 {
+		changes = append(changes, fmt.Sprintf("auth.api_keys (%d added, %d removed)", added, removed))
+	}
+	changed("auth.mode", old.Auth.Mode, next.Auth.Mode)
+	changed("auth.hmac_keys", old.Auth.HMACKeys, next.Auth.HMACKeys)
+	changed("auth.hmac_max_skew", old.Auth.HMACMaxSkew, next.Auth.HMACMaxSkew)
+	changed("auth.admin_key", old.Auth.AdminKey, next.Auth.AdminKey)
+	changed("auth.key_prefixes", old.Auth.KeyPrefixes, next.Auth.KeyPrefixes)
+	changed("auth.download_rate_limits", old.Auth.DownloadRateLimits, next.Auth.DownloadRateLimits)
+	changed("auth.zip_limits", old.Auth.ZipLimits, next.Auth.ZipLimits)
+	changed("auth.s3_credentials", old.Auth.S3Credentials, next.Auth.S3Credentials)
+	changed("log.level", old.Log.Level, next.Log.Level)
+	changed("log.redact_query_params", old.Log.RedactQueryParams, next.Log.RedactQueryParams)
+	changed("server.read_only", old.Server.ReadOnly, next.Server.ReadOnly)
+	changed("server.ready_check_writes", old.Server.ReadyCheckWrites, next.Server.ReadyCheckWrites)
+	changed("server.detect_content_type", old.Server.DetectContentType, next.Server.DetectContentType)
+	changed("server.upload", old.Server.Upload, next.Server.Upload)
+	changed("server.antivirus", old.Server.Antivirus, next.Server.Antivirus)
+	changed("server.download", old.Server.Download, next.Server.Download)
+	changed("server.info_batch", old.Server.InfoBatch, next.Server.InfoBatch)
+	changed("server.resize", old.Server.Resize, next.Server.Resize)
+	changed("server.share", old.Server.Share, next.Server.Share)
+	changed("server.ingest", old.Server.Ingest, next.Server.Ingest)
+	changed("server.idempotency", old.Server.Idempotency, next.Server.Idempotency)
+	changed("server.checksum", old.Server.Checksum, next.Server.Checksum)
+	changed("server.cache_control", old.Server.CacheControl, next.Server.CacheControl)
+	changed("server.content_disposition", old.Server.ContentDisposition, next.Server.ContentDisposition)
+	changed("server.cors", old.Server.CORS, next.Server.CORS)
+	changed("server.static_site", old.Server.StaticSite, next.Server.StaticSite)
+	changed("server.download_rate_limit_bytes_per_sec", old.Server.DownloadRateLimit, next.Server.DownloadRateLimit)
+	changed("server.queue_timeout", old.Server.QueueTimeout, next.Server.QueueTimeout)
+	changed("server.webdav", old.Server.WebDAV, next.Server.WebDAV)
+	changed("server.ui", old.Server.UI, next.Server.UI)
+	changed("server.s3_api", old.Server.S3API, next.Server.S3API)
+	changed("buckets", old.Buckets, next.Buckets)
+
+	return changes
+}
+
+// restartOnlyChanges lists the settings that differ between two
+// configurations but can only take effect after a restart
+func restartOnlyChanges(old, next *config.Config) []string {
+	changes := make([]string, 0)
+	if old.Server.Port != next.Server.Port {
+		changes = append(changes, "server.port")
+	}
+	if old.Server.MaxConcurrentRequests != next.Server.MaxConcurrentRequests {
+		changes = append(changes, "server.max_concurrent_requests")
+	}
+	if old.Log.Audit != next.Log.Audit {
+		changes = append(changes, "log.audit")
+	}
+	if old.Server.MetadataCache != next.Server.MetadataCache {
+		changes = append(changes, "server.metadata_cache")
+	}
+	if old.Server.Dedup != next.Server.Dedup {
+		changes = append(changes, "server.dedup")
+	}
+	if old.Server.S3API.PathPrefix != next.Server.S3API.PathPrefix {
+		changes = append(changes, "server.s3_api.path_prefix")
+	}
+	if old.DefaultStorage != next.DefaultStorage {
+		changes = append(changes, "default_storage")
+	}
+	changes = append(changes, storageChanges("storage", old.Storage, next.Storage)...)
+
+	names := make(map[string]bool)
+	for name := range old.Storages {
+		names[name] = true
+	}
+	for name := range next.Storages {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		oldBackend, inOld := old.Storages[name]
+		nextBackend, inNext := next.Storages[name]
+		if inOld != inNext {
+			changes = append(changes, "storages."+name)
+			continue
+		}
+		changes = append(changes, storageChanges("storages."+name, oldBackend, nextBackend)...)
+	}
+
+	return changes
+}
+
+// storageChanges reports a changed backend type on its own, and any other
+// change to the backend as a change of the whole section
+func storageChanges(key string, old, next config.StorageConfig) []string {
+	if old.Type != next.Type {
+		return []string{key + ".type"}
+	}
+	if !reflect.DeepEqual(old, next) {
+		return []string{key}
+	}
+	return nil
+}
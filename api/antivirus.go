@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/config"
+	"github.com/example/file-service/storage"
+)
+
+// virusScan streams an upload to a ClamAV daemon with the INSTREAM command
+// while it is being stored. It is an io.Writer meant for io.TeeReader: a
+// failure to reach the daemon is recorded rather than returned, so it never
+// aborts the upload itself and is reported by Verdict instead.
+type virusScan struct {
+	conn    net.Conn
+	timeout time.Duration
+	err     error
+}
+
+// startVirusScan connects to the scanner when server.antivirus is enabled. It
+// returns nil without an error when scanning is disabled, or when the scanner
+// is unreachable and fail_open is set.
+func (s *Server) startVirusScan() (*virusScan, error) {
+	avCfg := s.config().Server.Antivirus
+	if !avCfg.Enabled {
+		return nil, nil
+	}
+
+	scan, err := dialClamd(avCfg)
+	if err != nil {
+		if avCfg.FailOpen {
+			log.Printf("Virus scanner unavailable, storing upload unscanned: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	return scan, nil
+}
+
+// dialClamd connects to clamd at a host:port or unix:/path address and starts an INSTREAM scan
+func dialClamd(avCfg config.AntivirusConfig) (*virusScan, error) {
+	network, address := "tcp", strings.TrimPrefix(avCfg.Address, "tcp://")
+	if path, ok := strings.CutPrefix(avCfg.Address, "unix:"); ok {
+		network, address = "unix", strings.TrimPrefix(path, "//")
+	}
+
+	conn, err := net.DialTimeout(network, address, avCfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to clamd: %w", err)
+	}
+	scan := &virusScan{conn: conn, timeout: avCfg.Timeout}
+	scan.send([]byte("zINSTREAM\x00"))
+	if scan.err != nil {
+		conn.Close()
+		return nil, scan.err
+	}
+	return scan, nil
+}
+
+// send writes to the daemon, remembering the first failure
+func (v *virusScan) send(data []byte) {
+	if v.err != nil {
+		return
+	}
+	if v.timeout > 0 {
+		v.conn.SetWriteDeadline(time.Now().Add(v.timeout))
+	}
+	if _, err := v.conn.Write(data); err != nil {
+		v.err = fmt.Errorf("send to clamd: %w", err)
+	}
+}
+
+// Write sends p to the daemon as one INSTREAM chunk
+func (v *virusScan) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(p)))
+		v.send(size[:])
+		v.send(p)
+	}
+	return len(p), nil
+}
+
+// Verdict ends the stream and waits for the daemon's answer. It returns the
+// name of the detected signature, or "" when the data is clean; the error
+// reports that no verdict could be reached.
+func (v *virusScan) Verdict() (string, error) {
+	v.send([]byte{0, 0, 0, 0})
+	if v.err != nil {
+		return "", v.err
+	}
+	if v.timeout > 0 {
+		v.conn.SetReadDeadline(time.Now().Add(v.timeout))
+	}
+
+	reply, err := bufio.NewReader(v.conn).ReadBytes(0)
+	if err != nil {
+		return "", fmt.Errorf("read clamd reply: %w", err)
+	}
+	// Replies look like "stream: OK", "stream: <signature> FOUND" or "<reason> ERROR"
+	result := strings.TrimPrefix(string(bytes.TrimRight(reply, "\x00")), "stream: ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	}
+	return "", errors.New("clamd: " + result)
+}
+
+// Close releases the connection to the daemon
+func (v *virusScan) Close() error {
+	return v.conn.Close()
+}
+
+// rejectScannedUpload waits for the verdict on a stored upload. When the
+// scanner flags it, or gives no verdict and fail_open is off, the object is
+// deleted again and the error response sent, and it returns true.
+func (s *Server) rejectScannedUpload(c *gin.Context, ctx context.Context, scan *virusScan, store storage.Storage, bucket, object string, result *storage.UploadResult) bool {
+	signature, err := s.scanVerdict(ctx, scan, store, bucket, object, result)
+	return scanRejected(c, signature, err)
+}
+
+// rejectScannedObject scans an object assembled from parts and, like
+// rejectScannedUpload, sends the error response and returns true when it is
+// rejected
+func (s *Server) rejectScannedObject(c *gin.Context, ctx context.Context, store storage.Storage, bucket, object string) bool {
+	signature, err := s.scanStoredObject(ctx, store, bucket, object)
+	return scanRejected(c, signature, err)
+}
+
+// scanRejected sends the error response for an upload rejected with the
+// signature found or the scan error, and reports whether it was rejected
+func scanRejected(c *gin.Context, signature string, err error) bool {
+	switch {
+	case signature != "":
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "File rejected by virus scan", "signature": signature})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("Virus scan failed: %v", err)})
+	default:
+		return false
+	}
+	return true
+}
+
+// scanStoredObject scans an object assembled from parts, which arrive in
+// separate requests and can only be scanned once complete, by reading it back
+// from the backend. Like scanVerdict it deletes the object again when the
+// scanner flags it, or can't be reached or gives no verdict and fail_open is
+// off, and returns the signature found or the scan error.
+func (s *Server) scanStoredObject(ctx context.Context, store storage.Storage, bucket, object string) (string, error) {
+	scan, err := s.startVirusScan()
+	if scan == nil && err == nil {
+		return "", nil
+	}
+
+	result := &storage.UploadResult{}
+	if info, err := store.GetObjectInfo(ctx, bucket, object); err == nil {
+		result.ETag, result.VersionID = info.ETag, info.VersionID
+	}
+	if err != nil {
+		if err := discardUpload(ctx, store, bucket, object, result); err != nil {
+			log.Printf("Failed to delete unscanned upload %s/%s: %v", bucket, object, err)
+		}
+		return "", err
+	}
+	defer scan.Close()
+
+	reader, err := store.Download(ctx, bucket, object)
+	if err == nil {
+		_, err = io.Copy(scan, reader)
+		reader.Close()
+	}
+	if err != nil && scan.err == nil {
+		scan.err = fmt.Errorf("read upload for scanning: %w", err)
+	}
+	return s.scanVerdict(ctx, scan, store, bucket, object, result)
+}
+
+// scanVerdict waits for the verdict on a stored upload, deleting the object
+// again when the scanner flags it or gives no verdict and fail_open is off.
+// It returns the signature found, or the scan error, for the rejected upload.
+func (s *Server) scanVerdict(ctx context.Context, scan *virusScan, store storage.Storage, bucket, object string, result *storage.UploadResult) (string, error) {
+	signature, err := scan.Verdict()
+	if err != nil && s.config().Server.Antivirus.FailOpen {
+		log.Printf("Virus scan of %s/%s failed, keeping it unscanned: %v", bucket, object, err)
+		return "", nil
+	}
+	if signature == "" && err == nil {
+		return "", nil
+	}
+
+	if err := discardUpload(ctx, store, bucket, object, result); err != nil {
+		log.Printf("Failed to delete rejected upload %s/%s: %v", bucket, object, err)
+	}
+	return signature, err
+}
+
+// discardUpload removes an object stored by an upload that was then rejected,
+// deleting the exact version where the backend keeps versions
+func discardUpload(ctx context.Context, store storage.Storage, bucket, object string, result *storage.UploadResult) error {
+	if versioned, ok := store.(storage.VersionedStorage); ok && result.VersionID != "" {
+		return versioned.DeleteVersion(ctx, bucket, object, result.VersionID)
+	}
+	return store.Delete(ctx, bucket, object)
+}
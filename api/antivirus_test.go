@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// eicar marks test uploads the fake clamd flags
+const eicar = "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"
+
+// fakeClamd answers INSTREAM scans on a local port, flagging streams that
+// contain the EICAR test string, and returns its address
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil {
+					return
+				}
+				var stream bytes.Buffer
+				for {
+					var size [4]byte
+					if _, err := io.ReadFull(conn, size[:]); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size[:])
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&stream, conn, int64(n)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(stream.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// antivirusConfig enables scanning with the scanner at address, along with
+// WebDAV and the S3 API
+func antivirusConfig(address string, failOpen bool) string {
+	return "server:\n  antivirus:\n    enabled: true\n    address: " + address + "\n    timeout: 5s\n" +
+		"    fail_open: " + strconv.FormatBool(failOpen) + "\n" +
+		"  webdav:\n    enabled: true\n  s3_api:\n    enabled: true\n"
+}
+
+// unusedAddress returns a local address nothing listens on
+func unusedAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// uploadPaths store content at key through each upload endpoint and return
+// the final response
+var uploadPaths = map[string]func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder{
+	"POST /upload": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		return serve(server, http.MethodPost, "/upload/default/"+key, strings.NewReader(content), nil)
+	},
+	"WebDAV PUT": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		return serve(server, http.MethodPut, "/webdav/default/"+key, strings.NewReader(content), nil)
+	},
+	"S3 PutObject": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		return serve(server, http.MethodPut, "/s3/default/"+key, strings.NewReader(content), nil)
+	},
+	"PATCH /upload": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		contentRange := fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content))
+		return serve(server, http.MethodPatch, "/upload/default/"+key, strings.NewReader(content), map[string]string{"Content-Range": contentRange})
+	},
+	"resumable upload": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		rec := serve(server, http.MethodPost, "/uploads/default/"+key, nil, nil)
+		var started struct {
+			UploadID string `json:"upload_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil || started.UploadID == "" {
+			t.Fatalf("starting upload = %d %s", rec.Code, rec.Body)
+		}
+		query := "?uploadId=" + started.UploadID
+		if rec := serve(server, http.MethodPatch, "/uploads/default/"+key+query+"&partNumber=1", strings.NewReader(content), nil); rec.Code != http.StatusOK {
+			t.Fatalf("uploading part = %d %s", rec.Code, rec.Body)
+		}
+		return serve(server, http.MethodPost, "/uploads/default/"+key+query+"&complete=true", nil, nil)
+	},
+	"S3 multipart upload": func(t *testing.T, server *Server, key, content string) *httptest.ResponseRecorder {
+		rec := serve(server, http.MethodPost, "/s3/default/"+key+"?uploads", nil, nil)
+		var started s3InitiateMultipartUploadResult
+		if err := xml.Unmarshal(rec.Body.Bytes(), &started); err != nil || started.UploadID == "" {
+			t.Fatalf("CreateMultipartUpload = %d %s", rec.Code, rec.Body)
+		}
+		query := "?uploadId=" + started.UploadID
+		if rec := serve(server, http.MethodPut, "/s3/default/"+key+query+"&partNumber=1", strings.NewReader(content), nil); rec.Code != http.StatusOK {
+			t.Fatalf("UploadPart = %d %s", rec.Code, rec.Body)
+		}
+		return serve(server, http.MethodPost, "/s3/default/"+key+query, strings.NewReader("<CompleteMultipartUpload/>"), nil)
+	},
+}
+
+func TestVirusScanEveryUploadPath(t *testing.T) {
+	for name, upload := range uploadPaths {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, antivirusConfig(fakeClamd(t), false))
+
+			if rec := upload(t, server, "clean.txt", "harmless"); rec.Code >= 300 {
+				t.Fatalf("clean upload = %d %s", rec.Code, rec.Body)
+			}
+			if got := readObject(t, server, "clean.txt"); got != "harmless" {
+				t.Errorf("clean upload stored %q", got)
+			}
+
+			rec := upload(t, server, "infected.txt", eicar)
+			if rec.Code != http.StatusUnprocessableEntity && rec.Code != http.StatusForbidden {
+				t.Errorf("infected upload = %d %s, want it rejected", rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), "Eicar-Test-Signature") {
+				t.Errorf("rejection %s doesn't name the signature", rec.Body)
+			}
+			if objectExists(t, server, "infected.txt") {
+				t.Error("infected upload was kept")
+			}
+		})
+	}
+}
+
+func TestVirusScannerUnavailable(t *testing.T) {
+	address := unusedAddress(t)
+	for name, upload := range uploadPaths {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, antivirusConfig(address, false))
+			rec := upload(t, server, "unscanned.txt", "harmless")
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("upload = %d %s, want 503", rec.Code, rec.Body)
+			}
+			if objectExists(t, server, "unscanned.txt") {
+				t.Error("unscanned upload was kept")
+			}
+		})
+	}
+}
+
+func TestVirusScannerUnavailableFailOpen(t *testing.T) {
+	address := unusedAddress(t)
+	for name, upload := range uploadPaths {
+		t.Run(name, func(t *testing.T) {
+			server := newTestServer(t, antivirusConfig(address, true))
+			if rec := upload(t, server, "unscanned.txt", "harmless"); rec.Code >= 300 {
+				t.Fatalf("upload = %d %s, want it stored unscanned", rec.Code, rec.Body)
+			}
+			if got := readObject(t, server, "unscanned.txt"); got != "harmless" {
+				t.Errorf("stored %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,616 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/config"
+	"github.com/example/file-service/storage"
+)
+
+// clientWriter records the first error writing to the client, so write
+// failures can be told apart from backend read failures during a copy
+type clientWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write writes to the client, remembering any failure
+func (cw *clientWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	if err != nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+// archiveFormat describes an archive format clients can request
+type archiveFormat struct {
+	contentType string
+	extension   string
+}
+
+// archiveFormats maps the supported format names to their response headers
+var archiveFormats = map[string]archiveFormat{
+	"zip":   {contentType: "application/zip", extension: ".zip"},
+	"tar":   {contentType: "application/x-tar", extension: ".tar"},
+	"targz": {contentType: "application/gzip", extension: ".tar.gz"},
+}
+
+// archiveWriter writes a streamed archive one entry at a time. zip.Writer and
+// tar.Writer are not safe for concurrent use, so entries are written in order
+// by a single goroutine.
+type archiveWriter interface {
+	// WriteEntry adds one object to the archive under name
+	WriteEntry(name string, obj storage.FileObject, reader io.Reader) error
+
+	// Flush pushes the finished entries to the underlying writer
+	Flush() error
+
+	// Close finishes the archive
+	Close() error
+}
+
+// newArchiveWriter creates a writer for the named format; method selects the
+// ZIP compression method and is ignored by tar
+func newArchiveWriter(format string, w io.Writer, method uint16) archiveWriter {
+	switch format {
+	case "tar":
+		return &tarArchive{w: tar.NewWriter(w)}
+	case "targz":
+		gz := gzip.NewWriter(w)
+		return &tarArchive{w: tar.NewWriter(gz), gz: gz}
+	}
+	return &zipArchive{w: zip.NewWriter(w), method: method}
+}
+
+// zipArchive writes entries to a ZIP archive
+type zipArchive struct {
+	w      *zip.Writer
+	method uint16
+}
+
+// WriteEntry copies one object into the ZIP archive
+func (a *zipArchive) WriteEntry(name string, obj storage.FileObject, reader io.Reader) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: a.method,
+	}
+	if !obj.LastModified.IsZero() {
+		header.Modified = obj.LastModified
+	}
+
+	// Create file header in ZIP
+	entry, err := a.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	// Copy file content to ZIP
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// Flush flushes the ZIP writer
+func (a *zipArchive) Flush() error {
+	return a.w.Flush()
+}
+
+// Close writes the ZIP central directory
+func (a *zipArchive) Close() error {
+	return a.w.Close()
+}
+
+// tarArchive writes entries to a tar archive, optionally gzip-compressed
+type tarArchive struct {
+	w  *tar.Writer
+	gz *gzip.Writer // nil for an uncompressed tar
+}
+
+// WriteEntry copies one object into the tar archive. The header needs the
+// size up front, so an entry whose download fails part way is padded with
+// zeros to keep the rest of the archive readable; the error is still returned.
+func (a *tarArchive) WriteEntry(name string, obj storage.FileObject, reader io.Reader) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     obj.Size,
+		ModTime:  time.Now(),
+	}
+	if !obj.LastModified.IsZero() {
+		header.ModTime = obj.LastModified
+	}
+
+	if err := a.w.WriteHeader(header); err != nil {
+		return err
+	}
+	n, err := io.Copy(a.w, reader)
+	if err != nil && n < obj.Size {
+		io.CopyN(a.w, zeroReader{}, obj.Size-n)
+	}
+	return err
+}
+
+// zeroReader reads an endless stream of zero bytes
+type zeroReader struct{}
+
+// Read fills p with zeros
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Flush pads the last entry and flushes the tar writer, then pushes the
+// compressed data buffered so far out of the gzip writer
+func (a *tarArchive) Flush() error {
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Flush()
+	}
+	return nil
+}
+
+// Close writes the tar trailer, then the gzip footer; the tar writer must be
+// closed first so its trailer ends up inside the compressed stream
+func (a *tarArchive) Close() error {
+	if err := a.w.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+// archiveEntry is one object to be written to an archive
+type archiveEntry struct {
+	name string // Name inside the archive
+	obj  storage.FileObject
+
+	// Set for objects that weren't taken from a listing, whose size and
+	// modification time are looked up before they are downloaded
+	stat bool
+}
+
+// archiveErrorsName is the trailing entry listing the objects that could not
+// be added to an archive of explicitly requested objects
+const archiveErrorsName = "archive-errors.json"
+
+// archiveStatusTrailer is the trailer that carries the bulk status of an
+// archive of explicitly requested objects, which is only known once streamed
+const archiveStatusTrailer = "X-Archive-Status"
+
+// zipLimitsContextKey is the gin context key holding the archive limits of
+// the request's API key, see AuthConfig.ZipLimits
+const zipLimitsContextKey = "zip_limits"
+
+// zipLimits returns the archive limits that apply to the request: those of
+// its API key, or server.download's
+func (s *Server) zipLimits(c *gin.Context) config.ZipLimits {
+	if limits, ok := c.Get(zipLimitsContextKey); ok {
+		return limits.(config.ZipLimits)
+	}
+	return s.config().Server.Download.ZipLimits
+}
+
+// zipLimitExceeded reports why entries can't be archived under limits, or
+// "" when they can. The sizes come from the listing, so nothing is read.
+func zipLimitExceeded(entries []archiveEntry, limits config.ZipLimits) string {
+	if limits.MaxObjects > 0 && len(entries) > limits.MaxObjects {
+		return fmt.Sprintf("The directory holds %d objects, more than the %d a download may archive", len(entries), limits.MaxObjects)
+	}
+	if limits.MaxBytes > 0 {
+		var total int64
+		for _, entry := range entries {
+			total += entry.obj.Size
+		}
+		if total > limits.MaxBytes {
+			return fmt.Sprintf("The directory holds %d bytes, more than the %d a download may archive", total, limits.MaxBytes)
+		}
+	}
+	return ""
+}
+
+// archiveDigest returns a digest of everything a directory archive is made
+// from: the listed objects with their sizes, ETags and modification times,
+// and the format and compression. Archives of the same listing hold the
+// same content, so it identifies the archive without building it.
+func archiveDigest(prefix, formatName string, method uint16, entries []archiveEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%d\n", prefix, formatName, method)
+	for _, entry := range entries {
+		fmt.Fprintf(h, "%s\n%d\n%s\n%s\n", entry.obj.Name, entry.obj.Size, entry.obj.ETag, entry.obj.LastModifiedString())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// downloadDirectory streams every object under the prefix to the client as a
+// ZIP archive, or as a tar or tar.gz archive with ?format=, or builds it in
+// the background with ?async=true. A prefix holding a
+// single file streams that file directly unless ?force_zip=true is set. Entries are written and flushed one at a time so memory use
+// doesn't grow with the directory size, and the stream is abandoned without
+// finishing the archive once the client goes away.
+func (s *Server) downloadDirectory(c *gin.Context, store storage.Storage, bucket, object string) {
+	// Ensure object (prefix) ends with "/" to denote a directory
+	prefix := object
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	formatName := c.DefaultQuery("format", "zip")
+	format, ok := archiveFormats[formatName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected zip, tar or targz"})
+		return
+	}
+	method, ok := compressionMethod(c, c.Query("compression"))
+	if !ok {
+		return
+	}
+
+	// List objects with the given prefix
+	listCtx, cancel := s.operationContext(c)
+	objects, err := store.List(listCtx, bucket, prefix)
+	cancel()
+	if err != nil {
+		c.JSON(storageErrorStatus(listCtx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		return
+	}
+
+	// Skip directories, and archives built for earlier ?async=true downloads
+	asyncPrefix := s.config().Server.Download.AsyncPrefix
+	entries := make([]archiveEntry, 0, len(objects))
+	for _, obj := range objects {
+		if !obj.IsDir && !strings.HasSuffix(obj.Name, "/") && (asyncPrefix == "" || !strings.HasPrefix(obj.Name, asyncPrefix)) {
+			entries = append(entries, archiveEntry{
+				name: obj.Name[len(prefix):], // Remove prefix from file name in the archive
+				obj:  obj,
+			})
+		}
+	}
+
+	// An archive of nothing is more likely a wrong prefix than a wanted result,
+	// and a single file is served as itself unless an archive is forced
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No objects found under %s", prefix)})
+		return
+	}
+	if len(entries) == 1 && c.Query("force_zip") != "true" {
+		s.streamObject(c, store, bucket, entries[0].obj.Name, http.StatusOK)
+		return
+	}
+
+	// Refuse archives over the limits before anything is streamed
+	if reason := zipLimitExceeded(entries, s.zipLimits(c)); reason != "" {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": reason})
+		return
+	}
+
+	// Build the archive into the bucket, where it can be downloaded in ranges
+	if c.Query("async") == "true" {
+		s.downloadDirectoryAsync(c, store, bucket, prefix, formatName, method, entries)
+		return
+	}
+
+	// The listing is cheap and the archive isn't, so clients that already
+	// have an archive of the same listing are answered before anything is
+	// read. The validator is weak: an object that changes without changing
+	// its size, ETag or modification time isn't noticed.
+	etag := `W/"` + archiveDigest(prefix, formatName, method, entries) + `"`
+	c.Header("ETag", etag)
+	if etagListed(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	// Set response headers for the archive download
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", path.Base(strings.TrimSuffix(prefix, "/")), format.extension))
+
+	client := &clientWriter{w: s.downloadWriter(c)}
+	archive := newArchiveWriter(formatName, client, method)
+	label := bucket + "/" + prefix
+	if _, ok := s.streamArchive(c, store, bucket, entries, archive, client, label); !ok {
+		return
+	}
+
+	// Only finish the archive when everything was written; closing it on a
+	// dead connection would just fail again
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish archive download of %s: %v", label, err)
+	}
+}
+
+// archiveRequest is the body of POST /archive/:bucket
+type archiveRequest struct {
+	Objects []string `json:"objects"`
+	Format  string   `json:"format"`
+}
+
+// downloadArchive handles requests to archive an explicit list of objects.
+// Entries keep the object paths the client asked for, and objects that can't
+// be added are listed in a trailing archive-errors.json entry, since the
+// response status has already been sent by then; the status bulk operations
+// would answer is sent in the X-Archive-Status trailer instead.
+func (s *Server) downloadArchive(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	var req archiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid archive request: %v", err)})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "zip"
+	}
+	format, ok := archiveFormats[req.Format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected zip, tar or targz"})
+		return
+	}
+	method, ok := compressionMethod(c, c.Query("compression"))
+	if !ok {
+		return
+	}
+	if len(req.Objects) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No objects to archive"})
+		return
+	}
+
+	// Validate every path before anything is streamed, skipping repeats
+	entries := make([]archiveEntry, 0, len(req.Objects))
+	seen := make(map[string]bool)
+	for _, value := range req.Objects {
+		key, ok := objectKeyFrom(c, value)
+		if !ok {
+			return
+		}
+		if key == "" || strings.HasSuffix(key, "/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid object path %q: directories can't be archived by name", value)})
+			return
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, archiveEntry{name: key, obj: storage.FileObject{Name: key}, stat: true})
+	}
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", bucket, format.extension))
+	c.Header("Trailer", archiveStatusTrailer)
+
+	client := &clientWriter{w: s.downloadWriter(c)}
+	archive := newArchiveWriter(req.Format, client, method)
+	failures, ok := s.streamArchive(c, store, bucket, entries, archive, client, bucket)
+	if !ok {
+		return
+	}
+
+	if len(failures) > 0 {
+		manifest, _ := json.MarshalIndent(gin.H{"failed": failures}, "", "  ")
+		obj := storage.FileObject{Size: int64(len(manifest))}
+		if err := archive.WriteEntry(archiveErrorsName, obj, bytes.NewReader(manifest)); err != nil {
+			log.Printf("Aborting archive download of %s: %v", bucket, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish archive download of %s: %v", bucket, err)
+		return
+	}
+
+	// Every entry was either written or failed, so only the failures count
+	statuses := make([]int, len(entries))
+	for i, failure := range failures {
+		statuses[i] = failure.Status
+	}
+	c.Writer.Header().Set(archiveStatusTrailer, strconv.Itoa(bulkStatus(statuses)))
+}
+
+// compressionMethod parses the ZIP compression query parameter, answering
+// 400 Bad Request and returning false if it is invalid. Many media files
+// don't shrink under deflate, so storing them as-is is allowed.
+func compressionMethod(c *gin.Context, value string) (uint16, bool) {
+	switch value {
+	case "", "deflate":
+		return zip.Deflate, true
+	case "store":
+		return zip.Store, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid compression, expected store or deflate"})
+	return 0, false
+}
+
+// streamArchive writes the entries to the archive in order, flushing each one
+// to the client before the next. Objects that can't be fetched are skipped
+// and returned as failures. It returns false when the stream was abandoned
+// because the client went away, in which case the archive must not be closed.
+func (s *Server) streamArchive(c *gin.Context, store storage.Storage, bucket string, entries []archiveEntry, archive archiveWriter, client *clientWriter, label string) ([]bulkResult, bool) {
+	idleTimeout := s.downloadIdleTimeout(c.GetString(backendContextKey))
+	return s.writeArchive(c.Request.Context(), store, bucket, entries, idleTimeout, archive, client, c.Writer.Flush, label)
+}
+
+// writeArchive is streamArchive for any writer: flush pushes each finished
+// entry on, and ctx ending or client failing abandons the archive
+func (s *Server) writeArchive(ctx context.Context, store storage.Storage, bucket string, entries []archiveEntry, idleTimeout time.Duration, archive archiveWriter, client *clientWriter, flush func(), label string) ([]bulkResult, bool) {
+	// Stop fetching as soon as the archive is abandoned
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fetches := s.fetchArchiveEntries(ctx, store, bucket, entries, idleTimeout)
+	defer func() {
+		// Release entries fetched ahead that were never written
+		for _, fetch := range fetches {
+			if fetch == nil {
+				continue
+			}
+			go func(fetch <-chan archiveFetch) {
+				if f, ok := <-fetch; ok && f.err == nil {
+					f.reader.Close()
+				}
+			}(fetch)
+		}
+	}()
+
+	var failures []bulkResult
+	for i, entry := range entries {
+		var fetch archiveFetch
+		select {
+		case fetch = <-fetches[i]:
+			fetches[i] = nil
+		case <-ctx.Done():
+			log.Printf("Aborting archive download of %s: %v", label, ctx.Err())
+			return nil, false
+		}
+
+		err := fetch.err
+		if err == nil {
+			err = archive.WriteEntry(entry.name, fetch.obj, fetch.reader)
+			fetch.reader.Close()
+		}
+		if err != nil {
+			if client.err != nil || ctx.Err() != nil {
+				log.Printf("Aborting archive download of %s: %v", label, err)
+				return nil, false
+			}
+			// Log error and continue with other files
+			log.Printf("Skipping %s/%s in archive download: %v", bucket, entry.obj.Name, err)
+			failures = append(failures, bulkFailure(ctx, entry.obj.Name, err))
+			continue
+		}
+
+		// Push the finished entry to the client before writing the next one
+		if err := archive.Flush(); err != nil {
+			log.Printf("Aborting archive download of %s: %v", label, err)
+			return nil, false
+		}
+		flush()
+	}
+
+	return failures, true
+}
+
+// archivePrefetchLimit is the largest object read fully into memory by a
+// fetch worker; bigger objects are handed to the writer as an open stream
+const archivePrefetchLimit = 4 << 20
+
+// archiveFetch is the result of fetching one archive entry from the backend
+type archiveFetch struct {
+	obj    storage.FileObject
+	reader io.ReadCloser
+	err    error
+}
+
+// fetchArchiveEntries downloads the objects with a bounded pool of workers and
+// returns one channel per entry, in the same order, each delivering exactly
+// one result. Because the archive writers are not safe for concurrent use,
+// the caller writes entries itself by reading the channels in order. The
+// channels are unbuffered and a worker keeps its slot until its result has
+// been taken, which bounds how much is fetched ahead of the writer.
+func (s *Server) fetchArchiveEntries(ctx context.Context, store storage.Storage, bucket string, entries []archiveEntry, idleTimeout time.Duration) []chan archiveFetch {
+	fetches := make([]chan archiveFetch, len(entries))
+	for i := range fetches {
+		fetches[i] = make(chan archiveFetch)
+	}
+
+	slots := make(chan struct{}, s.config().Server.Download.ZipConcurrency)
+	go func() {
+		for i, entry := range entries {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				// Fail the remaining entries so nobody waits on them
+				for _, fetch := range fetches[i:] {
+					fetch <- archiveFetch{err: ctx.Err()}
+				}
+				return
+			}
+
+			go func(fetch chan<- archiveFetch, entry archiveEntry) {
+				fetch <- s.fetchArchiveEntry(ctx, store, bucket, entry, idleTimeout)
+				<-slots
+			}(fetches[i], entry)
+		}
+	}()
+
+	return fetches
+}
+
+// fetchArchiveEntry opens one object for the archive, buffering small objects
+// so their transfer overlaps with writing earlier entries
+func (s *Server) fetchArchiveEntry(ctx context.Context, store storage.Storage, bucket string, entry archiveEntry, idleTimeout time.Duration) archiveFetch {
+	obj := entry.obj
+	if entry.stat {
+		info, err := store.GetObjectInfo(ctx, bucket, obj.Name)
+		if err != nil {
+			return archiveFetch{err: err}
+		}
+		obj = *info
+		obj.Name = entry.obj.Name
+	}
+
+	// Download object, guarded by the idle timeout rather than the operation timeout
+	ctx, cancel := context.WithCancel(ctx)
+
+	reader, err := store.Download(ctx, bucket, obj.Name)
+	if err != nil {
+		cancel()
+		return archiveFetch{err: err}
+	}
+	reader = &cancelReadCloser{
+		ReadCloser: newIdleTimeoutReader(reader, idleTimeout, cancel),
+		cancel:     cancel,
+	}
+
+	if obj.Size > archivePrefetchLimit {
+		return archiveFetch{obj: obj, reader: reader}
+	}
+
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return archiveFetch{err: err}
+	}
+	return archiveFetch{obj: obj, reader: io.NopCloser(bytes.NewReader(data))}
+}
+
+// cancelReadCloser releases the download context when the reader is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the reader and cancels its context
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// zipContents returns the content of each file in a ZIP archive by name
+func zipContents(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("reading archive: %v", err)
+	}
+	contents := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[file.Name] = string(content)
+	}
+	return contents
+}
+
+func TestDirectoryArchiveNotModified(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "photos/a.jpg", "first photo")
+	putObject(t, server, "photos/b.jpg", "second photo")
+
+	rec := serve(server, http.MethodGet, "/download/default/photos?directory=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("directory download = %d %s", rec.Code, rec.Body)
+	}
+	if got := zipContents(t, rec.Body.Bytes()); len(got) != 2 || got["a.jpg"] != "first photo" {
+		t.Errorf("archive holds %v", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag = %q, want a weak validator", etag)
+	}
+
+	// The same listing is answered before anything is read
+	rec = serve(server, http.MethodGet, "/download/default/photos?directory=true", nil, map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("unchanged directory = %d with %d bytes, want 304 without a body", rec.Code, rec.Body.Len())
+	}
+	if rec.Header().Get("ETag") != etag {
+		t.Errorf("304 ETag = %q, want %q", rec.Header().Get("ETag"), etag)
+	}
+
+	// Another format is another archive
+	rec = serve(server, http.MethodGet, "/download/default/photos?directory=true&format=tar", nil, map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusOK {
+		t.Errorf("tar archive with the ZIP archive's ETag = %d, want 200", rec.Code)
+	}
+
+	putObject(t, server, "photos/b.jpg", "edited photo")
+	rec = serve(server, http.MethodGet, "/download/default/photos?directory=true", nil, map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("changed directory = %d, want 200", rec.Code)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("changed directory kept its ETag")
+	}
+	if got := zipContents(t, rec.Body.Bytes()); got["b.jpg"] != "edited photo" {
+		t.Errorf("archive of the changed directory holds %v", got)
+	}
+}
+
+func TestDirectoryArchiveLimits(t *testing.T) {
+	server := newTestServer(t, "server:\n  download:\n    max_zip_objects: 2\n    max_zip_bytes: 10\n"+
+		"auth:\n  enabled: true\n  api_keys:\n    small: team\n    large: batch\n  zip_limits:\n    large:\n      max_zip_objects: 10\n      max_zip_bytes: 1000\n")
+	for key, content := range map[string]string{
+		"few/a.txt": "aaaa", "few/b.txt": "bbbb",
+		"many/a.txt": "a", "many/b.txt": "b", "many/c.txt": "c",
+		"big/a.txt": "aaaaaa", "big/b.txt": "bbbbbb",
+	} {
+		putObject(t, server, key, content)
+	}
+
+	tests := []struct {
+		key  string
+		dir  string
+		want int
+	}{
+		{"small", "few", http.StatusOK},
+		{"small", "many", http.StatusRequestEntityTooLarge},
+		{"small", "big", http.StatusRequestEntityTooLarge},
+		{"large", "many", http.StatusOK},
+		{"large", "big", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := serve(server, http.MethodGet, "/download/default/"+tt.dir+"?directory=true", nil, map[string]string{"X-API-Key": tt.key})
+		if rec.Code != tt.want {
+			t.Errorf("%s archive with key %s = %d %s, want %d", tt.dir, tt.key, rec.Code, rec.Body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// asyncArchiveRetryAfter is the polling interval suggested while an archive builds
+const asyncArchiveRetryAfter = "5"
+
+// errArchiveAbandoned fails the upload of an archive whose build stopped
+// before every entry was written, so no truncated archive is stored
+var errArchiveAbandoned = errors.New("archive build was abandoned")
+
+// asyncArchiveBuild is an archive being built for ?async=true downloads
+type asyncArchiveBuild struct {
+	done chan struct{} // closed once the build has finished
+	err  error         // why the build failed, set before done is closed
+}
+
+// asyncArchiveKey returns the object an archive of entries is stored under.
+// It is named after a digest of everything the archive holds, so a directory
+// that changed gets a new archive and resumed downloads never mix two.
+func asyncArchiveKey(asyncPrefix, prefix, formatName string, method uint16, entries []archiveEntry) string {
+	name := path.Base(strings.TrimSuffix(prefix, "/"))
+	if prefix == "" {
+		name = "archive"
+	}
+	return asyncPrefix + archiveDigest(prefix, formatName, method, entries) + "/" + name + archiveFormats[formatName].extension
+}
+
+// downloadDirectoryAsync serves a directory archive built into the bucket
+// instead of streamed, so it can be downloaded in ranges and resumed. The
+// first request starts the build and answers 202 Accepted; once it has
+// finished, the same URL serves the stored archive like any other object
+// until server.download.async_ttl has passed.
+func (s *Server) downloadDirectoryAsync(c *gin.Context, store storage.Storage, bucket, prefix, formatName string, method uint16, entries []archiveEntry) {
+	download := s.config().Server.Download
+	key := asyncArchiveKey(download.AsyncPrefix, prefix, formatName, method, entries)
+
+	ctx, cancel := s.operationContext(c)
+	info, err := store.GetObjectInfo(ctx, bucket, key)
+	cancel()
+	if err == nil && (info.LastModified.IsZero() || time.Since(info.LastModified) < download.AsyncTTL) {
+		s.streamObject(c, store, bucket, key, http.StatusOK)
+		return
+	}
+	if err != nil && !storage.IsNotFound(err) {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get archive info: %v", err)})
+		return
+	}
+
+	// Archives are shared by every request for the same listing through the
+	// same backend and key prefix, and only one of them builds it
+	buildKey := strings.Join([]string{c.GetString(backendContextKey), storedKeyPrefix(c), bucket, key}, "\x00")
+	if existing, ok := s.archiveBuilds.Load(buildKey); ok {
+		build := existing.(*asyncArchiveBuild)
+		select {
+		case <-build.done:
+			// A failed build is reported once; the next request starts another
+			s.archiveBuilds.CompareAndDelete(buildKey, build)
+			if build.err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to build archive: %v", build.err)})
+				return
+			}
+			s.streamObject(c, store, bucket, key, http.StatusOK)
+		default:
+			archiveAccepted(c, key)
+		}
+		return
+	}
+
+	// Read-only mode serves archives that were already built but stores no new ones
+	if s.config().Server.ReadOnly {
+		c.JSON(http.StatusForbidden, gin.H{"error": "read-only mode"})
+		return
+	}
+
+	build := &asyncArchiveBuild{done: make(chan struct{})}
+	if _, loaded := s.archiveBuilds.LoadOrStore(buildKey, build); !loaded {
+		label := bucket + "/" + prefix
+		backend := c.GetString(backendContextKey)
+		go func() {
+			defer close(build.done)
+			if build.err = s.buildArchive(store, bucket, key, formatName, method, entries, s.downloadIdleTimeout(backend), label); build.err != nil {
+				log.Printf("Failed to build archive of %s: %v", label, build.err)
+				return
+			}
+			s.archiveBuilds.Delete(buildKey)
+			s.expireArchives(store, bucket, download.AsyncPrefix, download.AsyncTTL, s.operationTimeout(backend))
+		}()
+	}
+	archiveAccepted(c, key)
+}
+
+// archiveAccepted answers 202 Accepted for an archive that isn't built yet,
+// pointing the client back at the URL it requested, which serves the archive
+// once it is
+func archiveAccepted(c *gin.Context, key string) {
+	c.Header("Retry-After", asyncArchiveRetryAfter)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Archive is being built; request the status URL again to download it",
+		"status":     "building",
+		"archive":    key,
+		"status_url": c.Request.URL.RequestURI(),
+	})
+}
+
+// buildArchive writes an archive of entries to the object key, uploading it
+// as it is written. It runs after the request that started it has been
+// answered, so it isn't bound to that request's context or timeout.
+func (s *Server) buildArchive(store storage.Storage, bucket, key, formatName string, method uint16, entries []archiveEntry, idleTimeout time.Duration, label string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	go func() {
+		client := &clientWriter{w: writer}
+		archive := newArchiveWriter(formatName, client, method)
+		if _, ok := s.writeArchive(ctx, store, bucket, entries, idleTimeout, archive, client, func() {}, label); !ok {
+			writer.CloseWithError(errArchiveAbandoned)
+			return
+		}
+		writer.CloseWithError(archive.Close())
+	}()
+
+	_, err := store.Upload(ctx, bucket, key, reader, -1, archiveFormats[formatName].contentType, storage.ObjectHeaders{})
+	// Stop the writer when the upload gave up before reading everything
+	reader.CloseWithError(err)
+	return err
+}
+
+// expireArchives deletes the archives under asyncPrefix older than ttl,
+// bounded by the backend's operation timeout. It runs after each build, so
+// archives are cleaned up as long as the bucket keeps being archived.
+func (s *Server) expireArchives(store storage.Storage, bucket, asyncPrefix string, ttl, timeout time.Duration) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// An archive's modification time is when its build finished
+	cutoff := time.Now().Add(-ttl)
+	var expired []storage.FileObject
+	err := store.Walk(ctx, bucket, asyncPrefix, func(obj storage.FileObject) error {
+		if !obj.LastModified.IsZero() && obj.LastModified.Before(cutoff) {
+			expired = append(expired, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list expired archives in %s: %v", bucket, err)
+		return
+	}
+	for _, result := range deleteAll(ctx, store, bucket, expired) {
+		if result.Error != "" {
+			log.Printf("Failed to delete expired archive %s/%s: %s", bucket, result.Object, result.Error)
+		}
+	}
+}
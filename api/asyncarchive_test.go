@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// awaitArchive requests an ?async=true archive until it is served
+func awaitArchive(t *testing.T, server *Server, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec := serve(server, http.MethodGet, target, nil, nil)
+		if rec.Code != http.StatusAccepted {
+			return rec
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("archive still building: %s", rec.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// acceptedArchive requests an ?async=true archive that isn't built yet and
+// returns its key
+func acceptedArchive(t *testing.T, server *Server, target string) string {
+	t.Helper()
+	rec := serve(server, http.MethodGet, target, nil, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("async download = %d %s, want 202", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("202 without Retry-After")
+	}
+	var response struct {
+		Archive   string `json:"archive"`
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusURL != target {
+		t.Errorf("status_url = %q, want %q", response.StatusURL, target)
+	}
+	return response.Archive
+}
+
+func TestAsyncArchiveBuildThenServe(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "photos/a.jpg", "first photo")
+	putObject(t, server, "photos/b.jpg", "second photo")
+	const target = "/download/default/photos?directory=true&async=true"
+
+	key := acceptedArchive(t, server, target)
+	if !strings.HasPrefix(key, ".archives/") || !strings.HasSuffix(key, "/photos.zip") {
+		t.Errorf("archive key = %q, want photos.zip under .archives/", key)
+	}
+
+	rec := awaitArchive(t, server, target)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("built archive = %d %s", rec.Code, rec.Body)
+	}
+	if got := zipContents(t, rec.Body.Bytes()); len(got) != 2 || got["a.jpg"] != "first photo" || got["b.jpg"] != "second photo" {
+		t.Errorf("archive holds %v", got)
+	}
+	if !objectExists(t, server, key) {
+		t.Errorf("archive isn't stored at %s", key)
+	}
+
+	// The stored archive is an object, so it can be resumed in ranges
+	rec = serve(server, http.MethodGet, target, nil, map[string]string{"Range": "bytes=0-3"})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "PK\x03\x04" {
+		t.Errorf("ranged request = %d %q, want 206 with the ZIP signature", rec.Code, rec.Body)
+	}
+
+	// A changed directory gets a new archive instead of the stale one
+	putObject(t, server, "photos/b.jpg", "edited photo")
+	if changed := acceptedArchive(t, server, target); changed == key {
+		t.Errorf("changed directory reuses the archive %s", key)
+	}
+	rec = awaitArchive(t, server, target)
+	if got := zipContents(t, rec.Body.Bytes()); got["b.jpg"] != "edited photo" {
+		t.Errorf("archive of the changed directory holds %v", got)
+	}
+}
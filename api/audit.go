@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// Principals recorded in the audit log for requests without an API key
+const (
+	anonymousPrincipal = "anonymous"
+	sharePrincipal     = "share-link"
+)
+
+// openAuditLog opens the log.audit sink, returning nil when auditing is disabled.
+// Files are opened for appending and stay open for the life of the process.
+func openAuditLog(sink string) (*storage.AuditLogger, error) {
+	switch sink {
+	case "":
+		return nil, nil
+	case "stdout":
+		return storage.NewAuditLogger(os.Stdout), nil
+	case "stderr":
+		return storage.NewAuditLogger(os.Stderr), nil
+	}
+	file, err := os.OpenFile(sink, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return storage.NewAuditLogger(file), nil
+}
+
+// setPrincipal stores the authenticated principal in the request context,
+// where the audit log picks it up for every storage call of the request
+func setPrincipal(c *gin.Context, principal string) {
+	c.Request = c.Request.WithContext(storage.WithPrincipal(c.Request.Context(), principal))
+}
+
+// keyPrincipal identifies an API key by its configured description, or by a
+// masked prefix of the key so the secret never reaches the audit log
+func keyPrincipal(key, description string) string {
+	if description != "" {
+		return description
+	}
+	if len(key) > 6 {
+		key = key[:6]
+	}
+	return "key:" + key + "..."
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/config"
+)
+
+// Principal is the identity a request was authenticated as, along with the
+// restrictions that apply to it
+type Principal struct {
+	// Name recorded in the audit log
+	Name string
+
+	// API key the request was sent with, empty for authenticators that don't
+	// use auth.api_keys; auth.download_rate_limits and auth.zip_limits are
+	// looked up by it
+	APIKey string
+
+	// Object key prefix the request is confined to, empty for none; see
+	// AuthConfig.KeyPrefixes
+	KeyPrefix string
+}
+
+// Authenticator decides who a request is made by. AuthMiddleware calls it
+// for every REST, WebDAV and web UI request; a non-nil error rejects the
+// request with 401 Unauthorized and the error as its message.
+type Authenticator interface {
+	Authenticate(c *gin.Context) (Principal, error)
+}
+
+// ServerOption customizes a Server created by NewServer
+type ServerOption func(*Server)
+
+// WithAuthenticator replaces the authentication configured in auth with a,
+// for embedders with their own way of identifying clients
+func WithAuthenticator(a Authenticator) ServerOption {
+	return func(s *Server) {
+		s.authenticator = a
+	}
+}
+
+// Errors returned by APIKeyAuthenticator
+var (
+	errAPIKeyRequired = errors.New("API key is required")
+	errInvalidAPIKey  = errors.New("Invalid API key")
+)
+
+// APIKeyAuthenticator authenticates requests with the keys in auth.api_keys,
+// sent in the X-API-Key header, the api_key query parameter or as the Basic
+// auth password. Every request is anonymous while auth.enabled is off.
+type APIKeyAuthenticator struct {
+	config func() *config.Config
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator reading the keys from
+// the configuration cfg returns, so reloaded keys take effect right away
+func NewAPIKeyAuthenticator(cfg func() *config.Config) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{config: cfg}
+}
+
+// Authenticate looks up the request's API key
+func (a *APIKeyAuthenticator) Authenticate(c *gin.Context) (Principal, error) {
+	auth := a.config().Auth
+
+	// 如果未启用鉴权，则直接通过
+	if !auth.Enabled {
+		return Principal{Name: anonymousPrincipal}, nil
+	}
+
+	// 获取API Key
+	apiKey := c.GetHeader("X-API-Key")
+	if apiKey == "" {
+		// 如果header中没有，尝试从查询参数获取
+		apiKey = c.Query("api_key")
+	}
+	if apiKey == "" {
+		// Clients that only speak Basic auth, such as WebDAV, send the key as the password
+		_, apiKey, _ = c.Request.BasicAuth()
+	}
+
+	// 检查API Key是否有效
+	if apiKey == "" {
+		basicAuthChallenge(c)
+		return Principal{}, errAPIKeyRequired
+	}
+
+	// 检查API Key是否在配置中
+	description, exists := auth.APIKeys[apiKey]
+	if !exists {
+		basicAuthChallenge(c)
+		return Principal{}, errInvalidAPIKey
+	}
+	return Principal{
+		Name:      keyPrincipal(apiKey, description),
+		APIKey:    apiKey,
+		KeyPrefix: auth.KeyPrefix(apiKey),
+	}, nil
+}
+
+// configuredAuthenticator authenticates requests the way auth.mode says,
+// checked on every request so a reload can switch modes
+type configuredAuthenticator struct {
+	config func() *config.Config
+	apiKey *APIKeyAuthenticator
+	hmac   *HMACAuthenticator
+}
+
+// newConfiguredAuthenticator creates the authenticator NewServer uses by default
+func newConfiguredAuthenticator(cfg func() *config.Config) *configuredAuthenticator {
+	return &configuredAuthenticator{
+		config: cfg,
+		apiKey: NewAPIKeyAuthenticator(cfg),
+		hmac:   NewHMACAuthenticator(cfg),
+	}
+}
+
+// Authenticate authenticates the request with the configured mode
+func (a *configuredAuthenticator) Authenticate(c *gin.Context) (Principal, error) {
+	if a.config().Auth.Mode == config.AuthModeHMAC {
+		return a.hmac.Authenticate(c)
+	}
+	return a.apiKey.Authenticate(c)
+}
+
+// NoAuthenticator accepts every request as anonymous, whatever auth.enabled
+// says, for deployments where a proxy in front of the service authenticates
+type NoAuthenticator struct{}
+
+// Authenticate accepts the request
+func (NoAuthenticator) Authenticate(c *gin.Context) (Principal, error) {
+	return Principal{Name: anonymousPrincipal}, nil
+}
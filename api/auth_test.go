@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userAuthenticator trusts the X-User header set by a proxy in front of the
+// service and confines each user to their own directory
+type userAuthenticator struct{}
+
+func (userAuthenticator) Authenticate(c *gin.Context) (Principal, error) {
+	user := c.GetHeader("X-User")
+	if user == "" {
+		return Principal{}, errors.New("X-User is required")
+	}
+	return Principal{Name: "user:" + user, KeyPrefix: "users/" + user + "/"}, nil
+}
+
+func TestCustomAuthenticator(t *testing.T) {
+	// The injected authenticator replaces auth.api_keys entirely
+	server := newTestServer(t, "auth:\n  enabled: true\n  api_keys:\n    k1: team\n", WithAuthenticator(userAuthenticator{}))
+	putObject(t, server, "a.txt", "outside every user's directory")
+
+	rec := serve(server, http.MethodPost, "/upload/default/a.txt", strings.NewReader("alice's"), map[string]string{"X-User": "alice"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST as alice = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "users/alice/a.txt"); got != "alice's" {
+		t.Errorf("users/alice/a.txt = %q, want the upload confined to alice's prefix", got)
+	}
+	if rec := serve(server, http.MethodGet, "/download/default/a.txt", nil, map[string]string{"X-User": "bob"}); rec.Code != http.StatusNotFound {
+		t.Errorf("GET as bob = %d %s, want 404", rec.Code, rec.Body)
+	}
+
+	for name, headers := range map[string]map[string]string{
+		"no user":         nil,
+		"only an API key": {"X-API-Key": "k1"},
+	} {
+		rec := serve(server, http.MethodGet, "/download/default/a.txt", nil, headers)
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "X-User is required") {
+			t.Errorf("GET with %s = %d %s, want 401 from the authenticator", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestNoAuthenticator(t *testing.T) {
+	server := newTestServer(t, "auth:\n  enabled: true\n  api_keys:\n    k1: team\n", WithAuthenticator(NoAuthenticator{}))
+	putObject(t, server, "a.txt", "a")
+
+	if rec := serve(server, http.MethodGet, "/download/default/a.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("GET without a key = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
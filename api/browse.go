@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// browseDirectory handles requests for the directories and files directly
+// under a prefix, which is what a file browser shows for one folder and
+// otherwise takes both a recursive listing and a directory listing
+func (s *Server) browseDirectory(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	// An empty prefix lists the bucket root, anything else is a directory
+	prefix, ok := objectKeyFrom(c, c.Query("prefix"))
+	if !ok {
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	objects, err := store.ListObjects(ctx, bucket, prefix)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		return
+	}
+	if objects == nil {
+		objects = []storage.FileObject{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":  bucket,
+		"prefix":  prefix,
+		"objects": objects,
+	})
+}
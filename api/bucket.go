@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// listBuckets handles requests to enumerate the buckets of the selected backend
+func (s *Server) listBuckets(c *gin.Context) {
+	store := s.storageFor(c)
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	buckets, err := store.ListBuckets(ctx)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list buckets: %v", err)})
+		return
+	}
+
+	// Always answer with an array, even when there are no buckets
+	result := make([]gin.H, 0, len(buckets))
+	for _, bucket := range buckets {
+		entry := gin.H{"name": bucket.Name}
+		if !bucket.CreationDate.IsZero() {
+			entry["creation_date"] = bucket.CreationDate.UTC().Format(time.RFC3339)
+		}
+		result = append(result, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"buckets": result})
+}
+
+// keyPrefixed rejects bucket management by API keys confined to a key
+// prefix, since a bucket holds other keys' objects too
+func keyPrefixed(c *gin.Context) bool {
+	if c.GetString(keyPrefixContextKey) == "" {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "API key is restricted to a key prefix and cannot manage buckets"})
+	return true
+}
+
+// createBucket handles bucket creation requests
+func (s *Server) createBucket(c *gin.Context) {
+	if keyPrefixed(c) {
+		return
+	}
+	store := s.storageFor(c)
+	bucket := c.Param("bucket")
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	exists, err := store.BucketExists(ctx, bucket)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to check bucket: %v", err)})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bucket already exists", "bucket": bucket})
+		return
+	}
+
+	if err := store.CreateBucket(ctx, bucket); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create bucket: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bucket created successfully",
+		"bucket":  bucket,
+	})
+}
+
+// bucketExists handles bucket existence checks, answering 200 or 404
+func (s *Server) bucketExists(c *gin.Context) {
+	store := s.storageFor(c)
+	bucket := c.Param("bucket")
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	exists, err := store.BucketExists(ctx, bucket)
+	if err != nil {
+		c.Status(storageErrorStatus(ctx, err))
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// deleteBucket handles bucket deletion requests. A non-empty bucket is only
+// deleted when force=true, in which case all of its objects are removed first.
+func (s *Server) deleteBucket(c *gin.Context) {
+	if keyPrefixed(c) {
+		return
+	}
+	// The whole bucket is deleted, so it is checked and emptied without its
+	// default prefix, which would hide the objects outside of it
+	store := s.storages[c.GetString(backendContextKey)]
+	bucket := c.Param("bucket")
+	force := c.Query("force") == "true"
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	var objects []storage.FileObject
+	if force {
+		var err error
+		if objects, err = store.List(ctx, bucket, ""); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+			return
+		}
+		deleted, failed := deleteSummary(deleteAll(ctx, store, bucket, objects))
+		if len(failed) > 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to empty bucket",
+				"bucket":  bucket,
+				"deleted": deleted,
+				"errors":  failed,
+			})
+			return
+		}
+	} else {
+		// The first object found is enough to refuse
+		err := store.Walk(ctx, bucket, "", func(storage.FileObject) error {
+			return errStopWalk
+		})
+		if errors.Is(err, errStopWalk) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bucket is not empty", "bucket": bucket})
+			return
+		}
+		if err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+			return
+		}
+	}
+
+	if err := store.DeleteBucket(ctx, bucket); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to delete bucket: %v", err)})
+		return
+	}
+	s.knownBuckets.Delete(c.GetString(backendContextKey) + "/" + bucket)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Bucket deleted successfully",
+		"bucket":          bucket,
+		"deleted_objects": len(objects),
+	})
+}
+
+// ensureBucket creates the bucket on the selected backend if it doesn't exist yet.
+// Buckets seen once are remembered so later uploads skip the check.
+func (s *Server) ensureBucket(ctx context.Context, c *gin.Context, bucket string) error {
+	key := c.GetString(backendContextKey) + "/" + bucket
+	if _, known := s.knownBuckets.Load(key); known {
+		return nil
+	}
+
+	store := s.storageFor(c)
+	exists, err := store.BucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := store.CreateBucket(ctx, bucket); err != nil {
+			return err
+		}
+	}
+
+	s.knownBuckets.Store(key, struct{}{})
+	return nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// prefixedBucketConfig keeps the objects of the default bucket under uploads/
+const prefixedBucketConfig = "buckets:\n  default:\n    default_prefix: uploads\n"
+
+func TestDeleteBucketChecksObjectsOutsideDefaultPrefix(t *testing.T) {
+	server := newTestServer(t, prefixedBucketConfig)
+	putObject(t, server, "other/b.txt", "outside the prefix")
+
+	rec := serve(server, http.MethodDelete, "/bucket/default", nil, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("DELETE /bucket = %d %s, want 409", rec.Code, rec.Body)
+	}
+	if !objectExists(t, server, "other/b.txt") {
+		t.Error("object outside the prefix was deleted")
+	}
+}
+
+func TestDeleteBucketForceEmptiesWholeBucket(t *testing.T) {
+	server := newTestServer(t, prefixedBucketConfig)
+	putObject(t, server, "uploads/a.txt", "inside the prefix")
+	putObject(t, server, "other/b.txt", "outside the prefix")
+
+	rec := serve(server, http.MethodDelete, "/bucket/default?force=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /bucket?force=true = %d %s", rec.Code, rec.Body)
+	}
+	var response struct {
+		DeletedObjects int `json:"deleted_objects"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.DeletedObjects != 2 {
+		t.Errorf("deleted_objects = %d, want 2", response.DeletedObjects)
+	}
+	exists, err := testStore(server).BucketExists(context.Background(), "default")
+	if err != nil || exists {
+		t.Errorf("BucketExists after deletion = %v, %v", exists, err)
+	}
+}
+
+func TestDeleteEmptyBucket(t *testing.T) {
+	server := newTestServer(t, "")
+	if err := testStore(server).CreateBucket(context.Background(), "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(server, http.MethodDelete, "/bucket/empty", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /bucket = %d %s", rec.Code, rec.Body)
+	}
+}
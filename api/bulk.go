@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/example/file-service/storage"
+)
+
+// bulkResult is the outcome of a bulk operation for one object: the error and
+// the status a single request for it would have failed with, or neither when
+// it succeeded
+type bulkResult struct {
+	Object string `json:"object"`
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// bulkFailure records err as the failure of a bulk operation on object
+func bulkFailure(ctx context.Context, object string, err error) bulkResult {
+	status := storageErrorStatus(ctx, err)
+	switch {
+	case storage.IsNotFound(err):
+		status = http.StatusNotFound
+	case preconditionFailed(err):
+		status = http.StatusPreconditionFailed
+	}
+	return bulkResult{Object: object, Error: err.Error(), Status: status}
+}
+
+// bulkStatus is the response status of a bulk operation given the status of
+// each item, 0 for those that succeeded: 200 OK when none failed, 207
+// Multi-Status when only some did, and when all failed the status they share,
+// or 500 Internal Server Error if they failed differently
+func bulkStatus(statuses []int) int {
+	failed, shared := 0, 0
+	for _, status := range statuses {
+		if status == 0 {
+			continue
+		}
+		if failed == 0 {
+			shared = status
+		} else if status != shared {
+			shared = http.StatusInternalServerError
+		}
+		failed++
+	}
+	switch {
+	case failed == 0:
+		return http.StatusOK
+	case failed < len(statuses):
+		return http.StatusMultiStatus
+	}
+	return shared
+}
+
+// bulkStatuses returns the status of each result for bulkStatus
+func bulkStatuses(results []bulkResult) []int {
+	statuses := make([]int, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
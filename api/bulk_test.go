@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/example/file-service/storage"
+)
+
+func TestBulkStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{"all succeeded", []int{0, 0}, http.StatusOK},
+		{"some failed", []int{0, http.StatusNotFound}, http.StatusMultiStatus},
+		{"all failed alike", []int{http.StatusNotFound, http.StatusNotFound}, http.StatusNotFound},
+		{"all failed differently", []int{http.StatusNotFound, http.StatusPreconditionFailed}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bulkStatus(tt.statuses); got != tt.want {
+				t.Errorf("bulkStatus(%v) = %d, want %d", tt.statuses, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfoBatchStatus(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "a.txt", "a")
+	putObject(t, server, "b.txt", "b")
+
+	for body, want := range map[string]int{
+		`{"objects":["a.txt","b.txt"]}`:              http.StatusOK,
+		`{"objects":["a.txt","missing.txt"]}`:        http.StatusMultiStatus,
+		`{"objects":["missing.txt","missing2.txt"]}`: http.StatusNotFound,
+	} {
+		rec := serve(server, http.MethodPost, "/info-batch/default", strings.NewReader(body), nil)
+		if rec.Code != want {
+			t.Errorf("POST /info-batch %s = %d %s, want %d", body, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+// failingDeleteStore fails to delete objects whose name contains "locked"
+type failingDeleteStore struct {
+	storage.Storage
+}
+
+func (s *failingDeleteStore) Delete(ctx context.Context, bucket, objectName string) error {
+	if strings.Contains(objectName, "locked") {
+		return errors.New("access denied")
+	}
+	return s.Storage.Delete(ctx, bucket, objectName)
+}
+
+func TestDeleteByPrefixStatus(t *testing.T) {
+	server := newTestServer(t, "")
+	server.storages["default"] = &failingDeleteStore{Storage: testStore(server)}
+	for _, key := range []string{"ok/a.txt", "ok/b.txt", "mixed/a.txt", "mixed/locked.txt", "locked/a.txt", "locked/b.txt"} {
+		putObject(t, server, key, "content")
+	}
+
+	for prefix, want := range map[string]int{
+		"ok":     http.StatusOK,
+		"mixed":  http.StatusMultiStatus,
+		"locked": http.StatusInternalServerError,
+	} {
+		rec := serve(server, http.MethodDelete, "/delete/default/"+prefix+"?recursive=true", nil, nil)
+		if rec.Code != want {
+			t.Errorf("DELETE %s/ = %d %s, want %d", prefix, rec.Code, rec.Body, want)
+		}
+	}
+	if objectExists(t, server, "mixed/a.txt") || !objectExists(t, server, "mixed/locked.txt") {
+		t.Error("partial delete didn't delete exactly the deletable objects")
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+	"github.com/example/file-service/version"
+)
+
+// getCapabilities handles requests for the optional features of the backend
+// selected for the request, so clients can hide operations it doesn't support
+func (s *Server) getCapabilities(c *gin.Context) {
+	caps := s.storageFor(c).Capabilities()
+	c.JSON(http.StatusOK, gin.H{
+		"version": version.Version,
+		"backend": c.GetString(backendContextKey),
+		"type":    s.backendConfig(c).Type,
+		"capabilities": gin.H{
+			"versioning":       caps.Versioning,
+			"multipart":        caps.Multipart,
+			"server_side_copy": caps.ServerSideCopy,
+			"object_lock":      caps.ObjectLock,
+			"acl":              caps.ACLScope != "",
+			"acl_scope":        caps.ACLScope,
+			"atomic_create":    caps.AtomicCreate,
+			"list_metadata":    caps.ListMetadata,
+			"restore":          caps.Restore,
+			"presigned_urls":   caps.PresignedURLs,
+			"min_part_size":    caps.MinPartSize,
+			"tagging":          caps.Tagging,
+		},
+		"naming": namingResponse(caps.Naming),
+	})
+}
+
+// namingResponse renders a backend's naming rules; the bucket rules are left
+// out when any bucket name is accepted, and a max_length of 0 means no limit
+func namingResponse(naming storage.NamingRules) gin.H {
+	result := gin.H{
+		"object": gin.H{
+			"max_length":         naming.ObjectMaxLength,
+			"control_characters": false,
+		},
+	}
+	if naming.BucketPattern != nil {
+		result["bucket"] = gin.H{
+			"pattern":    naming.BucketPattern.String(),
+			"min_length": naming.BucketMinLength,
+			"max_length": naming.BucketMaxLength,
+			"rule":       naming.BucketRule,
+		}
+	}
+	return result
+}
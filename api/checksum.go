@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// checksumMetadataPrefix starts the user metadata entries computed checksums
+// are cached in, followed by the algorithm, e.g. checksum-sha256
+const checksumMetadataPrefix = "checksum-"
+
+// checksumAlgorithms are the digests GET /checksum computes
+var checksumAlgorithms = map[string]func() hash.Hash{
+	storage.ChecksumMD5:    md5.New,
+	storage.ChecksumSHA1:   sha1.New,
+	storage.ChecksumSHA256: sha256.New,
+}
+
+// objectChecksum handles requests for the digest of a stored object. A digest
+// the backend stores with the object, or one cached in its metadata by an
+// earlier request, is returned without reading the object; otherwise the
+// object is streamed through the hash on the server and only the digest is
+// sent to the client.
+func (s *Server) objectChecksum(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+
+	algorithm := strings.ToLower(c.DefaultQuery("algo", storage.ChecksumSHA256))
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported algo %q: use md5, sha1 or sha256", algorithm)})
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	versionID := c.Query("versionId")
+	view := storage.WithVersion(store, versionID)
+	info, err := view.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
+		return
+	}
+
+	response := gin.H{
+		"bucket":    bucket,
+		"object":    object,
+		"algorithm": algorithm,
+		"size":      info.Size,
+	}
+	if info.VersionID != "" {
+		response["version_id"] = info.VersionID
+	}
+
+	if sum := info.Checksums[algorithm]; sum != "" {
+		response["checksum"], response["source"] = sum, "backend"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if sum := metadataValue(info.Metadata, checksumMetadataPrefix+algorithm); sum != "" {
+		response["checksum"], response["source"] = sum, "metadata"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	reader, err := view.Download(ctx, bucket, object)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to download file: %v", err)})
+		return
+	}
+	defer reader.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, &contextReader{ctx: ctx, reader: reader}); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to read file: %v", err)})
+		return
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	response["checksum"], response["source"] = sum, "computed"
+
+	// Only the latest version is cached, and a read-only service doesn't
+	// write the metadata
+	cfg := s.config()
+	if cfg.Server.Checksum.CacheInMetadata && versionID == "" && !cfg.Server.ReadOnly {
+		if err := cacheChecksum(ctx, store, bucket, info, algorithm, sum); err != nil {
+			log.Printf("Failed to cache %s checksum of %s/%s: %v", algorithm, bucket, object, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// cacheChecksum adds a computed digest to the user metadata of the object
+// described by info, unless the object was replaced while it was hashed
+func cacheChecksum(ctx context.Context, store storage.Storage, bucket string, info *storage.FileObject, algorithm, sum string) error {
+	current, err := store.GetObjectInfo(ctx, bucket, info.Name)
+	if err != nil {
+		return err
+	}
+	if current.ETag != info.ETag {
+		return nil
+	}
+
+	metadata := maps.Clone(current.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[checksumMetadataPrefix+algorithm] = sum
+	return store.UpdateMetadata(ctx, bucket, info.Name, metadata, "")
+}
+
+// metadataValue returns the user metadata entry with the given name, which
+// backends return in different cases
+func metadataValue(metadata map[string]string, name string) string {
+	for key, value := range metadata {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"bufio"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// detectContentType guesses the content type of an upload. The object's file
+// extension is preferred when it maps to a known type, otherwise the first
+// bytes of the body are sniffed. Peeking leaves the bytes in the reader so
+// they are still streamed to the backend.
+func detectContentType(object string, body *bufio.Reader) string {
+	if contentType := mime.TypeByExtension(path.Ext(object)); contentType != "" {
+		return contentType
+	}
+
+	// Peek returns the available bytes along with an error for short bodies
+	head, _ := body.Peek(sniffLen)
+	if len(head) == 0 {
+		return ""
+	}
+	return http.DetectContentType(head)
+}
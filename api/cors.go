@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsAllowedMethods lists the methods browsers may use across origins
+const corsAllowedMethods = "GET, HEAD, POST, PUT, PATCH, DELETE"
+
+// corsExposedHeaders lists the response headers scripts may read across origins
+const corsExposedHeaders = "Content-Disposition, Content-Length, ETag, Last-Modified, X-Version-Id"
+
+// CORSMiddleware answers cross-origin requests with the policy of the bucket
+// named in the path, falling back to server.cors. It runs before routing so
+// that preflight OPTIONS requests are answered for any path, including paths
+// without an OPTIONS route.
+func (s *Server) CORSMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		allowed := s.config().AllowedOrigins(bucketFromPath(c.Request.URL.Path))
+		if !slices.Contains(allowed, "*") && !slices.Contains(allowed, origin) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Vary", "Origin")
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		// Answer preflight requests without passing them on
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", corsAllowedMethods)
+			if headers := c.GetHeader("Access-Control-Request-Headers"); headers != "" {
+				c.Header("Access-Control-Allow-Headers", headers)
+			}
+			c.Header("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// bucketFromPath returns the bucket segment of a request path. Every bucket
+// route has the form /<operation>/<bucket>/..., so it is the second segment;
+// an empty result selects the server-wide policy.
+func bucketFromPath(path string) string {
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[1]
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// directoryEntry is an immediate subdirectory of the listed prefix
+type directoryEntry struct {
+	// Name relative to the prefix, always with a trailing slash
+	Name string `json:"name"`
+	// Full key prefix of the directory
+	Path string `json:"path"`
+	// User metadata of the directory marker, with ?include=metadata
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// listDirectories handles requests for the immediate subdirectories under a
+// prefix, which is much cheaper than a recursive listing for tree views
+func (s *Server) listDirectories(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	// An empty prefix lists the bucket root, anything else is a directory
+	prefix, ok := objectKeyFrom(c, c.Query("prefix"))
+	if !ok {
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	switch include := c.Query("include"); include {
+	case "":
+	case "metadata":
+		ctx = storage.WithListMetadata(ctx)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid include %q, expected include=metadata", include)})
+		return
+	}
+
+	dirs, err := store.ListDirectories(ctx, bucket, prefix)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list directories: %v", err)})
+		return
+	}
+
+	// Backends differ in the names they return, so reduce each to the first
+	// path segment below the prefix
+	seen := make(map[string]bool)
+	entries := make([]directoryEntry, 0, len(dirs))
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir.Name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(dir.Name, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i]
+		}
+		if rest == "" || seen[rest] {
+			continue
+		}
+		seen[rest] = true
+		entry := directoryEntry{Name: rest + "/", Path: prefix + rest + "/"}
+		if dir.Name == entry.Path {
+			entry.Metadata = dir.Metadata
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":      bucket,
+		"prefix":      prefix,
+		"directories": entries,
+	})
+}
+
+// createDirectory handles requests to create a directory marker, storing the
+// X-Meta-* headers as its user metadata, e.g. to record who owns it. Missing
+// parent directories get bare markers, as they do for uploads, and an
+// existing directory has its metadata replaced.
+func (s *Server) createDirectory(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+	if object == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Directory path is required"})
+		return
+	}
+	if !strings.HasSuffix(object, "/") {
+		object += "/"
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if err := store.EnsurePathExists(ctx, bucket, strings.TrimSuffix(object, "/")); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create parent directories: %v", err)})
+		return
+	}
+	metadata := metadataFromHeaders(c.Request.Header)
+	if err := store.CreateDirectory(ctx, bucket, object, metadata); err != nil {
+		if errors.Is(err, storage.ErrNotSupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": fmt.Sprintf("Failed to create directory: %v", err)})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create directory: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Directory created successfully",
+		"bucket":   bucket,
+		"object":   object,
+		"metadata": metadata,
+	})
+}
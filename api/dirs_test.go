@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// nestedTree is a three-level tree with files at every level
+var nestedTree = []string{
+	"readme.txt",
+	"photos/cover.jpg",
+	"photos/2023/summer/beach.jpg",
+	"photos/2023/summer/dunes.jpg",
+	"photos/2023/winter/snow.jpg",
+	"photos/2024/spring/park.jpg",
+	"reports/q1.pdf",
+}
+
+// listedDirectories returns the names GET /dirs lists under prefix
+func listedDirectories(t testing.TB, server *Server, prefix string) []string {
+	t.Helper()
+	rec := serve(server, http.MethodGet, "/dirs/default?prefix="+url.QueryEscape(prefix), nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /dirs?prefix=%s = %d %s", prefix, rec.Code, rec.Body)
+	}
+	var response struct {
+		Directories []directoryEntry `json:"directories"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, dir := range response.Directories {
+		names = append(names, dir.Name)
+	}
+	return names
+}
+
+func TestListDirectoriesOfNestedTree(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, key := range nestedTree {
+		putObject(t, server, key, key)
+	}
+
+	for prefix, want := range map[string][]string{
+		"":                    {"photos/", "reports/"},
+		"photos":              {"2023/", "2024/"},
+		"photos/":             {"2023/", "2024/"},
+		"photos/2023/":        {"summer/", "winter/"},
+		"photos/2023/summer/": {},
+		"reports/":            {},
+	} {
+		if got := listedDirectories(t, server, prefix); !reflect.DeepEqual(got, want) {
+			t.Errorf("directories under %q = %v, want %v", prefix, got, want)
+		}
+	}
+}
+
+func TestBrowseNestedTree(t *testing.T) {
+	server := newTestServer(t, "")
+	for _, key := range nestedTree {
+		putObject(t, server, key, key)
+	}
+
+	rec := serve(server, http.MethodGet, "/browse/default?prefix=photos/2023", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /browse = %d %s", rec.Code, rec.Body)
+	}
+	var response struct {
+		Objects []struct {
+			Name  string
+			IsDir bool
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, obj := range response.Objects {
+		got = append(got, fmt.Sprintf("%s %t", obj.Name, obj.IsDir))
+	}
+	want := []string{"photos/2023/summer/ true", "photos/2023/winter/ true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GET /browse lists %v, want %v", got, want)
+	}
+}
+
+// BenchmarkListDirectories lists the root of a deep tree, 10 directories
+// wide with 10 files in each of 1000 leaf directories
+func BenchmarkListDirectories(b *testing.B) {
+	server := newTestServer(b, "")
+	for i := range 10 {
+		for j := range 10 {
+			for k := range 10 {
+				for f := range 10 {
+					putObject(b, server, fmt.Sprintf("d%d/d%d/d%d/f%d.txt", i, j, k, f), "x")
+				}
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if got := listedDirectories(b, server, ""); len(got) != 10 {
+			b.Fatalf("listed %d directories, want 10", len(got))
+		}
+	}
+}
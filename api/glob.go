@@ -0,0 +1,68 @@
+package api
+
+import (
+	"path"
+	"strings"
+)
+
+// Glob patterns are matched against full object keys. Each "/"-separated
+// segment uses path.Match syntax (*, ?, [...] and \ escapes, none of which
+// cross a "/"), and a segment that is exactly "**" matches zero or more
+// whole segments, e.g. "logs/**/*.json".
+
+// validateGlob reports whether pattern is a well-formed glob
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// globPrefix returns the literal part of pattern before its first wildcard,
+// which can be used as a backend list prefix
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// matchGlob reports whether name matches pattern; pattern must have passed validateGlob
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches key segments against pattern segments, expanding "**"
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive "**" and try every possible split point
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// quoteETag formats a bare ETag value for use in an HTTP header
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// preconditionFailed reports whether a conditional write failed its If-Match
+// check. A missing object never matches, even where the backend reports it as
+// not found rather than as a failed precondition.
+func preconditionFailed(err error) bool {
+	return storage.IsPreconditionFailed(err) || (storage.IsNotFound(err) && !errors.Is(err, storage.ErrBucketNotFound))
+}
+
+// httpTime converts a FileObject timestamp to the HTTP date format, returning
+// false when the backend didn't report one
+func httpTime(t time.Time) (string, bool) {
+	if t.IsZero() {
+		return "", false
+	}
+	return t.UTC().Format(http.TimeFormat), true
+}
+
+// parseRange parses a single-range Range header against an object of size
+// bytes. ranged is false when the whole object is to be sent, and ok is false
+// when the range can't be satisfied.
+func parseRange(header string, size int64) (start, length int64, ranged, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		// Missing, malformed and multi-range headers get the whole object
+		return 0, size, false, true
+	}
+	first, last, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, size, false, true
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, false
+		}
+		n = min(n, size)
+		return size - n, n, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, false
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end - start + 1, true, true
+}
+
+// ifRangeMatches reports whether an If-Range validator still identifies the
+// object, in which case its Range header is honored. An entity tag must match
+// strongly, so weak tags never do; a date must equal the object's
+// modification time to the second.
+func ifRangeMatches(value string, info *storage.FileObject) bool {
+	if strings.HasPrefix(value, `"`) {
+		return info.ETag != "" && value == quoteETag(info.ETag)
+	}
+	if strings.HasPrefix(value, "W/") {
+		return false
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || info.LastModified.IsZero() {
+		return false
+	}
+	return date.Equal(info.LastModified.Truncate(time.Second))
+}
+
+// etagListed reports whether an If-None-Match header lists etag, or is "*".
+// The comparison is weak, as If-None-Match requires: W/ prefixes are ignored.
+func etagListed(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.TrimPrefix(value, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// contentDisposition builds a Content-Disposition header for the object's base
+// name. Non-ASCII names get an ASCII fallback plus an RFC 5987 filename* parameter.
+func contentDisposition(disposition, object string) string {
+	filename := path.Base(object)
+
+	fallback := make([]byte, 0, len(filename))
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r >= 0x80 || r < 0x20 || r == 0x7f:
+			ascii = false
+			fallback = append(fallback, '_')
+		case r == '"' || r == '\\':
+			fallback = append(fallback, '\\', byte(r))
+		default:
+			fallback = append(fallback, byte(r))
+		}
+	}
+
+	if ascii {
+		return fmt.Sprintf(`%s; filename="%s"`, disposition, fallback)
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, encodeRFC5987(filename))
+}
+
+// encodeRFC5987 percent-encodes every byte outside the RFC 5987 attr-char set
+func encodeRFC5987(value string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || strings.IndexByte(attrChars, ch) >= 0 {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// setCacheControl sets the Cache-Control header configured for downloads from
+// the bucket. It is only called once a download succeeds, so error responses
+// are never cached.
+func (s *Server) setCacheControl(c *gin.Context, bucket string) {
+	if cacheControl := s.config().CacheControl(bucket); cacheControl != "" {
+		c.Header("Cache-Control", cacheControl)
+	}
+}
+
+// downloadDisposition returns the disposition a download of contentType is
+// sent with: the one asked for with ?disposition=, else that of the first
+// server.content_disposition rule matching the type, else inline for static
+// site pages and attachment for other downloads
+func (s *Server) downloadDisposition(c *gin.Context, contentType string) string {
+	switch disposition := c.Query("disposition"); disposition {
+	case "inline", "attachment":
+		return disposition
+	}
+	for _, rule := range s.config().Server.ContentDisposition {
+		if contentTypeAllowed([]string{rule.ContentType}, contentType) {
+			return rule.Disposition
+		}
+	}
+	if c.GetBool(staticSiteContextKey) {
+		return "inline"
+	}
+	return "attachment"
+}
+
+// objectHeaders reads the standard headers of an upload request that are
+// stored with the object, such as the Content-Encoding of a pre-compressed file
+func objectHeaders(header http.Header) storage.ObjectHeaders {
+	return storage.ObjectHeaders{
+		CacheControl:    header.Get("Cache-Control"),
+		ContentEncoding: header.Get("Content-Encoding"),
+		ContentLanguage: header.Get("Content-Language"),
+	}
+}
+
+// setObjectHeaders sends back the standard headers stored with an object. It
+// is called after setCacheControl, so an object's own Cache-Control takes
+// precedence over the one configured for its bucket.
+func setObjectHeaders(c *gin.Context, headers storage.ObjectHeaders) {
+	if headers.CacheControl != "" {
+		c.Header("Cache-Control", headers.CacheControl)
+	}
+	if headers.ContentEncoding != "" {
+		c.Header("Content-Encoding", headers.ContentEncoding)
+	}
+	if headers.ContentLanguage != "" {
+		c.Header("Content-Language", headers.ContentLanguage)
+	}
+}
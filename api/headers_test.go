@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/example/file-service/storage"
+)
+
+func TestDownloadContentDisposition(t *testing.T) {
+	server := newTestServer(t, "server:\n  content_disposition:\n"+
+		"    - content_type: application/pdf\n      disposition: inline\n"+
+		"    - content_type: image/*\n      disposition: inline\n"+
+		"    - content_type: application/zip\n      disposition: attachment\n")
+	files := map[string]string{
+		"report.pdf":  "application/pdf",
+		"photo.png":   "image/png",
+		"bundle.zip":  "application/zip",
+		"data.bin":    "application/octet-stream",
+		"report2.pdf": "application/pdf; charset=binary",
+	}
+	for key, contentType := range files {
+		if _, err := testStore(server).Upload(context.Background(), "default", key, strings.NewReader("x"), 1, contentType, storage.ObjectHeaders{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/download/default/report.pdf", `inline; filename="report.pdf"`},
+		{"/download/default/report2.pdf", `inline; filename="report2.pdf"`},
+		{"/download/default/photo.png", `inline; filename="photo.png"`},
+		{"/download/default/bundle.zip", `attachment; filename="bundle.zip"`},
+		{"/download/default/data.bin", `attachment; filename="data.bin"`},
+		{"/download/default/report.pdf?disposition=attachment", `attachment; filename="report.pdf"`},
+		{"/download/default/bundle.zip?disposition=inline", `inline; filename="bundle.zip"`},
+	}
+	for _, tt := range tests {
+		rec := serve(server, http.MethodGet, tt.target, nil, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d %s", tt.target, rec.Code, rec.Body)
+			continue
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != tt.want {
+			t.Errorf("GET %s Content-Disposition = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
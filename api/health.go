@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+	"github.com/example/file-service/version"
+)
+
+const (
+	// readyCacheTTL is how long a readiness result is reused before probing the backends again
+	readyCacheTTL = 5 * time.Second
+
+	// readyProbeTimeout bounds each backend probe so a hung backend reports as not ready
+	readyProbeTimeout = 5 * time.Second
+
+	// readyWriteProbeInterval is how long the result of a write probe is
+	// reused, since it writes to and deletes from the backend
+	readyWriteProbeInterval = time.Minute
+
+	// readyWriteProbeObject is the object written and deleted by write probes
+	readyWriteProbeObject = ".healthcheck"
+)
+
+// readyResult is the cached outcome of a readiness probe
+type readyResult struct {
+	checkedAt time.Time
+	status    int
+	body      gin.H
+}
+
+// writeProbe is the cached outcome of a write probe of one backend
+type writeProbe struct {
+	checkedAt time.Time
+	err       error
+}
+
+// versionInfo handles build information requests
+func (s *Server) versionInfo(c *gin.Context) {
+	cfg := s.config()
+	c.JSON(http.StatusOK, gin.H{
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_date": version.BuildDate,
+		"go_version": version.GoVersion,
+		"storage":    cfg.Backends()[cfg.DefaultBackend()].Type,
+	})
+}
+
+// readyCheck handles readiness requests by probing every storage backend
+func (s *Server) readyCheck(c *gin.Context) {
+	result := s.probeBackends()
+	c.JSON(result.status, result.body)
+}
+
+// probeBackends runs HealthCheck against the default bucket of every
+// backend, and with
+// server.ready_check_writes a write probe as well, reusing a recent result so
+// frequent probes don't hammer the backends
+func (s *Server) probeBackends() *readyResult {
+	s.readyMu.Lock()
+	defer s.readyMu.Unlock()
+
+	if s.readyCache != nil && time.Since(s.readyCache.checkedAt) < readyCacheTTL {
+		return s.readyCache
+	}
+
+	status := http.StatusOK
+	backends := make(map[string]string)
+	for name, store := range s.storages {
+		// Probes are detached from the request so a disconnecting client can't cache a failure
+		ctx, cancel := context.WithTimeout(context.Background(), readyProbeTimeout)
+		err := store.HealthCheck(ctx, s.backendBucket(name))
+		cancel()
+		if err == nil && s.config().Server.ReadyCheckWrites {
+			err = s.probeWrites(name, store)
+		}
+
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			backends[name] = err.Error()
+		} else {
+			backends[name] = "ok"
+		}
+	}
+
+	readiness := "ready"
+	if status != http.StatusOK {
+		readiness = "unavailable"
+	}
+
+	s.readyCache = &readyResult{
+		checkedAt: time.Now(),
+		status:    status,
+		body: gin.H{
+			"status":   readiness,
+			"backends": backends,
+		},
+	}
+	return s.readyCache
+}
+
+// probeWrites checks that a backend accepts writes by storing and deleting a
+// tiny object in its default bucket, since a backend can be reachable while
+// its credentials only allow reads. The result is reused for
+// readyWriteProbeInterval; the caller must hold readyMu. A read-only server
+// isn't expected to write, so nothing is probed then.
+func (s *Server) probeWrites(name string, store storage.Storage) error {
+	cfg := s.config()
+	if cfg.Server.ReadOnly {
+		return nil
+	}
+	if probe, ok := s.writeProbes[name]; ok && time.Since(probe.checkedAt) < readyWriteProbeInterval {
+		return probe.err
+	}
+
+	bucket := s.backendBucket(name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyProbeTimeout)
+	defer cancel()
+	err := func() error {
+		content := time.Now().UTC().Format(time.RFC3339)
+		if _, err := store.Upload(ctx, bucket, readyWriteProbeObject, strings.NewReader(content), int64(len(content)), "text/plain", storage.ObjectHeaders{}); err != nil {
+			return fmt.Errorf("write probe failed: %w", err)
+		}
+		if err := store.Delete(ctx, bucket, readyWriteProbeObject); err != nil {
+			return fmt.Errorf("write probe could not delete %s: %w", readyWriteProbeObject, err)
+		}
+		return nil
+	}()
+
+	if s.writeProbes == nil {
+		s.writeProbes = make(map[string]writeProbe)
+	}
+	s.writeProbes[name] = writeProbe{checkedAt: time.Now(), err: err}
+	return err
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+const (
+	// idempotencyKeyHeader names the header a client sets to make retries of
+	// an upload safe
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	// maxIdempotencyKeyLength bounds the keys kept in memory
+	maxIdempotencyKeyLength = 255
+
+	// idempotencySweepInterval is how often expired responses are dropped
+	idempotencySweepInterval = time.Minute
+)
+
+// idempotentResponse is a successful response kept to answer retries with
+type idempotentResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyEntry is the state of one idempotency key: claimed by a request
+// still in progress while response is nil, completed afterwards
+type idempotencyEntry struct {
+	target   string // backend and path of the request that claimed the key
+	response *idempotentResponse
+	expires  time.Time
+}
+
+// idempotencyCache holds the idempotency keys of recent uploads in memory,
+// keyed by API key, principal and idempotency key
+type idempotencyCache struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{entries: make(map[string]*idempotencyEntry)}
+}
+
+// begin claims key for a request to target and reports true, unless a
+// request already claimed it and either is in progress or completed less
+// than its TTL ago. In that case a copy of its entry is returned instead.
+func (ic *idempotencyCache) begin(key, target string) (idempotencyEntry, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(ic.lastSweep) >= idempotencySweepInterval {
+		for k, entry := range ic.entries {
+			if entry.response != nil && now.After(entry.expires) {
+				delete(ic.entries, k)
+			}
+		}
+		ic.lastSweep = now
+	}
+
+	if entry, ok := ic.entries[key]; ok && (entry.response == nil || now.Before(entry.expires)) {
+		return *entry, false
+	}
+	ic.entries[key] = &idempotencyEntry{target: target}
+	return idempotencyEntry{}, true
+}
+
+// finish completes a request that claimed key, keeping its response for ttl.
+// A nil response releases the key, so a failed request can be retried.
+func (ic *idempotencyCache) finish(key string, response *idempotentResponse, ttl time.Duration) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+
+	if response == nil {
+		delete(ic.entries, key)
+		return
+	}
+	entry := ic.entries[key]
+	entry.response = response
+	entry.expires = time.Now().Add(ttl)
+}
+
+// responseRecorder keeps a copy of the body written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// idempotent wraps an upload handler so that requests sending an
+// Idempotency-Key can be retried safely. The response of a successful
+// request is kept for server.idempotency.ttl and sent again to a retry with
+// the same key from the same API key, without storing the body again; a
+// retry while the first request is still running is rejected with 409, and
+// reusing a key for a different object with 422. Failed requests don't keep
+// their key, so they can be retried with it.
+func (s *Server) idempotent(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		ttl := s.config().Server.Idempotency.TTL
+		if key == "" || ttl <= 0 {
+			handler(c)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must not be longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)})
+			return
+		}
+
+		// Authenticators other than the API key one only tell clients apart by principal
+		cacheKey := c.GetString(apiKeyContextKey) + "\x00" + storage.PrincipalFromContext(c.Request.Context()) + "\x00" + key
+		target := c.GetString(backendContextKey) + "\x00" + c.Request.URL.Path
+		existing, claimed := s.idempotency.begin(cacheKey, target)
+		if !claimed {
+			switch {
+			case existing.target != target:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s was already used for a different object", idempotencyKeyHeader)})
+			case existing.response == nil:
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A request with this %s is still in progress", idempotencyKeyHeader)})
+			default:
+				for name, values := range existing.response.header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.response.status, existing.response.header.Get("Content-Type"), existing.response.body)
+			}
+			return
+		}
+
+		// Release the key even if the handler panics
+		var response *idempotentResponse
+		defer func() { s.idempotency.finish(cacheKey, response, ttl) }()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		handler(c)
+
+		if status := recorder.Status(); status >= 200 && status < 300 {
+			header := make(http.Header)
+			for _, name := range []string{"Content-Type", "ETag"} {
+				if value := recorder.Header().Get(name); value != "" {
+					header.Set(name, value)
+				}
+			}
+			response = &idempotentResponse{status: status, header: header, body: recorder.body.Bytes()}
+		}
+	}
+}
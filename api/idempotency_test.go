@@ -0,0 +1,93 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotentRetryReturnsCachedResponse(t *testing.T) {
+	server := newTestServer(t, "")
+	headers := map[string]string{idempotencyKeyHeader: "upload-1"}
+
+	first := serve(server, http.MethodPost, "/upload/default/report.txt", strings.NewReader("first"), headers)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first upload = %d %s", first.Code, first.Body)
+	}
+
+	retry := serve(server, http.MethodPost, "/upload/default/report.txt", strings.NewReader("second"), headers)
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry isn't marked Idempotent-Replayed")
+	}
+	if retry.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Errorf("retry ETag = %s, want %s", retry.Header().Get("ETag"), first.Header().Get("ETag"))
+	}
+	if got := readObject(t, server, "report.txt"); got != "first" {
+		t.Errorf("stored %q, want the body of the first request", got)
+	}
+}
+
+func TestIdempotentConcurrentDuplicateConflicts(t *testing.T) {
+	server := newTestServer(t, "")
+	headers := map[string]string{idempotencyKeyHeader: "upload-1"}
+
+	// The first request keeps running until its body is complete
+	body, writer := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(server, http.MethodPost, "/upload/default/report.txt", body, headers)
+	}()
+	writer.Write([]byte("first"))
+
+	duplicate := serve(server, http.MethodPost, "/upload/default/report.txt", strings.NewReader("second"), headers)
+	if duplicate.Code != http.StatusConflict {
+		t.Errorf("duplicate while the first request runs = %d %s, want 409", duplicate.Code, duplicate.Body)
+	}
+
+	writer.Close()
+	select {
+	case first := <-done:
+		if first.Code != http.StatusOK {
+			t.Errorf("first upload = %d %s", first.Code, first.Body)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("first upload didn't finish")
+	}
+	if got := readObject(t, server, "report.txt"); got != "first" {
+		t.Errorf("stored %q, want the body of the first request", got)
+	}
+}
+
+func TestIdempotencyKeyReusedForAnotherObject(t *testing.T) {
+	server := newTestServer(t, "")
+	headers := map[string]string{idempotencyKeyHeader: "upload-1"}
+
+	if rec := serve(server, http.MethodPost, "/upload/default/a.txt", strings.NewReader("a"), headers); rec.Code != http.StatusOK {
+		t.Fatalf("first upload = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(server, http.MethodPost, "/upload/default/b.txt", strings.NewReader("b"), headers); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("upload of another object with the same key = %d %s, want 422", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "b.txt") {
+		t.Error("upload with a reused key was stored")
+	}
+}
+
+func TestIdempotencyKeyReleasedAfterFailure(t *testing.T) {
+	server := newTestServer(t, "")
+	headers := map[string]string{idempotencyKeyHeader: "upload-1"}
+
+	// The upload fails because the bucket doesn't exist
+	if rec := serve(server, http.MethodPost, "/upload/missing/report.txt", strings.NewReader("lost"), headers); rec.Code < 400 {
+		t.Fatalf("upload to a missing bucket = %d %s, want an error", rec.Code, rec.Body)
+	}
+	if rec := serve(server, http.MethodPost, "/upload/missing/report.txt", strings.NewReader("lost"), headers); rec.Header().Get("Idempotent-Replayed") != "" || rec.Code == http.StatusConflict {
+		t.Errorf("retry after a failure = %d %s, want the upload tried again", rec.Code, rec.Body)
+	}
+}
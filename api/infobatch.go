@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// infoBatchRequest is the body of a batch object info request
+type infoBatchRequest struct {
+	Objects []string `json:"objects"`
+}
+
+// infoBatchResult is the outcome for one requested object, with its info when
+// the lookup succeeded
+type infoBatchResult struct {
+	bulkResult
+	Info *storage.FileObject `json:"info,omitempty"`
+}
+
+// getObjectInfoBatch handles requests for the info of several objects at once.
+// Objects are looked up with a bounded pool of workers and the results keep
+// the order of the request; a failed lookup does not fail the others, but
+// is reflected in the status as for other bulk operations.
+func (s *Server) getObjectInfoBatch(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	var req infoBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid info batch request: %v", err)})
+		return
+	}
+	if len(req.Objects) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No objects requested"})
+		return
+	}
+	batchCfg := s.config().Server.InfoBatch
+	if len(req.Objects) > batchCfg.MaxObjects {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many objects: %d requested, at most %d allowed", len(req.Objects), batchCfg.MaxObjects)})
+		return
+	}
+
+	// Validate every path before any lookup
+	keys := make([]string, len(req.Objects))
+	for i, value := range req.Objects {
+		key, ok := objectKeyFrom(c, value)
+		if !ok {
+			return
+		}
+		keys[i] = key
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	results := make([]infoBatchResult, len(keys))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(batchCfg.Concurrency, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = objectInfoResult(ctx, store, bucket, req.Objects[i], keys[i])
+			}
+		}()
+	}
+	for i := range keys {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	statuses := make([]int, len(results))
+	for i, result := range results {
+		statuses[i] = result.Status
+	}
+	c.JSON(bulkStatus(statuses), gin.H{
+		"bucket":  bucket,
+		"objects": results,
+	})
+}
+
+// objectInfoResult looks up the info of one object of a batch
+func objectInfoResult(ctx context.Context, store storage.Storage, bucket, object, key string) infoBatchResult {
+	info, err := store.GetObjectInfo(ctx, bucket, key)
+	if err != nil {
+		return infoBatchResult{bulkResult: bulkFailure(ctx, object, err)}
+	}
+	return infoBatchResult{bulkResult: bulkResult{Object: object}, Info: info}
+}
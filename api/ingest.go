@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/config"
+)
+
+// errIngestTooLarge is returned once a fetched body grows past server.ingest.max_size
+var errIngestTooLarge = errors.New("source is larger than the ingest size limit")
+
+// ingestRequest is the body of POST /ingest/:bucket/*object
+type ingestRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// ingestURLRejection explains why server.ingest refuses to fetch u, or
+// returns "" when it may be fetched. Only the host name is compared, so the
+// allowlist should name hosts that can't be pointed at internal addresses.
+func ingestURLRejection(cfg config.IngestConfig, u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	schemeAllowed := false
+	for _, allowed := range cfg.AllowedSchemes {
+		if strings.EqualFold(allowed, scheme) {
+			schemeAllowed = true
+			break
+		}
+	}
+	if !schemeAllowed {
+		return fmt.Sprintf("URL scheme %q is not allowed", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "URL has no host"
+	}
+	for _, allowed := range cfg.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if wildcard, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+wildcard) {
+				return ""
+			}
+		} else if host == allowed {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Host %s is not allowed", host)
+}
+
+// ingestClient returns an HTTP client that follows at most max_redirects
+// redirects, each to a URL the allowlist accepts
+func ingestClient(cfg config.IngestConfig) *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			if reason := ingestURLRejection(cfg, req.URL); reason != "" {
+				return fmt.Errorf("redirect to %s refused: %s", req.URL.Redacted(), reason)
+			}
+			return nil
+		},
+	}
+}
+
+// sizeLimitReader fails with errIngestTooLarge instead of returning more than
+// limit bytes; a limit of 0 is unlimited
+type sizeLimitReader struct {
+	reader   io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+// Read reads from the underlying reader until the limit is passed
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.limit > 0 && r.read > r.limit {
+		r.exceeded = true
+		return 0, errIngestTooLarge
+	}
+	return n, err
+}
+
+// ingestObject handles requests to store the content of a remote URL as an
+// object, streaming it from the source without buffering it in memory
+func (s *Server) ingestObject(c *gin.Context) {
+	cfg := s.config().Server.Ingest
+	if len(cfg.AllowedHosts) == 0 {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Ingesting from URLs is not enabled (server.ingest.allowed_hosts is empty)"})
+		return
+	}
+
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	if bucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket is required: none given in the path and no default bucket is configured"})
+		return
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+
+	var req ingestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ingest request: %v", err)})
+		return
+	}
+	source, err := url.Parse(req.URL)
+	if err != nil || !source.IsAbs() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL"})
+		return
+	}
+	if reason := ingestURLRejection(cfg, source); reason != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": reason})
+		return
+	}
+
+	// The operation timeout covers both the fetch and the full upload stream
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	fetch, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid url: %v", err)})
+		return
+	}
+	resp, err := ingestClient(cfg).Do(fetch)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("Failed to fetch source: %v", err)})
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Source returned %s", resp.Status), "upstream_status": resp.StatusCode})
+		return
+	}
+	if cfg.MaxSize > 0 && resp.ContentLength > cfg.MaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Source is %d bytes, more than the limit of %d", resp.ContentLength, cfg.MaxSize)})
+		return
+	}
+
+	// Keep the source's content type, detecting it like uploads when it is generic
+	contentType := resp.Header.Get("Content-Type")
+	reader := bufio.NewReader(resp.Body)
+	if s.config().Server.DetectContentType && (contentType == "" || contentType == "application/octet-stream") {
+		contentType = detectContentType(object, reader)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Refuse objects server.upload doesn't allow before anything is written
+	if reason := s.keyRejection(c, object); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+	if reason := s.uploadRejection(object, contentType); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+	if reason := s.sniffRejection(reader); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+
+	// Create the bucket on first upload if configured to
+	if s.backendConfig(c).AutoCreateBucket {
+		if err := s.ensureBucket(ctx, c, bucket); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create bucket: %v", err)})
+			return
+		}
+	}
+	if err := store.EnsurePathExists(ctx, bucket, object); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to ensure path exists: %v", err)})
+		return
+	}
+
+	// Stream the body to the virus scanner as it is stored
+	scan, err := s.startVirusScan()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("Virus scanner unavailable: %v", err)})
+		return
+	}
+	limited := &sizeLimitReader{reader: reader, limit: cfg.MaxSize}
+	var upload io.Reader = limited
+	if scan != nil {
+		defer scan.Close()
+		upload = io.TeeReader(limited, scan)
+	}
+
+	// ContentLength is -1 when the source doesn't send one, which backends
+	// treat as an unknown length
+	body := &contextReader{ctx: ctx, reader: upload}
+	result, err := store.Upload(ctx, bucket, object, body, resp.ContentLength, contentType, objectHeaders(resp.Header))
+	if limited.exceeded {
+		if err == nil {
+			// The backend stored the truncated body before reporting the read error
+			if err := discardUpload(ctx, store, bucket, object, result); err != nil {
+				c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to remove oversized object: %v", err)})
+				return
+			}
+		}
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Source is more than the limit of %d bytes", cfg.MaxSize)})
+		return
+	}
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to store source: %v", err)})
+		return
+	}
+
+	// Remove the object again when the scanner flags it
+	if scan != nil && s.rejectScannedUpload(c, ctx, scan, store, bucket, object, result) {
+		return
+	}
+
+	info, err := store.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
+		return
+	}
+	response := gin.H{
+		"message":       "Source ingested successfully",
+		"bucket":        bucket,
+		"object":        object,
+		"source":        source.Redacted(),
+		"size":          info.Size,
+		"content_type":  info.ContentType,
+		"etag":          info.ETag,
+		"last_modified": info.LastModified,
+	}
+	if info.ETag != "" {
+		c.Header("ETag", quoteETag(info.ETag))
+	}
+	if info.VersionID != "" {
+		response["version_id"] = info.VersionID
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLimiter caps the number of requests processed at once. The slot is
+// held until the handler returns, so a streaming download counts against the
+// limit for its full duration.
+type requestLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+}
+
+// newRequestLimiter returns a limiter for max concurrent requests, or nil when
+// max is 0 and requests are not limited
+func newRequestLimiter(max int) *requestLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &requestLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire takes a slot, waiting up to wait for one to free up. It gives up
+// early if the client goes away.
+func (l *requestLimiter) acquire(c *gin.Context, wait time.Duration) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+func (l *requestLimiter) release() {
+	<-l.slots
+}
+
+// LimitMiddleware rejects requests with 503 once server.max_concurrent_requests
+// are being processed and no slot frees up within server.queue_timeout
+func (s *Server) LimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.limiter == nil {
+			c.Next()
+			return
+		}
+
+		if !s.limiter.acquire(c, s.config().Server.QueueTimeout) {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is busy, too many concurrent requests"})
+			c.Abort()
+			return
+		}
+		s.limiter.inFlight.Add(1)
+		defer func() {
+			s.limiter.inFlight.Add(-1)
+			s.limiter.release()
+		}()
+
+		c.Next()
+	}
+}
+
+// inFlightRequests returns the number of requests holding a slot, or -1 when
+// requests are not limited
+func (s *Server) inFlightRequests() int64 {
+	if s.limiter == nil {
+		return -1
+	}
+	return s.limiter.inFlight.Load()
+}
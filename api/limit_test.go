@@ -0,0 +1,70 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestBeyondLimitIsRejected(t *testing.T) {
+	const limit = 2
+	server := newTestServer(t, "server:\n  max_concurrent_requests: 2\n")
+
+	// Uploads whose bodies never end hold their slots
+	writers := make([]*io.PipeWriter, limit)
+	done := make(chan int, limit)
+	for i := range writers {
+		reader, writer := io.Pipe()
+		writers[i] = writer
+		go func() {
+			done <- serve(server, http.MethodPost, "/upload/default/held.txt", reader, nil).Code
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.inFlightRequests() < limit {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests in flight, want %d", server.inFlightRequests(), limit)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	started := time.Now()
+	rec := serve(server, http.MethodGet, "/list/default", nil, nil)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request %d = %d %s, want 503 with Retry-After", limit+1, rec.Code, rec.Body)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("request %d was rejected after %v, want promptly", limit+1, elapsed)
+	}
+
+	for _, writer := range writers {
+		writer.Close()
+	}
+	for range writers {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("held upload = %d, want 200", code)
+		}
+	}
+	if rec := serve(server, http.MethodGet, "/list/default", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("request after the others finished = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestInfoBatchRejectsTooManyObjects(t *testing.T) {
+	server := newTestServer(t, "server:\n  info_batch:\n    max_objects: 3\n")
+	for _, key := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		putObject(t, server, key, key)
+	}
+
+	for body, want := range map[string]int{
+		`{"objects": ["a.txt", "b.txt", "c.txt"]}`:          http.StatusOK,
+		`{"objects": ["a.txt", "b.txt", "c.txt", "d.txt"]}`: http.StatusBadRequest,
+	} {
+		rec := serve(server, http.MethodPost, "/info-batch/default", strings.NewReader(body), map[string]string{"Content-Type": "application/json"})
+		if rec.Code != want {
+			t.Errorf("POST /info-batch %s = %d %s, want %d", body, rec.Code, rec.Body, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"github.com/example/file-service/storage"
+)
+
+// fillListMetadata looks up the user metadata of every listed object with a
+// bounded pool of workers, for backends that can't include it in the listing
+// itself. This costs one request per object, which is why ?include=metadata
+// must be asked for. An object that can't be looked up, e.g. because it was
+// deleted since it was listed, keeps an empty metadata map.
+func fillListMetadata(ctx context.Context, store storage.Storage, bucket string, objects []storage.FileObject, concurrency int) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(objects)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				info, err := store.GetObjectInfo(ctx, bucket, objects[i].Name)
+				if err != nil || info.Metadata == nil {
+					objects[i].Metadata = map[string]string{}
+					continue
+				}
+				objects[i].Metadata = info.Metadata
+			}
+		}()
+	}
+	for i := range objects {
+		if objects[i].IsDir {
+			objects[i].Metadata = map[string]string{}
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// dropListMetadata clears the user metadata some backends list anyway, so
+// listings only carry it when ?include=metadata asked for it
+func dropListMetadata(objects []storage.FileObject) {
+	for i := range objects {
+		objects[i].Metadata = nil
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/example/file-service/storage"
+)
+
+// objectSorts maps the ?sort= values of the list endpoint to their comparisons
+var objectSorts = map[string]func(a, b storage.FileObject) int{
+	"name": func(a, b storage.FileObject) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"size": func(a, b storage.FileObject) int {
+		return cmp.Compare(a.Size, b.Size)
+	},
+	// Objects without a modification time have the zero time and sort first
+	"modified": func(a, b storage.FileObject) int {
+		return a.LastModified.Compare(b.LastModified)
+	},
+}
+
+// sortObjects sorts objects in place by one of objectSorts, breaking ties by
+// name so the order is stable across requests. It returns false for an
+// unknown sort key or order.
+func sortObjects(objects []storage.FileObject, key, order string) bool {
+	compare, ok := objectSorts[key]
+	if !ok {
+		return false
+	}
+	var direction int
+	switch order {
+	case "", "asc":
+		direction = 1
+	case "desc":
+		direction = -1
+	default:
+		return false
+	}
+
+	slices.SortStableFunc(objects, func(a, b storage.FileObject) int {
+		if c := compare(a, b); c != 0 {
+			return c * direction
+		}
+		return strings.Compare(a.Name, b.Name) * direction
+	})
+	return true
+}
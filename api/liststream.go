@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// streamFlushInterval is how many listed objects are written between flushes,
+// matching the page size of the S3-style backends
+const streamFlushInterval = 1000
+
+// streamObjects writes a listing as newline-delimited JSON, one object per
+// line, while the backend pages through it. A failure before the first object
+// is answered with an error status as usual; once the stream has started it
+// is reported as a final {"error": ...} line instead. User metadata is only
+// written with includeMetadata, for backends that list it.
+func streamObjects(c *gin.Context, ctx context.Context, store storage.Storage, bucket, prefix, glob string, includeMetadata bool) {
+	enc := json.NewEncoder(c.Writer)
+	count := 0
+	err := store.Walk(ctx, bucket, prefix, func(obj storage.FileObject) error {
+		if glob != "" && !matchGlob(glob, obj.Name) {
+			return nil
+		}
+		if count == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		if !includeMetadata {
+			obj.Metadata = nil
+		}
+		if err := enc.Encode(obj); err != nil {
+			return err
+		}
+		count++
+		// The first object goes out right away, later ones in batches
+		if count == 1 || count%streamFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if count == 0 {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+			return
+		}
+		// The status line has been sent, so the client learns of the failure from the last line
+		_ = enc.Encode(gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		return
+	}
+	if count == 0 {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetadataCacheWithLowercaseKeys(t *testing.T) {
+	server := newTestServer(t, "  normalize_key_case: lower\nserver:\n  metadata_cache:\n    size: 100\n    ttl: 1h\n")
+	putObject(t, server, "a.txt", "old")
+
+	// Cache the info under a mixed-case spelling of the key
+	if rec := serve(server, http.MethodHead, "/info/default/A.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("HEAD /info = %d", rec.Code)
+	}
+
+	if rec := serve(server, http.MethodPost, "/upload/default/a.txt", strings.NewReader("new content"), nil); rec.Code != http.StatusOK {
+		t.Fatalf("POST /upload = %d %s", rec.Code, rec.Body)
+	}
+
+	rec := serve(server, http.MethodHead, "/info/default/A.txt", nil, nil)
+	if got := rec.Header().Get("Content-Length"); got != "11" {
+		t.Errorf("HEAD /info after overwriting = Content-Length %s, want 11", got)
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// maxMigrateConcurrency bounds the concurrency a migration may ask for
+const maxMigrateConcurrency = 64
+
+// migrateRequest is the body of POST /admin/migrate. Buckets default to the
+// default bucket of their backend.
+type migrateRequest struct {
+	SrcBackend  string `json:"src_backend" binding:"required"`
+	SrcBucket   string `json:"src_bucket"`
+	SrcPrefix   string `json:"src_prefix"`
+	DstBackend  string `json:"dst_backend" binding:"required"`
+	DstBucket   string `json:"dst_bucket"`
+	DstPrefix   string `json:"dst_prefix"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// Outcomes of migrating one object
+const (
+	migrateCopied  = "copied"
+	migrateSkipped = "skipped"
+	migrateFailed  = "failed"
+)
+
+// migrateResult is the progress line written for each migrated object
+type migrateResult struct {
+	bulkResult
+	Destination string `json:"destination"`
+	Action      string `json:"action"`
+	Size        int64  `json:"size"`
+}
+
+// migrate handles requests to copy every object under a prefix of one
+// backend to another backend, streaming each object through the service. A
+// line of newline-delimited JSON reports the outcome of each object as it
+// finishes and a final line sums them up. Objects already at the destination
+// with the same content are skipped, so an interrupted migration is resumed
+// by sending the same request again.
+func (s *Server) migrate(c *gin.Context) {
+	var req migrateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid migrate request: %v", err)})
+		return
+	}
+	src, ok := s.storages[req.SrcBackend]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown storage backend %q", req.SrcBackend)})
+		return
+	}
+	dst, ok := s.storages[req.DstBackend]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown storage backend %q", req.DstBackend)})
+		return
+	}
+	if req.SrcBucket == "" {
+		req.SrcBucket = s.backendBucket(req.SrcBackend)
+	}
+	if req.DstBucket == "" {
+		req.DstBucket = s.backendBucket(req.DstBackend)
+	}
+	if req.SrcBucket == "" || req.DstBucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "src_bucket and dst_bucket are required for backends without a default bucket"})
+		return
+	}
+	// Copying a prefix into itself, or onto a prefix that contains it, would
+	// migrate the objects it just wrote or overwrite the ones it is reading
+	if req.SrcBackend == req.DstBackend && req.SrcBucket == req.DstBucket &&
+		(strings.HasPrefix(req.DstPrefix, req.SrcPrefix) || strings.HasPrefix(req.SrcPrefix, req.DstPrefix)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("src_prefix %q and dst_prefix %q overlap in the same bucket", req.SrcPrefix, req.DstPrefix)})
+		return
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = s.config().Server.InfoBatch.Concurrency
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxMigrateConcurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("concurrency must be between 1 and %d", maxMigrateConcurrency)})
+		return
+	}
+
+	// The migration runs as long as it takes; the operation timeout applies
+	// to each object instead
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	objects := make(chan storage.FileObject)
+	results := make(chan migrateResult)
+	var wg sync.WaitGroup
+	for range req.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range objects {
+				results <- s.migrateObject(ctx, req, src, dst, obj)
+			}
+		}()
+	}
+	var walkErr error
+	go func() {
+		walkErr = src.Walk(ctx, req.SrcBucket, req.SrcPrefix, func(obj storage.FileObject) error {
+			select {
+			case objects <- obj:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(objects)
+		wg.Wait()
+		close(results)
+	}()
+
+	enc := json.NewEncoder(c.Writer)
+	counts := map[string]int{}
+	var copiedBytes int64
+	for result := range results {
+		if len(counts) == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		counts[result.Action]++
+		if result.Action == migrateCopied {
+			copiedBytes += result.Size
+		}
+		_ = enc.Encode(result)
+		c.Writer.Flush()
+	}
+
+	// Without any progress line the failure can still be answered with its status
+	if walkErr != nil && len(counts) == 0 {
+		c.JSON(storageErrorStatus(ctx, walkErr), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", walkErr)})
+		return
+	}
+	if len(counts) == 0 {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+	}
+	summary := gin.H{
+		"done":    walkErr == nil,
+		"copied":  counts[migrateCopied],
+		"skipped": counts[migrateSkipped],
+		"failed":  counts[migrateFailed],
+		"bytes":   copiedBytes,
+	}
+	if walkErr != nil {
+		summary["error"] = fmt.Sprintf("Failed to list objects: %v", walkErr)
+	}
+	_ = enc.Encode(summary)
+}
+
+// backendBucket returns the default bucket of the named backend
+func (s *Server) backendBucket(name string) string {
+	cfg := s.config()
+	if bucket := cfg.Backends()[name].Bucket; bucket != "" {
+		return bucket
+	}
+	return cfg.Storage.Bucket
+}
+
+// migrateObject copies one object to the destination of a migration, with
+// its content type, headers and user metadata, unless it is already there.
+// The copy is bounded by the longer operation timeout of the two backends.
+func (s *Server) migrateObject(ctx context.Context, req migrateRequest, src, dst storage.Storage, obj storage.FileObject) migrateResult {
+	timeout := max(s.operationTimeout(req.SrcBackend), s.operationTimeout(req.DstBackend))
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result := migrateResult{
+		bulkResult:  bulkResult{Object: obj.Name},
+		Destination: req.DstPrefix + strings.TrimPrefix(obj.Name, req.SrcPrefix),
+		Action:      migrateCopied,
+	}
+	fail := func(err error) migrateResult {
+		result.bulkResult = bulkFailure(ctx, obj.Name, err)
+		result.Action = migrateFailed
+		return result
+	}
+
+	if obj.IsDir {
+		if _, err := dst.GetObjectInfo(ctx, req.DstBucket, result.Destination); err == nil {
+			result.Action = migrateSkipped
+			return result
+		}
+		// Markers carry user metadata too, which the listing leaves out
+		marker, err := src.GetObjectInfo(ctx, req.SrcBucket, obj.Name)
+		if err != nil {
+			return fail(err)
+		}
+		if err := dst.CreateDirectory(ctx, req.DstBucket, result.Destination, marker.Metadata); err != nil {
+			return fail(err)
+		}
+		return result
+	}
+
+	info, err := src.GetObjectInfo(ctx, req.SrcBucket, obj.Name)
+	if err != nil {
+		return fail(err)
+	}
+	result.Size = info.Size
+	existing, err := dst.GetObjectInfo(ctx, req.DstBucket, result.Destination)
+	switch {
+	case err == nil && sameContent(info, existing):
+		result.Action = migrateSkipped
+		return result
+	case err != nil && !storage.IsNotFound(err):
+		return fail(err)
+	}
+
+	reader, err := src.Download(ctx, req.SrcBucket, obj.Name)
+	if err != nil {
+		return fail(err)
+	}
+	defer reader.Close()
+	if _, err := dst.Upload(ctx, req.DstBucket, result.Destination, &contextReader{ctx: ctx, reader: reader}, info.Size, info.ContentType, info.Headers); err != nil {
+		return fail(err)
+	}
+	if len(info.Metadata) > 0 {
+		if err := dst.UpdateMetadata(ctx, req.DstBucket, result.Destination, info.Metadata, ""); err != nil {
+			return fail(fmt.Errorf("copied, but the metadata was not: %w", err))
+		}
+	}
+	return result
+}
+
+// sameContent reports whether dst holds the content of src: the sizes match
+// and so do the MD5 digests when both backends report one. ETags aren't
+// compared since providers compute them differently.
+func sameContent(src, dst *storage.FileObject) bool {
+	if src.Size != dst.Size {
+		return false
+	}
+	srcMD5, dstMD5 := src.Checksums[storage.ChecksumMD5], dst.Checksums[storage.ChecksumMD5]
+	if srcMD5 != "" && dstMD5 != "" {
+		return strings.EqualFold(srcMD5, dstMD5)
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/example/file-service/storage"
+)
+
+// migrateConfig configures two in-memory backends and the admin key
+const migrateConfig = `storages:
+  old:
+    type: memory
+    bucket: old
+  new:
+    type: memory
+    bucket: new
+default_storage: old
+auth:
+  admin_key: secret
+`
+
+// migrateSummary is the last line of a POST /admin/migrate response
+type migrateSummary struct {
+	Done    bool  `json:"done"`
+	Copied  int   `json:"copied"`
+	Skipped int   `json:"skipped"`
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// runMigration sends body to POST /admin/migrate and returns its summary
+func runMigration(t *testing.T, server *Server, body string) migrateSummary {
+	t.Helper()
+	rec := serve(server, http.MethodPost, "/admin/migrate", strings.NewReader(body), map[string]string{
+		"X-Admin-Key":  "secret",
+		"Content-Type": "application/json",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/migrate = %d %s", rec.Code, rec.Body)
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var summary migrateSummary
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+		t.Fatalf("summary line %q: %v", lines[len(lines)-1], err)
+	}
+	return summary
+}
+
+func TestMigrateTreeBetweenBackends(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t, migrateConfig)
+	src, dst := server.storages["old"], server.storages["new"]
+
+	for key, content := range map[string]string{"reports/q1.txt": "first quarter", "reports/2024/q4.txt": "last quarter", "other.txt": "left behind"} {
+		if _, err := src.Upload(ctx, "old", key, strings.NewReader(content), int64(len(content)), "text/plain", storage.ObjectHeaders{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.UpdateMetadata(ctx, "old", "reports/q1.txt", map[string]string{"owner": "finance"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	const request = `{"src_backend": "old", "src_prefix": "reports/", "dst_backend": "new", "dst_prefix": "archive/"}`
+	summary := runMigration(t, server, request)
+	if !summary.Done || summary.Copied != 2 || summary.Failed != 0 || summary.Bytes != int64(len("first quarter")+len("last quarter")) {
+		t.Fatalf("summary = %+v, want 2 objects copied", summary)
+	}
+
+	for key, want := range map[string]string{"archive/q1.txt": "first quarter", "archive/2024/q4.txt": "last quarter"} {
+		reader, err := dst.Download(ctx, "new", key)
+		if err != nil {
+			t.Fatalf("Download %s: %v", key, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || string(content) != want {
+			t.Errorf("Download %s = %q, %v, want %q", key, content, err, want)
+		}
+	}
+	info, err := dst.GetObjectInfo(ctx, "new", "archive/q1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "text/plain" || info.Metadata["owner"] != "finance" {
+		t.Errorf("migrated object has content type %q and metadata %v", info.ContentType, info.Metadata)
+	}
+	if _, err := dst.GetObjectInfo(ctx, "new", "archive/other.txt"); !storage.IsNotFound(err) {
+		t.Errorf("object outside src_prefix was migrated: %v", err)
+	}
+	if _, err := src.GetObjectInfo(ctx, "old", "reports/q1.txt"); err != nil {
+		t.Errorf("source object removed: %v", err)
+	}
+
+	// Sending the request again resumes it, with nothing left to copy
+	summary = runMigration(t, server, request)
+	if !summary.Done || summary.Copied != 0 || summary.Skipped != 2 {
+		t.Errorf("repeated migration = %+v, want both objects skipped", summary)
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// movePrefixRequest is the body of POST /move-prefix/:bucket
+type movePrefixRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// movePrefixResult is the outcome of moving one object to its destination
+type movePrefixResult struct {
+	bulkResult
+	Destination string `json:"destination"`
+}
+
+// movePrefix handles requests to move every object under one prefix to
+// another with server-side copies. Objects are moved by a bounded pool of
+// workers and a failed move does not stop the others, but is reflected in
+// the status as for other bulk operations.
+func (s *Server) movePrefix(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	var req movePrefixRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid move request: %v", err)})
+		return
+	}
+	from, ok := movePrefixParam(c, req.From)
+	if !ok {
+		return
+	}
+	to, ok := movePrefixParam(c, req.To)
+	if !ok {
+		return
+	}
+
+	// Moving a prefix into itself, or onto a prefix that contains it, would
+	// move the objects it just wrote or overwrite the ones it is reading
+	if strings.HasPrefix(to, from) || strings.HasPrefix(from, to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("from %q and to %q overlap", from, to)})
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	objects, err := store.List(ctx, bucket, from)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		return
+	}
+
+	results := make([]movePrefixResult, len(objects))
+	for i, obj := range objects {
+		results[i] = movePrefixResult{bulkResult: bulkResult{Object: obj.Name}, Destination: to + strings.TrimPrefix(obj.Name, from)}
+		if reason := s.keyRejection(c, results[i].Destination); reason != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cannot move %s to %s: %s", obj.Name, results[i].Destination, reason)})
+			return
+		}
+	}
+
+	if c.Query("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"bucket":     bucket,
+			"from":       from,
+			"to":         to,
+			"dry_run":    true,
+			"would_move": results,
+			"count":      len(results),
+		})
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(s.config().Server.InfoBatch.Concurrency, len(objects)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := moveObject(ctx, store, bucket, objects[i], results[i].Destination); err != nil {
+					results[i].bulkResult = bulkFailure(ctx, objects[i].Name, err)
+				}
+			}
+		}()
+	}
+	for i := range objects {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	statuses := make([]int, len(results))
+	for i, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+		statuses[i] = result.Status
+	}
+	c.JSON(bulkStatus(statuses), gin.H{
+		"bucket":  bucket,
+		"from":    from,
+		"to":      to,
+		"moved":   len(results) - failed,
+		"failed":  failed,
+		"objects": results,
+	})
+}
+
+// movePrefixParam normalizes a prefix of a move request so that it names a
+// whole directory, answering 400 Bad Request for an invalid or empty one
+func movePrefixParam(c *gin.Context, value string) (string, bool) {
+	prefix, ok := objectKeyFrom(c, value)
+	if !ok {
+		return "", false
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must not name the bucket root"})
+		return "", false
+	}
+	return prefix + "/", true
+}
+
+// moveObject copies obj to dst on the server and deletes it, keeping the
+// source when it changed during the copy
+func moveObject(ctx context.Context, store storage.Storage, bucket string, obj storage.FileObject, dst string) error {
+	if err := store.Copy(ctx, bucket, obj.Name, dst, nil); err != nil {
+		return fmt.Errorf("copy failed: %w", err)
+	}
+	if obj.ETag == "" {
+		return store.Delete(ctx, bucket, obj.Name)
+	}
+	if err := store.DeleteIfMatch(ctx, bucket, obj.Name, obj.ETag); err != nil {
+		return fmt.Errorf("copied, but the source was not deleted: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMovePrefixNestedTree(t *testing.T) {
+	server := newTestServer(t, "")
+	tree := map[string]string{"old/a.txt": "a", "old/sub/b.txt": "b", "old/sub/deeper/c.txt": "c"}
+	for key, content := range tree {
+		putObject(t, server, key, content)
+	}
+	putObject(t, server, "older/kept.txt", "kept")
+
+	// A dry run only reports the moves
+	rec := serve(server, http.MethodPost, "/move-prefix/default?dry_run=true", strings.NewReader(`{"from":"old","to":"new/"}`), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"new/sub/deeper/c.txt"`) {
+		t.Fatalf("dry run = %d %s", rec.Code, rec.Body)
+	}
+	if !objectExists(t, server, "old/a.txt") || objectExists(t, server, "new/a.txt") {
+		t.Fatal("dry run moved objects")
+	}
+
+	rec = serve(server, http.MethodPost, "/move-prefix/default", strings.NewReader(`{"from":"old","to":"new/"}`), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /move-prefix = %d %s", rec.Code, rec.Body)
+	}
+	for key, want := range tree {
+		if objectExists(t, server, key) {
+			t.Errorf("%s is still in place", key)
+		}
+		dst := "new/" + strings.TrimPrefix(key, "old/")
+		if got := readObject(t, server, dst); got != want {
+			t.Errorf("%s = %q, want %q", dst, got, want)
+		}
+	}
+	if !objectExists(t, server, "older/kept.txt") {
+		t.Error("object under a sibling prefix was moved")
+	}
+}
+
+func TestMovePrefixRejectsOverlap(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "docs/a.txt", "a")
+
+	for _, body := range []string{
+		`{"from":"docs","to":"docs/archive"}`,
+		`{"from":"docs/","to":"docs"}`,
+		`{"from":"docs/sub","to":"docs"}`,
+		`{"from":"docs","to":"/"}`,
+	} {
+		if rec := serve(server, http.MethodPost, "/move-prefix/default", strings.NewReader(body), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /move-prefix %s = %d %s, want 400", body, rec.Code, rec.Body)
+		}
+	}
+	if got := readObject(t, server, "docs/a.txt"); got != "a" {
+		t.Errorf("docs/a.txt = %q after rejected moves", got)
+	}
+}
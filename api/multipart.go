@@ -0,0 +1,326 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// maxPartNumber is the highest part number accepted, matching the S3 limit
+const maxPartNumber = 10000
+
+// initOrCompleteUpload dispatches POST /uploads: with complete=true it
+// finishes the given upload, otherwise it starts a new one
+func (s *Server) initOrCompleteUpload(c *gin.Context) {
+	if c.Query("complete") == "true" {
+		s.completeUpload(c)
+		return
+	}
+	s.initUpload(c)
+}
+
+// initUpload starts a resumable upload and returns its upload ID
+func (s *Server) initUpload(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	uploadID, contentType, ok := s.startUpload(c, ctx, store, bucket, object)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":       bucket,
+		"object":       object,
+		"upload_id":    uploadID,
+		"content_type": contentType,
+	})
+}
+
+// startUpload starts a multipart upload of object with the request's content
+// type, answering the client and returning false on failure
+func (s *Server) startUpload(c *gin.Context, ctx context.Context, store storage.MultipartStorage, bucket, object string) (string, string, bool) {
+	// The body isn't sent yet, so only the extension can be used for detection
+	contentType := c.GetHeader("Content-Type")
+	if s.config().Server.DetectContentType && (contentType == "" || contentType == "application/octet-stream") {
+		contentType = mime.TypeByExtension(path.Ext(object))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if reason := s.keyRejection(c, object); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return "", "", false
+	}
+	if reason := s.uploadRejection(object, contentType); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return "", "", false
+	}
+
+	// Create the bucket on first upload if configured to
+	if s.backendConfig(c).AutoCreateBucket {
+		if err := s.ensureBucket(ctx, c, bucket); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create bucket: %v", err)})
+			return "", "", false
+		}
+	}
+
+	// Ensure path exists
+	if err := s.storageFor(c).EnsurePathExists(ctx, bucket, object); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to ensure path exists: %v", err)})
+		return "", "", false
+	}
+
+	uploadID, err := store.InitMultipart(ctx, bucket, object, contentType, objectHeaders(c.Request.Header))
+	if err != nil {
+		multipartError(c, ctx, err, "start upload")
+		return "", "", false
+	}
+	return uploadID, contentType, true
+}
+
+// uploadPart handles PATCH requests carrying one part of a resumable upload
+func (s *Server) uploadPart(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	uploadID := c.Query("uploadId")
+	if uploadID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uploadId is required"})
+		return
+	}
+	partNumber, err := strconv.Atoi(c.Query("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid partNumber, expected 1 to %d", maxPartNumber)})
+		return
+	}
+
+	// Backends need the part size up front
+	if c.Request.ContentLength < 0 {
+		c.JSON(http.StatusLengthRequired, gin.H{"error": "Content-Length is required for upload parts"})
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	body := &contextReader{ctx: ctx, reader: c.Request.Body}
+	part, err := store.UploadPart(ctx, bucket, object, uploadID, partNumber, body, c.Request.ContentLength)
+	if err != nil {
+		multipartError(c, ctx, err, "upload part")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload_id":   uploadID,
+		"part_number": part.Number,
+		"etag":        part.ETag,
+		"size":        part.Size,
+	})
+}
+
+// completeUpload assembles the object from every part uploaded so far
+func (s *Server) completeUpload(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	uploadID := c.Query("uploadId")
+	if uploadID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uploadId is required"})
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if err := store.CompleteMultipart(ctx, bucket, object, uploadID); err != nil {
+		multipartError(c, ctx, err, "complete upload")
+		return
+	}
+	if s.rejectScannedObject(c, ctx, s.storageFor(c), bucket, object) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "File uploaded successfully",
+		"bucket":    bucket,
+		"object":    object,
+		"upload_id": uploadID,
+	})
+}
+
+// listUploads lists the parts of an upload when uploadId is given, so a client
+// knows where to resume, and otherwise the uploads in progress under the path
+func (s *Server) listUploads(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if uploadID := c.Query("uploadId"); uploadID != "" {
+		parts, err := store.ListParts(ctx, bucket, object, uploadID)
+		if err != nil {
+			multipartError(c, ctx, err, "list parts")
+			return
+		}
+
+		result := make([]gin.H, 0, len(parts))
+		for _, part := range parts {
+			result = append(result, gin.H{"part_number": part.Number, "etag": part.ETag, "size": part.Size})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"bucket":    bucket,
+			"object":    object,
+			"upload_id": uploadID,
+			"parts":     result,
+		})
+		return
+	}
+
+	uploads, err := store.ListMultipartUploads(ctx, bucket, object)
+	if err != nil {
+		multipartError(c, ctx, err, "list uploads")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":  bucket,
+		"prefix":  object,
+		"count":   len(uploads),
+		"uploads": uploadsResponse(uploads),
+	})
+}
+
+// abortUploads aborts the upload given by uploadId, or with older_than every
+// upload under the path that was started longer ago than that duration
+func (s *Server) abortUploads(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if uploadID := c.Query("uploadId"); uploadID != "" {
+		if err := store.AbortMultipart(ctx, bucket, object, uploadID); err != nil {
+			multipartError(c, ctx, err, "abort upload")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Upload aborted successfully",
+			"bucket":    bucket,
+			"object":    object,
+			"upload_id": uploadID,
+		})
+		return
+	}
+
+	olderThan, err := time.ParseDuration(c.Query("older_than"))
+	if err != nil || olderThan < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either uploadId or older_than (e.g. 24h) is required"})
+		return
+	}
+
+	uploads, err := store.ListMultipartUploads(ctx, bucket, object)
+	if err != nil {
+		multipartError(c, ctx, err, "list uploads")
+		return
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	aborted := make([]storage.MultipartUpload, 0)
+	var errs []string
+	for _, upload := range uploads {
+		if upload.Initiated.After(cutoff) {
+			continue
+		}
+		if err := store.AbortMultipart(ctx, bucket, upload.Object, upload.UploadID); err != nil {
+			errs = append(errs, fmt.Sprintf("Failed to abort upload %s of %s: %v", upload.UploadID, upload.Object, err))
+			continue
+		}
+		aborted = append(aborted, upload)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bucket":  bucket,
+		"prefix":  object,
+		"count":   len(aborted),
+		"aborted": uploadsResponse(aborted),
+		"errors":  errs,
+	})
+}
+
+// multipartTarget resolves the bucket, object and multipart-capable backend of
+// a resumable upload request, answering the client and returning false on failure
+func (s *Server) multipartTarget(c *gin.Context) (storage.MultipartStorage, string, string, bool) {
+	store, ok := s.storageFor(c).(storage.MultipartStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Resumable uploads are not supported by this storage backend"})
+		return nil, "", "", false
+	}
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	if bucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket is required: none given in the path and no default bucket is configured"})
+		return nil, "", "", false
+	}
+
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return nil, "", "", false
+	}
+	return store, bucket, object, true
+}
+
+// multipartError answers a failed multipart call, mapping unknown uploads to
+// 404 and operations the backend lacks to 501
+func multipartError(c *gin.Context, ctx context.Context, err error, action string) {
+	status := storageErrorStatus(ctx, err)
+	switch {
+	case storage.IsNotFound(err):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrNotSupported):
+		status = http.StatusNotImplemented
+	}
+	c.JSON(status, gin.H{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// uploadsResponse renders multipart uploads for a JSON response
+func uploadsResponse(uploads []storage.MultipartUpload) []gin.H {
+	result := make([]gin.H, 0, len(uploads))
+	for _, upload := range uploads {
+		entry := gin.H{"object": upload.Object, "upload_id": upload.UploadID}
+		if !upload.Initiated.IsZero() {
+			entry["initiated"] = upload.Initiated.UTC().Format(time.RFC3339)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
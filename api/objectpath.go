@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// errParentSegment is returned for object paths that try to escape with ".."
+var errParentSegment = errors.New(`path must not contain ".." segments`)
+
+// normalizeObjectKey turns a request path into a deterministic object key. The
+// leading slash Gin adds to wildcard parameters and any redundant slashes are
+// dropped, "." segments are removed and a trailing slash, which marks a
+// directory, is kept. Percent-encoded slashes have already been decoded by
+// the router, so "a%2Fb" and "a/b" name the same object.
+func normalizeObjectKey(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	kept := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return "", errParentSegment
+		}
+		kept = append(kept, segment)
+	}
+
+	normalized := strings.Join(kept, "/")
+	if normalized != "" && strings.HasSuffix(key, "/") {
+		normalized += "/"
+	}
+	return normalized, nil
+}
+
+// objectParam reads a path parameter as a normalized object key, answering
+// 400 Bad Request and returning false if the path is invalid
+func objectParam(c *gin.Context, name string) (string, bool) {
+	return objectKeyFrom(c, c.Param(name))
+}
+
+// objectKeyFrom normalizes a client-supplied object key or prefix, answering
+// 400 Bad Request and returning false if it is invalid or breaks the naming
+// rules of the selected backend. The default prefix of the bucket and the key
+// prefix of the API key count toward the length limit, since they are part of the stored key.
+func objectKeyFrom(c *gin.Context, value string) (string, bool) {
+	key, err := checkObjectKey(c, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid object path: %v", err)})
+		return "", false
+	}
+	return key, true
+}
+
+// checkObjectKey normalizes an object key like objectKeyFrom, returning the
+// error instead of answering the request
+func checkObjectKey(c *gin.Context, value string) (string, error) {
+	key, err := normalizeObjectKey(value)
+	if err != nil {
+		return "", err
+	}
+	rules, _ := c.Get(namingContextKey)
+	naming, _ := rules.(storage.NamingRules)
+	if err := naming.CheckObject(storedKeyPrefix(c) + key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeObjectKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{key: "/a/b", want: "a/b"},
+		{key: "/a//b", want: "a/b"},
+		{key: "//a/b", want: "a/b"},
+		{key: "/./a", want: "a"},
+		{key: "/a/./b/.", want: "a/b"},
+		{key: "/a/b/", want: "a/b/"},
+		{key: "/a//b//", want: "a/b/"},
+		{key: "/", want: ""},
+		{key: "//", want: ""},
+		{key: "/a/../b", wantErr: errParentSegment},
+		{key: "/..", wantErr: errParentSegment},
+		{key: "/a/..", wantErr: errParentSegment},
+		{key: "/a..b/c", want: "a..b/c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := normalizeObjectKey(tt.key)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("normalizeObjectKey(%q) = %q, %v; want %q, %v", tt.key, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUploadKeyIsNormalized(t *testing.T) {
+	server := newTestServer(t, "")
+
+	// Every spelling of the path stores the same object
+	for _, target := range []string{"/upload/default/docs/a.txt", "/upload/default//docs//a.txt", "/upload/default/./docs/a.txt", "/upload/default/docs%2Fa.txt"} {
+		rec := serve(server, http.MethodPost, target, strings.NewReader(target), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s = %d %s", target, rec.Code, rec.Body)
+		}
+		if got := readObject(t, server, "docs/a.txt"); got != target {
+			t.Errorf("POST %s stored elsewhere: docs/a.txt holds %q", target, got)
+		}
+	}
+
+	for _, target := range []string{"/upload/default/docs/../a.txt", "/upload/default/docs/%2E%2E/a.txt"} {
+		if rec := serve(server, http.MethodPost, target, strings.NewReader("x"), nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d %s, want 400", target, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allowOrder is the order methods are listed in Allow headers; methods not
+// listed here follow in the order they were registered
+var allowOrder = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// registerOptionsRoutes answers OPTIONS for every registered path that doesn't
+// handle it itself with 204 No Content and an Allow header listing the
+// methods the path supports, e.g. "GET, OPTIONS" for /download/:bucket/*object.
+// The handler doesn't require authentication, so clients can discover what a
+// path supports before they have a key. It must run after every other route
+// is registered.
+func (s *Server) registerOptionsRoutes() {
+	methods := make(map[string][]string)
+	var paths []string
+	for _, route := range s.engine.Routes() {
+		if _, seen := methods[route.Path]; !seen {
+			paths = append(paths, route.Path)
+		}
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+
+	for _, path := range paths {
+		if slices.Contains(methods[path], http.MethodOptions) {
+			continue
+		}
+		allow := allowHeader(methods[path])
+		s.engine.OPTIONS(path, func(c *gin.Context) {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
+
+// allowHeader renders the methods of a path as an Allow header value, ending with OPTIONS
+func allowHeader(methods []string) string {
+	var allow []string
+	for _, method := range allowOrder {
+		if slices.Contains(methods, method) {
+			allow = append(allow, method)
+		}
+	}
+	for _, method := range methods {
+		if !slices.Contains(allow, method) {
+			allow = append(allow, method)
+		}
+	}
+	return strings.Join(append(allow, http.MethodOptions), ", ")
+}
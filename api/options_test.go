@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOptionsAllowHeader(t *testing.T) {
+	// OPTIONS needs no key even when authentication is on
+	server := newTestServer(t, "auth:\n  enabled: true\n  api_keys:\n    k1: team\n")
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/upload/default/docs/a.txt", "POST, PATCH, OPTIONS"},
+		{"/download/default/docs/a.txt", "GET, OPTIONS"},
+		{"/info/default/docs/a.txt", "HEAD, PATCH, OPTIONS"},
+		{"/delete/default/docs/a.txt", "DELETE, OPTIONS"},
+		{"/bucket/default", "HEAD, PUT, DELETE, OPTIONS"},
+		{"/buckets", "GET, OPTIONS"},
+	}
+	for _, tt := range tests {
+		rec := serve(server, http.MethodOptions, tt.target, nil, nil)
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("OPTIONS %s = %d %s, want 204", tt.target, rec.Code, rec.Body)
+			continue
+		}
+		if got := rec.Header().Get("Allow"); got != tt.want {
+			t.Errorf("OPTIONS %s Allow = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	if rec := serve(server, http.MethodGet, "/buckets", nil, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /buckets without a key = %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// defaultPresignExpiry is the validity of listed download URLs when
+// ?expiry= is not given
+const defaultPresignExpiry = 15 * time.Minute
+
+// presignHeader is set to presignUnsupported on listings asked for download
+// URLs when the backend can't sign them
+const (
+	presignHeader      = "X-Presign"
+	presignUnsupported = "unsupported"
+)
+
+// presignedObject is a listed object with a URL it can be downloaded from
+// straight from the provider
+type presignedObject struct {
+	storage.FileObject
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// presignExpiry parses ?expiry=, in seconds, for listed download URLs,
+// answering 400 Bad Request when it is invalid
+func presignExpiry(c *gin.Context) (time.Duration, bool) {
+	value := c.Query("expiry")
+	if value == "" {
+		return defaultPresignExpiry, true
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry, expected a positive number of seconds"})
+		return 0, false
+	}
+	if expiry := time.Duration(seconds) * time.Second; expiry <= storage.MaxPresignExpiry {
+		return expiry, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expiry must not exceed %d seconds", int64(storage.MaxPresignExpiry/time.Second))})
+	return 0, false
+}
+
+// presignObjects signs a download URL for each listed file with up to
+// concurrency signings at a time. Directories, and files whose URL couldn't
+// be signed, are returned without one.
+func presignObjects(ctx context.Context, store storage.PresignStorage, bucket string, objects []storage.FileObject, expiry time.Duration, concurrency int) []presignedObject {
+	presigned := make([]presignedObject, len(objects))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(concurrency, len(objects))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if signed, err := store.PresignDownload(ctx, bucket, objects[i].Name, expiry); err == nil {
+					presigned[i].DownloadURL = signed
+				}
+			}
+		}()
+	}
+	for i, obj := range objects {
+		presigned[i].FileObject = obj
+		if !obj.IsDir {
+			next <- i
+		}
+	}
+	close(next)
+	wg.Wait()
+	return presigned
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/example/file-service/storage"
+)
+
+// presigningStore signs URLs the way a provider would, on top of the
+// in-memory backend, which can't
+type presigningStore struct {
+	storage.Storage
+}
+
+func (p *presigningStore) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	u := url.URL{Scheme: "https", Host: bucket + ".provider.example", Path: "/" + objectName}
+	u.RawQuery = url.Values{"expires": {strconv.Itoa(int(expiry / time.Second))}, "signature": {"sig"}}.Encode()
+	return u.String(), nil
+}
+
+func (p *presigningStore) Capabilities() storage.Capabilities {
+	caps := p.Storage.Capabilities()
+	caps.PresignedURLs = true
+	return caps
+}
+
+// listedObjects decodes the objects of a GET /list response
+func listedObjects(t *testing.T, body []byte) []map[string]any {
+	t.Helper()
+	var response struct {
+		Objects []map[string]any `json:"objects"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return response.Objects
+}
+
+func TestListPresignedURLs(t *testing.T) {
+	server := newTestServer(t, "")
+	server.storages["default"] = &presigningStore{Storage: testStore(server)}
+	putObject(t, server, "photos/a.jpg", "a")
+	putObject(t, server, "photos/b.jpg", "b")
+
+	rec := serve(server, http.MethodGet, "/list/default?prefix=photos/&presign=true&expiry=600", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /list = %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(presignHeader); got != "" {
+		t.Errorf("%s = %q on a presigning backend", presignHeader, got)
+	}
+	objects := listedObjects(t, rec.Body.Bytes())
+	if len(objects) != 2 {
+		t.Fatalf("listed %d objects, want 2", len(objects))
+	}
+	for _, obj := range objects {
+		signed, _ := obj["download_url"].(string)
+		u, err := url.Parse(signed)
+		if err != nil || u.Scheme != "https" || u.Path != "/"+obj["Name"].(string) {
+			t.Errorf("download_url of %v = %q, want an https URL of the object", obj["Name"], signed)
+			continue
+		}
+		if got := u.Query().Get("expires"); got != "600" {
+			t.Errorf("download_url of %v expires in %s seconds, want 600", obj["Name"], got)
+		}
+	}
+}
+
+func TestListPresignUnsupported(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "a.jpg", "a")
+
+	rec := serve(server, http.MethodGet, "/list/default?presign=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /list = %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get(presignHeader); got != presignUnsupported {
+		t.Errorf("%s = %q, want %q", presignHeader, got, presignUnsupported)
+	}
+	for _, obj := range listedObjects(t, rec.Body.Bytes()) {
+		if _, ok := obj["download_url"]; ok {
+			t.Errorf("%v has a download_url on a backend that can't sign", obj["Name"])
+		}
+	}
+}
+
+func TestListPresignInvalidExpiry(t *testing.T) {
+	server := newTestServer(t, "")
+	server.storages["default"] = &presigningStore{Storage: testStore(server)}
+
+	maxSeconds := int(storage.MaxPresignExpiry / time.Second)
+	for _, expiry := range []string{"0", "-5", "soon", strconv.Itoa(maxSeconds + 1)} {
+		rec := serve(server, http.MethodGet, "/list/default?presign=true&expiry="+expiry, nil, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expiry=%s: GET /list = %d, want 400", expiry, rec.Code)
+		}
+	}
+}
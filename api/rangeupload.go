@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contentRange is a parsed Content-Range request header. A status query,
+// "bytes */total", has no range and only asks for the committed offset.
+type contentRange struct {
+	start, end int64 // inclusive
+	total      int64
+	query      bool
+}
+
+// parseContentRange parses "bytes start-end/total" or "bytes */total"
+func parseContentRange(header string) (contentRange, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return contentRange{}, false
+	}
+	rangeSpec, totalSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return contentRange{}, false
+	}
+	total, err := strconv.ParseInt(totalSpec, 10, 64)
+	if err != nil || total < 1 {
+		return contentRange{}, false
+	}
+	if rangeSpec == "*" {
+		return contentRange{total: total, query: true}, true
+	}
+
+	startSpec, endSpec, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return contentRange{}, false
+	}
+	start, err1 := strconv.ParseInt(startSpec, 10, 64)
+	end, err2 := strconv.ParseInt(endSpec, 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start || end >= total {
+		return contentRange{}, false
+	}
+	return contentRange{start: start, end: end, total: total}, true
+}
+
+// rangeUpload handles PATCH /upload requests that send an object as a
+// sequence of byte ranges, each described by a Content-Range header. The
+// first range, starting at byte 0, starts a multipart upload whose ID is
+// returned; later ranges name it with ?uploadId= and must continue exactly
+// where the committed bytes end. Each range is stored as the next part, and
+// the object is assembled once the range ending at the total size arrives.
+// The committed offset is derived from the parts the backend holds, so an
+// upload can be resumed after a restart or on another instance.
+func (s *Server) rangeUpload(c *gin.Context) {
+	store, bucket, object, ok := s.multipartTarget(c)
+	if !ok {
+		return
+	}
+
+	r, ok := parseContentRange(c.GetHeader("Content-Range"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Range must be bytes start-end/total, or bytes */total to ask for the committed offset"})
+		return
+	}
+	if !r.query && c.Request.ContentLength != r.end-r.start+1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Content-Length must be %d to match Content-Range", r.end-r.start+1)})
+		return
+	}
+	// The backend would only refuse a small part once the upload is completed,
+	// after the client has sent everything
+	if minSize := s.storageFor(c).Capabilities().MinPartSize; !r.query && r.end+1 < r.total && r.end-r.start+1 < minSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Every range but the last must be at least %d bytes on this backend", minSize)})
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	// A range starting at 0 without an upload ID starts a new upload
+	uploadID := c.Query("uploadId")
+	var offset int64
+	var parts int
+	switch {
+	case uploadID != "":
+		uploaded, err := store.ListParts(ctx, bucket, object, uploadID)
+		if err != nil {
+			multipartError(c, ctx, err, "list parts")
+			return
+		}
+		for _, part := range uploaded {
+			offset += part.Size
+		}
+		parts = len(uploaded)
+	case r.query || r.start != 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uploadId is required to continue an upload; it is returned for the range starting at byte 0"})
+		return
+	default:
+		if uploadID, _, ok = s.startUpload(c, ctx, store, bucket, object); !ok {
+			return
+		}
+	}
+
+	response := gin.H{
+		"bucket":    bucket,
+		"object":    object,
+		"upload_id": uploadID,
+		"total":     r.total,
+	}
+	if r.query {
+		response["offset"], response["complete"] = offset, false
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Ranges are stored as consecutive parts, so they can't leave gaps or overlap
+	if r.start != offset {
+		response["error"] = fmt.Sprintf("Range starts at byte %d, but %d bytes are committed; send the range starting there", r.start, offset)
+		response["offset"] = offset
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	if parts >= maxPartNumber {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An upload can't have more than %d ranges", maxPartNumber)})
+		return
+	}
+
+	body := &contextReader{ctx: ctx, reader: c.Request.Body}
+	if _, err := store.UploadPart(ctx, bucket, object, uploadID, parts+1, body, c.Request.ContentLength); err != nil {
+		multipartError(c, ctx, err, "upload range")
+		return
+	}
+	offset = r.end + 1
+
+	if offset < r.total {
+		response["offset"], response["complete"] = offset, false
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if err := store.CompleteMultipart(ctx, bucket, object, uploadID); err != nil {
+		multipartError(c, ctx, err, "complete upload")
+		return
+	}
+	if s.rejectScannedObject(c, ctx, s.storageFor(c), bucket, object) {
+		return
+	}
+	response["message"] = "File uploaded successfully"
+	response["offset"], response["complete"] = offset, true
+	c.JSON(http.StatusOK, response)
+}
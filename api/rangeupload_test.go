@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/example/file-service/storage"
+)
+
+// rangeResponse is the body of a PATCH /upload response
+type rangeResponse struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Complete bool   `json:"complete"`
+}
+
+// sendRange sends content[start:end+1] of content to PATCH /upload as one range
+func sendRange(t *testing.T, server *Server, key, uploadID, content string, start, end int) (*httptest.ResponseRecorder, rangeResponse) {
+	t.Helper()
+	target := "/upload/default/" + key
+	if uploadID != "" {
+		target += "?uploadId=" + uploadID
+	}
+	contentRange := fmt.Sprintf("bytes %d-%d/%d", start, end, len(content))
+	rec := serve(server, http.MethodPatch, target, strings.NewReader(content[start:end+1]), map[string]string{"Content-Range": contentRange})
+	var response rangeResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec, response
+}
+
+func TestRangeUploadContiguous(t *testing.T) {
+	server := newTestServer(t, "")
+	const content = "0123456789"
+
+	rec, first := sendRange(t, server, "video.mp4", "", content, 0, 3)
+	if rec.Code != http.StatusOK || first.UploadID == "" || first.Offset != 4 || first.Complete {
+		t.Fatalf("first range = %d %s", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "video.mp4") {
+		t.Error("object visible before the last range")
+	}
+
+	rec, second := sendRange(t, server, "video.mp4", first.UploadID, content, 4, 7)
+	if rec.Code != http.StatusOK || second.Offset != 8 || second.Complete {
+		t.Fatalf("second range = %d %s", rec.Code, rec.Body)
+	}
+
+	rec, last := sendRange(t, server, "video.mp4", first.UploadID, content, 8, 9)
+	if rec.Code != http.StatusOK || last.Offset != 10 || !last.Complete {
+		t.Fatalf("last range = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "video.mp4"); got != content {
+		t.Errorf("assembled object = %q, want %q", got, content)
+	}
+}
+
+func TestRangeUploadOutOfOrder(t *testing.T) {
+	server := newTestServer(t, "")
+	const content = "0123456789"
+
+	_, first := sendRange(t, server, "video.mp4", "", content, 0, 3)
+
+	// A range past the committed offset leaves a gap
+	rec, skipped := sendRange(t, server, "video.mp4", first.UploadID, content, 8, 9)
+	if rec.Code != http.StatusBadRequest || skipped.Offset != 4 {
+		t.Fatalf("range past the offset = %d %s, want 400 with offset 4", rec.Code, rec.Body)
+	}
+	// A range before it overlaps the committed bytes
+	if rec, _ := sendRange(t, server, "video.mp4", first.UploadID, content, 2, 5); rec.Code != http.StatusBadRequest {
+		t.Fatalf("overlapping range = %d %s, want 400", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "video.mp4") {
+		t.Error("object visible after refused ranges")
+	}
+
+	// The client resumes from the offset it is told
+	rec = serve(server, http.MethodPatch, "/upload/default/video.mp4?uploadId="+first.UploadID, nil, map[string]string{"Content-Range": "bytes */10"})
+	var status rangeResponse
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || status.Offset != 4 {
+		t.Fatalf("status query = %d %s, want offset 4", rec.Code, rec.Body)
+	}
+	sendRange(t, server, "video.mp4", first.UploadID, content, 4, 7)
+	if rec, last := sendRange(t, server, "video.mp4", first.UploadID, content, 8, 9); !last.Complete {
+		t.Fatalf("last range = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "video.mp4"); got != content {
+		t.Errorf("assembled object = %q, want %q", got, content)
+	}
+}
+
+// minPartStore reports a minimum part size, as S3-style backends do, on top
+// of the in-memory backend
+type minPartStore struct {
+	storage.Storage
+	storage.MultipartStorage
+}
+
+func (m *minPartStore) Capabilities() storage.Capabilities {
+	caps := m.Storage.Capabilities()
+	caps.MinPartSize = 5
+	return caps
+}
+
+func TestRangeUploadRejectsSmallRanges(t *testing.T) {
+	server := newTestServer(t, "")
+	store := testStore(server)
+	server.storages["default"] = &minPartStore{Storage: store, MultipartStorage: store.(storage.MultipartStorage)}
+	const content = "0123456789"
+
+	if rec, _ := sendRange(t, server, "video.mp4", "", content, 0, 3); rec.Code != http.StatusBadRequest {
+		t.Errorf("range below the minimum part size = %d %s, want 400", rec.Code, rec.Body)
+	}
+	uploads, err := store.(storage.MultipartStorage).ListMultipartUploads(context.Background(), "default", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 0 {
+		t.Errorf("refused range started %d uploads", len(uploads))
+	}
+
+	// The last range may be smaller
+	rec, first := sendRange(t, server, "video.mp4", "", content, 0, 5)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first range = %d %s", rec.Code, rec.Body)
+	}
+	if rec, last := sendRange(t, server, "video.mp4", first.UploadID, content, 6, 9); !last.Complete {
+		t.Fatalf("last range = %d %s", rec.Code, rec.Body)
+	}
+	if rec, _ := sendRange(t, server, "small.txt", "", "abc", 0, 2); rec.Code != http.StatusOK {
+		t.Errorf("single small range = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyPosts are the POST routes that only read from storage, which stay
+// available in read-only mode
+var readOnlyPosts = map[string]bool{
+	"/info-batch/:bucket":    true,
+	"/info-batch/":           true,
+	"/archive/:bucket":       true,
+	"/share/:bucket/*object": true,
+}
+
+// ReadOnlyMiddleware rejects requests that would change storage with 403
+// Forbidden while server.read_only is set, so a standby can serve reads only
+func (s *Server) ReadOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.config().Server.ReadOnly && mutatingRequest(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "read-only mode"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// mutatingRequest reports whether a REST or WebDAV request can change storage
+func mutatingRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return false
+	case http.MethodPost:
+		return !readOnlyPosts[c.FullPath()]
+	}
+	return true
+}
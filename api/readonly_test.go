@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	server := newTestServer(t, "server:\n  read_only: true\n  share:\n    secret: test-secret\n")
+	putObject(t, server, "report.txt", "quarterly")
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/upload/default/new.txt", "new", http.StatusForbidden},
+		{http.MethodDelete, "/delete/default/report.txt", "", http.StatusForbidden},
+		{http.MethodPatch, "/info/default/report.txt", `{"owner":"finance"}`, http.StatusForbidden},
+		{http.MethodPost, "/info-batch/default", `{"objects":["report.txt"]}`, http.StatusOK},
+		{http.MethodPost, "/archive/default", `{"objects":["report.txt"]}`, http.StatusOK},
+		{http.MethodPost, "/share/default/report.txt", "", http.StatusOK},
+		{http.MethodGet, "/download/default/report.txt", "", http.StatusOK},
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/ready", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := serve(server, tt.method, tt.target, strings.NewReader(tt.body), map[string]string{"Content-Type": "application/json"})
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d %s, want %d", tt.method, tt.target, rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	if objectExists(t, server, "new.txt") {
+		t.Error("upload was stored in read-only mode")
+	}
+	if got := readObject(t, server, "report.txt"); got != "quarterly" {
+		t.Errorf("report.txt = %q, want it left in place", got)
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+
+	"github.com/example/file-service/storage"
+)
+
+const (
+	// defaultResizeQuality is the JPEG quality used when ?quality= is not given
+	defaultResizeQuality = 85
+
+	// maxSourcePixels rejects originals whose decoded size would exhaust memory
+	maxSourcePixels = 100_000_000
+)
+
+// resizableTypes are the content types eligible for resizing
+var resizableTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// downloadResized serves a resized copy of an image for ?resize=WxH. It reports
+// false without writing a response when the object is not a resizable image,
+// in which case the caller serves the original.
+func (s *Server) downloadResized(c *gin.Context, store storage.Storage, bucket, object string) bool {
+	resizeCfg := s.config().Server.Resize
+
+	width, height, err := parseDimensions(c.Query("resize"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid resize: %v", err)})
+		return true
+	}
+	if width > resizeCfg.MaxDimension || height > resizeCfg.MaxDimension {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Resize dimensions must not exceed %d", resizeCfg.MaxDimension)})
+		return true
+	}
+
+	quality := defaultResizeQuality
+	if q := c.Query("quality"); q != "" {
+		quality, err = strconv.Atoi(q)
+		if err != nil || quality < 1 || quality > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quality, expected 1-100"})
+			return true
+		}
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	info, err := store.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get file info: %v", err)})
+		return true
+	}
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(info.ContentType, ";")[0]))
+	if !resizableTypes[contentType] {
+		return false
+	}
+
+	// Older versions aren't cached; the cache only tracks the latest version
+	cache := resizeCfg.Cache && c.Query("versionId") == ""
+
+	// Serve the cached copy when it is newer than the original
+	cacheKey := fmt.Sprintf("%s%dx%d/", resizeCfg.CachePrefix, width, height)
+	if c.Query("quality") != "" {
+		cacheKey = fmt.Sprintf("%s%dx%dq%d/", resizeCfg.CachePrefix, width, height, quality)
+	}
+	cacheKey += object
+	if cache && s.serveCachedResize(ctx, c, store, bucket, cacheKey, info.LastModified) {
+		return true
+	}
+
+	data, outputType, err := s.resizeObject(ctx, store, bucket, object, contentType, width, height, quality)
+	if err != nil {
+		status := storageErrorStatus(ctx, err)
+		if err == errImageTooLarge {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": fmt.Sprintf("Failed to resize image: %v", err)})
+		return true
+	}
+
+	// A read-only service serves cached resizes but doesn't store new ones
+	if cache && !s.config().Server.ReadOnly {
+		if _, err := store.Upload(ctx, bucket, cacheKey, bytes.NewReader(data), int64(len(data)), outputType, storage.ObjectHeaders{}); err != nil {
+			log.Printf("Failed to cache resized image %s/%s: %v", bucket, cacheKey, err)
+		}
+	}
+
+	s.setCacheControl(c, bucket)
+	c.Data(http.StatusOK, outputType, data)
+	return true
+}
+
+// serveCachedResize streams a previously cached resize if it is at least as
+// new as the original, reporting whether it did
+func (s *Server) serveCachedResize(ctx context.Context, c *gin.Context, store storage.Storage, bucket, cacheKey string, originalModified time.Time) bool {
+	cached, err := store.GetObjectInfo(ctx, bucket, cacheKey)
+	if err != nil {
+		return false
+	}
+	if cached.LastModified.IsZero() || originalModified.IsZero() || cached.LastModified.Before(originalModified) {
+		return false
+	}
+
+	reader, err := store.Download(ctx, bucket, cacheKey)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	s.setCacheControl(c, bucket)
+	c.DataFromReader(http.StatusOK, cached.Size, cached.ContentType, reader, nil)
+	return true
+}
+
+// errImageTooLarge is returned for originals above maxSourcePixels
+var errImageTooLarge = fmt.Errorf("source image exceeds %d pixels", maxSourcePixels)
+
+// resizeObject downloads an image and scales it to fit within width x height,
+// preserving its aspect ratio. JPEG stays JPEG; PNG and WebP are encoded as
+// PNG since there is no WebP encoder available.
+func (s *Server) resizeObject(ctx context.Context, store storage.Storage, bucket, object, contentType string, width, height, quality int) ([]byte, string, error) {
+	reader, err := store.Download(ctx, bucket, object)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	original, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Check the dimensions before decoding the pixels
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return nil, "", err
+	}
+	if imgCfg.Width*imgCfg.Height > maxSourcePixels {
+		return nil, "", errImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := fitWithin(src.Bounds().Dx(), src.Bounds().Dy(), width, height)
+	dst := image.NewRGBA(bounds)
+	draw.CatmullRom.Scale(dst, bounds, src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", err
+}
+
+// parseDimensions parses a "WxH" resize value
+func parseDimensions(value string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected WxH, got %q", value)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width < 1 {
+		return 0, 0, fmt.Errorf("invalid width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height < 1 {
+		return 0, 0, fmt.Errorf("invalid height %q", h)
+	}
+	return width, height, nil
+}
+
+// fitWithin scales srcW x srcH down to fit inside maxW x maxH preserving the
+// aspect ratio; images are never enlarged
+func fitWithin(srcW, srcH, maxW, maxH int) image.Rectangle {
+	if srcW <= maxW && srcH <= maxH {
+		return image.Rect(0, 0, srcW, srcH)
+	}
+
+	w, h := maxW, srcH*maxW/srcW
+	if h > maxH {
+		w, h = srcW*maxH/srcH, maxH
+	}
+	return image.Rect(0, 0, max(w, 1), max(h, 1))
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// defaultRestoreDays is how long a restored copy is kept when the request
+// doesn't say
+const defaultRestoreDays = 7
+
+// restoreTier handles requests to restore an object from an archive tier, so
+// it can be downloaded once the backend has finished the restore
+func (s *Server) restoreTier(c *gin.Context) {
+	store, ok := s.storageFor(c).(storage.RestoreStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Restoring archived objects is not supported by this storage backend"})
+		return
+	}
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+
+	days := defaultRestoreDays
+	if value := c.Query("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid days %q, expected a positive number", value)})
+			return
+		}
+		days = n
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if err := store.RestoreObject(ctx, bucket, object, days); err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to restore object: %v", err)})
+		return
+	}
+
+	// The restore runs in the background; the object's restore_status tells when it is done
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Restore started",
+		"bucket":  bucket,
+		"object":  object,
+		"days":    days,
+	})
+}
+
+// restoreRequired answers a download of an archived object that hasn't been
+// restored yet, with the restore status so clients know whether to request
+// a restore or wait for the one in progress
+func restoreRequired(c *gin.Context, bucket, object string, info *storage.FileObject) {
+	response := gin.H{
+		"error":          "Object is in an archive tier and must be restored before it can be downloaded",
+		"restore_url":    fmt.Sprintf("/restore-tier/%s/%s", bucket, object),
+		"storage_class":  "",
+		"restore_status": "",
+	}
+	if info != nil {
+		response["storage_class"] = info.StorageClass
+		response["restore_status"] = info.RestoreStatus
+	}
+	c.JSON(http.StatusConflict, response)
+}
@@ -0,0 +1,777 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// s3TimeFormat is the timestamp format of S3 XML responses
+const s3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+// s3MaxKeys is the largest page ListObjects returns
+const s3MaxKeys = 1000
+
+// s3Error is the XML body of an S3 error response
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource,omitempty"`
+}
+
+// writeS3Error sends an S3 error response; HEAD responses carry only the status
+func writeS3Error(c *gin.Context, status int, code, message string) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+	} else {
+		c.XML(status, s3Error{Code: code, Message: message, Resource: c.Request.URL.Path})
+	}
+	c.Abort()
+}
+
+// writeS3StorageError maps a failed storage call to an S3 error response
+func writeS3StorageError(c *gin.Context, ctx context.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUploadNotFound):
+		writeS3Error(c, http.StatusNotFound, "NoSuchUpload", err.Error())
+	case errors.Is(err, storage.ErrBucketNotFound):
+		writeS3Error(c, http.StatusNotFound, "NoSuchBucket", err.Error())
+	case storage.IsNotFound(err):
+		writeS3Error(c, http.StatusNotFound, "NoSuchKey", err.Error())
+	case storage.IsObjectLocked(err):
+		writeS3Error(c, http.StatusForbidden, "AccessDenied", err.Error())
+	case errors.Is(err, errTrashOccupied):
+		writeS3Error(c, http.StatusConflict, "OperationAborted", err.Error())
+	case errors.Is(err, errPayloadMismatch):
+		writeS3Error(c, http.StatusBadRequest, "XAmzContentSHA256Mismatch", err.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		writeS3Error(c, http.StatusBadRequest, "IncompleteBody", "The request body ended before its declared length")
+	case storageErrorStatus(ctx, err) == http.StatusGatewayTimeout:
+		writeS3Error(c, http.StatusServiceUnavailable, "SlowDown", err.Error())
+	default:
+		writeS3Error(c, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// writeS3ScanRejection sends the S3 error response for an upload rejected by
+// the virus scanner, and reports whether it was rejected
+func writeS3ScanRejection(c *gin.Context, signature string, err error) bool {
+	switch {
+	case signature != "":
+		writeS3Error(c, http.StatusForbidden, "AccessDenied", "File rejected by virus scan: "+signature)
+	case err != nil:
+		writeS3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", fmt.Sprintf("Virus scan failed: %v", err))
+	default:
+		return false
+	}
+	return true
+}
+
+// S3VirtualHostMiddleware serves S3 requests addressed to server.s3_api.domain
+// before any route is matched: virtual-host style requests name the bucket in
+// the host, <bucket>.<domain>, and requests to the domain itself are path-style
+func (s *Server) S3VirtualHostMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s3API := s.config().Server.S3API
+		if !s3API.Enabled || s3API.Domain == "" {
+			c.Next()
+			return
+		}
+		host := c.Request.Host
+		if h, _, ok := strings.Cut(host, ":"); ok {
+			host = h
+		}
+		path := strings.TrimPrefix(c.Request.URL.Path, "/")
+		var bucket, object string
+		if host == s3API.Domain {
+			// The domain itself takes path-style requests
+			bucket, object, _ = strings.Cut(path, "/")
+		} else if name, ok := strings.CutSuffix(host, "."+s3API.Domain); ok {
+			bucket, object = name, path
+		} else {
+			c.Next()
+			return
+		}
+
+		// The limiter is not part of the chain here, so it is applied inline
+		s.LimitMiddleware()(c)
+		if c.IsAborted() {
+			return
+		}
+		s.serveS3(c, bucket, object)
+		c.Abort()
+	}
+}
+
+// serveS3Path serves path-style S3 requests under server.s3_api.path_prefix
+func (s *Server) serveS3Path(c *gin.Context) {
+	bucket, object, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	s.serveS3(c, bucket, object)
+}
+
+// serveS3 authenticates an S3 request and dispatches it to the operation
+// selected by its method and query
+func (s *Server) serveS3(c *gin.Context, bucket, object string) {
+	cfg := s.config()
+	if !cfg.Server.S3API.Enabled {
+		writeS3Error(c, http.StatusNotImplemented, "NotImplemented", "The S3 API is not enabled")
+		return
+	}
+
+	// Every request is signed with SigV4 unless authentication is disabled
+	var sig *sigV4Request
+	if cfg.Auth.Enabled {
+		var err error
+		sig, err = verifySigV4(c.Request, cfg.Auth.S3Credentials, time.Now())
+		if err != nil {
+			var authErr *s3AuthError
+			if errors.As(err, &authErr) {
+				writeS3Error(c, authErr.status, authErr.code, authErr.message)
+				return
+			}
+			writeS3Error(c, http.StatusForbidden, "AccessDenied", err.Error())
+			return
+		}
+		setPrincipal(c, "s3:"+sig.accessKey)
+	} else {
+		setPrincipal(c, anonymousPrincipal)
+	}
+
+	// Only reads are served in read-only mode; every other S3 method changes storage
+	if cfg.Server.ReadOnly && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		writeS3Error(c, http.StatusForbidden, "AccessDenied", "read-only mode")
+		return
+	}
+
+	// Backends are selected with the X-Storage-Backend header, as in the REST API
+	backend := c.GetHeader("X-Storage-Backend")
+	if backend == "" {
+		backend = cfg.DefaultBackend()
+	}
+	if _, exists := s.storages[backend]; !exists {
+		writeS3Error(c, http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("Unknown storage backend: %s", backend))
+		return
+	}
+	c.Set(backendContextKey, backend)
+
+	naming := s.storages[backend].Capabilities().Naming
+	if bucket != "" {
+		if err := naming.CheckBucket(bucket); err != nil {
+			writeS3Error(c, http.StatusBadRequest, "InvalidBucketName", err.Error())
+			return
+		}
+	}
+	// Keys are taken relative to the bucket's default prefix, as in the REST API
+	setBucketPrefix(c, cfg, bucket)
+	object, err := normalizeObjectKey(object)
+	if err == nil {
+		err = naming.CheckObject(storedKeyPrefix(c) + object)
+	}
+	if err != nil {
+		writeS3Error(c, http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("Invalid object key: %v", err))
+		return
+	}
+
+	query := c.Request.URL.Query()
+	method := c.Request.Method
+	switch {
+	case bucket == "" && method == http.MethodGet:
+		s.s3ListBuckets(c)
+	case bucket == "":
+		writeS3Error(c, http.StatusMethodNotAllowed, "MethodNotAllowed", "The operation is not allowed on the service")
+
+	case object == "" && method == http.MethodGet && query.Has("location"):
+		c.XML(http.StatusOK, s3Location{Region: cfg.Server.S3API.Region})
+	case object == "" && method == http.MethodGet && onlyParams(query, s3ListParams...):
+		s.s3ListObjects(c, bucket, query)
+	case object == "" && method == http.MethodHead:
+		s.s3HeadBucket(c, bucket)
+	case object == "":
+		writeS3Error(c, http.StatusNotImplemented, "NotImplemented", "The bucket operation is not implemented")
+
+	case method == http.MethodPost && query.Has("uploads"):
+		s.s3CreateMultipartUpload(c, bucket, object)
+	case method == http.MethodPut && query.Has("uploadId"):
+		s.s3UploadPart(c, bucket, object, query, sig)
+	case method == http.MethodPost && query.Has("uploadId"):
+		s.s3CompleteMultipartUpload(c, bucket, object, query.Get("uploadId"))
+	case method == http.MethodDelete && query.Has("uploadId"):
+		s.s3AbortMultipartUpload(c, bucket, object, query.Get("uploadId"))
+	case !onlyParams(query, "versionId", "x-id"):
+		writeS3Error(c, http.StatusNotImplemented, "NotImplemented", "The object operation is not implemented")
+
+	case method == http.MethodGet || method == http.MethodHead:
+		s.s3GetObject(c, bucket, object, query.Get("versionId"))
+	case method == http.MethodPut:
+		if c.GetHeader("X-Amz-Copy-Source") != "" {
+			writeS3Error(c, http.StatusNotImplemented, "NotImplemented", "CopyObject is not implemented")
+			return
+		}
+		s.s3PutObject(c, bucket, object, sig)
+	case method == http.MethodDelete:
+		s.s3DeleteObject(c, bucket, object, query.Get("versionId"))
+	default:
+		writeS3Error(c, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method is not allowed for this resource")
+	}
+}
+
+// s3Location is the GetBucketLocation response
+type s3Location struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+// s3ListBucketsResult is the ListBuckets response
+type s3ListBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// s3ListBuckets handles ListBuckets requests
+func (s *Server) s3ListBuckets(c *gin.Context) {
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	buckets, err := s.storageFor(c).ListBuckets(ctx)
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+
+	result := s3ListBucketsResult{Owner: s3Owner{ID: "file-service", DisplayName: "file-service"}}
+	for _, bucket := range buckets {
+		result.Buckets = append(result.Buckets, s3Bucket{Name: bucket.Name, CreationDate: bucket.CreationDate.UTC().Format(s3TimeFormat)})
+	}
+	c.XML(http.StatusOK, result)
+}
+
+// s3HeadBucket handles HeadBucket requests
+func (s *Server) s3HeadBucket(c *gin.Context, bucket string) {
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	exists, err := s.storageFor(c).BucketExists(ctx, bucket)
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	if !exists {
+		writeS3Error(c, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// s3ListParams lists the query parameters ListObjects understands
+var s3ListParams = []string{"list-type", "prefix", "delimiter", "max-keys", "continuation-token", "start-after", "marker", "encoding-type", "fetch-owner", "x-id"}
+
+// onlyParams reports whether every query parameter is one of names. SDKs add
+// x-id, naming the operation, which carries no meaning of its own.
+func onlyParams(query url.Values, names ...string) bool {
+	for name := range query {
+		if !slices.Contains(names, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// s3ListBucketResult is the ListObjects and ListObjectsV2 response
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	KeyCount              *int             `xml:"KeyCount,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	Marker                *string          `xml:"Marker,omitempty"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// s3ListObjects handles ListObjects (v1) and ListObjectsV2 requests. The
+// listing is walked from the start of the prefix and stops once the page is
+// full, skipping everything up to the continuation point.
+func (s *Server) s3ListObjects(c *gin.Context, bucket string, query url.Values) {
+	v2 := query.Get("list-type") == "2"
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	urlEncode := query.Get("encoding-type") == "url"
+
+	maxKeys := s3MaxKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeS3Error(c, http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+			return
+		}
+		maxKeys = min(n, s3MaxKeys)
+	}
+
+	// Everything up to and including after has already been returned
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				writeS3Error(c, http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect")
+				return
+			}
+			after = string(decoded)
+		}
+	}
+
+	encode := func(key string) string {
+		if urlEncode {
+			return s3URIEncode(key, false)
+		}
+		return key
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	var (
+		contents  []s3Object
+		prefixes  []s3CommonPrefix
+		last      string
+		truncated bool
+	)
+	err := s.storageFor(c).Walk(ctx, bucket, prefix, func(obj storage.FileObject) error {
+		if obj.Name <= after || (delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(obj.Name, after)) {
+			return nil
+		}
+
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(obj.Name[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = obj.Name[:len(prefix)+i+len(delimiter)]
+				if commonPrefix == last {
+					return nil
+				}
+			}
+		}
+
+		if len(contents)+len(prefixes) >= maxKeys {
+			truncated = true
+			return errStopWalk
+		}
+		if commonPrefix != "" {
+			prefixes = append(prefixes, s3CommonPrefix{Prefix: encode(commonPrefix)})
+			last = commonPrefix
+			return nil
+		}
+
+		lastModified := ""
+		if !obj.LastModified.IsZero() {
+			lastModified = obj.LastModified.UTC().Format(s3TimeFormat)
+		}
+		contents = append(contents, s3Object{
+			Key:          encode(obj.Name),
+			LastModified: lastModified,
+			ETag:         quoteETag(obj.ETag),
+			Size:         obj.Size,
+			StorageClass: "STANDARD",
+		})
+		last = obj.Name
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopWalk) {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+
+	result := s3ListBucketResult{
+		Name:           bucket,
+		Prefix:         encode(prefix),
+		Delimiter:      encode(delimiter),
+		MaxKeys:        maxKeys,
+		IsTruncated:    truncated,
+		Contents:       contents,
+		CommonPrefixes: prefixes,
+	}
+	if urlEncode {
+		result.EncodingType = "url"
+	}
+	if v2 {
+		keyCount := len(contents) + len(prefixes)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if truncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+		}
+	} else {
+		marker := encode(query.Get("marker"))
+		result.Marker = &marker
+		if truncated {
+			result.NextMarker = encode(last)
+		}
+	}
+	c.XML(http.StatusOK, result)
+}
+
+// s3GetObject handles GetObject and HeadObject requests, including single byte ranges
+func (s *Server) s3GetObject(c *gin.Context, bucket, object, versionID string) {
+	store := storage.WithVersion(s.storageFor(c), versionID)
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	info, err := store.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	if info.ETag != "" {
+		c.Header("ETag", quoteETag(info.ETag))
+	}
+	if info.VersionID != "" {
+		c.Header("x-amz-version-id", info.VersionID)
+	}
+	if lastModified, ok := httpTime(info.LastModified); ok {
+		c.Header("Last-Modified", lastModified)
+	}
+	for key, value := range info.Metadata {
+		c.Header("x-amz-meta-"+key, value)
+	}
+	c.Header("Accept-Ranges", "bytes")
+	s.setCacheControl(c, bucket)
+	setObjectHeaders(c, info.Headers)
+
+	start, length, ranged, ok := parseRange(c.GetHeader("Range"), info.Size)
+	if !ok {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		writeS3Error(c, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+		return
+	}
+	status := http.StatusOK
+	if ranged {
+		status = http.StatusPartialContent
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
+	}
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
+
+	if c.Request.Method == http.MethodHead {
+		c.Status(status)
+		return
+	}
+
+	// Like REST downloads, the stream is bounded by the idle timeout rather
+	// than the operation timeout
+	downloadCtx, cancelDownload := context.WithCancel(c.Request.Context())
+	defer cancelDownload()
+	var reader io.ReadCloser
+	if ranged {
+		reader, err = store.DownloadRange(downloadCtx, bucket, object, start, length)
+	} else {
+		reader, err = store.Download(downloadCtx, bucket, object)
+	}
+	if err != nil {
+		writeS3StorageError(c, downloadCtx, err)
+		return
+	}
+	reader = newIdleTimeoutReader(reader, s.downloadIdleTimeout(c.GetString(backendContextKey)), cancelDownload)
+	defer reader.Close()
+
+	c.Status(status)
+	// The status line is already sent, so a failure can only cut the body short
+	io.CopyN(s.downloadWriter(c), reader, length)
+}
+
+// s3PutObject handles PutObject requests. x-amz-meta-* headers are applied
+// with a metadata update after the upload.
+func (s *Server) s3PutObject(c *gin.Context, bucket, object string, sig *sigV4Request) {
+	store := s.storageFor(c)
+
+	body, size, err := s3Body(c.Request, sig)
+	if err != nil {
+		var authErr *s3AuthError
+		if errors.As(err, &authErr) {
+			writeS3Error(c, authErr.status, authErr.code, authErr.message)
+			return
+		}
+		writeS3Error(c, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if reason := s.keyRejection(c, object); reason != "" {
+		writeS3Error(c, http.StatusBadRequest, "KeyTooLongError", reason)
+		return
+	}
+	if reason := s.uploadRejection(object, contentType); reason != "" {
+		writeS3Error(c, http.StatusUnsupportedMediaType, "InvalidRequest", reason)
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	if err := store.EnsurePathExists(ctx, bucket, object); err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+
+	// Stream the upload to the virus scanner as it is stored
+	scan, err := s.startVirusScan()
+	if err != nil {
+		writeS3Error(c, http.StatusServiceUnavailable, "ServiceUnavailable", fmt.Sprintf("Virus scanner unavailable: %v", err))
+		return
+	}
+	if scan != nil {
+		defer scan.Close()
+		body = io.TeeReader(body, scan)
+	}
+	result, err := store.Upload(ctx, bucket, object, &contextReader{ctx: ctx, reader: body}, size, contentType, objectHeaders(c.Request.Header))
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	if scan != nil {
+		signature, err := s.scanVerdict(ctx, scan, store, bucket, object, result)
+		if writeS3ScanRejection(c, signature, err) {
+			return
+		}
+	}
+
+	if metadata := amzMetadata(c.Request.Header); len(metadata) > 0 {
+		if err := store.UpdateMetadata(ctx, bucket, object, metadata, ""); err != nil {
+			writeS3StorageError(c, ctx, err)
+			return
+		}
+	}
+
+	if result.ETag != "" {
+		c.Header("ETag", quoteETag(result.ETag))
+	}
+	if result.VersionID != "" {
+		c.Header("x-amz-version-id", result.VersionID)
+	}
+	c.Status(http.StatusOK)
+}
+
+// amzMetadata collects the x-amz-meta-* request headers
+func amzMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		if key, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok && len(values) > 0 {
+			metadata[key] = values[0]
+		}
+	}
+	return metadata
+}
+
+// s3DeleteObject handles DeleteObject requests. Like S3, deleting a missing
+// key succeeds. With storage.soft_delete the object is moved to the trash, as
+// DELETE /delete does.
+func (s *Server) s3DeleteObject(c *gin.Context, bucket, object, versionID string) {
+	store := storage.WithVersion(s.storageFor(c), versionID)
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	var err error
+	if s.backendConfig(c).SoftDelete && versionID == "" && !isTrashed(object) {
+		_, err = trashObject(ctx, store, bucket, object)
+		if storage.IsPreconditionFailed(err) {
+			writeS3Error(c, http.StatusPreconditionFailed, "PreconditionFailed", "The object changed while it was moved to trash; it was not deleted")
+			return
+		}
+	} else {
+		err = store.Delete(ctx, bucket, object)
+	}
+	if err != nil && (errors.Is(err, storage.ErrBucketNotFound) || !storage.IsNotFound(err)) {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// s3InitiateMultipartUploadResult is the CreateMultipartUpload response
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// s3CompleteMultipartUploadResult is the CompleteMultipartUpload response
+type s3CompleteMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+// multipartStore returns the selected backend's multipart support, answering
+// with NotImplemented when it has none
+func (s *Server) multipartStore(c *gin.Context) (storage.MultipartStorage, bool) {
+	store, ok := s.storageFor(c).(storage.MultipartStorage)
+	if !ok {
+		writeS3Error(c, http.StatusNotImplemented, "NotImplemented", "Multipart uploads are not supported by this storage backend")
+	}
+	return store, ok
+}
+
+// s3CreateMultipartUpload handles CreateMultipartUpload requests
+func (s *Server) s3CreateMultipartUpload(c *gin.Context, bucket, object string) {
+	store, ok := s.multipartStore(c)
+	if !ok {
+		return
+	}
+	contentType := c.GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if reason := s.keyRejection(c, object); reason != "" {
+		writeS3Error(c, http.StatusBadRequest, "KeyTooLongError", reason)
+		return
+	}
+	if reason := s.uploadRejection(object, contentType); reason != "" {
+		writeS3Error(c, http.StatusUnsupportedMediaType, "InvalidRequest", reason)
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	uploadID, err := store.InitMultipart(ctx, bucket, object, contentType, objectHeaders(c.Request.Header))
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	c.XML(http.StatusOK, s3InitiateMultipartUploadResult{Bucket: bucket, Key: object, UploadID: uploadID})
+}
+
+// s3UploadPart handles UploadPart requests
+func (s *Server) s3UploadPart(c *gin.Context, bucket, object string, query url.Values, sig *sigV4Request) {
+	store, ok := s.multipartStore(c)
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		writeS3Error(c, http.StatusBadRequest, "InvalidArgument", "partNumber must be between 1 and 10000")
+		return
+	}
+
+	body, size, err := s3Body(c.Request, sig)
+	if err != nil {
+		var authErr *s3AuthError
+		if errors.As(err, &authErr) {
+			writeS3Error(c, authErr.status, authErr.code, authErr.message)
+			return
+		}
+		writeS3Error(c, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+	if size < 0 {
+		writeS3Error(c, http.StatusLengthRequired, "MissingContentLength", "Parts must be sent with a known length")
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	part, err := store.UploadPart(ctx, bucket, object, query.Get("uploadId"), partNumber, &contextReader{ctx: ctx, reader: body}, size)
+	if err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	if part.ETag != "" {
+		c.Header("ETag", quoteETag(part.ETag))
+	}
+	c.Status(http.StatusOK)
+}
+
+// s3CompleteMultipartUpload handles CompleteMultipartUpload requests. The
+// object is assembled from every uploaded part; the part list in the request
+// body is not checked against them.
+func (s *Server) s3CompleteMultipartUpload(c *gin.Context, bucket, object, uploadID string) {
+	store, ok := s.multipartStore(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	if err := store.CompleteMultipart(ctx, bucket, object, uploadID); err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	signature, err := s.scanStoredObject(ctx, s.storageFor(c), bucket, object)
+	if writeS3ScanRejection(c, signature, err) {
+		return
+	}
+
+	result := s3CompleteMultipartUploadResult{Bucket: bucket, Key: object}
+	if info, err := s.storageFor(c).GetObjectInfo(ctx, bucket, object); err == nil {
+		result.ETag = quoteETag(info.ETag)
+	}
+	c.XML(http.StatusOK, result)
+}
+
+// s3AbortMultipartUpload handles AbortMultipartUpload requests
+func (s *Server) s3AbortMultipartUpload(c *gin.Context, bucket, object, uploadID string) {
+	store, ok := s.multipartStore(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	if err := store.AbortMultipart(ctx, bucket, object, uploadID); err != nil {
+		writeS3StorageError(c, ctx, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+// s3SoftDeleteConfig enables the S3 API, without authentication, and soft
+// delete on the default backend
+const s3SoftDeleteConfig = "  soft_delete: true\nserver:\n  s3_api:\n    enabled: true\n"
+
+func TestS3DeleteObjectMovesToTrash(t *testing.T) {
+	server := newTestServer(t, s3SoftDeleteConfig)
+	putObject(t, server, "docs/report.txt", "quarterly")
+
+	if rec := serve(server, http.MethodDelete, "/s3/default/docs/report.txt", nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteObject = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "docs/report.txt") {
+		t.Error("object is still in place")
+	}
+	if got := readObject(t, server, trashPrefix+"docs/report.txt"); got != "quarterly" {
+		t.Errorf("trash copy = %q, want %q", got, "quarterly")
+	}
+
+	// Deleting a missing key still succeeds, and deleting from the trash is permanent
+	if rec := serve(server, http.MethodDelete, "/s3/default/docs/missing.txt", nil, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("DeleteObject of a missing key = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if rec := serve(server, http.MethodDelete, "/s3/default/"+trashPrefix+"docs/report.txt", nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DeleteObject in the trash = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, trashPrefix+"docs/report.txt") {
+		t.Error("trashed object was not deleted")
+	}
+}
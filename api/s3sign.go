@@ -0,0 +1,420 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigV4 constants
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4MaxSkew     = 15 * time.Minute
+	maxChunkSize     = 16 << 20
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Values of x-amz-content-sha256 that don't carry the payload hash itself
+const (
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingSignedPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingSignedTrailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedPayload = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// s3AuthError is a failed request authentication, reported with its S3 error code
+type s3AuthError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3AuthError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// sigV4Request holds the parsed credential scope and signature of a request
+type sigV4Request struct {
+	accessKey     string
+	date          string // yyyymmdd of the credential scope
+	region        string
+	amzDate       string
+	signedHeaders []string
+	signature     string
+	signingKey    []byte
+}
+
+// scope returns the credential scope the request was signed for
+func (r *sigV4Request) scope() string {
+	return r.date + "/" + r.region + "/s3/aws4_request"
+}
+
+// verifySigV4 checks the AWS Signature Version 4 Authorization header of r
+// against the configured credentials and returns the verified request. The
+// payload itself is checked later while it is read, see s3Body.
+func verifySigV4(r *http.Request, credentials map[string]string, now time.Time) (*sigV4Request, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, &s3AuthError{http.StatusForbidden, "AccessDenied", "Request is not signed"}
+	}
+	req, err := parseAuthorization(authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, ok := credentials[strings.ToLower(req.accessKey)]
+	if !ok {
+		return nil, &s3AuthError{http.StatusForbidden, "InvalidAccessKeyId", "The access key ID does not exist"}
+	}
+
+	req.amzDate = r.Header.Get("X-Amz-Date")
+	if req.amzDate == "" {
+		req.amzDate = r.Header.Get("Date")
+	}
+	signedAt, err := time.Parse(sigV4TimeFormat, req.amzDate)
+	if err != nil {
+		return nil, &s3AuthError{http.StatusForbidden, "AccessDenied", "X-Amz-Date is missing or invalid"}
+	}
+	if skew := now.Sub(signedAt); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return nil, &s3AuthError{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large"}
+	}
+	if !strings.HasPrefix(req.amzDate, req.date) {
+		return nil, &s3AuthError{http.StatusForbidden, "SignatureDoesNotMatch", "Credential scope date does not match X-Amz-Date"}
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = unsignedPayload
+	}
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		req.amzDate,
+		req.scope(),
+		hashHex([]byte(canonicalRequest(r, req.signedHeaders, payloadHash))),
+	}, "\n")
+	req.signingKey = signingKey(secret, req.date, req.region)
+	expected := hex.EncodeToString(hmacSHA256(req.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(req.signature)) {
+		return nil, &s3AuthError{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature does not match the signature calculated with the secret key"}
+	}
+	return req, nil
+}
+
+// parseAuthorization parses "AWS4-HMAC-SHA256 Credential=..., SignedHeaders=..., Signature=..."
+func parseAuthorization(value string) (*sigV4Request, error) {
+	invalid := &s3AuthError{http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed"}
+
+	algorithm, params, ok := strings.Cut(value, " ")
+	if !ok || algorithm != sigV4Algorithm {
+		return nil, &s3AuthError{http.StatusBadRequest, "AuthorizationHeaderMalformed", "Only AWS Signature Version 4 is supported"}
+	}
+
+	req := &sigV4Request{}
+	for _, param := range strings.Split(params, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			return nil, invalid
+		}
+		switch key {
+		case "Credential":
+			parts := strings.Split(val, "/")
+			if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+				return nil, invalid
+			}
+			req.accessKey, req.date, req.region = parts[0], parts[1], parts[2]
+		case "SignedHeaders":
+			req.signedHeaders = strings.Split(val, ";")
+		case "Signature":
+			req.signature = val
+		}
+	}
+	if req.accessKey == "" || len(req.signedHeaders) == 0 || req.signature == "" {
+		return nil, invalid
+	}
+	// SigV4 always signs the host, so a signature can't be replayed against another
+	if !slices.Contains(req.signedHeaders, "host") {
+		return nil, &s3AuthError{http.StatusBadRequest, "AuthorizationHeaderMalformed", "SignedHeaders must include host"}
+	}
+	return req, nil
+}
+
+// canonicalRequest builds the SigV4 canonical request of r
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		default:
+			values = r.Header.Values(name)
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		s3URIEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery sorts and encodes the query parameters as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3URIEncode percent-encodes every byte except the RFC 3986 unreserved
+// characters, and "/" unless encodeSlash is set
+func s3URIEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// signingKey derives the SigV4 signing key for a date and region
+func signingKey(secret, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// errPayloadMismatch is returned by s3Body when the payload doesn't match its signature
+var errPayloadMismatch = errors.New("payload does not match its signature")
+
+// s3Body returns the payload of r as the client meant it: aws-chunked bodies
+// are decoded, and signed payloads are verified as they are read, failing the
+// read at the end of the body if they don't match. size is the decoded length,
+// or -1 when unknown. With req nil the request was not authenticated and no
+// signatures are checked. Other streaming payloads, such as SigV4a ones, are
+// refused with NotImplemented.
+func s3Body(r *http.Request, req *sigV4Request) (io.Reader, int64, error) {
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	switch payloadHash {
+	case streamingSignedPayload, streamingSignedTrailer, streamingUnsignedPayload:
+		size := int64(-1)
+		if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+			n, err := strconv.ParseInt(decoded, 10, 64)
+			if err != nil {
+				return nil, 0, &s3AuthError{http.StatusBadRequest, "InvalidArgument", "Invalid x-amz-decoded-content-length"}
+			}
+			size = n
+		}
+		chunked := &chunkedReader{reader: bufio.NewReader(r.Body)}
+		if payloadHash != streamingUnsignedPayload && req != nil {
+			chunked.req = req
+			chunked.prevSignature = req.signature
+			chunked.signedTrailer = payloadHash == streamingSignedTrailer
+		}
+		return chunked, size, nil
+	case "", unsignedPayload:
+		return r.Body, r.ContentLength, nil
+	}
+	if strings.HasPrefix(payloadHash, "STREAMING-") {
+		return nil, 0, &s3AuthError{http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("x-amz-content-sha256 %s is not supported", payloadHash)}
+	}
+
+	if req == nil {
+		return r.Body, r.ContentLength, nil
+	}
+	return &hashCheckReader{reader: r.Body, hash: sha256.New(), expected: payloadHash, remaining: r.ContentLength}, r.ContentLength, nil
+}
+
+// hashCheckReader fails the final read when the SHA-256 of the data read
+// differs from the hash the request was signed with. Like signedBody, it
+// counts the payload as complete after Content-Length bytes as well as at the
+// end of the stream, since backends reading exactly the size never see the end.
+type hashCheckReader struct {
+	reader    io.Reader
+	hash      hash.Hash
+	expected  string
+	remaining int64 // bytes left of the declared Content-Length, negative when unknown
+}
+
+func (h *hashCheckReader) Read(p []byte) (int, error) {
+	n, err := h.reader.Read(p)
+	h.hash.Write(p[:n])
+	if h.remaining >= 0 {
+		h.remaining -= int64(n)
+		if h.remaining <= 0 && err == nil {
+			err = io.EOF
+		}
+	}
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.expected {
+		return n, errPayloadMismatch
+	}
+	return n, err
+}
+
+// chunkedReader decodes an aws-chunked body, verifying the chunk signatures
+// when req is set, and the signature of the trailing headers as well with
+// signedTrailer. Trailing headers, such as checksums, are otherwise ignored.
+type chunkedReader struct {
+	reader        *bufio.Reader
+	req           *sigV4Request
+	signedTrailer bool
+	prevSignature string
+	chunk         []byte
+	done          bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// nextChunk reads "<hex size>[;chunk-signature=<sig>]\r\n<data>\r\n"
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	line = strings.TrimRight(line, "\r\n")
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeField, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return fmt.Errorf("invalid aws-chunked chunk size %q", sizeField)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	if c.req != nil {
+		signature := strings.TrimPrefix(extension, "chunk-signature=")
+		stringToSign := strings.Join([]string{
+			sigV4Algorithm + "-PAYLOAD",
+			c.req.amzDate,
+			c.req.scope(),
+			c.prevSignature,
+			emptyPayloadHash,
+			hashHex(data),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.req.signingKey, stringToSign))
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return errPayloadMismatch
+		}
+		c.prevSignature = signature
+	}
+
+	if size == 0 {
+		c.done = true
+		return c.readTrailer()
+	}
+
+	// Each chunk's data is followed by CRLF
+	crlf := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, crlf); err != nil || !bytes.Equal(crlf, []byte("\r\n")) {
+		return io.ErrUnexpectedEOF
+	}
+	c.chunk = data
+	return nil
+}
+
+// readTrailer reads the trailing headers up to the empty line ending the body,
+// checking x-amz-trailer-signature when the trailer is signed. The signature
+// covers each other header as "name:value\n", chained to the last chunk's.
+func (c *chunkedReader) readTrailer() error {
+	var trailer strings.Builder
+	var signature string
+	for {
+		line, err := c.reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" || err != nil {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if name == "x-amz-trailer-signature" {
+			signature = value
+			continue
+		}
+		trailer.WriteString(name + ":" + value + "\n")
+	}
+	if !c.signedTrailer {
+		return nil
+	}
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm + "-TRAILER",
+		c.req.amzDate,
+		c.req.scope(),
+		c.prevSignature,
+		hashHex([]byte(trailer.String())),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(c.req.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errPayloadMismatch
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/example/file-service/storage"
+)
+
+// originalKeyMetadata is the user metadata entry recording the key a client
+// asked for when it was sanitized, so repeated uploads of the same key land on
+// the same object instead of being treated as collisions
+const originalKeyMetadata = "original-key"
+
+// maxKeySuffix is how many numbered alternatives are tried for a sanitized
+// key before giving up with a conflict
+const maxKeySuffix = 100
+
+// errKeyCollision is returned when a sanitized key is taken by an object
+// uploaded under a different key
+var errKeyCollision = errors.New("a different object is already stored under the sanitized key")
+
+// sanitizeKey rewrites each segment of an object key to URL-safe ASCII. The
+// result only depends on the key, so the same key always maps to the same
+// sanitized key.
+func sanitizeKey(key string, lowercase bool) string {
+	directory := strings.HasSuffix(key, "/")
+	segments := strings.Split(strings.TrimSuffix(key, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = sanitizeSegment(segment, lowercase)
+	}
+	sanitized := strings.Join(segments, "/")
+	if directory {
+		sanitized += "/"
+	}
+	return sanitized
+}
+
+// sanitizeSegment drops accents, turns whitespace runs into "-", replaces other
+// ASCII punctuation with "_" and hex-encodes what is left of non-ASCII
+// characters as uXXXX
+func sanitizeSegment(segment string, lowercase bool) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFKD.String(segment) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks split off by NFKD, e.g. the accent of é
+			continue
+		case unicode.IsSpace(r):
+			if !space {
+				b.WriteByte('-')
+			}
+			space = true
+			continue
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		case r < utf8.RuneSelf:
+			b.WriteByte('_')
+		default:
+			fmt.Fprintf(&b, "u%04x", r)
+		}
+		space = false
+	}
+	if lowercase {
+		return strings.ToLower(b.String())
+	}
+	return b.String()
+}
+
+// withKeySuffix numbers a key before its extension, e.g. report-2.pdf
+func withKeySuffix(key string, n int) string {
+	directory := strings.HasSuffix(key, "/")
+	trimmed := strings.TrimSuffix(key, "/")
+	ext := path.Ext(trimmed)
+	if ext == path.Base(trimmed) {
+		// Dotfiles such as .env have no extension to keep
+		ext = ""
+	}
+	numbered := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(trimmed, ext), n, ext)
+	if directory {
+		numbered += "/"
+	}
+	return numbered
+}
+
+// sanitizedUploadKey returns the key an upload of requested is stored under
+// with server.upload.sanitize_keys. A sanitized key already holding an object
+// uploaded under another key is a collision: it fails with errKeyCollision,
+// or with disambiguate_keys the first free numbered key is used instead.
+func (s *Server) sanitizedUploadKey(ctx context.Context, store storage.Storage, bucket, requested string) (string, error) {
+	cfg := s.config().Server.Upload
+	key := sanitizeKey(requested, cfg.LowercaseKeys)
+	if key == requested {
+		return key, nil
+	}
+
+	for n := 0; n <= maxKeySuffix; n++ {
+		candidate := key
+		if n > 0 {
+			candidate = withKeySuffix(key, n)
+		}
+		info, err := store.GetObjectInfo(ctx, bucket, candidate)
+		if storage.IsNotFound(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if originalKey(info) == requested {
+			return candidate, nil
+		}
+		if !cfg.DisambiguateKeys {
+			return "", fmt.Errorf("%w: %s", errKeyCollision, candidate)
+		}
+	}
+	return "", fmt.Errorf("%w: %s and its first %d numbered alternatives", errKeyCollision, key, maxKeySuffix)
+}
+
+// originalKey returns the key an object was uploaded under before it was
+// sanitized, or "" for objects stored under their requested key. Backends
+// differ in the case they report metadata names in.
+func originalKey(info *storage.FileObject) string {
+	return metadataValue(info.Metadata, originalKeyMetadata)
+}
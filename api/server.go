@@ -1,14 +1,18 @@
 package api
 
 import (
-	// "context"
-	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -17,71 +21,228 @@ import (
 	"github.com/example/file-service/storage"
 )
 
+// backendContextKey is the gin context key holding the selected backend name
+const backendContextKey = "storage_backend"
+
+// keyPrefixContextKey is the gin context key holding the object key prefix
+// the request's API key is confined to, see AuthConfig.KeyPrefixes
+const keyPrefixContextKey = "key_prefix"
+
+// bucketPrefixContextKey is the gin context key holding the default prefix
+// of the request's bucket, see BucketPolicy.DefaultPrefix
+const bucketPrefixContextKey = "bucket_prefix"
+
+// apiKeyContextKey is the gin context key holding the request's API key,
+// empty when auth is disabled
+const apiKeyContextKey = "api_key"
+
+// namingContextKey is the gin context key holding the naming rules of the selected backend
+const namingContextKey = "naming_rules"
+
 // Server represents the HTTP server
 type Server struct {
-	engine  *gin.Engine
-	storage storage.Storage
-	config  *config.Config
+	engine   *gin.Engine
+	storages map[string]storage.Storage
+
+	// Current configuration, swapped as a whole by reloadConfig
+	cfg atomic.Pointer[config.Config]
+
+	// Serializes configuration reloads, see reloadConfig
+	reloadMu sync.Mutex
+
+	// Cached readiness probe result, see probeBackends
+	readyMu     sync.Mutex
+	readyCache  *readyResult
+	writeProbes map[string]writeProbe
+
+	// Buckets known to exist, keyed by backend and bucket name, see ensureBucket
+	knownBuckets sync.Map
+
+	// Caps concurrent requests, nil when unlimited, see LimitMiddleware
+	limiter *requestLimiter
+
+	// WebDAV lock systems, keyed by backend and bucket name, see davLockSystem
+	davLocks sync.Map
+
+	// Object info caches keyed by backend name, empty when server.metadata_cache is off
+	metadataCaches map[string]*storage.MetadataCache
+
+	// Responses of recent uploads sent with an Idempotency-Key, see idempotent
+	idempotency *idempotencyCache
+
+	// Archives being built for ?async=true directory downloads, see downloadDirectoryAsync
+	archiveBuilds sync.Map
+
+	// Decides who each request is made by, see AuthMiddleware
+	authenticator Authenticator
+}
+
+// config returns the current configuration. Callers needing several settings
+// to agree should keep the returned pointer rather than calling it again.
+func (s *Server) config() *config.Config {
+	return s.cfg.Load()
 }
 
-// AuthMiddleware is the authentication middleware
+// AuthMiddleware authenticates requests with the server's Authenticator
+// and records the principal, its key prefix and its per-key limits
 func (s *Server) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 如果未启用鉴权，则直接通过
-		if !s.config.Auth.Enabled {
-			c.Next()
+		principal, err := s.authenticator.Authenticate(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
 			return
 		}
 
-		// 获取API Key
-		apiKey := c.GetHeader("X-API-Key")
-		if apiKey == "" {
-			// 如果header中没有，尝试从查询参数获取
-			apiKey = c.Query("api_key")
+		setPrincipal(c, principal.Name)
+		if principal.KeyPrefix != "" {
+			c.Set(keyPrefixContextKey, principal.KeyPrefix)
+		}
+		if principal.APIKey != "" {
+			auth := s.config().Auth
+			c.Set(apiKeyContextKey, principal.APIKey)
+			if limit, ok := auth.DownloadRateLimits[principal.APIKey]; ok {
+				c.Set(downloadRateContextKey, limit)
+			}
+			if limits, ok := auth.ZipLimits[principal.APIKey]; ok {
+				c.Set(zipLimitsContextKey, limits)
+			}
 		}
 
-		// 检查API Key是否有效
-		if apiKey == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key is required"})
-			c.Abort()
-			return
+		// 鉴权通过
+		c.Next()
+	}
+}
+
+// BackendMiddleware selects the storage backend for the request from the
+// X-Storage-Backend header or the backend query parameter
+func (s *Server) BackendMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader("X-Storage-Backend")
+		if name == "" {
+			name = c.Query("backend")
+		}
+		if name == "" {
+			name = s.config().DefaultBackend()
 		}
 
-		// 检查API Key是否在配置中
-		if _, exists := s.config.Auth.APIKeys[apiKey]; !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		if _, exists := s.storages[name]; !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown storage backend: %s", name)})
 			c.Abort()
 			return
 		}
 
-		// 鉴权通过
+		// Names the backend would reject are refused before they reach it
+		naming := s.storages[name].Capabilities().Naming
+		if bucket := c.Param("bucket"); bucket != "" {
+			if err := naming.CheckBucket(bucket); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid bucket name: %v", err)})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(backendContextKey, name)
+		c.Set(namingContextKey, naming)
+		bucket := c.Param("bucket")
+		if bucket == "" {
+			bucket = s.defaultBucket(c)
+		}
+		setBucketPrefix(c, s.config(), bucket)
 		c.Next()
 	}
 }
 
-// NewServer creates a new HTTP server
-func NewServer(cfg *config.Config) (*Server, error) {
-	// Set gin to release mode in production
-	if viper.GetString("log.level") != "debug" {
-		gin.SetMode(gin.ReleaseMode)
+// objectInBucketParam treats the :bucket segment of a route without an
+// object as the object key, leaving the bucket to the default
+func objectInBucketParam(c *gin.Context) {
+	for i, param := range c.Params {
+		if param.Key == "bucket" {
+			c.Params[i] = gin.Param{Key: "object", Value: "/" + param.Value}
+		}
 	}
+	c.Next()
+}
 
-	// Create gin engine
+// setBucketPrefix records the default prefix of the bucket a request addresses
+func setBucketPrefix(c *gin.Context, cfg *config.Config, bucket string) {
+	if prefix := cfg.DefaultPrefix(bucket); prefix != "" {
+		c.Set(bucketPrefixContextKey, prefix)
+	}
+}
+
+// storedKeyPrefix returns what is prepended to the request's object keys
+// before they are stored: the default prefix of the bucket followed by the
+// key prefix of the API key, e.g. uploads/tenants/acme/
+func storedKeyPrefix(c *gin.Context) string {
+	return c.GetString(bucketPrefixContextKey) + c.GetString(keyPrefixContextKey)
+}
+
+// storageFor returns the storage backend selected for the request, confined
+// to the default prefix of its bucket and the key prefix of its API key
+func (s *Server) storageFor(c *gin.Context) storage.Storage {
+	prefix := storedKeyPrefix(c)
+	// Returned names carry the prefix as stored, so it must be folded the
+	// same way for WithKeyPrefix to strip it again
+	if s.backendConfig(c).NormalizeKeyCase == "lower" {
+		prefix = strings.ToLower(prefix)
+	}
+	return storage.WithKeyPrefix(s.storages[c.GetString(backendContextKey)], prefix)
+}
+
+// backendConfig returns the configuration of the backend selected for the request
+func (s *Server) backendConfig(c *gin.Context) config.StorageConfig {
+	return s.config().Backends()[c.GetString(backendContextKey)]
+}
+
+// defaultBucket returns the default bucket of the backend selected for the request
+func (s *Server) defaultBucket(c *gin.Context) string {
+	if bucket := s.backendConfig(c).Bucket; bucket != "" {
+		return bucket
+	}
+	return s.config().Storage.Bucket
+}
+
+// NewServer creates a new HTTP server. Requests are authenticated as auth.mode
+// in cfg says unless WithAuthenticator supplies another Authenticator.
+func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
+	// Reject unusable configuration before touching any backend
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Set gin to release mode in production
+	setLogLevel(viper.GetString("log.level"))
+
+	// Create gin engine; the logger and recovery middleware are added once
+	// the server exists, since the access log reads its configuration
 	engine := gin.New()
-	engine.Use(gin.Logger())
-	engine.Use(gin.Recovery())
 
-	// Create storage based on config
-	store, err := createStorage(cfg)
+	auditLog, err := openAuditLog(cfg.Log.Audit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create storage backends based on config
+	stores, caches, err := createStorages(cfg, auditLog)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create storage: %w", err)
+		return nil, err
 	}
 
 	server := &Server{
-		engine:  engine,
-		storage: store,
-		config:  cfg,
+		engine:         engine,
+		storages:       stores,
+		limiter:        newRequestLimiter(cfg.Server.MaxConcurrentRequests),
+		metadataCaches: caches,
+		idempotency:    newIdempotencyCache(),
+	}
+	server.cfg.Store(cfg)
+	server.authenticator = newConfiguredAuthenticator(server.config)
+	for _, opt := range opts {
+		opt(server)
 	}
+	engine.Use(gin.LoggerWithFormatter(server.accessLogFormatter))
+	engine.Use(gin.Recovery())
 
 	// Register routes
 	server.registerRoutes()
@@ -89,109 +250,312 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	return server, nil
 }
 
-// createStorage creates a storage instance based on configuration
-func createStorage(cfg *config.Config) (storage.Storage, error) {
-	switch cfg.Storage.Type {
-	case "minio":
-		return storage.NewMinIOStorage(
-			cfg.Storage.MinIO.Endpoint,
-			cfg.Storage.MinIO.AccessKey,
-			cfg.Storage.MinIO.SecretKey,
-			cfg.Storage.MinIO.UseSSL,
-		)
-	case "oss":
-		return storage.NewOSSStorage(
-			cfg.Storage.OSS.Endpoint,
-			cfg.Storage.OSS.AccessKey,
-			cfg.Storage.OSS.SecretKey,
-			cfg.Storage.OSS.UseSSL,
-		)
-	case "obs":
-		return storage.NewOBStorage(
-			cfg.Storage.OBS.Endpoint,
-			cfg.Storage.OBS.AccessKey,
-			cfg.Storage.OBS.SecretKey,
-			cfg.Storage.OBS.UseSSL,
-		)
-	case "azure":
-		// 如果提供了连接字符串，优先使用连接字符串
-		if cfg.Storage.Azure.ConnectionString != "" {
-			// 这里需要修改Azure存储实现以支持连接字符串
-			// 暂时还是使用账户名和密钥的方式
-		}
-		// 构造完整的endpoint URL
-		endpoint := cfg.Storage.Azure.Endpoint
-		if endpoint == "" && cfg.Storage.Azure.AccountName != "" {
-			endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.Storage.Azure.AccountName)
-		}
-		return storage.NewAzureStorage(
-			cfg.Storage.Azure.AccountName,
-			cfg.Storage.Azure.AccountKey,
-			endpoint,
-		)
-	default:
-		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Storage.Type)
+// createStorages creates a storage instance for every configured backend,
+// deduplicating uploads, staging uploads under temporary keys and without
+// directory markers when configured to, caching object info when
+// server.metadata_cache is enabled, with lower-cased keys when configured to and recording
+// their operations with auditLog unless it is nil. The caches are returned
+// keyed by backend name.
+func createStorages(cfg *config.Config, auditLog *storage.AuditLogger) (map[string]storage.Storage, map[string]*storage.MetadataCache, error) {
+	stores := make(map[string]storage.Storage)
+	caches := make(map[string]*storage.MetadataCache)
+	for name, storageCfg := range cfg.Backends() {
+		store, err := createStorage(storageCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create storage %q: %w", name, err)
+		}
+		if storageCfg.InsecureTLS() {
+			log.Printf("WARNING: TLS certificate verification is disabled for storage %q (insecure_skip_verify); anyone on the network path can impersonate the backend", name)
+		}
+		if cfg.Server.Dedup.Enabled {
+			store = storage.WithDedup(store)
+		}
+		if storageCfg.AtomicUpload {
+			store = storage.WithAtomicUploads(store)
+		}
+		if storageCfg.DirectoryMarkers == "implicit" {
+			store = storage.WithImplicitDirectories(store)
+		}
+		if cacheCfg := cfg.Server.MetadataCache; cacheCfg.Size > 0 {
+			caches[name] = storage.NewMetadataCache(cacheCfg.Size, cacheCfg.TTL)
+			store = storage.WithMetadataCache(store, caches[name])
+		}
+		// Keys are lower-cased before the cache sees them, so every casing of
+		// a key shares one entry and writes invalidate it
+		if storageCfg.NormalizeKeyCase == "lower" {
+			store = storage.WithLowercaseKeys(store)
+		}
+		if auditLog != nil {
+			store = storage.WithAuditLog(store, auditLog)
+		}
+		stores[name] = store
 	}
+	return stores, caches, nil
+}
+
+// createStorage creates a storage instance with the factory registered for
+// the configured type
+func createStorage(cfg config.StorageConfig) (storage.Storage, error) {
+	options, err := cfg.BackendOptions()
+	if err != nil {
+		return nil, err
+	}
+	return storage.New(cfg.Type, options)
 }
 
 // registerRoutes registers HTTP routes
 func (s *Server) registerRoutes() {
+	// CORS applies to every route and to preflight requests that match none
+	s.engine.Use(s.CORSMiddleware())
+	// Virtual-host style S3 requests can have any path, so they are taken before routing
+	s.engine.Use(s.S3VirtualHostMiddleware())
+
 	// Health check endpoint - 不需要鉴权
 	s.engine.GET("/health", s.healthCheck)
+	// Readiness endpoint probes the storage backends - 不需要鉴权
+	s.engine.GET("/ready", s.readyCheck)
+	// Build information - 不需要鉴权
+	s.engine.GET("/version", s.versionInfo)
+	// Share links carry their own signature - 不需要鉴权
+	s.engine.GET("/shared/:token", s.LimitMiddleware(), s.downloadShared)
+	// Admin endpoints use the separate admin key instead of the API keys
+	s.engine.POST("/admin/reload", s.AdminMiddleware(), s.reloadConfig)
+	s.engine.POST("/admin/migrate", s.AdminMiddleware(), s.ReadOnlyMiddleware(), s.migrate)
+
+	// POST /upload/*object stores in the default bucket. The router can't
+	// register it beside /upload/:bucket/*object, so the key arrives as
+	// :bucket and is moved to the object before the middlewares read it.
+	defaultBucket := s.engine.Group("/", objectInBucketParam)
+	defaultBucket.Use(s.LimitMiddleware(), s.AuthMiddleware(), s.ReadOnlyMiddleware(), s.BackendMiddleware())
+	defaultBucket.POST("/upload/:bucket", s.idempotent(s.uploadFile))
 
 	// 应用鉴权中间件到所有需要保护的路由
 	authorized := s.engine.Group("/")
+	authorized.Use(s.LimitMiddleware())
 	authorized.Use(s.AuthMiddleware())
+	authorized.Use(s.ReadOnlyMiddleware())
+	authorized.Use(s.BackendMiddleware())
 
 	{
 		// File operations
-		authorized.POST("/upload/:bucket/*object", s.uploadFile)
+		authorized.POST("/upload/:bucket/*object", s.idempotent(s.uploadFile))
+		authorized.PATCH("/upload/:bucket/*object", s.rangeUpload)
+		authorized.POST("/ingest/:bucket/*object", s.ingestObject)
+		authorized.POST("/upload-dir/:bucket/*prefix", s.uploadDirectory)
 		authorized.GET("/download/:bucket/*object", s.downloadFile)
 		authorized.DELETE("/delete/:bucket/*object", s.deleteFile)
 		authorized.GET("/list/:bucket", s.listObjects)
 		authorized.GET("/list/", s.listObjects) // 添加对/list/路径的支持
+		authorized.GET("/dirs/:bucket", s.listDirectories)
+		authorized.GET("/dirs/", s.listDirectories)
+		authorized.POST("/mkdir/:bucket/*object", s.createDirectory)
+		authorized.GET("/browse/:bucket", s.browseDirectory)
+		authorized.GET("/browse/", s.browseDirectory)
+		authorized.GET("/stat/:bucket/*prefix", s.statPrefix)
+		authorized.GET("/versions/:bucket/*prefix", s.listVersions)
+		authorized.PUT("/retention/:bucket/*object", s.setRetention)
+		authorized.PUT("/acl/:bucket/*object", s.setACL)
 		authorized.HEAD("/info/:bucket/*object", s.getObjectInfo)
+		authorized.GET("/checksum/:bucket/*object", s.objectChecksum)
+		authorized.PATCH("/info/:bucket/*object", s.updateObjectMetadata)
+		authorized.POST("/info-batch/:bucket", s.getObjectInfoBatch)
+		authorized.POST("/info-batch/", s.getObjectInfoBatch)
+		authorized.POST("/move-prefix/:bucket", s.movePrefix)
+		authorized.POST("/move-prefix/", s.movePrefix)
+
+		// Resumable uploads
+		authorized.POST("/uploads/:bucket/*object", s.initOrCompleteUpload)
+		authorized.PATCH("/uploads/:bucket/*object", s.uploadPart)
+		authorized.GET("/uploads/:bucket/*object", s.listUploads)
+		authorized.DELETE("/uploads/:bucket/*object", s.abortUploads)
+		authorized.GET("/uploads/:bucket", s.listUploads) // every upload in the bucket
+		authorized.DELETE("/uploads/:bucket", s.abortUploads)
+
+		// Bucket operations
+		authorized.GET("/buckets", s.listBuckets)
+		authorized.PUT("/bucket/:bucket", s.createBucket)
+		authorized.HEAD("/bucket/:bucket", s.bucketExists)
+		authorized.DELETE("/bucket/:bucket", s.deleteBucket)
+
+		// Trash for soft-deleted objects
+		authorized.POST("/restore/:bucket/*object", s.restoreFile)
+		authorized.DELETE("/trash/:bucket", s.purgeTrash)
+
+		// Objects in archive tiers
+		authorized.POST("/restore-tier/:bucket/*object", s.restoreTier)
+
+		// Archives of explicitly listed objects
+		authorized.POST("/archive/:bucket", s.downloadArchive)
+
+		// Share links
+		authorized.POST("/share/:bucket/*object", s.createShareLink)
+
+		// Optional features of the selected backend
+		authorized.GET("/capabilities", s.getCapabilities)
 	}
+
+	// WebDAV interface to the same backends, authenticated with the API keys
+	webdavGroup := s.engine.Group("/webdav")
+	webdavGroup.Use(s.LimitMiddleware())
+	webdavGroup.Use(func(c *gin.Context) { c.Set(basicAuthChallengeKey, true) })
+	webdavGroup.Use(s.AuthMiddleware())
+	webdavGroup.Use(s.ReadOnlyMiddleware())
+	webdavGroup.Use(s.BackendMiddleware())
+	for _, method := range webdavMethods {
+		webdavGroup.Handle(method, "/:bucket", s.serveWebDAV)
+		webdavGroup.Handle(method, "/:bucket/*path", s.serveWebDAV)
+	}
+
+	// Browser UI, which asks for the API key itself to call the API
+	uiGroup := s.engine.Group("/ui")
+	uiGroup.Use(s.LimitMiddleware())
+	uiGroup.Use(func(c *gin.Context) { c.Set(basicAuthChallengeKey, true) })
+	uiGroup.Use(s.AuthMiddleware())
+	uiGroup.GET("/*filepath", s.serveUI)
+
+	// S3-compatible API, authenticated with SigV4 instead of the API keys
+	s3Prefix := s.config().Server.S3API.PathPrefix
+	s.engine.Any(s3Prefix, s.LimitMiddleware(), s.serveS3Path)
+	s.engine.Any(s3Prefix+"/*path", s.LimitMiddleware(), s.serveS3Path)
+	
+	// OPTIONS on any of the paths above reports the methods it supports
+	s.registerOptionsRoutes()
 }
 
 // healthCheck handles health check requests
 func (s *Server) healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"storage": s.config.Storage.Type,
-	})
+	cfg := s.config()
+	backends := make(map[string]string)
+	for name, backend := range cfg.Backends() {
+		backends[name] = backend.Type
+	}
+
+	response := gin.H{
+		"status":    "ok",
+		"storage":   backends[cfg.DefaultBackend()],
+		"backends":  backends,
+		"read_only": cfg.Server.ReadOnly,
+	}
+	if inFlight := s.inFlightRequests(); inFlight >= 0 {
+		response["in_flight_requests"] = inFlight
+	}
+	if len(s.metadataCaches) > 0 {
+		caches := make(map[string]gin.H)
+		for name, cache := range s.metadataCaches {
+			stats := cache.Stats()
+			caches[name] = gin.H{"hits": stats.Hits, "misses": stats.Misses, "entries": stats.Entries}
+		}
+		response["metadata_cache"] = caches
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // uploadFile handles file upload requests
 func (s *Server) uploadFile(c *gin.Context) {
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
-	object := c.Param("object")
-	
-	// Remove leading slash from object name (Gin adds it for wildcard parameters)
-	if strings.HasPrefix(object, "/") {
-		object = object[1:]
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	if bucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket is required: none given in the path and no default bucket is configured"})
+		return
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
 	}
 	
-	// Debug logging
-	fmt.Printf("Upload request - Bucket: %s, Object: %s\n", bucket, object)
+	// Object keys can be sensitive, so they are only logged at debug level
+	if s.config().Log.Level == "debug" {
+		log.Printf("Upload request - Bucket: %s, Object: %s", bucket, object)
+	}
 	
-	// Ensure path exists
-	if err := s.storage.EnsurePathExists(c.Request.Context(), bucket, object); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to ensure path exists: %v", err)})
+	// Create-only uploads fail with 409 instead of replacing an existing object
+	ifMatch := c.GetHeader("If-Match")
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch != "" && ifNoneMatch != "*" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-None-Match only supports *"})
+		return
+	}
+	ifNotExists := ifNoneMatch == "*" || c.Query("if_not_exists") == "true"
+	if ifNotExists && ifMatch != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match can't be combined with If-None-Match or if_not_exists"})
 		return
 	}
 	
 	// Get content type
 	contentType := c.GetHeader("Content-Type")
+	// Buffered so the first bytes can be sniffed and still be uploaded
+	reader := bufio.NewReader(c.Request.Body)
+	// 未指定或为通用类型时根据扩展名和内容检测实际类型
+	if s.config().Server.DetectContentType && (contentType == "" || contentType == "application/octet-stream") {
+		contentType = detectContentType(object, reader)
+	}
 	// 当Content-Type不为空时使用它，否则使用默认值
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 	
-	// Get content length
+	// Refuse objects server.upload doesn't allow before anything is written
+	if reason := s.keyRejection(c, object); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+	if reason := s.uploadRejection(object, contentType); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+	if reason := s.sniffRejection(reader); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+	
+	// The operation timeout covers both the path check and the full upload stream
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	
+	// Create the bucket on first upload if configured to
+	if s.backendConfig(c).AutoCreateBucket {
+		if err := s.ensureBucket(ctx, c, bucket); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create bucket: %v", err)})
+			return
+		}
+	}
+	
+	// Store the object under a URL-safe key when configured to
+	requested := object
+	if s.config().Server.Upload.SanitizeKeys {
+		sanitized, err := s.sanitizedUploadKey(ctx, store, bucket, requested)
+		if errors.Is(err, errKeyCollision) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Failed to upload file: %v", err), "object": requested})
+			return
+		}
+		if err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to sanitize object key: %v", err)})
+			return
+		}
+		if object, ok = objectKeyFrom(c, sanitized); !ok {
+			return
+		}
+		// Encoding can make the sanitized key longer than the requested one
+		if reason := s.keyRejection(c, object); reason != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": reason, "object": requested})
+			return
+		}
+	}
+	
+	// Ensure path exists
+	if err := store.EnsurePathExists(ctx, bucket, object); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to ensure path exists: %v", err)})
+		return
+	}
+	
+	// Get content length; -1 tells the backend the length is unknown (chunked uploads)
 	contentLengthStr := c.GetHeader("Content-Length")
-	var contentLength int64
+	contentLength := int64(-1)
 	if contentLengthStr != "" {
 		var err error
 		contentLength, err = strconv.ParseInt(contentLengthStr, 10, 64)
@@ -201,245 +565,571 @@ func (s *Server) uploadFile(c *gin.Context) {
 		}
 	}
 	
-	// Upload file
-	err := s.storage.Upload(c.Request.Context(), bucket, object, c.Request.Body, contentLength, contentType)
+	// Stream the upload to the virus scanner as it is stored
+	scan, err := s.startVirusScan()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file: %v", err)})
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("Virus scanner unavailable: %v", err)})
 		return
 	}
+	var upload io.Reader = reader
+	if scan != nil {
+		defer scan.Close()
+		upload = io.TeeReader(reader, scan)
+	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"message": "File uploaded successfully",
-		"bucket":  bucket,
-		"object":  object,
-	})
+	// Upload file, refusing to overwrite a changed object when If-Match is
+	// given and any existing object for create-only uploads
+	body := &contextReader{ctx: ctx, reader: upload}
+	var result *storage.UploadResult
+	switch {
+	case ifMatch != "":
+		result, err = store.UploadIfMatch(ctx, bucket, object, body, contentLength, contentType, objectHeaders(c.Request.Header), ifMatch)
+	case ifNotExists:
+		result, err = store.UploadIfNotExists(ctx, bucket, object, body, contentLength, contentType, objectHeaders(c.Request.Header))
+	default:
+		result, err = store.Upload(ctx, bucket, object, body, contentLength, contentType, objectHeaders(c.Request.Header))
+	}
+	if err != nil {
+		if ifMatch != "" && preconditionFailed(err) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Object has changed since it was read (ETag does not match If-Match)"})
+			return
+		}
+		if ifNotExists && storage.IsObjectExists(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Object already exists", "object": object})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to upload file: %v", err)})
+		return
+	}
+	
+	// Remove the object again when the scanner flags it
+	if scan != nil && s.rejectScannedUpload(c, ctx, scan, store, bucket, object, result) {
+		return
+	}
+	
+	// Remember the requested key of a sanitized object, so uploading the same
+	// key again replaces it rather than counting as a collision
+	if object != requested {
+		if err := store.UpdateMetadata(ctx, bucket, object, map[string]string{originalKeyMetadata: requested}, ""); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to record original object key: %v", err)})
+			return
+		}
+		// Updating metadata writes a new version on versioned buckets
+		if info, err := store.GetObjectInfo(ctx, bucket, object); err == nil {
+			result.ETag, result.VersionID = info.ETag, info.VersionID
+		}
+	}
+	
+	// Report the stored ETag and version so clients can make conditional requests later
+	response := gin.H{
+		"message":      "File uploaded successfully",
+		"bucket":       bucket,
+		"object":       object,
+		"content_type": contentType,
+		"etag":         result.ETag,
+		"size":         result.Size,
+	}
+	if object != requested {
+		response["requested_object"] = requested
+	}
+	if result.ETag != "" {
+		c.Header("ETag", quoteETag(result.ETag))
+	}
+	if result.VersionID != "" {
+		response["version_id"] = result.VersionID
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // downloadFile handles file download requests
 // If the 'directory' query parameter is set to 'true', it downloads all files with the given prefix as a ZIP archive
 func (s *Server) downloadFile(c *gin.Context) {
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
 	if bucket == "" {
-		bucket = s.config.Storage.Bucket
+		bucket = s.defaultBucket(c)
 	}
-	object := c.Param("object")
-	
-	// Remove leading slash from object name (Gin adds it for wildcard parameters)
-	if strings.HasPrefix(object, "/") {
-		object = object[1:]
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
 	}
 	
 	// Check if directory download is requested
 	isDirectory := c.Query("directory") == "true"
 	
 	if isDirectory {
-		// Ensure object (prefix) ends with "/" to denote a directory
-		prefix := object
-		if prefix != "" && !strings.HasSuffix(prefix, "/") {
-			prefix += "/"
-		}
-		
-		// List objects with the given prefix
-		objects, err := s.storage.List(c.Request.Context(), bucket, prefix)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
-			return
-		}
-		
-		// Set response headers for ZIP file download
-		c.Header("Content-Type", "application/zip")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", path.Base(strings.TrimSuffix(prefix, "/"))))
-		
-		// Create a zip writer
-		zipWriter := zip.NewWriter(c.Writer)
-		defer zipWriter.Close()
-		
-		// Download and add each object to the ZIP archive
-		for _, obj := range objects {
-			// Skip directories
-			if obj.IsDir || strings.HasSuffix(obj.Name, "/") {
-				continue
-			}
-			
-			// Download object
-			reader, err := s.storage.Download(c.Request.Context(), bucket, obj.Name)
-			if err != nil {
-				// Log error and continue with other files
-				continue
-			}
-			
-			// Create file header in ZIP
-			zipFileWriter, err := zipWriter.Create(obj.Name[len(prefix):]) // Remove prefix from file name in ZIP
-			if err != nil {
-				reader.Close()
-				continue
-			}
-			
-			// Copy file content to ZIP
-			_, err = io.Copy(zipFileWriter, reader)
-			reader.Close()
-			if err != nil {
-				continue
-			}
-		}
+		s.downloadDirectory(c, store, bucket, object)
 		return
 	}
 	
-	// Download single file
-	reader, err := s.storage.Download(c.Request.Context(), bucket, object)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to download file: %v", err)})
+	// Read an older version when one is requested and the backend is versioned
+	store = storage.WithVersion(store, c.Query("versionId"))
+	
+	// Serve a resized copy for images; other content types fall through
+	if c.Query("resize") != "" && s.downloadResized(c, store, bucket, object) {
+		return
+	}
+	
+	// Serve index and error documents when the bucket is hosted as a static site
+	if site := s.config().Server.StaticSite; site.IndexDocument != "" || site.ErrorDocument != "" {
+		s.serveStaticSite(c, store, bucket, object, site)
 		return
 	}
-	defer reader.Close()
 	
+	s.streamObject(c, store, bucket, object, http.StatusOK)
+}
+
+// streamObject streams a single object to the client with its content and
+// caching headers. A single byte range is served when asked for, and only
+// while an If-Range validator still matches, so interrupted downloads can be
+// resumed without mixing two versions of the object. status is sent with
+// the full object, normally 200 OK; with any other status, such as for an
+// error document, ranges are ignored.
+func (s *Server) streamObject(c *gin.Context, store storage.Storage, bucket, object string, status int) {
 	// Get file info
-	info, err := s.storage.GetObjectInfo(c.Request.Context(), bucket, object)
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	info, err := store.GetObjectInfo(ctx, bucket, object)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get file info: %v", err)})
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get file info: %v", err)})
+		return
+	}
+	
+	// Archived objects can't be read until they are restored
+	if info.NeedsRestore() {
+		restoreRequired(c, bucket, object, info)
 		return
 	}
 	
 	// Set content type header
 	c.Header("Content-Type", info.ContentType)
 	
+	// Set caching and file name headers
+	s.setCacheControl(c, bucket)
+	setObjectHeaders(c, info.Headers)
+	c.Header("Accept-Ranges", "bytes")
+	if info.ETag != "" {
+		c.Header("ETag", quoteETag(info.ETag))
+	}
+	if info.VersionID != "" {
+		c.Header("X-Version-Id", info.VersionID)
+	}
+	if lastModified, ok := httpTime(info.LastModified); ok {
+		c.Header("Last-Modified", lastModified)
+	}
+	c.Header("Content-Disposition", contentDisposition(s.downloadDisposition(c, info.ContentType), object))
+	
+	// A changed object is sent in full rather than resumed
+	rangeHeader := c.GetHeader("Range")
+	if ifRange := c.GetHeader("If-Range"); ifRange != "" && !ifRangeMatches(ifRange, info) {
+		rangeHeader = ""
+	}
+	if status != http.StatusOK {
+		rangeHeader = ""
+	}
+	start, length, ranged, ok := parseRange(rangeHeader, info.Size)
+	if !ok {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": "Requested range not satisfiable"})
+		return
+	}
+	
+	// Download single file; the stream is guarded by the idle timeout since
+	// large files can legitimately outlast the operation timeout
+	downloadCtx, cancelDownload := context.WithCancel(c.Request.Context())
+	defer cancelDownload()
+	var reader io.ReadCloser
+	if ranged {
+		reader, err = store.DownloadRange(downloadCtx, bucket, object, start, length)
+	} else {
+		reader, err = store.Download(downloadCtx, bucket, object)
+	}
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			log.Printf("Client disconnected before download of %s/%s: %v", bucket, object, err)
+			return
+		}
+		// Backends that don't report the storage class only tell by the error
+		if storage.IsObjectArchived(err) {
+			restoreRequired(c, bucket, object, info)
+			return
+		}
+		c.JSON(storageErrorStatus(downloadCtx, err), gin.H{"error": fmt.Sprintf("Failed to download file: %v", err)})
+		return
+	}
+	reader = newIdleTimeoutReader(reader, s.downloadIdleTimeout(c.GetString(backendContextKey)), cancelDownload)
+	defer reader.Close()
+	
+	c.Header("Content-Length", strconv.FormatInt(length, 10))
+	if ranged {
+		status = http.StatusPartialContent
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
+	}
+	c.Status(status)
+	
 	// Stream file to client
-	_, err = io.Copy(c.Writer, reader)
+	_, err = io.Copy(s.downloadWriter(c), reader)
 	if err != nil {
+		// Nobody is left to read an error when the client went away; the
+		// deferred Close still releases the backend stream
+		if c.Request.Context().Err() != nil {
+			log.Printf("Client disconnected during download of %s/%s: %v", bucket, object, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to stream file: %v", err)})
 		return
 	}
 }
 
-// deleteObjects handles bulk object deletion requests by prefix
+// deleteObjects handles bulk object deletion requests by prefix, which share
+// the single-file route with recursive=true. The prefix names a directory,
+// so photos doesn't reach photos-archive/, and the trash is left alone. With
+// storage.soft_delete the objects are moved to the trash rather than deleted.
+// With dry_run=true the objects are only listed, so an over-broad prefix can
+// be caught before anything is lost.
 func (s *Server) deleteObjects(c *gin.Context) {
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
 	if bucket == "" {
-		bucket = s.config.Storage.Bucket
+		bucket = s.defaultBucket(c)
 	}
 	
 	// Get prefix from path parameter
-	prefix := c.Param("prefix")
-	// Remove leading slash from prefix (Gin adds it for wildcard parameters)
-	if strings.HasPrefix(prefix, "/") {
-		prefix = prefix[1:]
+	prefix, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+	// Emptying a whole bucket takes DELETE /bucket/:bucket?force=true
+	if strings.Trim(prefix, "/") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A prefix is required to delete recursively"})
+		return
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
 	}
 	
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	
 	// List objects with the given prefix
-	objects, err := s.storage.List(c.Request.Context(), bucket, prefix)
+	listed, err := store.List(ctx, bucket, prefix)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
 		return
 	}
+	// The trash is emptied by DELETE /trash/:bucket only
+	objects := make([]storage.FileObject, 0, len(listed))
+	for _, obj := range listed {
+		if !isTrashed(obj.Name) {
+			objects = append(objects, obj)
+		}
+	}
 	
-	// Delete each object
-	var deleted []string
-	var errors []string
-	
-	for _, obj := range objects {
-		err := s.storage.Delete(c.Request.Context(), bucket, obj.Name)
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Failed to delete %s: %v", obj.Name, err))
-		} else {
-			deleted = append(deleted, obj.Name)
+	if c.Query("dry_run") == "true" {
+		names := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			names = append(names, obj.Name)
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"bucket":       bucket,
+			"prefix":       prefix,
+			"dry_run":      true,
+			"would_delete": names,
+			"count":        len(names),
+		})
+		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	// Delete each object; the status tells whether some or all deletes failed
+	softDelete := s.backendConfig(c).SoftDelete
+	var results []bulkResult
+	if softDelete {
+		results = trashAll(ctx, store, bucket, objects)
+	} else {
+		results = deleteAll(ctx, store, bucket, objects)
+	}
+	deleted, errors := deleteSummary(results)
+	
+	c.JSON(bulkStatus(bulkStatuses(results)), gin.H{
 		"bucket":  bucket,
 		"prefix":  prefix,
 		"deleted": deleted,
 		"errors":  errors,
+		"objects": results,
+		"trashed": softDelete,
 	})
 }
 
+// deleteAll deletes every listed object, returning the result for each
+func deleteAll(ctx context.Context, store storage.Storage, bucket string, objects []storage.FileObject) []bulkResult {
+	results := make([]bulkResult, len(objects))
+	for i, obj := range objects {
+		if err := store.Delete(ctx, bucket, obj.Name); err != nil {
+			results[i] = bulkFailure(ctx, obj.Name, err)
+		} else {
+			results[i] = bulkResult{Object: obj.Name}
+		}
+	}
+	return results
+}
+
+// deleteSummary splits the results of deleteAll into the deleted names and
+// messages for the objects that could not be deleted
+func deleteSummary(results []bulkResult) ([]string, []string) {
+	var deleted []string
+	var errors []string
+	
+	for _, result := range results {
+		if result.Error != "" {
+			errors = append(errors, fmt.Sprintf("Failed to delete %s: %s", result.Object, result.Error))
+		} else {
+			deleted = append(deleted, result.Object)
+		}
+	}
+	
+	return deleted, errors
+}
+
 // deleteFile handles file deletion requests
 func (s *Server) deleteFile(c *gin.Context) {
+	if c.Query("recursive") == "true" {
+		s.deleteObjects(c)
+		return
+	}
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
 	if bucket == "" {
-		bucket = s.config.Storage.Bucket
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+	
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	
+	// With a version ID that version is removed for good; without one,
+	// versioned buckets keep the data behind a delete marker
+	versionID := c.Query("versionId")
+	if _, ok := store.(storage.VersionedStorage); !ok {
+		versionID = ""
 	}
-	object := c.Param("object")
+	store = storage.WithVersion(store, versionID)
 	
-	// Remove leading slash from object name (Gin adds it for wildcard parameters)
-	if strings.HasPrefix(object, "/") {
-		object = object[1:]
+	// Keep the object in the trash instead; deleting a version or an object
+	// that is already in the trash is always permanent
+	if s.backendConfig(c).SoftDelete && versionID == "" && !isTrashed(object) {
+		s.moveToTrash(c, ctx, store, bucket, object)
+		return
 	}
 	
-	// Delete file
-	err := s.storage.Delete(c.Request.Context(), bucket, object)
+	// Delete file, refusing to delete a changed object when If-Match is given
+	ifMatch := c.GetHeader("If-Match")
+	var err error
+	if ifMatch != "" {
+		err = store.DeleteIfMatch(ctx, bucket, object, ifMatch)
+	} else {
+		err = store.Delete(ctx, bucket, object)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete file: %v", err)})
+		if ifMatch != "" && preconditionFailed(err) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Object has changed since it was read (ETag does not match If-Match)"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to delete file: %v", err)})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "File deleted successfully",
 		"bucket":  bucket,
 		"object":  object,
-	})
+	}
+	if versionID != "" {
+		response["version_id"] = versionID
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // listObjects handles object listing requests
 func (s *Server) listObjects(c *gin.Context) {
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
 	if bucket == "" {
-		bucket = s.config.Storage.Bucket
+		bucket = s.defaultBucket(c)
 	}
 	
 	// Get prefix from query parameter or path parameter
 	prefix := c.Query("prefix")
 	if prefix == "" {
 		prefix = c.Param("prefix")
-		// Remove leading slash from prefix (Gin adds it for wildcard parameters)
-		if strings.HasPrefix(prefix, "/") {
-			prefix = prefix[1:]
+	}
+	prefix, ok := objectKeyFrom(c, prefix)
+	if !ok {
+		return
+	}
+	
+	// Narrow the backend listing to the literal part of the glob, if any
+	glob := c.Query("glob")
+	listPrefix := prefix
+	if glob != "" {
+		if err := validateGlob(glob); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid glob pattern: %v", err)})
+			return
+		}
+		if literal := globPrefix(glob); strings.HasPrefix(literal, prefix) {
+			listPrefix = literal
 		}
 	}
+	stream := c.Query("stream") == "true"
+	if stream && c.Query("sort") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort can't be combined with stream=true"})
+		return
+	}
+	
+	// User metadata is only listed when asked for, since most backends need
+	// an extra request per object to look it up
+	includeMetadata := false
+	switch include := c.Query("include"); include {
+	case "":
+	case "metadata":
+		includeMetadata = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid include %q, expected include=metadata", include)})
+		return
+	}
+	listsMetadata := store.Capabilities().ListMetadata
+	if stream && includeMetadata && !listsMetadata {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include=metadata can't be combined with stream=true on this backend"})
+		return
+	}
+	
+	// Direct download URLs are signed for each object when asked for, on
+	// backends that can sign them; the others say so in a header instead
+	var presign storage.PresignStorage
+	var presignExpiresIn time.Duration
+	if c.Query("presign") == "true" {
+		if stream {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "presign=true can't be combined with stream=true"})
+			return
+		}
+		if presignExpiresIn, ok = presignExpiry(c); !ok {
+			return
+		}
+		if signer, isSigner := store.(storage.PresignStorage); isSigner && store.Capabilities().PresignedURLs {
+			presign = signer
+		} else {
+			c.Header(presignHeader, presignUnsupported)
+		}
+	}
+	
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	if includeMetadata {
+		ctx = storage.WithListMetadata(ctx)
+	}
+	
+	// Stream the listing as it arrives instead of collecting it first when asked
+	if stream {
+		streamObjects(c, ctx, store, bucket, listPrefix, glob, includeMetadata)
+		return
+	}
 	
 	// List objects
-	objects, err := s.storage.List(c.Request.Context(), bucket, prefix)
+	objects, err := store.List(ctx, bucket, listPrefix)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	if glob != "" {
+		matched := make([]storage.FileObject, 0, len(objects))
+		for _, obj := range objects {
+			if matchGlob(glob, obj.Name) {
+				matched = append(matched, obj)
+			}
+		}
+		objects = matched
+	}
+	
+	// Sort server-side when asked, otherwise keep the backend's order
+	if sortKey := c.Query("sort"); sortKey != "" {
+		if !sortObjects(objects, sortKey, c.Query("order")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected sort=name|size|modified and order=asc|desc"})
+			return
+		}
+	}
+	
+	switch {
+	case !includeMetadata:
+		dropListMetadata(objects)
+	case !listsMetadata:
+		fillListMetadata(ctx, store, bucket, objects, s.config().Server.InfoBatch.Concurrency)
+	}
+	
+	response := gin.H{
 		"bucket":  bucket,
 		"prefix":  prefix,
 		"objects": objects,
-	})
+	}
+	if presign != nil {
+		response["objects"] = presignObjects(ctx, presign, bucket, objects, presignExpiresIn, s.config().Server.InfoBatch.Concurrency)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // getObjectInfo handles object info requests
 func (s *Server) getObjectInfo(c *gin.Context) {
+	store := s.storageFor(c)
+
 	// Use default bucket if not specified
 	bucket := c.Param("bucket")
 	if bucket == "" {
-		bucket = s.config.Storage.Bucket
+		bucket = s.defaultBucket(c)
 	}
-	object := c.Param("object")
-	
-	// Remove leading slash from object name (Gin adds it for wildcard parameters)
-	if strings.HasPrefix(object, "/") {
-		object = object[1:]
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
 	}
 	
-	// Get object info
-	info, err := s.storage.GetObjectInfo(c.Request.Context(), bucket, object)
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	
+	// Get object info, of an older version when one is requested
+	info, err := storage.WithVersion(store, c.Query("versionId")).GetObjectInfo(ctx, bucket, object)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
 		return
 	}
 	
 	// Set headers
 	c.Header("Content-Type", info.ContentType)
 	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
-	c.Header("Last-Modified", info.LastModified)
+	if lastModified, ok := httpTime(info.LastModified); ok {
+		c.Header("Last-Modified", lastModified)
+	}
+	if info.ETag != "" {
+		c.Header("ETag", quoteETag(info.ETag))
+	}
+	if info.VersionID != "" {
+		c.Header("X-Version-Id", info.VersionID)
+	}
+	setObjectHeaders(c, info.Headers)
+	if info.StorageClass != "" {
+		c.Header("X-Storage-Class", info.StorageClass)
+	}
+	if info.RestoreStatus != "" {
+		c.Header("X-Restore-Status", info.RestoreStatus)
+	}
 	
 	// Return metadata in response headers or body
 	for key, value := range info.Metadata {
@@ -449,8 +1139,68 @@ func (s *Server) getObjectInfo(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// updateObjectMetadata handles in-place metadata updates. The X-Meta-* headers
+// replace the object's user metadata and Content-Type, when given, replaces its content type.
+func (s *Server) updateObjectMetadata(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+	
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	
+	// Make sure the object exists so a missing object is a 404 rather than a failed copy
+	if _, err := store.GetObjectInfo(ctx, bucket, object); err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
+		return
+	}
+	
+	metadata := metadataFromHeaders(c.Request.Header)
+	contentType := c.GetHeader("Content-Type")
+	if contentType != "" && !contentTypeAllowed(s.config().Server.Upload.AllowedContentTypes, contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Content type %s is not allowed", contentType)})
+		return
+	}
+	
+	if err := store.UpdateMetadata(ctx, bucket, object, metadata, contentType); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to update metadata: %v", err)})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Metadata updated successfully",
+		"bucket":   bucket,
+		"object":   object,
+		"metadata": metadata,
+	})
+}
+
+// metadataFromHeaders collects X-Meta-* request headers into a metadata map with lowercase keys
+func metadataFromHeaders(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for key, values := range header {
+		if len(values) == 0 || !strings.HasPrefix(key, "X-Meta-") {
+			continue
+		}
+		metadata[strings.ToLower(strings.TrimPrefix(key, "X-Meta-"))] = values[0]
+	}
+	return metadata
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.config.Server.Port)
+	addr := fmt.Sprintf(":%d", s.config().Server.Port)
 	return s.engine.Run(addr)
 }
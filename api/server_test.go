@@ -0,0 +1,281 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"github.com/example/file-service/config"
+	"github.com/example/file-service/storage"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestServer creates a server with the in-memory backend and the default
+// bucket "default", configured by the YAML in extra on top of that. extra
+// continues the storage section when its first lines are indented.
+func newTestServer(t testing.TB, extra string, opts ...ServerOption) *Server {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "storage:\n  type: memory\n  bucket: default\n" + extra
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	server, err := NewServer(cfg, opts...)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return server
+}
+
+// testStore returns the default backend of server, to seed and inspect objects
+func testStore(server *Server) storage.Storage {
+	return server.storages[server.config().DefaultBackend()]
+}
+
+// putObject stores content at key in the default bucket of server
+func putObject(t testing.TB, server *Server, key, content string) {
+	t.Helper()
+	_, err := testStore(server).Upload(context.Background(), "default", key, strings.NewReader(content), int64(len(content)), "text/plain", storage.ObjectHeaders{})
+	if err != nil {
+		t.Fatalf("Upload %s: %v", key, err)
+	}
+}
+
+// readObject returns the content stored at key in the default bucket of server
+func readObject(t *testing.T, server *Server, key string) string {
+	t.Helper()
+	reader, err := testStore(server).Download(context.Background(), "default", key)
+	if err != nil {
+		t.Fatalf("Download %s: %v", key, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Download %s: %v", key, err)
+	}
+	return string(data)
+}
+
+// serve sends a request to server and returns the recorded response
+func serve(server *Server, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	server.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestShareLinkDownload(t *testing.T) {
+	server := newTestServer(t, "server:\n  share:\n    secret: test-secret\n")
+	putObject(t, server, "report.txt", "quarterly")
+
+	rec := serve(server, http.MethodPost, "/share/default/report.txt", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /share = %d %s", rec.Code, rec.Body)
+	}
+	token := shareTokenFrom(t, rec.Body.String())
+
+	rec = serve(server, http.MethodGet, "/shared/"+token, nil, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "quarterly" {
+		t.Fatalf("GET /shared = %d %q, want 200 %q", rec.Code, rec.Body, "quarterly")
+	}
+}
+
+// shareTokenFrom extracts the token from the url of a POST /share response
+func shareTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+	i := strings.Index(body, "/shared/")
+	if i < 0 {
+		t.Fatalf("no share URL in %s", body)
+	}
+	token := body[i+len("/shared/"):]
+	return token[:strings.IndexAny(token, "\"?")]
+}
+
+func TestRecursiveDeleteStaysInsidePrefix(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "photos/a.jpg", "a")
+	putObject(t, server, "photos/2024/b.jpg", "b")
+	putObject(t, server, "photos-archive/c.jpg", "c")
+
+	rec := serve(server, http.MethodDelete, "/delete/default/photos?recursive=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recursive delete = %d %s", rec.Code, rec.Body)
+	}
+	for key, want := range map[string]bool{"photos/a.jpg": false, "photos/2024/b.jpg": false, "photos-archive/c.jpg": true} {
+		if got := objectExists(t, server, key); got != want {
+			t.Errorf("%s exists = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRecursiveDeleteRequiresPrefix(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, "a.txt", "a")
+
+	for _, target := range []string{"/delete/default/?recursive=true", "/delete/default//?recursive=true"} {
+		if rec := serve(server, http.MethodDelete, target, nil, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("DELETE %s = %d, want 400", target, rec.Code)
+		}
+	}
+	if !objectExists(t, server, "a.txt") {
+		t.Error("a.txt was deleted")
+	}
+}
+
+func TestRecursiveDeleteSoftDelete(t *testing.T) {
+	server := newTestServer(t, "  soft_delete: true\n")
+	putObject(t, server, "docs/a.txt", "a")
+	putObject(t, server, ".trash/docs/old.txt", "old")
+
+	rec := serve(server, http.MethodDelete, "/delete/default/docs/?recursive=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recursive delete = %d %s", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "docs/a.txt") {
+		t.Error("docs/a.txt was not deleted")
+	}
+	if got := readObject(t, server, ".trash/docs/a.txt"); got != "a" {
+		t.Errorf(".trash/docs/a.txt = %q, want %q", got, "a")
+	}
+	if !objectExists(t, server, ".trash/docs/old.txt") {
+		t.Error("an object already in the trash was deleted")
+	}
+}
+
+func TestRecursiveDeleteSkipsTrash(t *testing.T) {
+	server := newTestServer(t, "")
+	putObject(t, server, ".trash/docs/old.txt", "old")
+
+	rec := serve(server, http.MethodDelete, "/delete/default/.trash?recursive=true", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recursive delete = %d %s", rec.Code, rec.Body)
+	}
+	if !objectExists(t, server, ".trash/docs/old.txt") {
+		t.Error("recursive delete removed an object from the trash")
+	}
+}
+
+// objectExists reports whether key is stored in the default bucket of server
+func objectExists(t *testing.T, server *Server, key string) bool {
+	t.Helper()
+	_, err := testStore(server).GetObjectInfo(context.Background(), "default", key)
+	if err != nil && !storage.IsNotFound(err) {
+		t.Fatalf("GetObjectInfo %s: %v", key, err)
+	}
+	return err == nil
+}
+
+func TestUploadBucketForms(t *testing.T) {
+	server := newTestServer(t, "")
+	if err := testStore(server).CreateBucket(context.Background(), "photos"); err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]struct{ bucket, object string }{
+		"/upload/photos/2024/a.txt": {"photos", "2024/a.txt"},
+		"/upload//2024/b.txt":       {"default", "2024/b.txt"},
+		"/upload/c.txt":             {"default", "c.txt"},
+	} {
+		rec := serve(server, http.MethodPost, target, strings.NewReader("content"), map[string]string{"Content-Type": "text/plain"})
+		if rec.Code != http.StatusOK {
+			t.Errorf("POST %s = %d %s", target, rec.Code, rec.Body)
+			continue
+		}
+		if _, err := testStore(server).GetObjectInfo(context.Background(), want.bucket, want.object); err != nil {
+			t.Errorf("POST %s didn't store %s/%s: %v", target, want.bucket, want.object, err)
+		}
+	}
+}
+
+func TestUploadWithoutDefaultBucket(t *testing.T) {
+	server := newTestServer(t, "")
+	// LoadConfig always fills in a default bucket
+	server.config().Storage.Bucket = ""
+
+	for _, target := range []string{"/upload//a.txt", "/upload/a.txt"} {
+		rec := serve(server, http.MethodPost, target, strings.NewReader("content"), nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "no default bucket") {
+			t.Errorf("POST %s = %d %s, want 400 for the missing bucket", target, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestListObjectsPastOneThousand(t *testing.T) {
+	const count = 2500
+	server := newTestServer(t, "")
+	for i := range count {
+		putObject(t, server, fmt.Sprintf("logs/%05d.json", i), "{}")
+	}
+	putObject(t, server, "other.txt", "left out")
+
+	rec := serve(server, http.MethodGet, "/list/default?prefix=logs/", nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /list = %d %s", rec.Code, rec.Body)
+	}
+	objects := listedObjects(t, rec.Body.Bytes())
+	if len(objects) != count {
+		t.Fatalf("GET /list returned %d objects, want %d", len(objects), count)
+	}
+	if last := objects[count-1]["Name"]; last != "logs/02499.json" {
+		t.Errorf("last object = %v, want logs/02499.json", last)
+	}
+}
+
+func TestUploadIfNotExists(t *testing.T) {
+	server := newTestServer(t, "")
+	conditions := map[string]struct {
+		query   string
+		headers map[string]string
+	}{
+		"query":  {query: "?if_not_exists=true"},
+		"header": {headers: map[string]string{"If-None-Match": "*"}},
+	}
+	for name, cond := range conditions {
+		t.Run(name, func(t *testing.T) {
+			target := "/upload/default/" + name + ".txt" + cond.query
+			if rec := serve(server, http.MethodPost, target, strings.NewReader("first"), cond.headers); rec.Code != http.StatusOK {
+				t.Fatalf("first POST = %d %s, want 200", rec.Code, rec.Body)
+			}
+			if rec := serve(server, http.MethodPost, target, strings.NewReader("second"), cond.headers); rec.Code != http.StatusConflict {
+				t.Fatalf("second POST = %d %s, want 409", rec.Code, rec.Body)
+			}
+			if got := readObject(t, server, name+".txt"); got != "first" {
+				t.Errorf("object = %q, want the first upload kept", got)
+			}
+		})
+	}
+
+	// A plain upload still overwrites
+	if rec := serve(server, http.MethodPost, "/upload/default/query.txt", strings.NewReader("third"), nil); rec.Code != http.StatusOK {
+		t.Fatalf("plain POST = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "query.txt"); got != "third" {
+		t.Errorf("object = %q, want %q", got, "third")
+	}
+
+	rec := serve(server, http.MethodPost, "/upload/default/query.txt?if_not_exists=true", strings.NewReader("x"), map[string]string{"If-Match": `"etag"`})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with If-Match and if_not_exists = %d %s, want 400", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// defaultShareExpiry is the share link lifetime when ?expiry= is not given
+const defaultShareExpiry = time.Hour
+
+var (
+	// errShareSignature is returned for malformed or tampered share tokens
+	errShareSignature = errors.New("invalid share token signature")
+
+	// errShareExpired is returned for share tokens past their expiry
+	errShareExpired = errors.New("share token has expired")
+)
+
+// shareClaims is the payload signed into a share token
+type shareClaims struct {
+	Backend string `json:"s"`
+	Bucket  string `json:"b"`
+	Object  string `json:"o"`
+	Expires int64  `json:"e"`
+}
+
+// createShareLink handles requests for an expiring link to an object that is
+// served by this service rather than by the cloud provider
+func (s *Server) createShareLink(c *gin.Context) {
+	secret := s.config().Server.Share.Secret
+	if secret == "" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Share links are not configured"})
+		return
+	}
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+
+	expiry := defaultShareExpiry
+	if value := c.Query("expiry"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry, expected a positive number of seconds"})
+			return
+		}
+		expiry = time.Duration(seconds) * time.Second
+	}
+	if maxExpiry := s.config().Server.Share.MaxExpiry; maxExpiry > 0 && expiry > maxExpiry {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expiry must not exceed %d seconds", int64(maxExpiry/time.Second))})
+		return
+	}
+
+	// Only share objects that exist
+	store := s.storageFor(c)
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	if _, err := store.GetObjectInfo(ctx, bucket, object); err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to get object info: %v", err)})
+		return
+	}
+
+	expiresAt := time.Now().Add(expiry)
+	token, err := signShareToken(secret, shareClaims{
+		Backend: c.GetString(backendContextKey),
+		Bucket:  bucket,
+		Object:  storedKeyPrefix(c) + object,
+		Expires: expiresAt.Unix(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create share link: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":        "/shared/" + token,
+		"bucket":     bucket,
+		"object":     object,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// downloadShared streams the object referenced by a valid share token
+func (s *Server) downloadShared(c *gin.Context) {
+	secret := s.config().Server.Share.Secret
+	if secret == "" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Share links are not configured"})
+		return
+	}
+
+	claims, err := verifyShareToken(secret, c.Param("token"), time.Now())
+	switch {
+	case errors.Is(err, errShareExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Share link has expired"})
+		return
+	case err != nil:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid share link"})
+		return
+	}
+
+	store, exists := s.storages[claims.Backend]
+	if !exists {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid share link"})
+		return
+	}
+	c.Set(backendContextKey, claims.Backend)
+	setPrincipal(c, sharePrincipal)
+
+	s.streamObject(c, store, claims.Bucket, claims.Object, http.StatusOK)
+}
+
+// signShareToken encodes the claims and appends their HMAC-SHA256 signature,
+// so the token is self-contained and can't be altered without the secret
+func signShareToken(secret string, claims shareClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + shareSignature(secret, encoded), nil
+}
+
+// verifyShareToken checks a token's signature before its expiry and returns its claims
+func verifyShareToken(secret, token string, now time.Time) (*shareClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(shareSignature(secret, encoded))) {
+		return nil, errShareSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errShareSignature
+	}
+	var claims shareClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errShareSignature
+	}
+
+	if now.Unix() >= claims.Expires {
+		return nil, errShareExpired
+	}
+	return &claims, nil
+}
+
+// shareSignature returns the base64url HMAC-SHA256 of the encoded payload
+func shareSignature(secret, encoded string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// prefixStat accumulates the size of the objects under a prefix
+type prefixStat struct {
+	ObjectCount  int64
+	TotalSize    int64
+	LastModified time.Time
+}
+
+// add counts one object
+func (p *prefixStat) add(obj storage.FileObject) {
+	p.ObjectCount++
+	p.TotalSize += obj.Size
+	if obj.LastModified.After(p.LastModified) {
+		p.LastModified = obj.LastModified
+	}
+}
+
+// response renders the totals, leaving out last_modified for an empty prefix
+func (p *prefixStat) response() gin.H {
+	result := gin.H{
+		"object_count": p.ObjectCount,
+		"total_size":   p.TotalSize,
+	}
+	if !p.LastModified.IsZero() {
+		result["last_modified"] = p.LastModified.UTC().Format(time.RFC3339)
+	}
+	return result
+}
+
+// statPrefix handles requests for the object count and total size under a
+// prefix. The listing is walked page by page, so only the totals are kept in memory.
+func (s *Server) statPrefix(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+
+	// Ensure prefix ends with "/" to denote a directory
+	prefix, ok := objectParam(c, "prefix")
+	if !ok {
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	breakdown := c.Query("breakdown") == "true"
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	var total prefixStat
+	subdirs := make(map[string]*prefixStat)
+	err := store.Walk(ctx, bucket, prefix, func(obj storage.FileObject) error {
+		// Skip directory markers
+		if obj.IsDir || strings.HasSuffix(obj.Name, "/") {
+			return nil
+		}
+
+		total.add(obj)
+		if breakdown {
+			rest := strings.TrimPrefix(obj.Name, prefix)
+			if i := strings.Index(rest, "/"); i >= 0 {
+				name := rest[:i+1]
+				if subdirs[name] == nil {
+					subdirs[name] = &prefixStat{}
+				}
+				subdirs[name].add(obj)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bucket not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list objects: %v", err)})
+		return
+	}
+
+	result := total.response()
+	result["bucket"] = bucket
+	result["prefix"] = prefix
+	if breakdown {
+		directories := make(gin.H, len(subdirs))
+		for name, stat := range subdirs {
+			directories[name] = stat.response()
+		}
+		result["directories"] = directories
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/config"
+	"github.com/example/file-service/storage"
+)
+
+// staticSiteContextKey is the gin context key marking a download served by
+// serveStaticSite, which browsers should display rather than save
+const staticSiteContextKey = "static_site"
+
+// serveStaticSite serves a download the way a static web host would: a path
+// ending in a slash, or the bucket root, serves the index document in that
+// directory, a directory requested without its slash is redirected to it so
+// relative links resolve, and a missing object is answered with the error
+// document and 404 Not Found
+func (s *Server) serveStaticSite(c *gin.Context, store storage.Storage, bucket, object string, site config.StaticSiteConfig) {
+	c.Set(staticSiteContextKey, true)
+	if site.IndexDocument != "" && (object == "" || strings.HasSuffix(object, "/")) {
+		object += site.IndexDocument
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+	_, err := store.GetObjectInfo(ctx, bucket, object)
+	if !storage.IsNotFound(err) {
+		// Other errors are reported by streamObject, which looks the object up again
+		s.streamObject(c, store, bucket, object, http.StatusOK)
+		return
+	}
+
+	if site.IndexDocument != "" && !strings.HasSuffix(object, "/"+site.IndexDocument) && object != site.IndexDocument {
+		if _, err := store.GetObjectInfo(ctx, bucket, object+"/"+site.IndexDocument); err == nil {
+			target := url.URL{Path: c.Request.URL.Path + "/", RawQuery: c.Request.URL.RawQuery}
+			c.Redirect(http.StatusFound, target.String())
+			return
+		}
+	}
+
+	if site.ErrorDocument != "" {
+		s.streamObject(c, store, bucket, site.ErrorDocument, http.StatusNotFound)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const staticSiteConfig = "server:\n  static_site:\n    index_document: index.html\n    error_document: 404.html\n"
+
+func TestStaticSiteIndexDocument(t *testing.T) {
+	server := newTestServer(t, staticSiteConfig)
+	putObject(t, server, "index.html", "home")
+	putObject(t, server, "docs/index.html", "docs home")
+
+	for target, want := range map[string]string{
+		"/download/default/":      "home",
+		"/download/default/docs/": "docs home",
+	} {
+		rec := serve(server, http.MethodGet, target, nil, nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want 200 %q", target, rec.Code, rec.Body, want)
+		}
+		if disposition := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "inline") {
+			t.Errorf("GET %s Content-Disposition = %q, want inline", target, disposition)
+		}
+	}
+}
+
+func TestStaticSiteDirectoryRedirect(t *testing.T) {
+	server := newTestServer(t, staticSiteConfig)
+	putObject(t, server, "docs/index.html", "docs home")
+
+	rec := serve(server, http.MethodGet, "/download/default/docs?lang=en", nil, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET /download/default/docs = %d, want 302", rec.Code)
+	}
+	if location := rec.Header().Get("Location"); location != "/download/default/docs/?lang=en" {
+		t.Errorf("Location = %q, want /download/default/docs/?lang=en", location)
+	}
+}
+
+func TestStaticSiteErrorDocument(t *testing.T) {
+	server := newTestServer(t, staticSiteConfig)
+	putObject(t, server, "404.html", "not here")
+
+	rec := serve(server, http.MethodGet, "/download/default/missing.html", nil, nil)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "not here" {
+		t.Errorf("GET missing object = %d %q, want 404 %q", rec.Code, rec.Body, "not here")
+	}
+
+	// A directory without an index document is missing too
+	rec = serve(server, http.MethodGet, "/download/default/empty/", nil, nil)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "not here" {
+		t.Errorf("GET directory without index = %d %q, want 404 %q", rec.Code, rec.Body, "not here")
+	}
+}
+
+func TestStaticSiteWithoutErrorDocument(t *testing.T) {
+	server := newTestServer(t, "server:\n  static_site:\n    index_document: index.html\n")
+
+	rec := serve(server, http.MethodGet, "/download/default/missing.html", nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing object = %d, want 404", rec.Code)
+	}
+}
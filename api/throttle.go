@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// downloadRateContextKey is the gin context key holding the download rate
+// limit of the request's API key, see AuthConfig.DownloadRateLimits
+const downloadRateContextKey = "download_rate_limit"
+
+// throttleChunksPerSecond is how many pieces a second's worth of data is
+// written in, so the rate stays smooth instead of arriving in bursts
+const throttleChunksPerSecond = 10
+
+// throttledWriter paces writes to a client to a fixed number of bytes per
+// second by sleeping between chunks. It stops as soon as the request context
+// is done, so a client that disconnects doesn't keep holding a request slot.
+type throttledWriter struct {
+	w       io.Writer
+	ctx     context.Context
+	rate    int64 // bytes per second
+	start   time.Time
+	written int64
+}
+
+// Write writes p in chunks, waiting after each until the bytes sent so far are due
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	chunk := max(t.rate/throttleChunksPerSecond, 1)
+	total := 0
+	for len(p) > 0 {
+		n, err := t.w.Write(p[:min(int64(len(p)), chunk)])
+		total += n
+		t.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		due := t.start.Add(time.Duration(float64(t.written) / float64(t.rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-t.ctx.Done():
+				timer.Stop()
+				return total, t.ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return total, nil
+}
+
+// downloadWriter returns the writer a download is streamed to: the response
+// itself, or a throttled wrapper when a download rate limit applies to the request
+func (s *Server) downloadWriter(c *gin.Context) io.Writer {
+	rate := s.config().Server.DownloadRateLimit
+	if limit, ok := c.Get(downloadRateContextKey); ok {
+		rate = limit.(int64)
+	}
+	if rate <= 0 {
+		return c.Writer
+	}
+	return &throttledWriter{w: c.Writer, ctx: c.Request.Context(), rate: rate, start: time.Now()}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// operationContext derives a context for a storage call bounded by the
+// operation timeout of the backend selected for the request
+func (s *Server) operationContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	if timeout := s.operationTimeout(c.GetString(backendContextKey)); timeout > 0 {
+		return context.WithTimeout(c.Request.Context(), timeout)
+	}
+	return context.WithCancel(c.Request.Context())
+}
+
+// operationTimeout returns the operation_timeout of the named backend, or
+// storage.operation_timeout when the backend doesn't set its own
+func (s *Server) operationTimeout(backend string) time.Duration {
+	cfg := s.config()
+	if timeout := cfg.Backends()[backend].OperationTimeout; timeout > 0 {
+		return timeout
+	}
+	return cfg.Storage.OperationTimeout
+}
+
+// downloadIdleTimeout returns the download_idle_timeout of the named backend,
+// or storage.download_idle_timeout when the backend doesn't set its own
+func (s *Server) downloadIdleTimeout(backend string) time.Duration {
+	cfg := s.config()
+	if timeout := cfg.Backends()[backend].DownloadIdleTimeout; timeout > 0 {
+		return timeout
+	}
+	return cfg.Storage.DownloadIdleTimeout
+}
+
+// storageErrorStatus maps a failed storage call to the HTTP status returned
+// to the client. Unexpected backend errors are logged with the provider's
+// details, which the client's message leaves out.
+func storageErrorStatus(ctx context.Context, err error) int {
+	// Some backends swallow the context error, so check the context as well
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if storage.IsObjectLocked(err) {
+		return http.StatusForbidden
+	}
+	if storage.IsNotFound(err) {
+		return http.StatusNotFound
+	}
+	if storage.IsObjectArchived(err) || errors.Is(err, errTrashOccupied) {
+		return http.StatusConflict
+	}
+	var storageErr *storage.Error
+	if errors.As(err, &storageErr) {
+		log.Printf("Storage error: backend=%s op=%s bucket=%q object=%q code=%q status=%d: %v",
+			storageErr.Backend, storageErr.Op, storageErr.Bucket, storageErr.Object, storageErr.Code, storageErr.StatusCode, storageErr.Err)
+	}
+	return http.StatusInternalServerError
+}
+
+// contextReader stops reading once its context is done, so backends that
+// ignore the context still abort the upload stream on timeout
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// idleTimeoutReader cancels a streaming download when no data arrives from
+// the backend for longer than the idle timeout
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timer     *time.Timer
+	timeout   time.Duration
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newIdleTimeoutReader wraps reader so that cancel is called and the reader is
+// closed once it has been idle for timeout; a zero timeout disables the check
+func newIdleTimeoutReader(reader io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) io.ReadCloser {
+	if timeout <= 0 {
+		return reader
+	}
+
+	r := &idleTimeoutReader{ReadCloser: reader, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		cancel()
+		// Closing the body unblocks backends that don't observe the context
+		r.Close()
+	})
+	return r
+}
+
+// Read reads from the backend and restarts the idle timer
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.timer.Reset(r.timeout)
+	return n, err
+}
+
+// Close stops the idle timer and closes the backend reader
+func (r *idleTimeoutReader) Close() error {
+	r.closeOnce.Do(func() {
+		r.timer.Stop()
+		r.closeErr = r.ReadCloser.Close()
+	})
+	return r.closeErr
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/example/file-service/storage"
+)
+
+// slowStore never answers GetObjectInfo, and records whether the call was
+// cancelled once its context ended
+type slowStore struct {
+	storage.Storage
+	cancelled chan error
+}
+
+func (s *slowStore) GetObjectInfo(ctx context.Context, bucket, objectName string) (*storage.FileObject, error) {
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestOperationTimeoutOfBackend(t *testing.T) {
+	server := newTestServer(t, `  operation_timeout: "1m"
+storages:
+  fast:
+    type: memory
+  slow:
+    type: memory
+    operation_timeout: "50ms"
+default_storage: fast
+`)
+	slow := &slowStore{Storage: server.storages["slow"], cancelled: make(chan error, 1)}
+	server.storages["slow"] = slow
+
+	started := time.Now()
+	rec := serve(server, http.MethodHead, "/info/default/report.pdf", nil, map[string]string{"X-Storage-Backend": "slow"})
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("HEAD /info = %d %s, want 504", rec.Code, rec.Body)
+	}
+	// The backend's own timeout applies rather than the storage section's
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("request took %v, want about 50ms", elapsed)
+	}
+	select {
+	case err := <-slow.cancelled:
+		if err != context.DeadlineExceeded {
+			t.Errorf("backend call ended with %v, want %v", err, context.DeadlineExceeded)
+		}
+	default:
+		t.Error("backend call was not cancelled")
+	}
+}
+
+func TestOperationTimeoutFallsBackToStorage(t *testing.T) {
+	server := newTestServer(t, `  operation_timeout: "50ms"
+storages:
+  slow:
+    type: memory
+default_storage: slow
+`)
+	slow := &slowStore{Storage: server.storages["slow"], cancelled: make(chan error, 1)}
+	server.storages["slow"] = slow
+
+	if rec := serve(server, http.MethodHead, "/info/default/report.pdf", nil, nil); rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("HEAD /info = %d %s, want 504", rec.Code, rec.Body)
+	}
+	if err := <-slow.cancelled; err != context.DeadlineExceeded {
+		t.Errorf("backend call ended with %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// trashPrefix is the key prefix soft-deleted objects are moved under, keeping
+// their original path after it
+const trashPrefix = ".trash/"
+
+// Metadata recorded on trashed objects
+const (
+	trashOriginalPathKey = "original_path"
+	trashDeletedAtKey    = "deleted_at"
+)
+
+// errTrashOccupied is returned when deleting an object whose path still has an
+// earlier copy in the trash, which the new one would replace
+var errTrashOccupied = errors.New("an earlier copy of the object is still in the trash; restore or purge it first")
+
+// defaultTrashRetention is used when storage.trash_retention is not set
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// isTrashed reports whether an object key is inside the trash
+func isTrashed(object string) bool {
+	return strings.HasPrefix(object, trashPrefix)
+}
+
+// moveToTrash soft-deletes an object by copying it under the trash prefix on
+// the server and then deleting the original. An If-Match header is checked
+// before the copy, and the original is only deleted if it is still the
+// version that was copied.
+func (s *Server) moveToTrash(c *gin.Context, ctx context.Context, store storage.Storage, bucket, object string) {
+	info, err := store.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to delete file: %v", err)})
+		return
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && ifMatch != "*" && info.ETag != strings.Trim(ifMatch, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Object has changed since it was read (ETag does not match If-Match)"})
+		return
+	}
+
+	trashObject, err := copyToTrash(ctx, store, bucket, object, info)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to move file to trash: %v", err)})
+		return
+	}
+	if err := store.DeleteIfMatch(ctx, bucket, object, info.ETag); err != nil {
+		if preconditionFailed(err) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Object changed while it was moved to trash; it was not deleted"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to delete file: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "File moved to trash",
+		"bucket":       bucket,
+		"object":       object,
+		"trash_object": trashObject,
+	})
+}
+
+// copyToTrash copies the object described by info under the trash prefix on
+// the server, recording its original path and the deletion time in the
+// copy's metadata, and returns the key of the copy. It fails with
+// errTrashOccupied rather than replace an earlier copy of the same path.
+func copyToTrash(ctx context.Context, store storage.Storage, bucket, object string, info *storage.FileObject) (string, error) {
+	trashObject := trashPrefix + object
+	if _, err := store.GetObjectInfo(ctx, bucket, trashObject); err == nil {
+		return "", errTrashOccupied
+	} else if !storage.IsNotFound(err) {
+		return "", err
+	}
+
+	metadata := make(map[string]string, len(info.Metadata)+2)
+	for k, v := range info.Metadata {
+		metadata[k] = v
+	}
+	metadata[trashOriginalPathKey] = object
+	metadata[trashDeletedAtKey] = time.Now().UTC().Format(time.RFC3339)
+
+	return trashObject, store.Copy(ctx, bucket, object, trashObject, metadata)
+}
+
+// trashObject soft-deletes an object the way moveToTrash does, without an
+// If-Match check, and returns the key of its trash copy. The original is only
+// deleted if it is still the version that was copied.
+func trashObject(ctx context.Context, store storage.Storage, bucket, object string) (string, error) {
+	info, err := store.GetObjectInfo(ctx, bucket, object)
+	if err != nil {
+		return "", err
+	}
+	trashed, err := copyToTrash(ctx, store, bucket, object, info)
+	if err != nil {
+		return "", err
+	}
+	return trashed, store.DeleteIfMatch(ctx, bucket, object, info.ETag)
+}
+
+// trashAll soft-deletes every listed object the way moveToTrash does a single
+// one, returning the result for each. An object that changed while it was
+// copied is left in place and reported with 412.
+func trashAll(ctx context.Context, store storage.Storage, bucket string, objects []storage.FileObject) []bulkResult {
+	results := make([]bulkResult, len(objects))
+	for i, obj := range objects {
+		results[i] = bulkResult{Object: obj.Name}
+		if _, err := trashObject(ctx, store, bucket, obj.Name); err != nil {
+			results[i] = bulkFailure(ctx, obj.Name, err)
+		}
+	}
+	return results
+}
+
+// restoreFile handles requests to move a soft-deleted object back to its
+// original path. An existing object at that path is only replaced with
+// ?overwrite=true.
+func (s *Server) restoreFile(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	object, ok := objectParam(c, "object")
+	if !ok {
+		return
+	}
+	// Accept the trash key as well as the original path
+	object = strings.TrimPrefix(object, trashPrefix)
+	trashObject := trashPrefix + object
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	info, err := store.GetObjectInfo(ctx, bucket, trashObject)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found in trash"})
+			return
+		}
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to restore file: %v", err)})
+		return
+	}
+
+	if c.Query("overwrite") != "true" {
+		if _, err := store.GetObjectInfo(ctx, bucket, object); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "An object already exists at the original path; use overwrite=true to replace it"})
+			return
+		} else if !storage.IsNotFound(err) {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to restore file: %v", err)})
+			return
+		}
+	}
+
+	// Drop the trash bookkeeping from the restored object's metadata
+	metadata := make(map[string]string, len(info.Metadata))
+	for k, v := range info.Metadata {
+		if !strings.EqualFold(k, trashOriginalPathKey) && !strings.EqualFold(k, trashDeletedAtKey) {
+			metadata[k] = v
+		}
+	}
+
+	if err := store.Copy(ctx, bucket, trashObject, object, metadata); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to restore file: %v", err)})
+		return
+	}
+	if err := store.Delete(ctx, bucket, trashObject); err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to remove file from trash: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File restored successfully",
+		"bucket":  bucket,
+		"object":  object,
+	})
+}
+
+// purgeTrash handles requests to permanently delete trashed objects older than
+// storage.trash_retention, or than ?older_than= when given
+func (s *Server) purgeTrash(c *gin.Context) {
+	store := s.storageFor(c)
+	bucket := c.Param("bucket")
+
+	retention := s.backendConfig(c).TrashRetention
+	if retention == 0 {
+		retention = defaultTrashRetention
+	}
+	if value := c.Query("older_than"); value != "" {
+		var err error
+		retention, err = time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid older_than, expected a duration such as 720h"})
+			return
+		}
+	}
+	cutoff := time.Now().Add(-retention)
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	// The copy into the trash sets the modification time, so it is the deletion time
+	var expired []storage.FileObject
+	err := store.Walk(ctx, bucket, trashPrefix, func(obj storage.FileObject) error {
+		if !obj.LastModified.IsZero() && obj.LastModified.Before(cutoff) {
+			expired = append(expired, obj)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list trash: %v", err)})
+		return
+	}
+
+	results := deleteAll(ctx, store, bucket, expired)
+	deleted, errors := deleteSummary(results)
+
+	c.JSON(bulkStatus(bulkStatuses(results)), gin.H{
+		"bucket":  bucket,
+		"deleted": deleted,
+		"errors":  errors,
+		"objects": results,
+	})
+}
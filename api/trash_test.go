@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestDeleteRestoreRoundTrip(t *testing.T) {
+	server := newTestServer(t, "  soft_delete: true\n")
+	putObject(t, server, "docs/report.txt", "quarterly")
+	if err := testStore(server).UpdateMetadata(context.Background(), "default", "docs/report.txt", map[string]string{"owner": "finance"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(server, http.MethodDelete, "/delete/default/docs/report.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, "docs/report.txt") {
+		t.Fatal("deleted object is still in place")
+	}
+	info, err := testStore(server).GetObjectInfo(context.Background(), "default", trashPrefix+"docs/report.txt")
+	if err != nil {
+		t.Fatalf("trash copy: %v", err)
+	}
+	if info.Metadata[trashOriginalPathKey] != "docs/report.txt" || info.Metadata[trashDeletedAtKey] == "" {
+		t.Errorf("trash copy metadata = %v", info.Metadata)
+	}
+
+	if rec := serve(server, http.MethodPost, "/restore/default/docs/report.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("POST /restore = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "docs/report.txt"); got != "quarterly" {
+		t.Errorf("restored content = %q, want %q", got, "quarterly")
+	}
+	if objectExists(t, server, trashPrefix+"docs/report.txt") {
+		t.Error("trash copy is left after the restore")
+	}
+	info, err = testStore(server).GetObjectInfo(context.Background(), "default", "docs/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.Metadata[trashOriginalPathKey]; ok || info.Metadata["owner"] != "finance" {
+		t.Errorf("restored metadata = %v, want only the original metadata", info.Metadata)
+	}
+}
+
+func TestDeleteKeepsEarlierTrashCopy(t *testing.T) {
+	server := newTestServer(t, "  soft_delete: true\n")
+	putObject(t, server, "notes.txt", "first")
+	if rec := serve(server, http.MethodDelete, "/delete/default/notes.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("first DELETE = %d %s", rec.Code, rec.Body)
+	}
+
+	// A second object at the same path can't replace the first in the trash
+	putObject(t, server, "notes.txt", "second")
+	if rec := serve(server, http.MethodDelete, "/delete/default/notes.txt", nil, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("second DELETE = %d %s, want 409", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, "notes.txt"); got != "second" {
+		t.Errorf("object = %q, want it left in place", got)
+	}
+	if got := readObject(t, server, trashPrefix+"notes.txt"); got != "first" {
+		t.Errorf("trash copy = %q, want the first deletion kept", got)
+	}
+
+	// Once the earlier copy is purged the object can be deleted
+	if rec := serve(server, http.MethodDelete, "/trash/default?older_than=0s", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE /trash = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(server, http.MethodDelete, "/delete/default/notes.txt", nil, nil); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE after purge = %d %s", rec.Code, rec.Body)
+	}
+	if got := readObject(t, server, trashPrefix+"notes.txt"); got != "second" {
+		t.Errorf("trash copy = %q, want %q", got, "second")
+	}
+}
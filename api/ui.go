@@ -0,0 +1,24 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiFiles holds the static browser UI, which only uses the JSON API
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// serveUI serves the files of the browser UI under /ui
+func (s *Server) serveUI(c *gin.Context) {
+	if !s.config().Server.UI.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The UI is not enabled"})
+		return
+	}
+	files, _ := fs.Sub(uiFiles, "ui")
+	c.FileFromFS(c.Param("filepath"), http.FS(files))
+}
@@ -0,0 +1,255 @@
+// Browser UI for file-service. It only talks to the JSON API, sending the API
+// key from session storage in the X-API-Key header.
+"use strict";
+
+const keyStorage = "file-service-api-key";
+
+const state = {
+  bucket: "",
+  prefix: "",
+};
+
+const $ = (id) => document.getElementById(id);
+
+// encodeKey percent-encodes each segment of an object key for use in a path
+function encodeKey(key) {
+  return key.split("/").map(encodeURIComponent).join("/");
+}
+
+class UnauthorizedError extends Error {}
+
+// api calls the service and returns the response, failing on error statuses
+async function api(path, options = {}) {
+  const headers = new Headers(options.headers || {});
+  const key = sessionStorage.getItem(keyStorage);
+  if (key) {
+    headers.set("X-API-Key", key);
+  }
+  const resp = await fetch(path, { ...options, headers });
+  if (resp.status === 401) {
+    throw new UnauthorizedError("API key is required");
+  }
+  if (!resp.ok) {
+    let message = resp.statusText;
+    try {
+      message = (await resp.json()).error || message;
+    } catch (e) {
+      // Keep the status text when the body isn't JSON
+    }
+    throw new Error(message);
+  }
+  return resp;
+}
+
+function showLogin(message) {
+  $("browser").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("login-error").textContent = message || "";
+  $("api-key").focus();
+}
+
+function showStatus(message, isError) {
+  const status = $("status");
+  status.textContent = message || "";
+  status.className = isError ? "error" : "";
+}
+
+// fail shows an error, or the login form when the key was refused
+function fail(err) {
+  if (err instanceof UnauthorizedError) {
+    // A stored key that stopped working is worth mentioning, a missing one isn't
+    const hadKey = sessionStorage.getItem(keyStorage) !== null;
+    sessionStorage.removeItem(keyStorage);
+    showLogin(hadKey ? "Invalid API key" : "");
+    return;
+  }
+  showStatus(err.message, true);
+}
+
+function formatSize(size) {
+  const units = ["B", "KB", "MB", "GB", "TB"];
+  let i = 0;
+  while (size >= 1024 && i < units.length - 1) {
+    size /= 1024;
+    i++;
+  }
+  return (i === 0 ? size : size.toFixed(1)) + " " + units[i];
+}
+
+async function loadBuckets() {
+  const resp = await api("/buckets");
+  const { buckets } = await resp.json();
+  const list = $("buckets");
+  list.replaceChildren();
+  for (const bucket of buckets) {
+    const link = document.createElement("a");
+    link.textContent = bucket.name;
+    link.dataset.bucket = bucket.name;
+    link.addEventListener("click", () => openDirectory(bucket.name, ""));
+    const item = document.createElement("li");
+    item.append(link);
+    list.append(item);
+  }
+  $("login").hidden = true;
+  $("browser").hidden = false;
+  $("logout").hidden = !sessionStorage.getItem(keyStorage);
+  if (buckets.length === 0) {
+    showStatus("No buckets");
+  } else if (!state.bucket) {
+    openDirectory(buckets[0].name, "");
+  }
+}
+
+// openDirectory shows the directories and files directly under prefix in bucket
+async function openDirectory(bucket, prefix) {
+  state.bucket = bucket;
+  state.prefix = prefix;
+  for (const link of $("buckets").querySelectorAll("a")) {
+    link.classList.toggle("selected", link.dataset.bucket === bucket);
+  }
+  renderBreadcrumb();
+  showStatus("Loading…");
+
+  try {
+    const resp = await api("/browse/" + encodeURIComponent(bucket) + "?prefix=" + encodeURIComponent(prefix));
+    const { objects } = await resp.json();
+    renderEntries(objects.filter((obj) => obj.IsDir), objects.filter((obj) => !obj.IsDir));
+    showStatus("");
+  } catch (err) {
+    fail(err);
+  }
+}
+
+function renderBreadcrumb() {
+  const crumbs = $("breadcrumb");
+  crumbs.replaceChildren();
+  const parts = state.prefix.split("/").filter(Boolean);
+  const add = (label, prefix) => {
+    const link = document.createElement("a");
+    link.textContent = label;
+    link.addEventListener("click", () => openDirectory(state.bucket, prefix));
+    const item = document.createElement("li");
+    item.append(link);
+    crumbs.append(item);
+  };
+  add(state.bucket, "");
+  parts.forEach((part, i) => add(part, parts.slice(0, i + 1).join("/") + "/"));
+}
+
+function renderEntries(directories, files) {
+  const rows = $("entries");
+  rows.replaceChildren();
+  const row = (link, size, modified) => {
+    const tr = document.createElement("tr");
+    for (const value of [link, size, modified]) {
+      const td = document.createElement("td");
+      td.append(value);
+      tr.append(td);
+    }
+    rows.append(tr);
+  };
+
+  for (const dir of directories) {
+    const link = document.createElement("a");
+    link.textContent = dir.Name.slice(state.prefix.length);
+    link.addEventListener("click", () => openDirectory(state.bucket, dir.Name));
+    row(link, "", "");
+  }
+  for (const file of files) {
+    const link = document.createElement("a");
+    link.textContent = file.Name.slice(state.prefix.length);
+    link.title = file.ContentType;
+    link.addEventListener("click", () => openFile(file));
+    const modified = file.LastModified && !file.LastModified.startsWith("0001-")
+      ? new Date(file.LastModified).toLocaleString()
+      : "";
+    row(link, formatSize(file.Size), modified);
+  }
+  if (directories.length === 0 && files.length === 0) {
+    showStatus("This directory is empty");
+  }
+}
+
+// openFile previews images and downloads anything else
+async function openFile(file) {
+  try {
+    const resp = await api("/download/" + encodeURIComponent(state.bucket) + "/" + encodeKey(file.Name));
+    const url = URL.createObjectURL(await resp.blob());
+    const name = file.Name.slice(file.Name.lastIndexOf("/") + 1);
+    if ((file.ContentType || "").startsWith("image/")) {
+      $("preview-image").src = url;
+      $("preview-name").textContent = name;
+      $("preview").hidden = false;
+      return;
+    }
+    const link = document.createElement("a");
+    link.href = url;
+    link.download = name;
+    link.click();
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+  } catch (err) {
+    fail(err);
+  }
+}
+
+async function upload(files) {
+  if (!state.bucket) {
+    return;
+  }
+  const bucket = state.bucket;
+  const prefix = state.prefix;
+  try {
+    for (const file of files) {
+      showStatus("Uploading " + file.name + "…");
+      await api("/upload/" + encodeURIComponent(bucket) + "/" + encodeKey(prefix + file.name), {
+        method: "POST",
+        headers: { "Content-Type": file.type || "application/octet-stream" },
+        body: file,
+      });
+    }
+    await openDirectory(bucket, prefix);
+  } catch (err) {
+    fail(err);
+  }
+}
+
+$("login").addEventListener("submit", (event) => {
+  event.preventDefault();
+  sessionStorage.setItem(keyStorage, $("api-key").value);
+  $("api-key").value = "";
+  loadBuckets().catch(fail);
+});
+
+$("logout").addEventListener("click", () => {
+  sessionStorage.removeItem(keyStorage);
+  state.bucket = "";
+  state.prefix = "";
+  showLogin("");
+});
+
+$("file-input").addEventListener("change", (event) => {
+  upload([...event.target.files]);
+  event.target.value = "";
+});
+
+$("preview").addEventListener("click", () => {
+  $("preview").hidden = true;
+  URL.revokeObjectURL($("preview-image").src);
+  $("preview-image").removeAttribute("src");
+});
+
+const dropZone = $("objects");
+dropZone.addEventListener("dragover", (event) => {
+  event.preventDefault();
+  dropZone.classList.add("dragging");
+});
+dropZone.addEventListener("dragleave", () => dropZone.classList.remove("dragging"));
+dropZone.addEventListener("drop", (event) => {
+  event.preventDefault();
+  dropZone.classList.remove("dragging");
+  upload([...event.dataTransfer.files]);
+});
+
+// Without auth, or with a key from earlier in this session, go straight in
+loadBuckets().catch(fail);
@@ -0,0 +1,434 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// dirEntry is one file of a directory upload, named relative to the prefix
+type dirEntry struct {
+	name        string
+	contentType string // declared by the client; empty for archive entries
+	reader      io.Reader
+}
+
+// dirEntries yields the files of a directory upload one at a time, returning
+// io.EOF after the last. An entry's reader is only valid until the next call.
+type dirEntries interface {
+	next() (*dirEntry, error)
+}
+
+// multipartEntries reads the files of a multipart form; fields without a
+// file name are skipped
+type multipartEntries struct {
+	r *multipart.Reader
+}
+
+func (m *multipartEntries) next() (*dirEntry, error) {
+	for {
+		part, err := m.r.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		// Part.FileName drops the directories browsers send for folder uploads
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if name := params["filename"]; name != "" {
+			return &dirEntry{name: name, contentType: part.Header.Get("Content-Type"), reader: part}, nil
+		}
+	}
+}
+
+// tarEntries reads the regular files of a tar archive as it arrives
+type tarEntries struct {
+	r *tar.Reader
+}
+
+func (t *tarEntries) next() (*dirEntry, error) {
+	for {
+		header, err := t.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header.FileInfo().Mode().IsRegular() {
+			return &dirEntry{name: header.Name, reader: t.r}, nil
+		}
+	}
+}
+
+// zipEntries reads the regular files of a ZIP archive spooled to disk
+type zipEntries struct {
+	files   []*zip.File
+	current io.ReadCloser
+}
+
+func (z *zipEntries) next() (*dirEntry, error) {
+	if z.current != nil {
+		z.current.Close()
+		z.current = nil
+	}
+	for len(z.files) > 0 {
+		file := z.files[0]
+		z.files = z.files[1:]
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		z.current = reader
+		return &dirEntry{name: file.Name, reader: reader}, nil
+	}
+	return nil, io.EOF
+}
+
+// uploadDirResult is the outcome for one file of a directory upload, with
+// what was stored when it succeeded
+type uploadDirResult struct {
+	bulkResult
+	RequestedObject string `json:"requested_object,omitempty"`
+	Size            int64  `json:"size,omitempty"`
+	ETag            string `json:"etag,omitempty"`
+	VersionID       string `json:"version_id,omitempty"`
+}
+
+// uploadDirectory handles uploads of many files at once, sent as a multipart
+// form or a ZIP or tar archive, and stores them under a prefix. Each file is
+// spooled to a temporary file and stored by its own goroutine, at most
+// server.upload.dir_concurrency at a time; the request body isn't read on
+// while all of them are busy, so a large upload is slowed down rather than
+// held in memory. The response is a manifest of every file, and a failed
+// file doesn't stop the others but is reflected in the status as for other
+// bulk operations.
+func (s *Server) uploadDirectory(c *gin.Context) {
+	store := s.storageFor(c)
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	if bucket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bucket is required: none given in the path and no default bucket is configured"})
+		return
+	}
+	prefix, ok := objectParam(c, "prefix")
+	if !ok {
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ifNotExists := c.Query("if_not_exists") == "true"
+
+	entries, closeEntries, ok := openDirUpload(c)
+	if !ok {
+		return
+	}
+	defer closeEntries()
+
+	// The operation timeout covers reading and storing every file
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	// Create the bucket on first upload if configured to
+	if s.backendConfig(c).AutoCreateBucket {
+		if err := s.ensureBucket(ctx, c, bucket); err != nil {
+			c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to create bucket: %v", err)})
+			return
+		}
+	}
+
+	uploadCfg := s.config().Server.Upload
+	// Workers fill in their result through the pointer, so appending is safe
+	var results []*uploadDirResult
+	var readErr error
+	tooMany := false
+	slots := make(chan struct{}, uploadCfg.DirConcurrency)
+	var wg sync.WaitGroup
+	for {
+		entry, err := entries.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		if uploadCfg.DirMaxFiles > 0 && len(results) == uploadCfg.DirMaxFiles {
+			tooMany = true
+			break
+		}
+
+		result := &uploadDirResult{bulkResult: bulkResult{Object: prefix + entry.name}}
+		results = append(results, result)
+		key, contentType, reader, rejection := s.checkDirEntry(c, prefix, entry)
+		if rejection.Status != 0 {
+			result.bulkResult = rejection
+			continue
+		}
+
+		// Wait for a free worker before reading the file, so the client is
+		// held back instead of the upload piling up on disk
+		slots <- struct{}{}
+		spool, size, err := spoolFile(reader)
+		if err != nil {
+			<-slots
+			result.Error, result.Status = fmt.Sprintf("Failed to read file: %v", err), http.StatusBadRequest
+			readErr = err
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer os.Remove(spool.Name())
+			defer spool.Close()
+			*result = s.storeDirFile(ctx, c, store, bucket, key, contentType, spool, size, ifNotExists)
+		}()
+	}
+	wg.Wait()
+
+	// Files stored before the upload was cut short are kept and listed
+	switch {
+	case tooMany:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   fmt.Sprintf("Too many files: at most %d allowed in one upload", uploadCfg.DirMaxFiles),
+			"bucket":  bucket,
+			"prefix":  prefix,
+			"objects": results,
+		})
+		return
+	case readErr != nil:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   fmt.Sprintf("Failed to read upload: %v", readErr),
+			"bucket":  bucket,
+			"prefix":  prefix,
+			"objects": results,
+		})
+		return
+	case len(results) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files in upload"})
+		return
+	}
+
+	statuses := make([]int, len(results))
+	uploaded := 0
+	for i, result := range results {
+		statuses[i] = result.Status
+		if result.Status == 0 {
+			uploaded++
+		}
+	}
+	c.JSON(bulkStatus(statuses), gin.H{
+		"bucket":   bucket,
+		"prefix":   prefix,
+		"uploaded": uploaded,
+		"failed":   len(results) - uploaded,
+		"objects":  results,
+	})
+}
+
+// openDirUpload reads the files of a directory upload from the request body
+// by its content type, answering the request and returning false if it can't
+// be read. Multipart forms and tar archives are read as they arrive; a ZIP is
+// spooled to a temporary file first, since its index is at the end. The
+// returned function releases what was opened.
+func openDirUpload(c *gin.Context) (dirEntries, func(), bool) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid multipart form: %v", err)})
+			return nil, nil, false
+		}
+		return &multipartEntries{r: reader}, func() {}, true
+	case "application/x-tar":
+		return &tarEntries{r: tar.NewReader(c.Request.Body)}, func() {}, true
+	case "application/gzip", "application/x-gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid gzip archive: %v", err)})
+			return nil, nil, false
+		}
+		return &tarEntries{r: tar.NewReader(gz)}, func() { gz.Close() }, true
+	case "application/zip":
+		spool, size, err := spoolFile(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to read upload: %v", err)})
+			return nil, nil, false
+		}
+		release := func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+		archive, err := zip.NewReader(spool, size)
+		if err != nil {
+			release()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ZIP archive: %v", err)})
+			return nil, nil, false
+		}
+		entries := &zipEntries{files: archive.File}
+		return entries, func() {
+			if entries.current != nil {
+				entries.current.Close()
+			}
+			release()
+		}, true
+	}
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Expected multipart/form-data, application/zip, application/x-tar or application/gzip"})
+	return nil, nil, false
+}
+
+// checkDirEntry applies the checks of POST /upload to one file of a directory
+// upload before it is read: the key and content type it would be stored with
+// are returned, or the failure when server.upload refuses it
+func (s *Server) checkDirEntry(c *gin.Context, prefix string, entry *dirEntry) (string, string, *bufio.Reader, bulkResult) {
+	requested := prefix + entry.name
+	key, err := checkObjectKey(c, requested)
+	if err == nil && (key == "" || strings.HasSuffix(key, "/")) {
+		err = errors.New("not a file name")
+	}
+	if err != nil {
+		return "", "", nil, bulkResult{Object: requested, Error: fmt.Sprintf("Invalid object path: %v", err), Status: http.StatusBadRequest}
+	}
+	if reason := s.keyRejection(c, key); reason != "" {
+		return "", "", nil, bulkResult{Object: key, Error: reason, Status: http.StatusBadRequest}
+	}
+
+	// Buffered so the first bytes can be sniffed and still be uploaded
+	reader := bufio.NewReader(entry.reader)
+	contentType := entry.contentType
+	if s.config().Server.DetectContentType && (contentType == "" || contentType == "application/octet-stream") {
+		contentType = detectContentType(key, reader)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if reason := s.uploadRejection(key, contentType); reason != "" {
+		return "", "", nil, bulkResult{Object: key, Error: reason, Status: http.StatusUnsupportedMediaType}
+	}
+	if reason := s.sniffRejection(reader); reason != "" {
+		return "", "", nil, bulkResult{Object: key, Error: reason, Status: http.StatusUnsupportedMediaType}
+	}
+	return key, contentType, reader, bulkResult{}
+}
+
+// storeDirFile stores one spooled file of a directory upload, sanitizing its
+// key and scanning it as POST /upload would
+func (s *Server) storeDirFile(ctx context.Context, c *gin.Context, store storage.Storage, bucket, key, contentType string, spool *os.File, size int64, ifNotExists bool) uploadDirResult {
+	failed := func(object string, status int, message string) uploadDirResult {
+		return uploadDirResult{bulkResult: bulkResult{Object: object, Error: message, Status: status}}
+	}
+
+	// Store the object under a URL-safe key when configured to
+	requested := key
+	if s.config().Server.Upload.SanitizeKeys {
+		sanitized, err := s.sanitizedUploadKey(ctx, store, bucket, requested)
+		if errors.Is(err, errKeyCollision) {
+			return failed(requested, http.StatusConflict, err.Error())
+		}
+		if err != nil {
+			return uploadDirResult{bulkResult: bulkFailure(ctx, requested, err)}
+		}
+		if key, err = checkObjectKey(c, sanitized); err != nil {
+			return failed(requested, http.StatusBadRequest, fmt.Sprintf("Invalid object path: %v", err))
+		}
+		// Encoding can make the sanitized key longer than the requested one
+		if reason := s.keyRejection(c, key); reason != "" {
+			return failed(requested, http.StatusBadRequest, reason)
+		}
+	}
+
+	if err := store.EnsurePathExists(ctx, bucket, key); err != nil {
+		return uploadDirResult{bulkResult: bulkFailure(ctx, key, err)}
+	}
+
+	// Stream the file to the virus scanner as it is stored
+	scan, err := s.startVirusScan()
+	if err != nil {
+		return failed(key, http.StatusServiceUnavailable, fmt.Sprintf("Virus scanner unavailable: %v", err))
+	}
+	var upload io.Reader = spool
+	if scan != nil {
+		defer scan.Close()
+		upload = io.TeeReader(spool, scan)
+	}
+
+	body := &contextReader{ctx: ctx, reader: upload}
+	var result *storage.UploadResult
+	if ifNotExists {
+		result, err = store.UploadIfNotExists(ctx, bucket, key, body, size, contentType, storage.ObjectHeaders{})
+	} else {
+		result, err = store.Upload(ctx, bucket, key, body, size, contentType, storage.ObjectHeaders{})
+	}
+	if err != nil {
+		if ifNotExists && storage.IsObjectExists(err) {
+			return failed(key, http.StatusConflict, "Object already exists")
+		}
+		return uploadDirResult{bulkResult: bulkFailure(ctx, key, err)}
+	}
+
+	// Remove the object again when the scanner flags it
+	if scan != nil {
+		signature, err := s.scanVerdict(ctx, scan, store, bucket, key, result)
+		if signature != "" {
+			return failed(key, http.StatusUnprocessableEntity, fmt.Sprintf("File rejected by virus scan: %s", signature))
+		}
+		if err != nil {
+			return failed(key, http.StatusServiceUnavailable, fmt.Sprintf("Virus scan failed: %v", err))
+		}
+	}
+
+	// Remember the requested key of a sanitized object, as POST /upload does
+	stored := uploadDirResult{bulkResult: bulkResult{Object: key}, Size: size, ETag: result.ETag, VersionID: result.VersionID}
+	if key != requested {
+		stored.RequestedObject = requested
+		if err := store.UpdateMetadata(ctx, bucket, key, map[string]string{originalKeyMetadata: requested}, ""); err != nil {
+			return uploadDirResult{bulkResult: bulkFailure(ctx, key, err)}
+		}
+		if info, err := store.GetObjectInfo(ctx, bucket, key); err == nil {
+			stored.ETag, stored.VersionID = info.ETag, info.VersionID
+		}
+	}
+	return stored
+}
+
+// spoolFile copies a file to a temporary file, so it can be stored with a
+// known size while the request goes on to the next one. The caller closes
+// and removes it.
+func spoolFile(reader io.Reader) (*os.File, int64, error) {
+	spool, err := os.CreateTemp("", "file-service-upload-dir-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := io.Copy(spool, reader)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, 0, err
+	}
+	return spool, size, nil
+}
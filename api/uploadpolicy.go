@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadRejection explains why server.upload refuses an object, or returns ""
+// when the object may be stored. Every upload entry point checks it before
+// anything is written.
+func (s *Server) uploadRejection(object, contentType string) string {
+	if reason := s.extensionRejection(object); reason != "" {
+		return reason
+	}
+	if !contentTypeAllowed(s.config().Server.Upload.AllowedContentTypes, contentType) {
+		return fmt.Sprintf("Content type %s is not allowed", contentType)
+	}
+	return ""
+}
+
+// extensionRejection explains why server.upload.denied_extensions refuses an
+// object name, or returns "" when it is allowed
+func (s *Server) extensionRejection(object string) string {
+	ext := strings.ToLower(path.Ext(object))
+	if ext == "" {
+		return ""
+	}
+	for _, denied := range s.config().Server.Upload.DeniedExtensions {
+		if ext == normalizeExtension(denied) {
+			return fmt.Sprintf("Files with the %s extension are not allowed", ext)
+		}
+	}
+	return ""
+}
+
+// keyRejection explains why server.upload.max_key_length or max_path_depth
+// refuses an object key, or returns "" when it may be stored. The default
+// prefix of the bucket and the key prefix of the API key count, since they are
+// part of the stored key.
+func (s *Server) keyRejection(c *gin.Context, key string) string {
+	uploadCfg := s.config().Server.Upload
+	stored := storedKeyPrefix(c) + key
+	if uploadCfg.MaxKeyLength > 0 && len(stored) > uploadCfg.MaxKeyLength {
+		return fmt.Sprintf("Object key is %d bytes long, longer than the %d allowed", len(stored), uploadCfg.MaxKeyLength)
+	}
+	if depth := strings.Count(stored, "/"); uploadCfg.MaxPathDepth > 0 && depth > uploadCfg.MaxPathDepth {
+		return fmt.Sprintf("Object key has %d path separators, more than the %d allowed", depth, uploadCfg.MaxPathDepth)
+	}
+	return ""
+}
+
+// sniffRejection checks the first bytes of an upload against the allowed
+// content types when server.upload.verify_content_type is set, so a client
+// can't get around the allowlist by declaring a different type. The bytes
+// stay in body for the upload. It returns "" when the content is allowed.
+func (s *Server) sniffRejection(body *bufio.Reader) string {
+	uploadCfg := s.config().Server.Upload
+	if !uploadCfg.VerifyContentType || len(uploadCfg.AllowedContentTypes) == 0 {
+		return ""
+	}
+	// Peek returns the available bytes along with an error for short bodies
+	head, _ := body.Peek(sniffLen)
+	if len(head) == 0 {
+		return ""
+	}
+	if sniffed := http.DetectContentType(head); !contentTypeAllowed(uploadCfg.AllowedContentTypes, sniffed) {
+		return fmt.Sprintf("File content is not of an allowed type (detected %s)", sniffed)
+	}
+	return ""
+}
+
+// contentTypeAllowed reports whether contentType matches one of patterns,
+// which are media types such as image/png, type wildcards such as image/*, or
+// */* for any type. Parameters and case are ignored. An empty list allows all.
+func contentTypeAllowed(patterns []string, contentType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "*/*" || pattern == "*":
+			return true
+		case strings.HasSuffix(pattern, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case pattern == mediaType:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExtension lowercases a configured extension and adds the leading dot
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
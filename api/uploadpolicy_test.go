@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// keyLimitsConfig allows keys of up to 24 bytes and 2 path separators
+const keyLimitsConfig = "server:\n  upload:\n    max_key_length: 24\n    max_path_depth: 2\n"
+
+func TestUploadKeyLimits(t *testing.T) {
+	server := newTestServer(t, keyLimitsConfig)
+
+	for key, want := range map[string]int{
+		"a/b/c.txt":                      http.StatusOK,
+		"a/b/c/d.txt":                    http.StatusBadRequest,
+		strings.Repeat("k", 20) + ".txt": http.StatusOK,
+		strings.Repeat("k", 21) + ".txt": http.StatusBadRequest,
+	} {
+		rec := serve(server, http.MethodPost, "/upload/default/"+key, strings.NewReader("x"), nil)
+		if rec.Code != want {
+			t.Errorf("POST %s = %d %s, want %d", key, rec.Code, rec.Body, want)
+		}
+		if stored := objectExists(t, server, key); stored != (want == http.StatusOK) {
+			t.Errorf("POST %s stored = %v", key, stored)
+		}
+	}
+
+	// Move destinations are held to the same limits
+	putObject(t, server, "src/a.txt", "a")
+	rec := serve(server, http.MethodPost, "/move-prefix/default", strings.NewReader(`{"from":"src","to":"x/y/z"}`), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /move-prefix too deep = %d %s, want 400", rec.Code, rec.Body)
+	}
+	if !objectExists(t, server, "src/a.txt") {
+		t.Error("rejected move removed the source")
+	}
+}
+
+func TestUploadKeyLimitsCountDefaultPrefix(t *testing.T) {
+	server := newTestServer(t, keyLimitsConfig+prefixedBucketConfig)
+
+	// The bucket's default prefix adds a separator to the stored key
+	if rec := serve(server, http.MethodPost, "/upload/default/a/b.txt", strings.NewReader("x"), nil); rec.Code != http.StatusOK {
+		t.Errorf("POST a/b.txt = %d %s, want 200", rec.Code, rec.Body)
+	}
+	if rec := serve(server, http.MethodPost, "/upload/default/a/b/c.txt", strings.NewReader("x"), nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST a/b/c.txt = %d %s, want 400", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/example/file-service/storage"
+)
+
+// listVersions handles requests to enumerate every version and delete marker
+// of the objects under a prefix
+func (s *Server) listVersions(c *gin.Context) {
+	store, ok := s.storageFor(c).(storage.VersionedStorage)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Object versions are not supported by this storage backend"})
+		return
+	}
+
+	// Use default bucket if not specified
+	bucket := c.Param("bucket")
+	if bucket == "" {
+		bucket = s.defaultBucket(c)
+	}
+	prefix, ok := objectParam(c, "prefix")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := s.operationContext(c)
+	defer cancel()
+
+	versions, err := store.ListVersions(ctx, bucket, prefix)
+	if err != nil {
+		c.JSON(storageErrorStatus(ctx, err), gin.H{"error": fmt.Sprintf("Failed to list versions: %v", err)})
+		return
+	}
+
+	result := make([]gin.H, 0, len(versions))
+	for _, version := range versions {
+		entry := gin.H{
+			"name":          version.Name,
+			"version_id":    version.VersionID,
+			"is_latest":     version.IsLatest,
+			"last_modified": version.LastModified,
+		}
+		if version.IsDeleteMarker {
+			entry["delete_marker"] = true
+		} else {
+			entry["size"] = version.Size
+			entry["etag"] = version.ETag
+		}
+		result = append(result, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bucket": bucket, "versions": result})
+}
@@ -0,0 +1,685 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+
+	"github.com/example/file-service/storage"
+)
+
+// webdavMethods lists the methods routed to the WebDAV handler
+var webdavMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// basicAuthChallengeKey is the gin context key asking APIKeyAuthenticator to send a
+// Basic auth challenge with 401 responses, which WebDAV clients need to prompt
+// for credentials
+const basicAuthChallengeKey = "basic_auth_challenge"
+
+// basicAuthChallenge adds a Basic auth challenge to a 401 response when the
+// route asked for one
+func basicAuthChallenge(c *gin.Context) {
+	if c.GetBool(basicAuthChallengeKey) {
+		c.Header("WWW-Authenticate", `Basic realm="file-service"`)
+	}
+}
+
+// errStopWalk ends a Walk once the first object has been seen
+var errStopWalk = errors.New("stop walk")
+
+// errScanRejected fails a PUT whose upload the virus scanner rejected
+var errScanRejected = errors.New("rejected by virus scan")
+
+// davRequest carries per-request state from serveWebDAV to the file system
+type davRequest struct {
+	// Body length of a PUT request, -1 when unknown or for other methods
+	contentLength int64
+	// Content-Type of a PUT request
+	contentType string
+	// Virus scan of a PUT request body, nil when scanning is off
+	scan *virusScan
+	// Signature found, or scan error, when the scanner rejected a PUT
+	signature string
+	scanErr   error
+	// Infos of the entries listed by Readdir, so PROPFIND doesn't stat every child
+	stats map[string]*davFileInfo
+}
+
+type davRequestKey struct{}
+
+// requestState returns the state serveWebDAV stored in ctx
+func requestState(ctx context.Context) *davRequest {
+	if req, ok := ctx.Value(davRequestKey{}).(*davRequest); ok {
+		return req
+	}
+	return &davRequest{contentLength: -1, stats: make(map[string]*davFileInfo)}
+}
+
+// serveWebDAV handles WebDAV requests for a bucket when server.webdav.enabled is set
+func (s *Server) serveWebDAV(c *gin.Context) {
+	if !s.config().Server.WebDAV.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WebDAV is not enabled"})
+		return
+	}
+
+	bucket := c.Param("bucket")
+	if reason := s.davKeyRejection(c, bucket); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+		return
+	}
+	if reason := s.davUploadRejection(c); reason != "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+	req := &davRequest{contentLength: -1, stats: make(map[string]*davFileInfo)}
+	if c.Request.Method == http.MethodPut {
+		req.contentLength = c.Request.ContentLength
+		req.contentType = c.GetHeader("Content-Type")
+
+		// Stream the upload to the virus scanner as it is stored
+		scan, err := s.startVirusScan()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("Virus scanner unavailable: %v", err)})
+			return
+		}
+		if scan != nil {
+			defer scan.Close()
+			req.scan = scan
+		}
+	}
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), davRequestKey{}, req))
+
+	handler := &webdav.Handler{
+		Prefix:     "/webdav/" + bucket,
+		FileSystem: &davFS{store: s.storageFor(c), bucket: bucket, softDelete: s.backendConfig(c).SoftDelete, verdict: s.scanVerdict},
+		LockSystem: s.davLockSystem(c.GetString(backendContextKey), bucket, storedKeyPrefix(c)),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && s.config().Log.Level == "debug" {
+				log.Printf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	if req.scan == nil {
+		handler.ServeHTTP(c.Writer, c.Request)
+		return
+	}
+	handler.ServeHTTP(&davScanWriter{ResponseWriter: c.Writer, req: req}, c.Request)
+	scanRejected(c, req.signature, req.scanErr)
+}
+
+// davScanWriter drops the response the WebDAV handler sends for a PUT the
+// virus scanner rejected, so serveWebDAV can answer as POST /upload does
+type davScanWriter struct {
+	http.ResponseWriter
+	req *davRequest
+}
+
+func (w *davScanWriter) rejected() bool {
+	return w.req.signature != "" || w.req.scanErr != nil
+}
+
+func (w *davScanWriter) WriteHeader(status int) {
+	if !w.rejected() {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *davScanWriter) Write(p []byte) (int, error) {
+	if w.rejected() {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// davUploadRejection applies server.upload to requests that store objects:
+// PUT, and COPY or MOVE to a destination with a denied extension
+func (s *Server) davUploadRejection(c *gin.Context) string {
+	switch c.Request.Method {
+	case http.MethodPut:
+		key := davKey(c.Param("path"))
+		return s.uploadRejection(key, davContentType(key, c.GetHeader("Content-Type")))
+	case "COPY", "MOVE":
+		if dest, err := url.Parse(c.GetHeader("Destination")); err == nil {
+			return s.extensionRejection(dest.Path)
+		}
+	}
+	return ""
+}
+
+// davKeyRejection applies the key length and depth limits of server.upload
+// to the object a PUT stores or the destination of a COPY or MOVE
+func (s *Server) davKeyRejection(c *gin.Context, bucket string) string {
+	switch c.Request.Method {
+	case http.MethodPut:
+		return s.keyRejection(c, davKey(c.Param("path")))
+	case "COPY", "MOVE":
+		if dest, err := url.Parse(c.GetHeader("Destination")); err == nil {
+			return s.keyRejection(c, davKey(strings.TrimPrefix(dest.Path, "/webdav/"+bucket)))
+		}
+	}
+	return ""
+}
+
+// davLockSystem returns the lock system of a bucket, separate for each stored
+// key prefix since lock paths are relative to it. Locks are held in memory, so
+// they are lost on restart and not shared between instances.
+func (s *Server) davLockSystem(backend, bucket, keyPrefix string) webdav.LockSystem {
+	key := backend + "/" + bucket + "/" + keyPrefix
+	if ls, ok := s.davLocks.Load(key); ok {
+		return ls.(webdav.LockSystem)
+	}
+	ls, _ := s.davLocks.LoadOrStore(key, webdav.NewMemLS())
+	return ls.(webdav.LockSystem)
+}
+
+// davFS implements webdav.FileSystem on top of a bucket. Directories are key
+// prefixes: one exists as long as any object is stored under it.
+type davFS struct {
+	store  storage.Storage
+	bucket string
+	// softDelete moves deleted objects to the trash (storage.soft_delete)
+	softDelete bool
+	// verdict waits for the virus scan of an upload, deleting it again when
+	// rejected (Server.scanVerdict)
+	verdict func(ctx context.Context, scan *virusScan, store storage.Storage, bucket, object string, result *storage.UploadResult) (string, error)
+}
+
+// davKey turns a WebDAV path into an object key
+func davKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// notExist converts a storage not-found error into one os.IsNotExist recognizes
+func notExist(op, name string, err error) error {
+	if storage.IsNotFound(err) {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+// Stat returns the info of an object, or of a directory when objects exist under the name
+func (fs *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.stat(ctx, name)
+}
+
+func (fs *davFS) stat(ctx context.Context, name string) (*davFileInfo, error) {
+	key := davKey(name)
+	if key == "" {
+		return &davFileInfo{name: "/", dir: true}, nil
+	}
+	if info, ok := requestState(ctx).stats[key]; ok {
+		return info, nil
+	}
+
+	obj, err := fs.store.GetObjectInfo(ctx, fs.bucket, key)
+	if err == nil {
+		return fileInfoFromObject(path.Base(key), *obj), nil
+	}
+	if !storage.IsNotFound(err) {
+		return nil, err
+	}
+
+	err = fs.store.Walk(ctx, fs.bucket, key+"/", func(storage.FileObject) error {
+		return errStopWalk
+	})
+	if errors.Is(err, errStopWalk) {
+		return &davFileInfo{name: path.Base(key), dir: true}, nil
+	}
+	if err != nil {
+		return nil, notExist("stat", name, err)
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// Mkdir creates a directory marker; the parent must already exist
+func (fs *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	key := davKey(name)
+	if key == "" {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if _, err := fs.stat(ctx, name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if parent, err := fs.stat(ctx, path.Dir("/"+key)); err != nil {
+		return err
+	} else if !parent.dir {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	clear(requestState(ctx).stats)
+	return fs.store.CreateDirectory(ctx, fs.bucket, key, nil)
+}
+
+// OpenFile opens an object for reading or writing, or a directory for listing.
+// Writes are streamed to the backend and stored when the file is closed.
+func (fs *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	key := davKey(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		if key == "" {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		if info, err := fs.stat(ctx, name); err == nil && info.dir {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		return fs.create(ctx, key)
+	}
+
+	info, err := fs.stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.dir {
+		return &davDir{fs: fs, ctx: ctx, prefix: dirPrefix(key), info: info}, nil
+	}
+	return &davReadFile{fs: fs, ctx: ctx, key: key, info: info}, nil
+}
+
+// RemoveAll deletes an object and everything stored under it as a directory.
+// With storage.soft_delete they are moved to the trash instead.
+func (fs *davFS) RemoveAll(ctx context.Context, name string) error {
+	key := davKey(name)
+	if key == "" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	clear(requestState(ctx).stats)
+
+	if err := fs.remove(ctx, key); err != nil && !storage.IsNotFound(err) {
+		return err
+	}
+	objects, err := fs.store.List(ctx, fs.bucket, key+"/")
+	if err != nil {
+		return err
+	}
+	if _, errs := deleteSummary(fs.removeAll(ctx, key, objects)); len(errs) > 0 {
+		return fmt.Errorf("failed to delete %d objects: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// trashes reports whether deleting key, or the objects under it, moves them
+// to the trash. Deleting what is already in the trash is permanent.
+func (fs *davFS) trashes(key string) bool {
+	return fs.softDelete && !isTrashed(key+"/")
+}
+
+// remove deletes one object, or moves it to the trash
+func (fs *davFS) remove(ctx context.Context, key string) error {
+	if fs.trashes(key) {
+		_, err := trashObject(ctx, fs.store, fs.bucket, key)
+		return err
+	}
+	return fs.store.Delete(ctx, fs.bucket, key)
+}
+
+// removeAll deletes the objects listed under key, or moves them to the trash
+func (fs *davFS) removeAll(ctx context.Context, key string, objects []storage.FileObject) []bulkResult {
+	if fs.trashes(key) {
+		return trashAll(ctx, fs.store, fs.bucket, objects)
+	}
+	return deleteAll(ctx, fs.store, fs.bucket, objects)
+}
+
+// Rename moves an object, or every object under a directory, with server-side
+// copies. With storage.soft_delete the sources are moved to the trash.
+func (fs *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, newKey := davKey(oldName), davKey(newName)
+	if oldKey == "" || newKey == "" {
+		return &os.PathError{Op: "rename", Path: oldName, Err: os.ErrPermission}
+	}
+	info, err := fs.stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	clear(requestState(ctx).stats)
+
+	if !info.dir {
+		if err := fs.store.Copy(ctx, fs.bucket, oldKey, newKey, nil); err != nil {
+			return notExist("rename", oldName, err)
+		}
+		return fs.remove(ctx, oldKey)
+	}
+
+	objects, err := fs.store.List(ctx, fs.bucket, oldKey+"/")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		dst := newKey + "/" + strings.TrimPrefix(obj.Name, oldKey+"/")
+		if err := fs.store.Copy(ctx, fs.bucket, obj.Name, dst, nil); err != nil {
+			return err
+		}
+	}
+	if _, errs := deleteSummary(fs.removeAll(ctx, oldKey, objects)); len(errs) > 0 {
+		return fmt.Errorf("failed to delete %d moved objects: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// davContentType is the content type an upload of key is stored with: the
+// declared one, or the one of its extension when none or a generic one is given
+func davContentType(key, declared string) string {
+	contentType := declared
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return contentType
+}
+
+// create starts streaming an upload of key through a pipe
+func (fs *davFS) create(ctx context.Context, key string) (*davWriteFile, error) {
+	req := requestState(ctx)
+	clear(req.stats)
+
+	if err := fs.store.EnsurePathExists(ctx, fs.bucket, key); err != nil {
+		return nil, err
+	}
+
+	contentType := davContentType(key, req.contentType)
+
+	reader, writer := io.Pipe()
+	f := &davWriteFile{
+		writer:   writer,
+		expected: req.contentLength,
+		info:     &davFileInfo{name: path.Base(key), contentType: contentType},
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(f.done)
+		var upload io.Reader = reader
+		if req.scan != nil {
+			upload = io.TeeReader(reader, req.scan)
+		}
+		f.result, f.err = fs.store.Upload(ctx, fs.bucket, key, upload, req.contentLength, contentType, storage.ObjectHeaders{})
+		if f.err == nil && req.scan != nil {
+			req.signature, req.scanErr = fs.verdict(ctx, req.scan, fs.store, fs.bucket, key, f.result)
+			if req.signature != "" || req.scanErr != nil {
+				f.err = errScanRejected
+			}
+		}
+		reader.CloseWithError(f.err)
+	}()
+	return f, nil
+}
+
+// dirPrefix returns the key prefix of a directory
+func dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// davFileInfo implements os.FileInfo, webdav.ContentTyper and webdav.ETager
+type davFileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	dir         bool
+	contentType string
+	etag        string
+}
+
+// fileInfoFromObject describes a stored object
+func fileInfoFromObject(name string, obj storage.FileObject) *davFileInfo {
+	return &davFileInfo{
+		name:        name,
+		size:        obj.Size,
+		modTime:     obj.LastModified,
+		contentType: obj.ContentType,
+		etag:        obj.ETag,
+	}
+}
+
+func (fi *davFileInfo) Name() string       { return fi.name }
+func (fi *davFileInfo) Size() int64        { return fi.size }
+func (fi *davFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *davFileInfo) IsDir() bool        { return fi.dir }
+func (fi *davFileInfo) Sys() any           { return nil }
+
+func (fi *davFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType returns the stored content type, falling back to the file
+// extension so the handler never downloads the object to sniff it
+func (fi *davFileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.contentType != "" && fi.contentType != "application/octet-stream" {
+		return fi.contentType, nil
+	}
+	if contentType := mime.TypeByExtension(path.Ext(fi.name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// ETag returns the backend ETag, or lets the handler derive one when there is none
+func (fi *davFileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return quoteETag(fi.etag), nil
+}
+
+// davReadFile reads an object. The download is opened on the first read, so
+// that PROPFIND and the size check of GET don't fetch the body; seeking
+// reopens it and skips ahead, since the backends are read as streams.
+type davReadFile struct {
+	fs     *davFS
+	ctx    context.Context
+	key    string
+	info   *davFileInfo
+	reader io.ReadCloser
+	offset int64
+}
+
+func (f *davReadFile) Read(p []byte) (int, error) {
+	if f.reader == nil {
+		reader, err := f.fs.store.Download(f.ctx, f.fs.bucket, f.key)
+		if err != nil {
+			return 0, notExist("read", f.key, err)
+		}
+		if _, err := io.CopyN(io.Discard, reader, f.offset); err != nil {
+			reader.Close()
+			return 0, err
+		}
+		f.reader = reader
+	}
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *davReadFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.key, Err: os.ErrInvalid}
+	}
+	if offset != f.offset && f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *davReadFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.key, Err: errors.New("not a directory")}
+}
+
+func (f *davReadFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *davReadFile) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.key, Err: os.ErrPermission}
+}
+
+func (f *davReadFile) Close() error {
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+// davWriteFile streams writes to a backend upload running in the background
+type davWriteFile struct {
+	writer   *io.PipeWriter
+	expected int64
+	written  int64
+	info     *davFileInfo
+	done     chan struct{}
+	result   *storage.UploadResult
+	err      error
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.writer.Write(p)
+	f.written += int64(n)
+	return n, err
+}
+
+// Close finishes the upload. A PUT whose body ended before its Content-Length
+// is aborted rather than stored truncated.
+func (f *davWriteFile) Close() error {
+	if f.expected >= 0 && f.written != f.expected {
+		f.writer.CloseWithError(io.ErrUnexpectedEOF)
+	} else {
+		f.writer.Close()
+	}
+	<-f.done
+	if f.err != nil {
+		return f.err
+	}
+
+	// The handler reads the ETag from the info returned by Stat before Close
+	f.info.size = f.result.Size
+	f.info.etag = f.result.ETag
+	f.info.modTime = time.Now()
+	return nil
+}
+
+func (f *davWriteFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *davWriteFile) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: f.info.name, Err: os.ErrPermission}
+}
+
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: f.info.name, Err: os.ErrInvalid}
+}
+
+func (f *davWriteFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.info.name, Err: errors.New("not a directory")}
+}
+
+// davDir lists the objects and subdirectories directly under a prefix
+type davDir struct {
+	fs      *davFS
+	ctx     context.Context
+	prefix  string
+	info    *davFileInfo
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (d *davDir) list() error {
+	if d.listed {
+		return nil
+	}
+	req := requestState(d.ctx)
+	seen := make(map[string]bool)
+	err := d.fs.store.Walk(d.ctx, d.fs.bucket, d.prefix, func(obj storage.FileObject) error {
+		rest := strings.TrimPrefix(obj.Name, d.prefix)
+		if rest == "" {
+			return nil
+		}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			name := rest[:i]
+			if !seen[name] {
+				seen[name] = true
+				info := &davFileInfo{name: name, dir: true}
+				req.stats[d.prefix+name] = info
+				d.entries = append(d.entries, info)
+			}
+			return nil
+		}
+		info := fileInfoFromObject(rest, obj)
+		req.stats[d.prefix+rest] = info
+		d.entries = append(d.entries, info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.listed = true
+	return nil
+}
+
+func (d *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if err := d.list(); err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *davDir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *davDir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.prefix, Err: errors.New("is a directory")}
+}
+
+func (d *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (d *davDir) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.prefix, Err: errors.New("is a directory")}
+}
+
+func (d *davDir) Close() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+// davSoftDeleteConfig enables WebDAV and soft delete on the default backend
+const davSoftDeleteConfig = "  soft_delete: true\nserver:\n  webdav:\n    enabled: true\n"
+
+func TestWebDAVDeleteMovesToTrash(t *testing.T) {
+	server := newTestServer(t, davSoftDeleteConfig)
+	putObject(t, server, "notes.txt", "note")
+	putObject(t, server, "docs/a.txt", "a")
+	putObject(t, server, "docs/b.txt", "b")
+
+	for _, target := range []string{"/webdav/default/notes.txt", "/webdav/default/docs"} {
+		if rec := serve(server, http.MethodDelete, target, nil, nil); rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE %s = %d %s, want 204", target, rec.Code, rec.Body)
+		}
+	}
+	for key, want := range map[string]string{"notes.txt": "note", "docs/a.txt": "a", "docs/b.txt": "b"} {
+		if objectExists(t, server, key) {
+			t.Errorf("%s is still in place", key)
+		}
+		if got := readObject(t, server, trashPrefix+key); got != want {
+			t.Errorf("trash copy of %s = %q, want %q", key, got, want)
+		}
+	}
+
+	// Deleting from the trash is permanent
+	if rec := serve(server, http.MethodDelete, "/webdav/default/"+trashPrefix+"notes.txt", nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE in the trash = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if objectExists(t, server, trashPrefix+"notes.txt") || objectExists(t, server, trashPrefix+trashPrefix+"notes.txt") {
+		t.Error("trashed object was not deleted")
+	}
+}
+
+func TestWebDAVMoveTrashesSource(t *testing.T) {
+	server := newTestServer(t, davSoftDeleteConfig)
+	putObject(t, server, "draft.txt", "text")
+	putObject(t, server, "old/a.txt", "a")
+
+	moves := map[string]string{"/webdav/default/draft.txt": "/webdav/default/final.txt", "/webdav/default/old": "/webdav/default/new"}
+	for src, dst := range moves {
+		if rec := serve(server, "MOVE", src, nil, map[string]string{"Destination": dst}); rec.Code != http.StatusCreated {
+			t.Fatalf("MOVE %s = %d %s, want 201", src, rec.Code, rec.Body)
+		}
+	}
+	for src, dst := range map[string]string{"draft.txt": "final.txt", "old/a.txt": "new/a.txt"} {
+		if objectExists(t, server, src) {
+			t.Errorf("%s is still in place", src)
+		}
+		if got, want := readObject(t, server, trashPrefix+src), readObject(t, server, dst); got != want {
+			t.Errorf("trash copy of %s = %q, want %q", src, got, want)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"github.com/example/file-service/api"
 	"github.com/example/file-service/config"
+	"github.com/example/file-service/version"
 )
 
 func main() {
+	configFile := flag.String("config", "", "path to the config file (overrides $"+config.ConfigFileEnv+")")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
@@ -21,7 +26,8 @@ func main() {
 	}
 
 	// Start server
-	log.Printf("Starting file service on port %d with %s storage", cfg.Server.Port, cfg.Storage.Type)
+	log.Printf("Starting file service version=%s commit=%s build_date=%s go_version=%s port=%d storage=%s",
+		version.Version, version.Commit, version.BuildDate, version.GoVersion, cfg.Server.Port, cfg.Backends()[cfg.DefaultBackend()].Type)
 	if err := server.Start(); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
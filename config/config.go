@@ -1,34 +1,424 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
 // Config holds the configuration for the file service
 type Config struct {
 	Server   ServerConfig   `mapstructure:"server"`
+	Auth     AuthConfig     `mapstructure:"auth"`
 	Storage  StorageConfig  `mapstructure:"storage"`
+	
+	// Named storage backends selectable per request; when empty the single
+	// storage section above is exposed as the "default" backend
+	Storages       map[string]StorageConfig `mapstructure:"storages"`
+	DefaultStorage string                   `mapstructure:"default_storage"`
+	
+	// Per-bucket policies overriding the server-wide cache and CORS settings
+	Buckets map[string]BucketPolicy `mapstructure:"buckets"`
+	
+	// Proxy for backends without their own <type>.http.proxy; empty leaves
+	// them to HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	OutboundProxy string `mapstructure:"outbound_proxy"`
 	Log      LogConfig      `mapstructure:"log"`
 }
 
 // ServerConfig holds the HTTP server configuration
 type ServerConfig struct {
 	Port int `mapstructure:"port"`
+	
+	// Reject every request that would change storage with 403, e.g. on a
+	// disaster-recovery standby; downloads, listings and info keep working
+	ReadOnly bool `mapstructure:"read_only"`
+	
+	// Also make /ready store and delete a small .healthcheck object in the
+	// default bucket of every backend, so missing write access is reported
+	ReadyCheckWrites bool `mapstructure:"ready_check_writes"`
+	
+	// Detect the content type of uploads sent without a specific Content-Type
+	DetectContentType bool `mapstructure:"detect_content_type"`
+	
+	Upload UploadConfig `mapstructure:"upload"`
+	
+	Antivirus AntivirusConfig `mapstructure:"antivirus"`
+	
+	Download DownloadConfig `mapstructure:"download"`
+	
+	InfoBatch InfoBatchConfig `mapstructure:"info_batch"`
+	
+	Resize ResizeConfig `mapstructure:"resize"`
+	
+	Share ShareConfig `mapstructure:"share"`
+	
+	Ingest IngestConfig `mapstructure:"ingest"`
+	
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	
+	Checksum ChecksumConfig `mapstructure:"checksum"`
+	
+	// Cache-Control header sent with downloads; empty sends none
+	CacheControl string `mapstructure:"cache_control"`
+	
+	// Content-Disposition of downloads by content type, first match wins;
+	// downloads matching no rule are sent as attachments
+	ContentDisposition []DispositionRule `mapstructure:"content_disposition"`
+	
+	CORS CORSConfig `mapstructure:"cors"`
+	
+	StaticSite StaticSiteConfig `mapstructure:"static_site"`
+	
+	// Bytes per second each download is sent at, overridable per API key in
+	// auth.download_rate_limits; 0 is unlimited
+	DownloadRateLimit int64 `mapstructure:"download_rate_limit_bytes_per_sec"`
+	
+	// Requests processed at once, including streaming downloads; 0 is unlimited
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	
+	// How long a request waits for a free slot before it is rejected with 503;
+	// 0 rejects it right away
+	QueueTimeout time.Duration `mapstructure:"queue_timeout"`
+	
+	MetadataCache MetadataCacheConfig `mapstructure:"metadata_cache"`
+	
+	Dedup DedupConfig `mapstructure:"dedup"`
+	
+	WebDAV WebDAVConfig `mapstructure:"webdav"`
+	
+	UI UIConfig `mapstructure:"ui"`
+	
+	S3API S3APIConfig `mapstructure:"s3_api"`
+}
+
+// S3APIConfig holds configuration for the S3-compatible API
+type S3APIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	
+	// Path the API is served under for path-style requests, e.g. /s3/<bucket>/<key>
+	PathPrefix string `mapstructure:"path_prefix"`
+	
+	// Domain for virtual-host style requests, e.g. <bucket>.s3.example.com;
+	// empty serves path-style requests only
+	Domain string `mapstructure:"domain"`
+	
+	// Region reported by GetBucketLocation
+	Region string `mapstructure:"region"`
+}
+
+// StaticSiteConfig holds configuration for serving buckets as static web
+// sites through GET /download
+type StaticSiteConfig struct {
+	// Object name served for requests ending in a slash, e.g. index.html;
+	// empty serves those paths as any other
+	IndexDocument string `mapstructure:"index_document"`
+	
+	// Object served with 404 Not Found in place of a missing one, relative to
+	// the bucket root, e.g. 404.html; empty answers with a JSON error
+	ErrorDocument string `mapstructure:"error_document"`
+}
+
+// WebDAVConfig holds configuration for the WebDAV interface under /webdav
+type WebDAVConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// UIConfig holds configuration for the browser UI under /ui
+type UIConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// DispositionRule sets whether downloads of a content type are displayed
+// inline or saved as attachments
+type DispositionRule struct {
+	// Media type such as application/pdf, or a wildcard such as image/*
+	ContentType string `mapstructure:"content_type"`
+	
+	// "inline" or "attachment"
+	Disposition string `mapstructure:"disposition"`
+}
+
+// CORSConfig holds cross-origin request configuration
+type CORSConfig struct {
+	// Origins allowed to call the service from a browser; "*" allows any
+	// origin and an empty list disables CORS
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+}
+
+// BucketPolicy holds settings for one bucket; empty fields fall back to the
+// server-wide settings
+type BucketPolicy struct {
+	CacheControl string     `mapstructure:"cache_control"`
+	CORS         CORSConfig `mapstructure:"cors"`
+	
+	// Object key prefix every key in the bucket is stored under, hidden from
+	// clients; it comes before the key prefix of an API key
+	DefaultPrefix string `mapstructure:"default_prefix"`
+}
+
+// ShareConfig holds configuration for signed share links served by the service
+type ShareConfig struct {
+	// HMAC key used to sign share tokens; sharing is disabled when empty
+	Secret string `mapstructure:"secret"`
+	
+	// Longest lifetime a client may request for a share link
+	MaxExpiry time.Duration `mapstructure:"max_expiry"`
+}
+
+// IngestConfig restricts the URLs POST /ingest may fetch objects from
+type IngestConfig struct {
+	// Hosts URLs may point to, exact or with a leading wildcard such as
+	// *.example.com; ingesting is disabled when empty
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+	
+	// URL schemes that may be fetched
+	AllowedSchemes []string `mapstructure:"allowed_schemes"`
+	
+	// Largest body fetched in bytes; 0 is unlimited
+	MaxSize int64 `mapstructure:"max_size"`
+	
+	// Redirects followed before the fetch fails
+	MaxRedirects int `mapstructure:"max_redirects"`
+}
+
+// IdempotencyConfig holds configuration for uploads sent with an Idempotency-Key header
+type IdempotencyConfig struct {
+	// How long the response of an upload is kept to answer retries with the
+	// same key; 0 ignores the header
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// ChecksumConfig holds configuration for GET /checksum
+type ChecksumConfig struct {
+	// Store computed digests in the object's user metadata, e.g. as
+	// checksum-sha256, so later requests don't read the object again
+	CacheInMetadata bool `mapstructure:"cache_in_metadata"`
+}
+
+// ResizeConfig holds image resizing configuration for downloads with ?resize=WxH
+type ResizeConfig struct {
+	// Largest width or height a client may request
+	MaxDimension int `mapstructure:"max_dimension"`
+	
+	// Store resized images back to the bucket so repeat requests are cheap
+	Cache bool `mapstructure:"cache"`
+	
+	// Key prefix resized images are cached under, followed by WxH/<object>
+	CachePrefix string `mapstructure:"cache_prefix"`
+}
+
+// UploadConfig restricts what may be stored through the upload endpoints
+type UploadConfig struct {
+	// Content types accepted, exact or with a wildcard subtype such as image/*;
+	// empty accepts any type
+	AllowedContentTypes []string `mapstructure:"allowed_content_types"`
+	
+	// Object extensions that are rejected regardless of content type, e.g. .exe
+	DeniedExtensions []string `mapstructure:"denied_extensions"`
+	
+	// Also sniff the first bytes of POST /upload bodies and reject content
+	// that doesn't match allowed_content_types, whatever type was declared
+	VerifyContentType bool `mapstructure:"verify_content_type"`
+	
+	// Rewrite POST /upload object keys to URL-safe ASCII: accents are dropped,
+	// whitespace becomes "-" and other characters are replaced or hex-encoded
+	SanitizeKeys bool `mapstructure:"sanitize_keys"`
+	
+	// Also lowercase sanitized keys
+	LowercaseKeys bool `mapstructure:"lowercase_keys"`
+	
+	// Append -1, -2, ... to a sanitized key instead of failing with 409 when a
+	// different object is already stored under it
+	DisambiguateKeys bool `mapstructure:"disambiguate_keys"`
+	
+	// Longest object key accepted, in bytes, including the key prefix of the
+	// API key; 0 disables the check
+	MaxKeyLength int `mapstructure:"max_key_length"`
+	
+	// Most "/" separators accepted in an object key; 0 disables the check
+	MaxPathDepth int `mapstructure:"max_path_depth"`
+	
+	// Number of files of a POST /upload-dir request stored in parallel; each
+	// is spooled to a temporary file first, so this also bounds the disk used
+	DirConcurrency int `mapstructure:"dir_concurrency"`
+	
+	// Most files accepted in one POST /upload-dir request; 0 is unlimited
+	DirMaxFiles int `mapstructure:"dir_max_files"`
+}
+
+// AntivirusConfig holds configuration for scanning uploads with a ClamAV daemon
+type AntivirusConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	
+	// clamd address, host:port or unix:/path/to/clamd.sock
+	Address string `mapstructure:"address"`
+	
+	// Limit for connecting, each write and the final verdict
+	Timeout time.Duration `mapstructure:"timeout"`
+	
+	// Store uploads unscanned when the daemon can't be reached or gives no
+	// verdict, instead of rejecting them
+	FailOpen bool `mapstructure:"fail_open"`
+}
+
+// DownloadConfig holds directory (archive) download configuration
+type DownloadConfig struct {
+	// Number of objects fetched from the backend in parallel while building an archive
+	ZipConcurrency int `mapstructure:"zip_concurrency"`
+	
+	ZipLimits `mapstructure:",squash"`
+	
+	// Key prefix archives built for ?async=true directory downloads are
+	// stored under, in the bucket of the directory
+	AsyncPrefix string `mapstructure:"async_prefix"`
+	
+	// How long a built archive is served before it is rebuilt and deleted
+	AsyncTTL time.Duration `mapstructure:"async_ttl"`
+}
+
+// ZipLimits bounds the directories that may be downloaded as one archive,
+// overridable per API key in auth.zip_limits
+type ZipLimits struct {
+	// Most objects in one archive; 0 is unlimited
+	MaxObjects int `mapstructure:"max_zip_objects"`
+	
+	// Largest total size of the objects in one archive in bytes; 0 is unlimited
+	MaxBytes int64 `mapstructure:"max_zip_bytes"`
+}
+
+// InfoBatchConfig holds configuration for batch object info requests
+type InfoBatchConfig struct {
+	// Most objects a single request may ask for
+	MaxObjects int `mapstructure:"max_objects"`
+	
+	// Number of objects looked up on the backend in parallel
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+// MetadataCacheConfig holds configuration for caching object info in front of the backends
+type MetadataCacheConfig struct {
+	// Most objects cached per backend, least recently used first out; 0 disables the cache
+	Size int `mapstructure:"size"`
+	
+	// How long cached object info is used before the backend is asked again
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// DedupConfig holds configuration for deduplicating uploads by content
+type DedupConfig struct {
+	// Store the content of uploads once per SHA-256 digest, with each object
+	// referring to the shared copy
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// Authentication modes for the REST API, WebDAV and the web UI
+const (
+	AuthModeAPIKey = "api_key" // a key from api_keys sent with each request
+	AuthModeHMAC   = "hmac"    // requests signed with a secret from hmac_keys
+)
+
+// AuthConfig holds the API key authentication configuration
+type AuthConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	APIKeys map[string]string `mapstructure:"api_keys"` // api key -> description
+	
+	// AuthModeAPIKey, the default when empty, or AuthModeHMAC
+	Mode string `mapstructure:"mode"`
+	
+	// Key ID -> secret used to sign requests in hmac mode. The config loader
+	// lowercases map keys, so key IDs match case-insensitively.
+	HMACKeys map[string]string `mapstructure:"hmac_keys"`
+	
+	// Largest difference between the time a request was signed and the
+	// server's clock in hmac mode, in either direction
+	HMACMaxSkew time.Duration `mapstructure:"hmac_max_skew"`
+	
+	// Key for the /admin endpoints, sent as X-Admin-Key; admin endpoints are disabled when empty
+	AdminKey string `mapstructure:"admin_key"`
+	
+	// Access key ID -> secret access key pairs accepted by the S3 API. The
+	// config loader lowercases map keys, so access key IDs match case-insensitively.
+	S3Credentials map[string]string `mapstructure:"s3_credentials"`
+	
+	// API key -> object key prefix the key is confined to; keys without an
+	// entry see the whole bucket
+	KeyPrefixes map[string]string `mapstructure:"key_prefixes"`
+	
+	// API key -> download rate limit in bytes per second replacing
+	// server.download_rate_limit_bytes_per_sec for the key; 0 is unlimited
+	DownloadRateLimits map[string]int64 `mapstructure:"download_rate_limits"`
+	
+	// API key -> archive limits replacing server.download.max_zip_objects
+	// and max_zip_bytes for the key
+	ZipLimits map[string]ZipLimits `mapstructure:"zip_limits"`
+}
+
+// keySetting names the setting listing the keys clients authenticate with
+// in the configured mode, which the per-key settings refer to
+func (a AuthConfig) keySetting() (string, map[string]string) {
+	if a.Mode == AuthModeHMAC {
+		return "auth.hmac_keys", a.HMACKeys
+	}
+	return "auth.api_keys", a.APIKeys
+}
+
+// KeyPrefix returns the object key prefix apiKey is confined to, with a
+// trailing slash, or "" when the key is not restricted
+func (a AuthConfig) KeyPrefix(apiKey string) string {
+	prefix := strings.Trim(a.KeyPrefixes[apiKey], "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
 }
 
 // StorageConfig holds the storage configuration
 type StorageConfig struct {
-	Type string `mapstructure:"type"` // minio, oss, obs, azure
+	Type string `mapstructure:"type"` // minio, s3compat, oss, obs, azure, memory
 	
 	// Default bucket name
 	Bucket string `mapstructure:"bucket"`
 	
+	// Create the bucket on first upload when it doesn't exist yet
+	AutoCreateBucket bool `mapstructure:"auto_create_bucket"`
+	
+	// Upper bound for a single storage call, including the full upload stream (0 disables)
+	OperationTimeout time.Duration `mapstructure:"operation_timeout"`
+	
+	// Maximum time a streaming download may go without receiving data (0 disables)
+	DownloadIdleTimeout time.Duration `mapstructure:"download_idle_timeout"`
+	
+	// Move deleted objects under .trash/ instead of deleting them
+	SoftDelete bool `mapstructure:"soft_delete"`
+	
+	// Age after which DELETE /trash purges trashed objects (0 uses 30 days)
+	TrashRetention time.Duration `mapstructure:"trash_retention"`
+	
+	// "explicit" stores an empty marker object for every directory;
+	// "implicit" never does and derives directories from key prefixes alone
+	DirectoryMarkers string `mapstructure:"directory_markers"`
+	
+	// "lower" folds object keys to lower case before every storage call;
+	// empty passes keys through unchanged
+	NormalizeKeyCase string `mapstructure:"normalize_key_case"`
+	
+	// Upload to a temporary key under .tmp/ and copy it to the object key
+	// once complete, so failed uploads never leave a partial object behind
+	AtomicUpload bool `mapstructure:"atomic_upload"`
+	
 	// MinIO configuration
 	MinIO MinIOConfig `mapstructure:"minio"`
 	
+	// Generic S3-compatible configuration (Wasabi, Backblaze B2, DigitalOcean Spaces, ...)
+	S3Compat S3CompatConfig `mapstructure:"s3compat"`
+	
 	// Aliyun OSS configuration
 	OSS OSSConfig `mapstructure:"oss"`
 	
@@ -37,6 +427,175 @@ type StorageConfig struct {
 	
 	// Azure Blob configuration
 	Azure AzureConfig `mapstructure:"azure"`
+	
+	// Remaining keys, holding the sections of backends registered with
+	// storage.Register, e.g. storage.mybackend for type "mybackend"
+	Extra map[string]any `mapstructure:",remain"`
+	
+	// Credentials set both inline and as a file, reported by Validate
+	secretConflicts []string
+}
+
+// BackendOptions returns the configuration section of the backend type as
+// the map passed to its storage.Factory. The in-memory backend, which has no
+// section of its own, gets the default bucket to create.
+func (s StorageConfig) BackendOptions() (map[string]any, error) {
+	var section any
+	switch s.Type {
+	case "minio":
+		section = s.MinIO
+	case "s3compat":
+		section = s.S3Compat
+	case "oss":
+		section = s.OSS
+	case "obs":
+		section = s.OBS
+	case "azure":
+		section = s.Azure
+	case "memory":
+		return map[string]any{"bucket": s.Bucket}, nil
+	default:
+		if options, ok := s.Extra[s.Type].(map[string]any); ok {
+			return options, nil
+		}
+		return map[string]any{}, nil
+	}
+	
+	options := make(map[string]any)
+	if err := mapstructure.Decode(section, &options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// secretFile is a credential field and the file it may be read from instead
+type secretFile struct {
+	field string // config key relative to the storage section, e.g. minio.access_key
+	value *string
+	path  string
+}
+
+// secretFiles lists the credentials of every built-in backend that can be given as a file
+func (s *StorageConfig) secretFiles() []secretFile {
+	return []secretFile{
+		{"minio.access_key", &s.MinIO.AccessKey, s.MinIO.AccessKeyFile},
+		{"minio.secret_key", &s.MinIO.SecretKey, s.MinIO.SecretKeyFile},
+		{"s3compat.access_key", &s.S3Compat.AccessKey, s.S3Compat.AccessKeyFile},
+		{"s3compat.secret_key", &s.S3Compat.SecretKey, s.S3Compat.SecretKeyFile},
+		{"oss.access_key", &s.OSS.AccessKey, s.OSS.AccessKeyFile},
+		{"oss.secret_key", &s.OSS.SecretKey, s.OSS.SecretKeyFile},
+		{"obs.access_key", &s.OBS.AccessKey, s.OBS.AccessKeyFile},
+		{"obs.secret_key", &s.OBS.SecretKey, s.OBS.SecretKeyFile},
+		{"azure.account_key", &s.Azure.AccountKey, s.Azure.AccountKeyFile},
+		{"azure.connection_string", &s.Azure.ConnectionString, s.Azure.ConnectionStringFile},
+	}
+}
+
+// readSecretFiles replaces credentials of the selected backend type given as
+// *_file paths with the contents of those files, without trailing newlines.
+// A credential that is also set inline is left alone and recorded for
+// Validate to report.
+func (s *StorageConfig) readSecretFiles(key string) error {
+	s.secretConflicts = nil
+	for _, secret := range s.secretFiles() {
+		if secret.path == "" || !strings.HasPrefix(secret.field, s.Type+".") {
+			continue
+		}
+		if *secret.value != "" {
+			s.secretConflicts = append(s.secretConflicts, secret.field)
+			continue
+		}
+		data, err := os.ReadFile(secret.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s.%s_file: %w", key, secret.field, err)
+		}
+		*secret.value = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// httpClient returns the HTTP settings of the selected built-in backend type
+func (s StorageConfig) httpClient() (HTTPClientConfig, bool) {
+	if h := s.httpClientSection(); h != nil {
+		return *h, true
+	}
+	return HTTPClientConfig{}, false
+}
+
+// httpClientSection points to the HTTP settings of the selected built-in
+// backend type, or is nil for other types
+func (s *StorageConfig) httpClientSection() *HTTPClientConfig {
+	switch s.Type {
+	case "minio":
+		return &s.MinIO.HTTP
+	case "s3compat":
+		return &s.S3Compat.HTTP
+	case "oss":
+		return &s.OSS.HTTP
+	case "obs":
+		return &s.OBS.HTTP
+	case "azure":
+		return &s.Azure.HTTP
+	}
+	return nil
+}
+
+// InsecureTLS reports whether the selected backend accepts any TLS certificate
+func (s StorageConfig) InsecureTLS() bool {
+	h, _ := s.httpClient()
+	return h.InsecureSkipVerify
+}
+
+// inheritProxy sets the proxy of the selected built-in backend type to proxy
+// unless it has one of its own
+func (s *StorageConfig) inheritProxy(proxy string) {
+	if h := s.httpClientSection(); h != nil && h.Proxy == "" {
+		h.Proxy = proxy
+	}
+}
+
+// validateProxy reports a proxy that isn't an absolute http, https or socks5 URL
+func validateProxy(key, proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("%s must be a URL such as http://proxy:3128: %w", key, err)
+	}
+	// Keep a password in the URL out of the error
+	if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+		return fmt.Errorf("%s must be an http, https or socks5 URL such as http://proxy:3128, got %q", key, u.Redacted())
+	}
+	return nil
+}
+
+// validate reports negative timeouts and connection limits
+func (h HTTPClientConfig) validate(key string) []error {
+	var errs []error
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"dial_timeout", h.DialTimeout},
+		{"tls_handshake_timeout", h.TLSHandshakeTimeout},
+		{"response_header_timeout", h.ResponseHeaderTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s must not be negative", key, d.field))
+		}
+	}
+	if h.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_idle_conns must not be negative, got %d", key, h.MaxIdleConns))
+	}
+	if h.MaxIdleConnsPerHost < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_idle_conns_per_host must not be negative, got %d", key, h.MaxIdleConnsPerHost))
+	}
+	if err := validateProxy(key+".proxy", h.Proxy); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
 }
 
 // MinIOConfig holds MinIO configuration
@@ -45,6 +604,41 @@ type MinIOConfig struct {
 	AccessKey   string `mapstructure:"access_key"`
 	SecretKey   string `mapstructure:"secret_key"`
 	UseSSL      bool   `mapstructure:"use_ssl"`
+	
+	// Files holding the credentials instead, e.g. mounted Docker or Kubernetes secrets
+	AccessKeyFile string `mapstructure:"access_key_file"`
+	SecretKeyFile string `mapstructure:"secret_key_file"`
+	
+	// Multipart upload part size in bytes and number of parts uploaded in
+	// parallel; 0 keeps the client defaults (16 MiB, 4 threads)
+	PartSize   int64 `mapstructure:"part_size"`
+	NumThreads int   `mapstructure:"num_threads"`
+	
+	HTTP HTTPClientConfig `mapstructure:"http"`
+}
+
+// MinIO accepts multipart part sizes between 5 MiB and 5 GiB
+const (
+	MinIOMinPartSize = 5 << 20
+	MinIOMaxPartSize = 5 << 30
+)
+
+// S3CompatConfig holds configuration for S3-compatible services other than MinIO
+type S3CompatConfig struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
+	
+	// Files holding the credentials instead, e.g. mounted Docker or Kubernetes secrets
+	AccessKeyFile string `mapstructure:"access_key_file"`
+	SecretKeyFile string `mapstructure:"secret_key_file"`
+	
+	// Address buckets as endpoint/bucket instead of bucket.endpoint
+	PathStyle bool `mapstructure:"path_style"`
+	
+	HTTP HTTPClientConfig `mapstructure:"http"`
 }
 
 // OSSConfig holds Aliyun OSS configuration
@@ -53,6 +647,12 @@ type OSSConfig struct {
 	AccessKey   string `mapstructure:"access_key"`
 	SecretKey   string `mapstructure:"secret_key"`
 	UseSSL      bool   `mapstructure:"use_ssl"`
+	
+	// Files holding the credentials instead, e.g. mounted Docker or Kubernetes secrets
+	AccessKeyFile string `mapstructure:"access_key_file"`
+	SecretKeyFile string `mapstructure:"secret_key_file"`
+	
+	HTTP HTTPClientConfig `mapstructure:"http"`
 }
 
 // OBSConfig holds Huawei Cloud OBS configuration
@@ -61,6 +661,12 @@ type OBSConfig struct {
 	AccessKey   string `mapstructure:"access_key"`
 	SecretKey   string `mapstructure:"secret_key"`
 	UseSSL      bool   `mapstructure:"use_ssl"`
+	
+	// Files holding the credentials instead, e.g. mounted Docker or Kubernetes secrets
+	AccessKeyFile string `mapstructure:"access_key_file"`
+	SecretKeyFile string `mapstructure:"secret_key_file"`
+	
+	HTTP HTTPClientConfig `mapstructure:"http"`
 }
 
 // AzureConfig holds Azure Blob configuration
@@ -69,24 +675,158 @@ type AzureConfig struct {
 	AccountName     string `mapstructure:"account_name"`
 	AccountKey      string `mapstructure:"account_key"`
 	ConnectionString string `mapstructure:"connection_string"`
+	
+	// Files holding the credentials instead, e.g. mounted Docker or Kubernetes secrets
+	AccountKeyFile       string `mapstructure:"account_key_file"`
+	ConnectionStringFile string `mapstructure:"connection_string_file"`
+	
+	HTTP HTTPClientConfig `mapstructure:"http"`
+}
+
+// HTTPClientConfig tunes the connections a backend opens to its service;
+// zero values keep the defaults of the backend's SDK
+type HTTPClientConfig struct {
+	// Time allowed to establish a TCP connection
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+	
+	// Time allowed for the TLS handshake of a new connection; not supported by OSS and OBS
+	TLSHandshakeTimeout time.Duration `mapstructure:"tls_handshake_timeout"`
+	
+	// Time allowed between sending a request and receiving the response headers
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+	
+	// Idle connections kept open in total and to each host
+	MaxIdleConns        int `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+	
+	// URL of the proxy the backend is reached through, e.g. http://proxy:3128
+	Proxy string `mapstructure:"proxy"`
+	
+	// PEM file of CA certificates trusted for the backend's TLS certificate
+	CACertFile string `mapstructure:"ca_cert_file"`
+	
+	// Accept any TLS certificate from the backend; dangerous outside of tests
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
 }
 
 // LogConfig holds log configuration
 type LogConfig struct {
 	Level string `mapstructure:"level"`
+	
+	// Where storage operations are audited: "stdout", "stderr" or a file
+	// path to append to; empty disables the audit log
+	Audit string `mapstructure:"audit"`
+	
+	// Query parameters whose values are masked in the access log besides
+	// api_key, which always is
+	RedactQueryParams []string `mapstructure:"redact_query_params"`
+}
+
+// DefaultBackendName is the name the single storage section is registered under
+const DefaultBackendName = "default"
+
+// Backends returns the configured storage backends keyed by name
+func (c *Config) Backends() map[string]StorageConfig {
+	if len(c.Storages) == 0 {
+		return map[string]StorageConfig{DefaultBackendName: c.Storage}
+	}
+	return c.Storages
 }
 
-// LoadConfig loads configuration from file and environment variables
-func LoadConfig() (*Config, error) {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
+// DefaultBackend returns the name of the backend used when a request does not select one
+func (c *Config) DefaultBackend() string {
+	if c.DefaultStorage != "" {
+		return c.DefaultStorage
+	}
+	if len(c.Storages) == 0 {
+		return DefaultBackendName
+	}
+	if len(c.Storages) == 1 {
+		for name := range c.Storages {
+			return name
+		}
+	}
+	return ""
+}
+
+// CacheControl returns the Cache-Control header for downloads from a bucket
+func (c *Config) CacheControl(bucket string) string {
+	if policy, ok := c.Buckets[bucket]; ok && policy.CacheControl != "" {
+		return policy.CacheControl
+	}
+	return c.Server.CacheControl
+}
+
+// AllowedOrigins returns the CORS origins allowed for a bucket
+func (c *Config) AllowedOrigins(bucket string) []string {
+	if policy, ok := c.Buckets[bucket]; ok && len(policy.CORS.AllowedOrigins) > 0 {
+		return policy.CORS.AllowedOrigins
+	}
+	return c.Server.CORS.AllowedOrigins
+}
+
+// DefaultPrefix returns the object key prefix of a bucket, with a trailing
+// slash, or "" when its keys are stored as given
+func (c *Config) DefaultPrefix(bucket string) string {
+	prefix := strings.Trim(c.Buckets[bucket].DefaultPrefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// ConfigFileEnv names the environment variable holding an explicit config file path
+const ConfigFileEnv = "FILESERVICE_CONFIG_FILE"
+
+// LoadConfig loads configuration from file and environment variables. When
+// path is empty, config.yaml is searched for in . and ./config.
+func LoadConfig(path string) (*Config, error) {
+	// An explicit file path, from the caller or FILESERVICE_CONFIG_FILE, replaces the search paths
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path != "" {
+		viper.SetConfigFile(path)
+	} else {
+		viper.SetConfigName("config")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(".")
+		viper.AddConfigPath("./config")
+	}
 	
 	// Set default values
 	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.read_only", false)
+	viper.SetDefault("server.detect_content_type", true)
+	viper.SetDefault("server.download.zip_concurrency", 4)
+	viper.SetDefault("server.download.async_prefix", ".archives/")
+	viper.SetDefault("server.download.async_ttl", "24h")
+	viper.SetDefault("server.download_rate_limit_bytes_per_sec", 0)
+	viper.SetDefault("server.antivirus.timeout", "30s")
+	// OSS allows the shortest keys and Azure the fewest path segments
+	viper.SetDefault("server.upload.max_key_length", 1023)
+	viper.SetDefault("server.upload.max_path_depth", 253)
+	viper.SetDefault("server.upload.dir_concurrency", 4)
+	viper.SetDefault("server.upload.dir_max_files", 10000)
+	viper.SetDefault("server.info_batch.max_objects", 1000)
+	viper.SetDefault("server.info_batch.concurrency", 16)
+	viper.SetDefault("server.metadata_cache.ttl", "30s")
+	viper.SetDefault("server.share.max_expiry", "168h")
+	viper.SetDefault("server.idempotency.ttl", "24h")
+	viper.SetDefault("server.ingest.allowed_schemes", []string{"https"})
+	viper.SetDefault("server.ingest.max_size", 1<<30)
+	viper.SetDefault("server.ingest.max_redirects", 5)
+	viper.SetDefault("server.resize.max_dimension", 4096)
+	viper.SetDefault("server.resize.cache", true)
+	viper.SetDefault("server.resize.cache_prefix", "_thumbs/")
+	viper.SetDefault("server.s3_api.path_prefix", "/s3")
+	viper.SetDefault("server.s3_api.region", "us-east-1")
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.mode", AuthModeAPIKey)
+	viper.SetDefault("auth.hmac_max_skew", "5m")
 	viper.SetDefault("storage.type", "minio")
 	viper.SetDefault("storage.bucket", "default")
+	viper.SetDefault("storage.directory_markers", "explicit")
 	viper.SetDefault("log.level", "info")
 	
 	// Enable environment variable support
@@ -96,7 +836,8 @@ func LoadConfig() (*Config, error) {
 	
 	// Read configuration
 	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		// A missing explicit file is an error rather than a silent fall back to defaults
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok || path != "" {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 		// Config file not found, will use defaults and environment variables
@@ -107,7 +848,281 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 	
-
+	// Read credentials given as files into their fields
+	if err := config.Storage.readSecretFiles("storage"); err != nil {
+		return nil, err
+	}
+	for name, backend := range config.Storages {
+		if err := backend.readSecretFiles("storages." + name); err != nil {
+			return nil, err
+		}
+		config.Storages[name] = backend
+	}
+	
+	// Backends without a proxy of their own use the global one
+	if config.OutboundProxy != "" {
+		config.Storage.inheritProxy(config.OutboundProxy)
+		for name, backend := range config.Storages {
+			backend.inheritProxy(config.OutboundProxy)
+			config.Storages[name] = backend
+		}
+	}
 	
 	return &config, nil
-}
\ No newline at end of file
+}
+
+// Validate checks that the configuration is usable and returns a single error
+// listing every problem found, or nil if the configuration is valid
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if err := validateProxy("outbound_proxy", c.OutboundProxy); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Server.Download.ZipConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("server.download.zip_concurrency must be at least 1, got %d", c.Server.Download.ZipConcurrency))
+	}
+	if c.Server.Download.MaxObjects < 0 || c.Server.Download.MaxBytes < 0 {
+		errs = append(errs, errors.New("server.download.max_zip_objects and max_zip_bytes must not be negative"))
+	}
+	if prefix := c.Server.Download.AsyncPrefix; !strings.HasSuffix(prefix, "/") || strings.HasPrefix(prefix, "/") {
+		errs = append(errs, fmt.Errorf("server.download.async_prefix must be a relative key prefix ending in \"/\", got %q", prefix))
+	}
+	if c.Server.Download.AsyncTTL <= 0 {
+		errs = append(errs, fmt.Errorf("server.download.async_ttl must be positive, got %s", c.Server.Download.AsyncTTL))
+	}
+	if c.Server.Antivirus.Enabled && c.Server.Antivirus.Address == "" {
+		errs = append(errs, errors.New("server.antivirus.enabled requires server.antivirus.address"))
+	}
+	if c.Server.Antivirus.Timeout < 0 {
+		errs = append(errs, errors.New("server.antivirus.timeout must not be negative"))
+	}
+	if c.Server.InfoBatch.MaxObjects < 1 {
+		errs = append(errs, fmt.Errorf("server.info_batch.max_objects must be at least 1, got %d", c.Server.InfoBatch.MaxObjects))
+	}
+	if c.Server.Upload.MaxKeyLength < 0 {
+		errs = append(errs, fmt.Errorf("server.upload.max_key_length must not be negative, got %d", c.Server.Upload.MaxKeyLength))
+	}
+	if c.Server.Upload.MaxPathDepth < 0 {
+		errs = append(errs, fmt.Errorf("server.upload.max_path_depth must not be negative, got %d", c.Server.Upload.MaxPathDepth))
+	}
+	if c.Server.Upload.DirConcurrency < 1 {
+		errs = append(errs, fmt.Errorf("server.upload.dir_concurrency must be at least 1, got %d", c.Server.Upload.DirConcurrency))
+	}
+	if c.Server.Upload.DirMaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("server.upload.dir_max_files must not be negative, got %d", c.Server.Upload.DirMaxFiles))
+	}
+	if c.Server.InfoBatch.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("server.info_batch.concurrency must be at least 1, got %d", c.Server.InfoBatch.Concurrency))
+	}
+
+	if c.Server.MetadataCache.Size < 0 {
+		errs = append(errs, fmt.Errorf("server.metadata_cache.size must not be negative, got %d", c.Server.MetadataCache.Size))
+	}
+	if c.Server.MetadataCache.Size > 0 && c.Server.MetadataCache.TTL <= 0 {
+		errs = append(errs, errors.New("server.metadata_cache.ttl must be positive when the cache is enabled"))
+	}
+
+	if c.Server.Idempotency.TTL < 0 {
+		errs = append(errs, errors.New("server.idempotency.ttl must not be negative"))
+	}
+
+	for i, rule := range c.Server.ContentDisposition {
+		if rule.ContentType == "" {
+			errs = append(errs, fmt.Errorf("server.content_disposition[%d].content_type must not be empty", i))
+		}
+		if rule.Disposition != "inline" && rule.Disposition != "attachment" {
+			errs = append(errs, fmt.Errorf("server.content_disposition[%d].disposition must be inline or attachment, got %q", i, rule.Disposition))
+		}
+	}
+
+	if c.Server.Resize.MaxDimension < 1 {
+		errs = append(errs, fmt.Errorf("server.resize.max_dimension must be at least 1, got %d", c.Server.Resize.MaxDimension))
+	}
+
+	if c.Server.DownloadRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("server.download_rate_limit_bytes_per_sec must not be negative, got %d", c.Server.DownloadRateLimit))
+	}
+	if c.Server.MaxConcurrentRequests < 0 {
+		errs = append(errs, fmt.Errorf("server.max_concurrent_requests must not be negative, got %d", c.Server.MaxConcurrentRequests))
+	}
+	if c.Server.QueueTimeout < 0 {
+		errs = append(errs, errors.New("server.queue_timeout must not be negative"))
+	}
+
+	if c.Server.S3API.Enabled {
+		if prefix := c.Server.S3API.PathPrefix; !strings.HasPrefix(prefix, "/") || prefix == "/" || strings.HasSuffix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("server.s3_api.path_prefix must start with a slash and not end with one, got %q", prefix))
+		}
+		if c.Auth.Enabled && len(c.Auth.S3Credentials) == 0 {
+			errs = append(errs, errors.New("server.s3_api.enabled requires auth.s3_credentials when auth is enabled"))
+		}
+	}
+
+	if c.Server.Share.MaxExpiry < 0 {
+		errs = append(errs, errors.New("server.share.max_expiry must not be negative"))
+	}
+
+	if index := c.Server.StaticSite.IndexDocument; strings.Contains(index, "/") || index == "." || index == ".." {
+		errs = append(errs, fmt.Errorf("server.static_site.index_document must be an object name without slashes, got %q", index))
+	}
+	if doc := c.Server.StaticSite.ErrorDocument; strings.HasPrefix(doc, "/") || strings.HasSuffix(doc, "/") || strings.Contains(doc, "..") {
+		errs = append(errs, fmt.Errorf("server.static_site.error_document must be a relative object path without \"..\", got %q", doc))
+	}
+
+	for _, scheme := range c.Server.Ingest.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			errs = append(errs, fmt.Errorf("server.ingest.allowed_schemes may only contain http and https, got %q", scheme))
+		}
+	}
+	if c.Server.Ingest.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("server.ingest.max_size must not be negative, got %d", c.Server.Ingest.MaxSize))
+	}
+	if c.Server.Ingest.MaxRedirects < 0 {
+		errs = append(errs, fmt.Errorf("server.ingest.max_redirects must not be negative, got %d", c.Server.Ingest.MaxRedirects))
+	}
+
+	if c.Auth.Mode != "" && c.Auth.Mode != AuthModeAPIKey && c.Auth.Mode != AuthModeHMAC {
+		errs = append(errs, fmt.Errorf("auth.mode must be %s or %s, got %q", AuthModeAPIKey, AuthModeHMAC, c.Auth.Mode))
+	}
+	keySetting, keys := c.Auth.keySetting()
+	if c.Auth.Enabled && len(keys) == 0 {
+		errs = append(errs, fmt.Errorf("auth.enabled is true but %s is empty", keySetting))
+	}
+	if c.Auth.Mode == AuthModeHMAC && c.Auth.HMACMaxSkew <= 0 {
+		errs = append(errs, errors.New("auth.hmac_max_skew must be positive"))
+	}
+	for key, prefix := range c.Auth.KeyPrefixes {
+		if _, ok := keys[key]; !ok {
+			errs = append(errs, fmt.Errorf("auth.key_prefixes has an entry for a key that is not in %s", keySetting))
+		}
+		if strings.HasPrefix(prefix, "/") || strings.Contains(prefix, "..") || strings.Trim(prefix, "/") == "" {
+			errs = append(errs, fmt.Errorf("auth.key_prefixes value %q must be a non-empty relative path without \"..\"", prefix))
+		}
+	}
+	for key, limit := range c.Auth.DownloadRateLimits {
+		if _, ok := keys[key]; !ok {
+			errs = append(errs, fmt.Errorf("auth.download_rate_limits has an entry for a key that is not in %s", keySetting))
+		}
+		if limit < 0 {
+			errs = append(errs, errors.New("auth.download_rate_limits values must not be negative"))
+		}
+	}
+	for key, limits := range c.Auth.ZipLimits {
+		if _, ok := keys[key]; !ok {
+			errs = append(errs, fmt.Errorf("auth.zip_limits has an entry for a key that is not in %s", keySetting))
+		}
+		if limits.MaxObjects < 0 || limits.MaxBytes < 0 {
+			errs = append(errs, errors.New("auth.zip_limits values must not be negative"))
+		}
+	}
+
+	for bucket, policy := range c.Buckets {
+		if prefix := policy.DefaultPrefix; prefix != "" && (strings.HasPrefix(prefix, "/") || strings.Contains(prefix, "..") || strings.Trim(prefix, "/") == "") {
+			errs = append(errs, fmt.Errorf("buckets.%s.default_prefix %q must be a non-empty relative path without \"..\"", bucket, prefix))
+		}
+	}
+
+	if len(c.Storages) == 0 {
+		errs = append(errs, c.Storage.validate("storage")...)
+	} else {
+		// The storage section still holds the timeouts backends fall back to
+		errs = append(errs, c.Storage.validateTimeouts("storage")...)
+		for name, backend := range c.Storages {
+			errs = append(errs, backend.validate("storages."+name)...)
+		}
+	}
+
+	if name := c.DefaultBackend(); name == "" {
+		errs = append(errs, errors.New("default_storage is required when more than one backend is configured in storages"))
+	} else if _, ok := c.Backends()[name]; !ok {
+		errs = append(errs, fmt.Errorf("default_storage %q does not match any configured backend", name))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateTimeouts checks the storage call timeouts under key
+func (s StorageConfig) validateTimeouts(key string) []error {
+	var errs []error
+	if s.OperationTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.operation_timeout must not be negative", key))
+	}
+	if s.DownloadIdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.download_idle_timeout must not be negative", key))
+	}
+	return errs
+}
+
+// validate checks that the selected backend has all of its required fields set
+func (s StorageConfig) validate(key string) []error {
+	var errs []error
+	missing := func(field, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s.%s.%s is required when %s.type is %q", key, s.Type, field, key, s.Type))
+		}
+	}
+
+	errs = append(errs, s.validateTimeouts(key)...)
+	if s.TrashRetention < 0 {
+		errs = append(errs, fmt.Errorf("%s.trash_retention must not be negative", key))
+	}
+	if s.DirectoryMarkers != "" && s.DirectoryMarkers != "explicit" && s.DirectoryMarkers != "implicit" {
+		errs = append(errs, fmt.Errorf("%s.directory_markers must be \"explicit\" or \"implicit\", got %q", key, s.DirectoryMarkers))
+	}
+	if s.NormalizeKeyCase != "" && s.NormalizeKeyCase != "lower" {
+		errs = append(errs, fmt.Errorf("%s.normalize_key_case must be empty or \"lower\", got %q", key, s.NormalizeKeyCase))
+	}
+	for _, field := range s.secretConflicts {
+		errs = append(errs, fmt.Errorf("%s.%s and %s.%s_file must not both be set", key, field, key, field))
+	}
+	if httpCfg, ok := s.httpClient(); ok {
+		errs = append(errs, httpCfg.validate(key+"."+s.Type+".http")...)
+	}
+
+	switch s.Type {
+	case "minio":
+		missing("endpoint", s.MinIO.Endpoint)
+		missing("access_key", s.MinIO.AccessKey)
+		missing("secret_key", s.MinIO.SecretKey)
+		if size := s.MinIO.PartSize; size != 0 && (size < MinIOMinPartSize || size > MinIOMaxPartSize) {
+			errs = append(errs, fmt.Errorf("%s.minio.part_size must be between %d (5 MiB) and %d (5 GiB) bytes, got %d", key, MinIOMinPartSize, MinIOMaxPartSize, size))
+		}
+		if s.MinIO.NumThreads < 0 {
+			errs = append(errs, fmt.Errorf("%s.minio.num_threads must not be negative, got %d", key, s.MinIO.NumThreads))
+		}
+	case "s3compat":
+		missing("endpoint", s.S3Compat.Endpoint)
+		missing("access_key", s.S3Compat.AccessKey)
+		missing("secret_key", s.S3Compat.SecretKey)
+	case "oss":
+		missing("endpoint", s.OSS.Endpoint)
+		missing("access_key", s.OSS.AccessKey)
+		missing("secret_key", s.OSS.SecretKey)
+	case "obs":
+		missing("endpoint", s.OBS.Endpoint)
+		missing("access_key", s.OBS.AccessKey)
+		missing("secret_key", s.OBS.SecretKey)
+	case "azure":
+		// A connection string carries the account credentials on its own
+		if s.Azure.ConnectionString == "" {
+			missing("account_name", s.Azure.AccountName)
+			missing("account_key", s.Azure.AccountKey)
+		}
+	case "memory":
+		// In-memory storage needs no configuration
+	case "":
+		errs = append(errs, fmt.Errorf("%s.type is required", key))
+	default:
+		// Types registered with storage.Register are checked when the backend is created
+	}
+
+	return errs
+}
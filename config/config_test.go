@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// loadTestConfig loads a config file holding yaml, with the defaults of
+// LoadConfig for everything it leaves out
+func loadTestConfig(t *testing.T, yaml string) *Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateBackendRequiredFields(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string // one entry per expected problem
+	}{
+		{
+			name: "minio without credentials",
+			yaml: "storage:\n  type: minio\n",
+			want: []string{"storage.minio.endpoint is required", "storage.minio.access_key is required", "storage.minio.secret_key is required"},
+		},
+		{
+			name: "minio without secret key",
+			yaml: "storage:\n  type: minio\n  minio:\n    endpoint: localhost:9000\n    access_key: key\n",
+			want: []string{"storage.minio.secret_key is required"},
+		},
+		{
+			name: "s3compat without credentials",
+			yaml: "storage:\n  type: s3compat\n",
+			want: []string{"storage.s3compat.endpoint is required", "storage.s3compat.access_key is required", "storage.s3compat.secret_key is required"},
+		},
+		{
+			name: "oss without credentials",
+			yaml: "storage:\n  type: oss\n",
+			want: []string{"storage.oss.endpoint is required", "storage.oss.access_key is required", "storage.oss.secret_key is required"},
+		},
+		{
+			name: "obs without credentials",
+			yaml: "storage:\n  type: obs\n",
+			want: []string{"storage.obs.endpoint is required", "storage.obs.access_key is required", "storage.obs.secret_key is required"},
+		},
+		{
+			name: "azure without credentials",
+			yaml: "storage:\n  type: azure\n",
+			want: []string{"storage.azure.account_name is required", "storage.azure.account_key is required"},
+		},
+		{
+			name: "azure with an account name only",
+			yaml: "storage:\n  type: azure\n  azure:\n    account_name: acct\n",
+			want: []string{"storage.azure.account_key is required"},
+		},
+		{
+			name: "named backend without a type",
+			yaml: "storages:\n  archive:\n    bucket: old\ndefault_storage: archive\n",
+			want: []string{"storages.archive.type is required"},
+		},
+		{
+			name: "named backend without credentials",
+			yaml: "storages:\n  archive:\n    type: oss\ndefault_storage: archive\n",
+			want: []string{"storages.archive.oss.endpoint is required", "storages.archive.oss.access_key is required", "storages.archive.oss.secret_key is required"},
+		},
+		{
+			name: "named backend with negative timeouts",
+			yaml: "storages:\n  archive:\n    type: memory\n    operation_timeout: -1s\n    download_idle_timeout: -1s\ndefault_storage: archive\n",
+			want: []string{"storages.archive.operation_timeout must not be negative", "storages.archive.download_idle_timeout must not be negative"},
+		},
+		{
+			name: "negative fallback timeout with named backends",
+			yaml: "storage:\n  operation_timeout: -1s\nstorages:\n  archive:\n    type: memory\ndefault_storage: archive\n",
+			want: []string{"storage.operation_timeout must not be negative"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertProblems(t, loadTestConfig(t, tt.yaml).Validate(), tt.want)
+		})
+	}
+}
+
+func TestValidateCompleteBackends(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"minio":                   "storage:\n  type: minio\n  minio:\n    endpoint: localhost:9000\n    access_key: key\n    secret_key: secret\n",
+		"s3compat":                "storage:\n  type: s3compat\n  s3compat:\n    endpoint: s3.example.com\n    access_key: key\n    secret_key: secret\n",
+		"oss":                     "storage:\n  type: oss\n  oss:\n    endpoint: oss-cn-hangzhou.aliyuncs.com\n    access_key: key\n    secret_key: secret\n",
+		"obs":                     "storage:\n  type: obs\n  obs:\n    endpoint: obs.cn-north-4.myhuaweicloud.com\n    access_key: key\n    secret_key: secret\n",
+		"azure with account key":  "storage:\n  type: azure\n  azure:\n    account_name: acct\n    account_key: a2V5\n",
+		"azure connection string": "storage:\n  type: azure\n  azure:\n    connection_string: UseDevelopmentStorage=true\n",
+		"memory":                  "storage:\n  type: memory\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := loadTestConfig(t, yaml).Validate(); err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestValidateServerAndAuth(t *testing.T) {
+	const memory = "storage:\n  type: memory\n"
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "port out of range",
+			yaml: memory + "server:\n  port: 70000\n",
+			want: []string{"server.port must be between 1 and 65535"},
+		},
+		{
+			name: "negative port",
+			yaml: memory + "server:\n  port: -1\n",
+			want: []string{"server.port must be between 1 and 65535"},
+		},
+		{
+			name: "auth enabled without api keys",
+			yaml: memory + "auth:\n  enabled: true\n",
+			want: []string{"auth.enabled is true but auth.api_keys is empty"},
+		},
+		{
+			name: "hmac auth without hmac keys",
+			yaml: memory + "auth:\n  enabled: true\n  mode: hmac\n  api_keys:\n    k1: unused in hmac mode\n",
+			want: []string{"auth.enabled is true but auth.hmac_keys is empty"},
+		},
+		{
+			name: "unknown auth mode",
+			yaml: memory + "auth:\n  mode: token\n",
+			want: []string{`auth.mode must be api_key or hmac, got "token"`},
+		},
+		{
+			name: "key prefix for an unknown key",
+			yaml: memory + "auth:\n  enabled: true\n  api_keys:\n    k1: team\n  key_prefixes:\n    k2: tenants/b\n",
+			want: []string{"auth.key_prefixes has an entry for a key that is not in auth.api_keys"},
+		},
+		{
+			name: "s3 api with auth but no s3 credentials",
+			yaml: memory + "server:\n  s3_api:\n    enabled: true\nauth:\n  enabled: true\n  api_keys:\n    k1: team\n",
+			want: []string{"server.s3_api.enabled requires auth.s3_credentials"},
+		},
+		{
+			name: "several backends without a default",
+			yaml: "storages:\n  a:\n    type: memory\n  b:\n    type: memory\n",
+			want: []string{"default_storage is required"},
+		},
+		{
+			name: "default backend that isn't configured",
+			yaml: "storages:\n  a:\n    type: memory\ndefault_storage: b\n",
+			want: []string{`default_storage "b" does not match any configured backend`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertProblems(t, loadTestConfig(t, tt.yaml).Validate(), tt.want)
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := loadTestConfig(t, "server:\n  port: 0\nauth:\n  enabled: true\nstorage:\n  type: azure\n")
+	assertProblems(t, cfg.Validate(), []string{
+		"server.port must be between 1 and 65535",
+		"auth.enabled is true but auth.api_keys is empty",
+		"storage.azure.account_name is required",
+		"storage.azure.account_key is required",
+	})
+}
+
+// assertProblems checks that err lists exactly the problems in want, one per
+// line after the "invalid configuration" heading
+func assertProblems(t *testing.T, err error, want []string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("Validate() = nil, want %d problems", len(want))
+	}
+	problems := strings.Split(strings.TrimPrefix(err.Error(), "invalid configuration: "), "\n")
+	if len(problems) != len(want) {
+		t.Errorf("Validate() reported %d problems, want %d:\n%v", len(problems), len(want), err)
+	}
+	for _, w := range want {
+		found := false
+		for _, problem := range problems {
+			if strings.Contains(problem, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Validate() = %v, missing %q", err, w)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Canned ACLs accepted by SetACL
+const (
+	// ACLPrivate limits reads to the service's own credentials
+	ACLPrivate = "private"
+
+	// ACLPublicRead lets anyone read the object from the provider without credentials
+	ACLPublicRead = "public-read"
+)
+
+// Scopes of an ACL change, as reported in Capabilities.ACLScope
+const (
+	// ACLScopeObject changes the access of the given object only
+	ACLScopeObject = "object"
+
+	// ACLScopeBucket changes the access of every object in the bucket
+	ACLScopeBucket = "bucket"
+)
+
+// ACLStorage is implemented by backends that can make objects publicly
+// readable straight from the provider. MinIO, S3-compatible services, OSS and
+// OBS change single objects; Azure has no blob-level access control, so its
+// SetACL changes the public access of the whole container, as reported by
+// Capabilities.ACLScope. The in-memory backend doesn't implement it.
+type ACLStorage interface {
+	// SetACL applies a canned ACL, ACLPrivate or ACLPublicRead, to an object
+	SetACL(ctx context.Context, bucket, objectName, acl string) error
+
+	// PublicURL returns the unauthenticated URL of an object at the
+	// provider, which serves it while it is publicly readable
+	PublicURL(bucket, objectName string) string
+}
+
+// virtualHostURL returns the URL of an object on a provider that addresses
+// buckets as subdomains of its endpoint, e.g. https://bucket.endpoint/key
+func virtualHostURL(endpoint, bucket, objectName string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	u.Host = bucket + "." + u.Host
+	u.Path = "/" + objectName
+	return u.String()
+}
+
+// prefixedACL confines the operations of an ACLStorage to a prefix
+type prefixedACL struct {
+	inner  ACLStorage
+	prefix string
+}
+
+func (p *prefixedACL) SetACL(ctx context.Context, bucket, objectName, acl string) error {
+	return p.inner.SetACL(ctx, bucket, p.prefix+objectName, acl)
+}
+
+func (p *prefixedACL) PublicURL(bucket, objectName string) string {
+	return p.inner.PublicURL(bucket, p.prefix+objectName)
+}
+
+// auditACL logs the operations of an ACLStorage
+type auditACL struct {
+	inner ACLStorage
+	log   *AuditLogger
+}
+
+func (a *auditACL) SetACL(ctx context.Context, bucket, objectName, acl string) error {
+	start := time.Now()
+	err := a.inner.SetACL(ctx, bucket, objectName, acl)
+	a.log.record(ctx, "set_acl", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditACL) PublicURL(bucket, objectName string) string {
+	return a.inner.PublicURL(bucket, objectName)
+}
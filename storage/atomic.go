@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// atomicTempPrefix holds uploads in progress; listings leave it out
+const atomicTempPrefix = ".tmp/"
+
+// atomicUploads stages uploads under a temporary key and renames them into
+// place once complete
+type atomicUploads struct {
+	Storage
+}
+
+// WithAtomicUploads returns a view of s whose uploads only appear at the
+// object key once they are complete. Upload streams the content to a random
+// key under .tmp/, then copies it to the object key on the server and
+// deletes the temporary object. A failed or interrupted upload deletes the
+// temporary object and leaves whatever was stored at the key before.
+//
+// Conditional uploads, multipart uploads and the operations of the optional
+// interfaces are passed through to s, so their checks stay as atomic as the
+// backend makes them. The result keeps implementing the optional interfaces of s.
+func WithAtomicUploads(s Storage) Storage {
+	return withOptional(&atomicUploads{Storage: s}, s)
+}
+
+func (a *atomicUploads) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	staged, err := randomKey(atomicTempPrefix)
+	if err != nil {
+		return nil, err
+	}
+	// The temporary object goes whether the upload succeeded or not; a
+	// partial one may have been left behind by a failed stream
+	defer a.Storage.Delete(context.WithoutCancel(ctx), bucket, staged)
+
+	stored, err := a.Storage.Upload(ctx, bucket, staged, reader, size, contentType, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Storage.Copy(ctx, bucket, staged, objectName, nil); err != nil {
+		return nil, err
+	}
+
+	// The copy has an ETag and version of its own
+	info, err := a.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadResult{ETag: info.ETag, VersionID: info.VersionID, Size: stored.Size}, nil
+}
+
+// isAtomicTemp reports whether obj is an upload staged by atomicUploads
+func isAtomicTemp(obj FileObject) bool {
+	return strings.HasPrefix(obj.Name, atomicTempPrefix)
+}
+
+// withoutAtomicTemps drops staged uploads from a listing
+func withoutAtomicTemps(objects []FileObject) []FileObject {
+	kept := objects[:0]
+	for _, obj := range objects {
+		if !isAtomicTemp(obj) {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+func (a *atomicUploads) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	objects, err := a.Storage.List(ctx, bucket, prefix)
+	return withoutAtomicTemps(objects), err
+}
+
+func (a *atomicUploads) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	objects, err := a.Storage.ListObjects(ctx, bucket, prefix)
+	return withoutAtomicTemps(objects), err
+}
+
+func (a *atomicUploads) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	dirs, err := a.Storage.ListDirectories(ctx, bucket, prefix)
+	return withoutAtomicTemps(dirs), err
+}
+
+func (a *atomicUploads) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	return a.Storage.Walk(ctx, bucket, prefix, func(obj FileObject) error {
+		if isAtomicTemp(obj) {
+			return nil
+		}
+		return fn(obj)
+	})
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failingReader returns its content and then fails, like a client that
+// disconnects in the middle of an upload
+type failingReader struct {
+	reader io.Reader
+}
+
+var errDisconnected = errors.New("client disconnected")
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	if err == io.EOF {
+		return n, errDisconnected
+	}
+	return n, err
+}
+
+func newAtomicStore(t *testing.T) (Storage, *MemoryStorage) {
+	t.Helper()
+	mem := NewMemoryStorage()
+	if err := mem.CreateBucket(context.Background(), "default"); err != nil {
+		t.Fatal(err)
+	}
+	return WithAtomicUploads(mem), mem
+}
+
+func TestAtomicUploadAbortedLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	store, mem := newAtomicStore(t)
+
+	reader := &failingReader{reader: strings.NewReader("the first half")}
+	if _, err := store.Upload(ctx, "default", "report.txt", reader, 28, "text/plain", ObjectHeaders{}); err == nil {
+		t.Fatal("Upload of an interrupted body succeeded")
+	}
+
+	if _, err := mem.GetObjectInfo(ctx, "default", "report.txt"); !IsNotFound(err) {
+		t.Errorf("GetObjectInfo of the final key = %v, want not found", err)
+	}
+	staged, err := mem.ListObjects(ctx, "default", atomicTempPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 0 {
+		t.Errorf("%d staged objects left behind", len(staged))
+	}
+}
+
+func TestAtomicUploadAbortedKeepsPreviousObject(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newAtomicStore(t)
+	uploadString(t, store, "report.txt", "previous")
+
+	reader := &failingReader{reader: strings.NewReader("the first half")}
+	if _, err := store.Upload(ctx, "default", "report.txt", reader, 28, "text/plain", ObjectHeaders{}); err == nil {
+		t.Fatal("Upload of an interrupted body succeeded")
+	}
+
+	content, err := store.Download(ctx, "default", "report.txt")
+	if got := readAll(t, content, err); got != "previous" {
+		t.Errorf("Download after an aborted upload = %q, want the previous object", got)
+	}
+}
+
+func TestAtomicUploadComplete(t *testing.T) {
+	ctx := context.Background()
+	store, mem := newAtomicStore(t)
+	uploadString(t, store, "report.txt", "complete")
+
+	content, err := store.Download(ctx, "default", "report.txt")
+	if got := readAll(t, content, err); got != "complete" {
+		t.Errorf("Download = %q, want the uploaded content", got)
+	}
+	staged, err := mem.ListObjects(ctx, "default", atomicTempPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 0 {
+		t.Errorf("%d staged objects left behind", len(staged))
+	}
+}
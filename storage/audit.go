@@ -0,0 +1,430 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// principalKey is the context key the authenticated principal is stored under
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal recorded in audit events
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, or "" if there is none
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+// AuditEvent is one audited storage operation, written as a JSON line
+type AuditEvent struct {
+	Time       string `json:"time"`
+	Principal  string `json:"principal,omitempty"`
+	Op         string `json:"op"`
+	Bucket     string `json:"bucket,omitempty"`
+	Object     string `json:"object,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// AuditLogger writes audit events as JSON lines to a sink shared by every
+// audited backend
+type AuditLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewAuditLogger returns a logger writing to w
+func NewAuditLogger(w io.Writer) *AuditLogger {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &AuditLogger{enc: enc}
+}
+
+// record writes the event for an operation started at start that ended with err
+func (l *AuditLogger) record(ctx context.Context, op, bucket, object string, size int64, start time.Time, err error) {
+	event := AuditEvent{
+		Time:       start.UTC().Format(time.RFC3339Nano),
+		Principal:  PrincipalFromContext(ctx),
+		Op:         op,
+		Bucket:     bucket,
+		Object:     object,
+		Size:       size,
+		Result:     "ok",
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		event.Result = "error"
+		event.Error = err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// An audit sink that fails can't be reported anywhere better, so the error is dropped
+	_ = l.enc.Encode(event)
+}
+
+// auditStorage logs every operation of the wrapped Storage
+type auditStorage struct {
+	inner Storage
+	log   *AuditLogger
+}
+
+// auditMultipart logs the operations of a MultipartStorage
+type auditMultipart struct {
+	inner MultipartStorage
+	log   *AuditLogger
+}
+
+// auditVersioned logs the operations of a VersionedStorage
+type auditVersioned struct {
+	inner VersionedStorage
+	log   *AuditLogger
+}
+
+// WithAuditLog returns a Storage that records every operation on s with log.
+// The result keeps implementing MultipartStorage and VersionedStorage when s
+// does, and ObjectLockStorage and ACLStorage when s also implements both of
+// those; ACLStorage only together with RestoreStorage and PresignStorage,
+// which it then keeps too.
+func WithAuditLog(s Storage, log *AuditLogger) Storage {
+	base := &auditStorage{inner: s, log: log}
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			*auditStorage
+			*auditMultipart
+			*auditVersioned
+			*auditObjectLock
+			*auditACL
+			*auditRestore
+			*auditPresign
+		}{base, &auditMultipart{inner: multipart, log: log}, &auditVersioned{inner: versioned, log: log}, &auditObjectLock{inner: locking, log: log}, &auditACL{inner: acl, log: log}, &auditRestore{inner: restore, log: log}, &auditPresign{inner: presign, log: log}}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			*auditStorage
+			*auditMultipart
+			*auditVersioned
+			*auditObjectLock
+		}{base, &auditMultipart{inner: multipart, log: log}, &auditVersioned{inner: versioned, log: log}, &auditObjectLock{inner: locking, log: log}}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			*auditStorage
+			*auditMultipart
+			*auditVersioned
+			*auditACL
+			*auditRestore
+			*auditPresign
+		}{base, &auditMultipart{inner: multipart, log: log}, &auditVersioned{inner: versioned, log: log}, &auditACL{inner: acl, log: log}, &auditRestore{inner: restore, log: log}, &auditPresign{inner: presign, log: log}}
+	case isMultipart && isVersioned:
+		return &struct {
+			*auditStorage
+			*auditMultipart
+			*auditVersioned
+		}{base, &auditMultipart{inner: multipart, log: log}, &auditVersioned{inner: versioned, log: log}}
+	case isMultipart:
+		return &struct {
+			*auditStorage
+			*auditMultipart
+		}{base, &auditMultipart{inner: multipart, log: log}}
+	case isVersioned:
+		return &struct {
+			*auditStorage
+			*auditVersioned
+		}{base, &auditVersioned{inner: versioned, log: log}}
+	}
+	return base
+}
+
+// auditReader records a download once the stream is closed, with the number
+// of bytes read and the time the transfer took
+type auditReader struct {
+	io.ReadCloser
+	ctx            context.Context
+	log            *AuditLogger
+	op             string
+	bucket, object string
+	start          time.Time
+	n              int64
+	err            error
+	once           sync.Once
+}
+
+func (r *auditReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *auditReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() {
+		result := r.err
+		if result == nil {
+			result = err
+		}
+		r.log.record(r.ctx, r.op, r.bucket, r.object, r.n, r.start, result)
+	})
+	return err
+}
+
+// download records a failed open right away and a successful one when the stream is closed
+func (l *AuditLogger) download(ctx context.Context, op, bucket, object string, start time.Time, reader io.ReadCloser, err error) (io.ReadCloser, error) {
+	if err != nil {
+		l.record(ctx, op, bucket, object, 0, start, err)
+		return nil, err
+	}
+	return &auditReader{ReadCloser: reader, ctx: ctx, log: l, op: op, bucket: bucket, object: object, start: start}, nil
+}
+
+// uploadSize returns the stored size of an upload, falling back to the declared size
+func uploadSize(result *UploadResult, size int64) int64 {
+	if result != nil {
+		return result.Size
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+func (a *auditStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	start := time.Now()
+	result, err := a.inner.Upload(ctx, bucket, objectName, reader, size, contentType, headers)
+	a.log.record(ctx, "upload", bucket, objectName, uploadSize(result, size), start, err)
+	return result, err
+}
+
+func (a *auditStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := a.inner.Download(ctx, bucket, objectName)
+	return a.log.download(ctx, "download", bucket, objectName, start, reader, err)
+}
+
+func (a *auditStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := a.inner.DownloadRange(ctx, bucket, objectName, offset, length)
+	return a.log.download(ctx, "download", bucket, objectName, start, reader, err)
+}
+
+func (a *auditStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	start := time.Now()
+	result, err := a.inner.UploadIfMatch(ctx, bucket, objectName, reader, size, contentType, headers, etag)
+	a.log.record(ctx, "upload", bucket, objectName, uploadSize(result, size), start, err)
+	return result, err
+}
+
+func (a *auditStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	start := time.Now()
+	result, err := a.inner.UploadIfNotExists(ctx, bucket, objectName, reader, size, contentType, headers)
+	a.log.record(ctx, "upload", bucket, objectName, uploadSize(result, size), start, err)
+	return result, err
+}
+
+func (a *auditStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	start := time.Now()
+	err := a.inner.Delete(ctx, bucket, objectName)
+	a.log.record(ctx, "delete", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	start := time.Now()
+	err := a.inner.DeleteIfMatch(ctx, bucket, objectName, etag)
+	a.log.record(ctx, "delete", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	start := time.Now()
+	objects, err := a.inner.List(ctx, bucket, prefix)
+	a.log.record(ctx, "list", bucket, prefix, 0, start, err)
+	return objects, err
+}
+
+func (a *auditStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	start := time.Now()
+	err := a.inner.Walk(ctx, bucket, prefix, fn)
+	a.log.record(ctx, "list", bucket, prefix, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	start := time.Now()
+	info, err := a.inner.GetObjectInfo(ctx, bucket, objectName)
+	var size int64
+	if info != nil {
+		size = info.Size
+	}
+	a.log.record(ctx, "stat", bucket, objectName, size, start, err)
+	return info, err
+}
+
+func (a *auditStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	start := time.Now()
+	err := a.inner.UpdateMetadata(ctx, bucket, objectName, metadata, contentType)
+	a.log.record(ctx, "update_metadata", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	start := time.Now()
+	err := a.inner.Copy(ctx, bucket, srcObject, dstObject, metadata)
+	a.log.record(ctx, "copy", bucket, srcObject+" -> "+dstObject, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	start := time.Now()
+	err := a.inner.CreateDirectory(ctx, bucket, objectName, metadata)
+	a.log.record(ctx, "create_directory", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	start := time.Now()
+	dirs, err := a.inner.ListDirectories(ctx, bucket, prefix)
+	a.log.record(ctx, "list_directories", bucket, prefix, 0, start, err)
+	return dirs, err
+}
+
+func (a *auditStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	start := time.Now()
+	objects, err := a.inner.ListObjects(ctx, bucket, prefix)
+	a.log.record(ctx, "list_objects", bucket, prefix, 0, start, err)
+	return objects, err
+}
+
+func (a *auditStorage) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	start := time.Now()
+	err := a.inner.EnsurePathExists(ctx, bucket, objectPath)
+	a.log.record(ctx, "create_directory", bucket, objectPath, 0, start, err)
+	return err
+}
+
+func (a *auditStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	start := time.Now()
+	exists, err := a.inner.BucketExists(ctx, bucket)
+	a.log.record(ctx, "bucket_exists", bucket, "", 0, start, err)
+	return exists, err
+}
+
+func (a *auditStorage) CreateBucket(ctx context.Context, bucket string) error {
+	start := time.Now()
+	err := a.inner.CreateBucket(ctx, bucket)
+	a.log.record(ctx, "create_bucket", bucket, "", 0, start, err)
+	return err
+}
+
+func (a *auditStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	start := time.Now()
+	err := a.inner.DeleteBucket(ctx, bucket)
+	a.log.record(ctx, "delete_bucket", bucket, "", 0, start, err)
+	return err
+}
+
+func (a *auditStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	start := time.Now()
+	buckets, err := a.inner.ListBuckets(ctx)
+	a.log.record(ctx, "list_buckets", "", "", 0, start, err)
+	return buckets, err
+}
+
+// HealthCheck is a probe rather than an object operation, so it isn't audited
+func (a *auditStorage) HealthCheck(ctx context.Context, bucket string) error {
+	return a.inner.HealthCheck(ctx, bucket)
+}
+
+func (a *auditStorage) Capabilities() Capabilities {
+	return a.inner.Capabilities()
+}
+
+func (a *auditMultipart) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	start := time.Now()
+	uploadID, err := a.inner.InitMultipart(ctx, bucket, objectName, contentType, headers)
+	a.log.record(ctx, "init_multipart", bucket, objectName, 0, start, err)
+	return uploadID, err
+}
+
+func (a *auditMultipart) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	start := time.Now()
+	part, err := a.inner.UploadPart(ctx, bucket, objectName, uploadID, partNumber, reader, size)
+	a.log.record(ctx, "upload_part", bucket, objectName, part.Size, start, err)
+	return part, err
+}
+
+func (a *auditMultipart) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	start := time.Now()
+	parts, err := a.inner.ListParts(ctx, bucket, objectName, uploadID)
+	a.log.record(ctx, "list_parts", bucket, objectName, 0, start, err)
+	return parts, err
+}
+
+func (a *auditMultipart) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	start := time.Now()
+	err := a.inner.CompleteMultipart(ctx, bucket, objectName, uploadID)
+	a.log.record(ctx, "complete_multipart", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditMultipart) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	start := time.Now()
+	err := a.inner.AbortMultipart(ctx, bucket, objectName, uploadID)
+	a.log.record(ctx, "abort_multipart", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditMultipart) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	start := time.Now()
+	uploads, err := a.inner.ListMultipartUploads(ctx, bucket, prefix)
+	a.log.record(ctx, "list_multipart_uploads", bucket, prefix, 0, start, err)
+	return uploads, err
+}
+
+func (a *auditVersioned) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := a.inner.DownloadVersion(ctx, bucket, objectName, versionID)
+	return a.log.download(ctx, "download_version", bucket, objectName, start, reader, err)
+}
+
+func (a *auditVersioned) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	start := time.Now()
+	info, err := a.inner.GetObjectVersionInfo(ctx, bucket, objectName, versionID)
+	var size int64
+	if info != nil {
+		size = info.Size
+	}
+	a.log.record(ctx, "stat_version", bucket, objectName, size, start, err)
+	return info, err
+}
+
+func (a *auditVersioned) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	start := time.Now()
+	err := a.inner.DeleteVersion(ctx, bucket, objectName, versionID)
+	a.log.record(ctx, "delete_version", bucket, objectName, 0, start, err)
+	return err
+}
+
+func (a *auditVersioned) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	start := time.Now()
+	versions, err := a.inner.ListVersions(ctx, bucket, prefix)
+	a.log.record(ctx, "list_versions", bucket, prefix, 0, start, err)
+	return versions, err
+}
@@ -2,54 +2,204 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 )
 
 // AzureStorage implements the Storage interface for Azure Blob Storage
 type AzureStorage struct {
 	client *azblob.Client
+	
+	// The client holds the account key, which signing SAS URLs takes
+	sharedKey bool
+}
+
+func init() {
+	Register("azure", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Endpoint         string      `mapstructure:"endpoint"`
+			AccountName      string      `mapstructure:"account_name"`
+			AccountKey       string      `mapstructure:"account_key"`
+			ConnectionString string      `mapstructure:"connection_string"`
+			HTTP             HTTPOptions `mapstructure:"http"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		// 如果提供了连接字符串，优先使用连接字符串
+		if opts.ConnectionString != "" {
+			return NewAzureStorageFromConnectionString(opts.ConnectionString, opts.HTTP)
+		}
+		// 构造完整的endpoint URL
+		endpoint := opts.Endpoint
+		if endpoint == "" && opts.AccountName != "" {
+			endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", opts.AccountName)
+		}
+		return NewAzureStorage(opts.AccountName, opts.AccountKey, endpoint, opts.HTTP)
+	})
 }
 
 // NewAzureStorage creates a new Azure Blob storage instance
-func NewAzureStorage(accountName, accountKey, serviceURL string) (*AzureStorage, error) {
+func NewAzureStorage(accountName, accountKey, serviceURL string, httpOpts HTTPOptions) (*AzureStorage, error) {
 	// Create a credential object using the account name and key
 	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
 	if err != nil {
 		return nil, err
 	}
 
+	options, err := azureClientOptions(httpOpts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a client
-	client, err := azblob.NewClientWithSharedKeyCredential(serviceURL, credential, nil)
+	client, err := azblob.NewClientWithSharedKeyCredential(serviceURL, credential, options)
 	if err != nil {
 		return nil, err
 	}
 
 	return &AzureStorage{
-		client: client,
+		client:    client,
+		sharedKey: true,
 	}, nil
 }
 
+// NewAzureStorageFromConnectionString creates a new Azure Blob Storage instance
+// from a storage account connection string
+func NewAzureStorageFromConnectionString(connectionString string, httpOpts HTTPOptions) (*AzureStorage, error) {
+	options, err := azureClientOptions(httpOpts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := azblob.NewClientFromConnectionString(connectionString, options)
+	if err != nil {
+		return nil, err
+	}
+	// Connection strings carry either the account key or a SAS; signing is
+	// local, so trying it tells which
+	_, err = client.ServiceClient().GetSASURL(sas.AccountResourceTypes{Object: true}, sas.AccountPermissions{Read: true}, time.Now().Add(time.Minute), nil)
+
+	return &AzureStorage{
+		client:    client,
+		sharedKey: err == nil,
+	}, nil
+}
+
+// azureClientOptions returns the client options applying httpOpts, or nil to
+// keep the SDK's shared default client when none are set. The transport
+// otherwise starts from the same settings as the SDK default, including its
+// proxy from the environment.
+func azureClientOptions(httpOpts HTTPOptions) (*azblob.ClientOptions, error) {
+	if httpOpts == (HTTPOptions{}) {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 10
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:    tls.VersionTLS12,
+		Renegotiation: tls.RenegotiateFreelyAsClient,
+	}
+	configured, err := httpOpts.configureTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	options := &azblob.ClientOptions{}
+	options.Transport = &http.Client{Transport: configured}
+	return options, nil
+}
+
 // Upload uploads a file to Azure Blob Storage
-func (a *AzureStorage) Upload(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, contentType string) error {
+func (a *AzureStorage) Upload(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
 	// Upload blob
-	options := &azblob.UploadStreamOptions{}
-	if contentType != "" {
-		options.HTTPHeaders = &blob.HTTPHeaders{
-			BlobContentType: &contentType,
+	options := &azblob.UploadStreamOptions{
+		HTTPHeaders: azureHTTPHeaders(contentType, headers),
+	}
+	
+	return a.uploadStream(ctx, containerName, blobName, reader, options)
+}
+
+// UploadIfMatch uploads a file to Azure Blob Storage with an If-Match access condition
+func (a *AzureStorage) UploadIfMatch(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	options := &azblob.UploadStreamOptions{
+		AccessConditions: ifMatchCondition(etag),
+		HTTPHeaders:      azureHTTPHeaders(contentType, headers),
+	}
+	
+	return a.uploadStream(ctx, containerName, blobName, reader, options)
+}
+
+// UploadIfNotExists uploads a file to Azure Blob Storage with an
+// If-None-Match: * access condition, checked when the block list is committed
+func (a *AzureStorage) UploadIfNotExists(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	noneMatch := azcore.ETagAny
+	options := &azblob.UploadStreamOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &noneMatch},
+		},
+		HTTPHeaders: azureHTTPHeaders(contentType, headers),
+	}
+	
+	result, err := a.uploadStream(ctx, containerName, blobName, reader, options)
+	return result, existsError(err)
+}
+
+// azureHTTPHeaders returns the blob headers storing contentType and headers,
+// or nil when there are none so Azure applies its defaults
+func azureHTTPHeaders(contentType string, headers ObjectHeaders) *blob.HTTPHeaders {
+	if contentType == "" && headers == (ObjectHeaders{}) {
+		return nil
+	}
+	value := func(s string) *string {
+		if s == "" {
+			return nil
 		}
+		return &s
+	}
+	return &blob.HTTPHeaders{
+		BlobContentType:     value(contentType),
+		BlobCacheControl:    value(headers.CacheControl),
+		BlobContentEncoding: value(headers.ContentEncoding),
+		BlobContentLanguage: value(headers.ContentLanguage),
+	}
+}
+
+// uploadStream uploads a blob and reports what was stored; the commit
+// response has no size, so the bytes sent are counted
+func (a *AzureStorage) uploadStream(ctx context.Context, containerName, blobName string, reader io.Reader, options *azblob.UploadStreamOptions) (*UploadResult, error) {
+	counter := &countingReader{Reader: reader}
+	resp, err := a.client.UploadStream(ctx, containerName, blobName, counter, options)
+	if err != nil {
+		return nil, err
 	}
 	
-	_, err := a.client.UploadStream(ctx, containerName, blobName, reader, options)
-	return err
+	result := &UploadResult{Size: counter.n}
+	if resp.ETag != nil {
+		result.ETag = trimETag(string(*resp.ETag))
+	}
+	if resp.VersionID != nil {
+		result.VersionID = *resp.VersionID
+	}
+	return result, nil
 }
 
 // Download downloads a file from Azure Blob Storage
@@ -64,6 +214,20 @@ func (a *AzureStorage) Download(ctx context.Context, containerName, blobName str
 	return resp.Body, nil
 }
 
+// DownloadRange downloads a byte range of a file from Azure Blob Storage
+func (a *AzureStorage) DownloadRange(ctx context.Context, containerName, blobName string, offset, length int64) (io.ReadCloser, error) {
+	// A count of 0 reads to the end of the blob
+	count := max(length, 0)
+	resp, err := a.client.DownloadStream(ctx, containerName, blobName, &azblob.DownloadStreamOptions{
+		Range: blob.HTTPRange{Offset: offset, Count: count},
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return resp.Body, nil
+}
+
 // Delete deletes a file from Azure Blob Storage
 func (a *AzureStorage) Delete(ctx context.Context, containerName, blobName string) error {
 	// Delete blob
@@ -71,60 +235,70 @@ func (a *AzureStorage) Delete(ctx context.Context, containerName, blobName strin
 	return err
 }
 
+// DeleteIfMatch deletes a blob from Azure Blob Storage with an If-Match access condition
+func (a *AzureStorage) DeleteIfMatch(ctx context.Context, containerName, blobName, etag string) error {
+	_, err := a.client.DeleteBlob(ctx, containerName, blobName, &azblob.DeleteBlobOptions{
+		AccessConditions: ifMatchCondition(etag),
+	})
+	return err
+}
+
+// ifMatchCondition builds an access condition requiring the blob's ETag to equal etag
+func ifMatchCondition(etag string) *blob.AccessConditions {
+	match := azcore.ETagAny
+	if etag != "*" {
+		match = azcore.ETag(`"` + trimETag(etag) + `"`)
+	}
+	return &blob.AccessConditions{
+		ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: &match},
+	}
+}
+
 // List lists objects in an Azure Blob Storage container
 func (a *AzureStorage) List(ctx context.Context, containerName string, prefix string) ([]FileObject, error) {
-	// Create a pager to list blobs
+	return listAll(ctx, a, containerName, prefix)
+}
+
+// Walk calls fn for each blob in an Azure Blob Storage container as pages arrive
+func (a *AzureStorage) Walk(ctx context.Context, containerName, prefix string, fn func(FileObject) error) error {
+	// Create a pager to list blobs, with their metadata when asked for
 	pager := a.client.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{
-		Prefix: &prefix,
+		Prefix:  &prefix,
+		Include: azblob.ListBlobsInclude{Metadata: listMetadataRequested(ctx)},
 	})
-
-	var objects []FileObject
 	
 	// Iterate through the pages
 	for pager.More() {
 		// Get the next page
 		resp, err := pager.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		
 		// Process blobs
 		for _, blob := range resp.Segment.BlobItems {
-			// Extract content type
-			contentType := "application/octet-stream"
-			if blob.Properties.ContentType != nil {
-				contentType = *blob.Properties.ContentType
-			}
-			
-			// Extract last modified time
-			lastModified := time.Now()
-			if blob.Properties.LastModified != nil {
-				lastModified = *blob.Properties.LastModified
+			if err := fn(azureFileObject(blob)); err != nil {
+				return err
 			}
-			
-			// Extract blob size
-			var size int64 = 0
-			if blob.Properties.ContentLength != nil {
-				size = *blob.Properties.ContentLength
-			}
-			
-			objects = append(objects, FileObject{
-				Name:         *blob.Name,
-				Size:         size,
-				ContentType:  contentType,
-				LastModified: lastModified.Format(time.RFC3339),
-				Metadata:     make(map[string]string), // Metadata not directly available in this context
-			})
 		}
 	}
 	
-	return objects, nil
+	return nil
 }
 
 // GetObjectInfo gets metadata of a blob from Azure Blob Storage
 func (a *AzureStorage) GetObjectInfo(ctx context.Context, containerName, blobName string) (*FileObject, error) {
+	return a.blobInfo(ctx, a.blobClient(containerName, blobName), blobName)
+}
+
+// blobClient returns the client for a single blob in a container
+func (a *AzureStorage) blobClient(containerName, blobName string) *blob.Client {
+	return a.client.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName)
+}
+
+// blobInfo gets metadata of the blob, or blob version, addressed by blobClient
+func (a *AzureStorage) blobInfo(ctx context.Context, blobClient *blob.Client, blobName string) (*FileObject, error) {
 	// Get blob properties
-	blobClient := a.client.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName)
 	resp, err := blobClient.GetProperties(ctx, nil)
 	if err != nil {
 		return nil, err
@@ -136,8 +310,8 @@ func (a *AzureStorage) GetObjectInfo(ctx context.Context, containerName, blobNam
 		contentType = *resp.ContentType
 	}
 	
-	// Extract last modified time
-	lastModified := time.Now()
+	// Extract last modified time, left zero when the service doesn't send it
+	var lastModified time.Time
 	if resp.LastModified != nil {
 		lastModified = *resp.LastModified
 	}
@@ -148,25 +322,148 @@ func (a *AzureStorage) GetObjectInfo(ctx context.Context, containerName, blobNam
 		size = *resp.ContentLength
 	}
 	
+	// Extract ETag
+	etag := ""
+	if resp.ETag != nil {
+		etag = trimETag(string(*resp.ETag))
+	}
+	
+	versionID := ""
+	if resp.VersionID != nil {
+		versionID = *resp.VersionID
+	}
+	
+	metadata := make(map[string]string, len(resp.Metadata))
+	for k, v := range resp.Metadata {
+		if v != nil {
+			metadata[k] = *v
+		}
+	}
+	
+	checksums := make(map[string]string)
+	if len(resp.ContentMD5) == md5.Size {
+		checksums[ChecksumMD5] = hex.EncodeToString(resp.ContentMD5)
+	}
+	
+	var headers ObjectHeaders
+	if resp.CacheControl != nil {
+		headers.CacheControl = *resp.CacheControl
+	}
+	if resp.ContentEncoding != nil {
+		headers.ContentEncoding = *resp.ContentEncoding
+	}
+	if resp.ContentLanguage != nil {
+		headers.ContentLanguage = *resp.ContentLanguage
+	}
+	
+	// A rehydrating blob stays in the Archive tier until it is moved to Hot
+	storageClass := ""
+	if resp.AccessTier != nil {
+		storageClass = *resp.AccessTier
+	}
+	restoreStatus := ""
+	if resp.ArchiveStatus != nil && strings.HasPrefix(*resp.ArchiveStatus, "rehydrate-pending") {
+		restoreStatus = RestoreOngoing
+	}
+	
 	return &FileObject{
 		Name:         blobName,
 		Size:         size,
 		ContentType:  contentType,
-		LastModified: lastModified.Format(time.RFC3339),
-		Metadata:     make(map[string]string), // Metadata not directly available in this context
+		LastModified: lastModified,
+		ETag:         etag,
+		VersionID:    versionID,
+		Metadata:     metadata,
+		Headers:      headers,
+		Checksums:    checksums,
+		StorageClass: storageClass,
+		RestoreStatus: restoreStatus,
 	}, nil
 }
 
+// UpdateMetadata replaces blob metadata and content type in Azure Blob Storage
+func (a *AzureStorage) UpdateMetadata(ctx context.Context, containerName, blobName string, metadata map[string]string, contentType string) error {
+	blobClient := a.client.ServiceClient().NewContainerClient(containerName).NewBlobClient(blobName)
+	
+	// SetHTTPHeaders replaces every header, so carry over the ones we don't change
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		return err
+	}
+	
+	azureMetadata := make(map[string]*string, len(metadata))
+	for k, v := range metadata {
+		value := v
+		azureMetadata[k] = &value
+	}
+	if _, err := blobClient.SetMetadata(ctx, azureMetadata, nil); err != nil {
+		return err
+	}
+	
+	if contentType == "" {
+		return nil
+	}
+	_, err = blobClient.SetHTTPHeaders(ctx, blob.HTTPHeaders{
+		BlobContentType:        &contentType,
+		BlobCacheControl:       props.CacheControl,
+		BlobContentDisposition: props.ContentDisposition,
+		BlobContentEncoding:    props.ContentEncoding,
+		BlobContentLanguage:    props.ContentLanguage,
+		BlobContentMD5:         props.ContentMD5,
+	}, nil)
+	return err
+}
+
+// azureCopyPollInterval is how often a pending server-side copy is checked
+const azureCopyPollInterval = 500 * time.Millisecond
+
+// Copy copies a blob within an Azure Blob Storage container on the server and
+// waits for the copy to finish. Azure copies the source metadata when none is
+// given, so an empty non-nil metadata can't clear it.
+func (a *AzureStorage) Copy(ctx context.Context, containerName, srcBlob, dstBlob string, metadata map[string]string) error {
+	options := &blob.StartCopyFromURLOptions{}
+	if metadata != nil {
+		options.Metadata = make(map[string]*string, len(metadata))
+		for k, v := range metadata {
+			value := v
+			options.Metadata[k] = &value
+		}
+	}
+	
+	dstClient := a.blobClient(containerName, dstBlob)
+	resp, err := dstClient.StartCopyFromURL(ctx, a.blobClient(containerName, srcBlob).URL(), options)
+	if err != nil {
+		return err
+	}
+	
+	// Copies within an account usually finish at once, but may be left pending
+	status := resp.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(azureCopyPollInterval):
+		}
+		props, err := dstClient.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		status = props.CopyStatus
+	}
+	if status != nil && *status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("copy of %s/%s to %s ended with status %s", containerName, srcBlob, dstBlob, *status)
+	}
+	return nil
+}
+
 // ListDirectories lists directories in a bucket with the given prefix
 func (a *AzureStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
-	// In Azure Blob Storage, directories are simulated using prefixes
-	// We'll list blobs and extract directory-like prefixes
-	
-	pager := a.client.NewListBlobsFlatPager(bucket, &azblob.ListBlobsFlatOptions{
+	// In Azure Blob Storage, directories are simulated using prefixes; a
+	// hierarchical listing returns the immediate ones without listing every blob
+	pager := a.client.ServiceClient().NewContainerClient(bucket).NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
 		Prefix: &prefix,
 	})
 	
-	dirMap := make(map[string]bool) // To avoid duplicates
 	var dirs []FileObject
 	
 	for pager.More() {
@@ -175,34 +472,113 @@ func (a *AzureStorage) ListDirectories(ctx context.Context, bucket, prefix strin
 			return nil, err
 		}
 		
-		for _, blob := range resp.Segment.BlobItems {
-			// Extract directory path from blob name
-			if blob.Name != nil {
-				parts := strings.Split(*blob.Name, "/")
-				if len(parts) > 1 {
-					// Add all parent directories
-					for i := 1; i < len(parts); i++ {
-						dirPath := strings.Join(parts[:i], "/") + "/"
-						if !dirMap[dirPath] {
-							dirMap[dirPath] = true
-							dirs = append(dirs, FileObject{
-								Name:        dirPath,
-								Size:        0,
-								ContentType: "application/directory",
-								IsDir:       true,
-							})
-						}
-					}
-				}
+		for _, blobPrefix := range resp.Segment.BlobPrefixes {
+			if blobPrefix.Name == nil {
+				continue
 			}
+			dirs = append(dirs, FileObject{
+				Name:        *blobPrefix.Name,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
 		}
 	}
 	
+	if err := fillDirectoryMetadata(ctx, a, bucket, dirs); err != nil {
+		return nil, err
+	}
 	return dirs, nil
 }
 
+// ListObjects lists the directories and files directly under prefix in Azure
+func (a *AzureStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	pager := a.client.ServiceClient().NewContainerClient(bucket).NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+		Prefix: &prefix,
+	})
+	
+	var dirs, files []FileObject
+	
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, blobPrefix := range resp.Segment.BlobPrefixes {
+			if blobPrefix.Name == nil {
+				continue
+			}
+			dirs = append(dirs, FileObject{
+				Name:        *blobPrefix.Name,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		}
+		for _, blob := range resp.Segment.BlobItems {
+			if blob.Name == nil || *blob.Name == prefix {
+				continue
+			}
+			files = append(files, azureFileObject(blob))
+		}
+	}
+	
+	return append(dirs, files...), nil
+}
+
+// azureFileObject converts a blob from a listing to a FileObject
+func azureFileObject(blob *container.BlobItem) FileObject {
+	// Extract content type
+	contentType := "application/octet-stream"
+	if blob.Properties.ContentType != nil {
+		contentType = *blob.Properties.ContentType
+	}
+	
+	// Extract last modified time, left zero when the service doesn't send it
+	var lastModified time.Time
+	if blob.Properties.LastModified != nil {
+		lastModified = *blob.Properties.LastModified
+	}
+	
+	// Extract blob size
+	var size int64 = 0
+	if blob.Properties.ContentLength != nil {
+		size = *blob.Properties.ContentLength
+	}
+	
+	// Extract ETag
+	etag := ""
+	if blob.Properties.ETag != nil {
+		etag = trimETag(string(*blob.Properties.ETag))
+	}
+	
+	// Metadata is only listed when the listing included it
+	metadata := make(map[string]string, len(blob.Metadata))
+	for k, v := range blob.Metadata {
+		if v != nil {
+			metadata[k] = *v
+		}
+	}
+	
+	storageClass := ""
+	if blob.Properties.AccessTier != nil {
+		storageClass = string(*blob.Properties.AccessTier)
+	}
+	
+	return FileObject{
+		Name:         *blob.Name,
+		Size:         size,
+		ContentType:  contentType,
+		LastModified: lastModified,
+		ETag:         etag,
+		Metadata:     metadata,
+		StorageClass: storageClass,
+	}
+}
+
 // CreateDirectory creates a directory in the storage
-func (a *AzureStorage) CreateDirectory(ctx context.Context, bucket, objectName string) error {
+func (a *AzureStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
 	// Ensure the object name ends with "/"
 	if !strings.HasSuffix(objectName, "/") {
 		objectName += "/"
@@ -210,10 +586,16 @@ func (a *AzureStorage) CreateDirectory(ctx context.Context, bucket, objectName s
 	
 	// Create an empty blob to represent the directory
 	contentType := "application/directory"
+	azureMetadata := make(map[string]*string, len(metadata))
+	for k, v := range metadata {
+		value := v
+		azureMetadata[k] = &value
+	}
 	_, err := a.client.UploadBuffer(ctx, bucket, objectName, []byte{}, &azblob.UploadBufferOptions{
 		HTTPHeaders: &blob.HTTPHeaders{
 			BlobContentType: &contentType,
 		},
+		Metadata: azureMetadata,
 	})
 	return err
 }
@@ -243,9 +625,393 @@ func (a *AzureStorage) EnsurePathExists(ctx context.Context, bucket, objectPath
 	// If the error indicates the blob doesn't exist, create the directory
 	var respErr *azcore.ResponseError
 	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
-		return a.CreateDirectory(ctx, bucket, dir)
+		return a.CreateDirectory(ctx, bucket, dir, nil)
 	}
 	
 	// For other errors, return the error
 	return err
-}
\ No newline at end of file
+}
+
+// HealthCheck verifies that the Azure Blob service is reachable and the
+// container exists, reading its properties rather than listing containers,
+// which a SAS or role scoped to the container can't do
+func (a *AzureStorage) HealthCheck(ctx context.Context, containerName string) error {
+	exists, err := a.BucketExists(ctx, containerName)
+	if err == nil && !exists {
+		err = fmt.Errorf("container %s: %w", containerName, ErrBucketNotFound)
+	}
+	return err
+}
+
+// Capabilities reports that Azure Blob Storage supports versioning, multipart
+// uploads, server-side copies, container-level public access and listing
+// metadata, and presigned URLs when the client has the account key
+func (a *AzureStorage) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Multipart: true, ServerSideCopy: true, ACLScope: ACLScopeBucket, AtomicCreate: true, ListMetadata: true, Restore: true, PresignedURLs: a.sharedKey, Naming: azureNaming}
+}
+
+// SetACL changes the public access level of the container holding a blob,
+// since Azure has no per-blob access control: ACLPublicRead lets anyone read
+// every blob in it, and ACLPrivate makes all of them private again. The
+// container's stored access policies are kept.
+func (a *AzureStorage) SetACL(ctx context.Context, containerName, blobName, acl string) error {
+	var access *container.PublicAccessType
+	switch acl {
+	case ACLPublicRead:
+		blobAccess := container.PublicAccessTypeBlob
+		access = &blobAccess
+	case ACLPrivate:
+	default:
+		return fmt.Errorf("unsupported ACL %q", acl)
+	}
+	if _, err := a.blobClient(containerName, blobName).GetProperties(ctx, nil); err != nil {
+		return err
+	}
+	
+	containerClient := a.client.ServiceClient().NewContainerClient(containerName)
+	policy, err := containerClient.GetAccessPolicy(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = containerClient.SetAccessPolicy(ctx, &container.SetAccessPolicyOptions{
+		Access:       access,
+		ContainerACL: policy.SignedIdentifiers,
+	})
+	return err
+}
+
+// RestoreObject rehydrates an archived blob by moving it to the Hot tier,
+// where it stays; days doesn't apply to Azure
+func (a *AzureStorage) RestoreObject(ctx context.Context, containerName, blobName string, days int) error {
+	_, err := a.blobClient(containerName, blobName).SetTier(ctx, blob.AccessTierHot, nil)
+	return err
+}
+
+// PresignDownload returns the URL of a blob with a read-only SAS. Signing
+// takes the account key, so it fails with ErrNotSupported for clients
+// created from a connection string without one.
+func (a *AzureStorage) PresignDownload(ctx context.Context, containerName, blobName string, expiry time.Duration) (string, error) {
+	if !a.sharedKey {
+		return "", ErrNotSupported
+	}
+	return a.blobClient(containerName, blobName).GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(expiry), nil)
+}
+
+// PublicURL returns the unauthenticated URL of a blob in Azure Blob Storage
+func (a *AzureStorage) PublicURL(containerName, blobName string) string {
+	return a.blobClient(containerName, blobName).URL()
+}
+
+// ListBuckets lists all containers in Azure Blob Storage. Azure doesn't report
+// a creation date, so the container's last modification time is used instead.
+func (a *AzureStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	result := make([]BucketInfo, 0)
+	pager := a.client.NewListContainersPager(nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, container := range resp.ContainerItems {
+			info := BucketInfo{}
+			if container.Name != nil {
+				info.Name = *container.Name
+			}
+			if container.Properties != nil && container.Properties.LastModified != nil {
+				info.CreationDate = *container.Properties.LastModified
+			}
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+// BucketExists reports whether the container exists in Azure Blob Storage
+func (a *AzureStorage) BucketExists(ctx context.Context, containerName string) (bool, error) {
+	_, err := a.client.ServiceClient().NewContainerClient(containerName).GetProperties(ctx, nil)
+	if err == nil {
+		return true, nil
+	}
+	
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// CreateBucket creates a new container in Azure Blob Storage
+func (a *AzureStorage) CreateBucket(ctx context.Context, containerName string) error {
+	_, err := a.client.CreateContainer(ctx, containerName, nil)
+	return err
+}
+
+// DeleteBucket deletes a container from Azure Blob Storage
+func (a *AzureStorage) DeleteBucket(ctx context.Context, containerName string) error {
+	_, err := a.client.DeleteContainer(ctx, containerName, nil)
+	return err
+}
+
+// Azure has no multipart upload IDs, so parts are staged as uncommitted blocks
+// whose IDs carry a random upload token and the part number. The upload ID is
+// the token followed by the encoded content type and headers, which are only
+// needed when the block list is committed.
+
+// azureBlockToken is the length of the random token identifying an upload's blocks
+const azureBlockToken = 16
+
+// InitMultipart returns a new upload ID; nothing is sent to Azure until the first part
+func (a *AzureStorage) InitMultipart(ctx context.Context, containerName, blobName, contentType string, headers ObjectHeaders) (string, error) {
+	token := make([]byte, azureBlockToken/2)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	
+	// Header values can't contain newlines, so they follow the content type
+	// one per line; IDs without headers keep the content type alone
+	encoded := contentType
+	if headers != (ObjectHeaders{}) {
+		encoded = strings.Join([]string{contentType, headers.CacheControl, headers.ContentEncoding, headers.ContentLanguage}, "\n")
+	}
+	return hex.EncodeToString(token) + "." + base64.RawURLEncoding.EncodeToString([]byte(encoded)), nil
+}
+
+// UploadPart stages one part as an uncommitted block. The block body must be
+// seekable for retries, so the part is spooled to a temporary file first.
+func (a *AzureStorage) UploadPart(ctx context.Context, containerName, blobName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	token, _, _, err := parseAzureUploadID(uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+	
+	spool, err := os.CreateTemp("", "file-service-part-*")
+	if err != nil {
+		return Part{}, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	
+	written, err := io.Copy(spool, reader)
+	if err != nil {
+		return Part{}, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return Part{}, err
+	}
+	
+	blockClient := a.client.ServiceClient().NewContainerClient(containerName).NewBlockBlobClient(blobName)
+	if _, err := blockClient.StageBlock(ctx, azureBlockID(token, partNumber), spool, nil); err != nil {
+		return Part{}, err
+	}
+	return Part{Number: partNumber, Size: written}, nil
+}
+
+// ListParts lists the blocks staged for an upload, ordered by part number
+func (a *AzureStorage) ListParts(ctx context.Context, containerName, blobName, uploadID string) ([]Part, error) {
+	parts, _, err := a.stagedParts(ctx, containerName, blobName, uploadID)
+	return parts, err
+}
+
+// CompleteMultipart commits the upload's blocks in part number order. Azure
+// discards every other uncommitted block of the blob at the same time.
+func (a *AzureStorage) CompleteMultipart(ctx context.Context, containerName, blobName, uploadID string) error {
+	_, contentType, headers, err := parseAzureUploadID(uploadID)
+	if err != nil {
+		return err
+	}
+	
+	_, blockIDs, err := a.stagedParts(ctx, containerName, blobName, uploadID)
+	if err != nil {
+		return err
+	}
+	
+	options := &blockblob.CommitBlockListOptions{
+		HTTPHeaders: azureHTTPHeaders(contentType, headers),
+	}
+	blockClient := a.client.ServiceClient().NewContainerClient(containerName).NewBlockBlobClient(blobName)
+	_, err = blockClient.CommitBlockList(ctx, blockIDs, options)
+	return err
+}
+
+// AbortMultipart only validates the upload ID: Azure can't delete uncommitted
+// blocks, which are garbage collected after seven days instead
+func (a *AzureStorage) AbortMultipart(ctx context.Context, containerName, blobName, uploadID string) error {
+	_, _, _, err := parseAzureUploadID(uploadID)
+	return err
+}
+
+// ListMultipartUploads is not supported because staged blocks aren't tracked as uploads
+func (a *AzureStorage) ListMultipartUploads(ctx context.Context, containerName, prefix string) ([]MultipartUpload, error) {
+	return nil, ErrNotSupported
+}
+
+// stagedParts returns the parts staged for an upload along with their block
+// IDs, both ordered by part number
+func (a *AzureStorage) stagedParts(ctx context.Context, containerName, blobName, uploadID string) ([]Part, []string, error) {
+	token, _, _, err := parseAzureUploadID(uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	blockClient := a.client.ServiceClient().NewContainerClient(containerName).NewBlockBlobClient(blobName)
+	resp, err := blockClient.GetBlockList(ctx, blockblob.BlockListTypeUncommitted, nil)
+	if err != nil {
+		// Nothing has been staged for the blob yet
+		if IsNotFound(err) {
+			return []Part{}, nil, nil
+		}
+		return nil, nil, err
+	}
+	
+	parts := make([]Part, 0)
+	blockIDs := make(map[int]string)
+	for _, block := range resp.UncommittedBlocks {
+		if block.Name == nil {
+			continue
+		}
+		number, ok := azureBlockPart(token, *block.Name)
+		if !ok {
+			continue
+		}
+		part := Part{Number: number}
+		if block.Size != nil {
+			part.Size = *block.Size
+		}
+		parts = append(parts, part)
+		blockIDs[number] = *block.Name
+	}
+	
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	ids := make([]string, 0, len(parts))
+	for _, part := range parts {
+		ids = append(ids, blockIDs[part.Number])
+	}
+	return parts, ids, nil
+}
+
+// parseAzureUploadID splits an upload ID into its block token, content type and headers
+func parseAzureUploadID(uploadID string) (string, string, ObjectHeaders, error) {
+	token, encoded, ok := strings.Cut(uploadID, ".")
+	if !ok || len(token) != azureBlockToken {
+		return "", "", ObjectHeaders{}, fmt.Errorf("upload %s: %w", uploadID, ErrUploadNotFound)
+	}
+	if _, err := hex.DecodeString(token); err != nil {
+		return "", "", ObjectHeaders{}, fmt.Errorf("upload %s: %w", uploadID, ErrUploadNotFound)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", ObjectHeaders{}, fmt.Errorf("upload %s: %w", uploadID, ErrUploadNotFound)
+	}
+	
+	fields := strings.Split(string(decoded), "\n")
+	var headers ObjectHeaders
+	if len(fields) == 4 {
+		headers = ObjectHeaders{CacheControl: fields[1], ContentEncoding: fields[2], ContentLanguage: fields[3]}
+	}
+	return token, fields[0], headers, nil
+}
+
+// azureBlockID builds the block ID of a part; all IDs of a blob must have the same length
+func azureBlockID(token string, partNumber int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s%05d", token, partNumber)))
+}
+
+// azureBlockPart returns the part number of a block staged for the upload with the given token
+func azureBlockPart(token, blockID string) (int, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(blockID)
+	if err != nil || !strings.HasPrefix(string(decoded), token) {
+		return 0, false
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(string(decoded), token))
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// DownloadVersion downloads a specific version of a blob from Azure Blob Storage
+func (a *AzureStorage) DownloadVersion(ctx context.Context, containerName, blobName, versionID string) (io.ReadCloser, error) {
+	blobClient, err := a.blobClient(containerName, blobName).WithVersionID(versionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	resp, err := blobClient.DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	
+	return resp.Body, nil
+}
+
+// GetObjectVersionInfo gets metadata of a specific version of a blob from Azure Blob Storage
+func (a *AzureStorage) GetObjectVersionInfo(ctx context.Context, containerName, blobName, versionID string) (*FileObject, error) {
+	blobClient, err := a.blobClient(containerName, blobName).WithVersionID(versionID)
+	if err != nil {
+		return nil, err
+	}
+	
+	return a.blobInfo(ctx, blobClient, blobName)
+}
+
+// DeleteVersion permanently deletes a specific version of a blob from Azure Blob Storage
+func (a *AzureStorage) DeleteVersion(ctx context.Context, containerName, blobName, versionID string) error {
+	blobClient, err := a.blobClient(containerName, blobName).WithVersionID(versionID)
+	if err != nil {
+		return err
+	}
+	
+	_, err = blobClient.Delete(ctx, nil)
+	return err
+}
+
+// ListVersions lists all versions of blobs in an Azure Blob Storage container.
+// Azure has no delete markers; a deleted blob simply has no current version.
+func (a *AzureStorage) ListVersions(ctx context.Context, containerName, prefix string) ([]ObjectVersion, error) {
+	pager := a.client.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{
+		Prefix:  &prefix,
+		Include: azblob.ListBlobsInclude{Versions: true},
+	})
+	
+	versions := make([]ObjectVersion, 0)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, item := range resp.Segment.BlobItems {
+			version := ObjectVersion{
+				FileObject: FileObject{
+					Name:        *item.Name,
+					ContentType: "application/octet-stream",
+					Metadata:    make(map[string]string),
+				},
+			}
+			if item.VersionID != nil {
+				version.VersionID = *item.VersionID
+			}
+			if item.IsCurrentVersion != nil {
+				version.IsLatest = *item.IsCurrentVersion
+			}
+			if props := item.Properties; props != nil {
+				if props.ContentType != nil {
+					version.ContentType = *props.ContentType
+				}
+				if props.LastModified != nil {
+					version.LastModified = *props.LastModified
+				}
+				if props.ContentLength != nil {
+					version.Size = *props.ContentLength
+				}
+				if props.ETag != nil {
+					version.ETag = trimETag(string(*props.ETag))
+				}
+			}
+			versions = append(versions, version)
+		}
+	}
+	
+	return versions, nil
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Digest algorithms of FileObject.Checksums
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA1   = "sha1"
+	ChecksumSHA256 = "sha256"
+)
+
+// base64Checksum converts a base64-encoded digest of size bytes to hex. It
+// returns "" for anything else, such as the checksum of part checksums S3
+// reports for multipart uploads.
+func base64Checksum(value string, size int) string {
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != size {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys of the objects deduplicated storage keeps for itself
+const (
+	// dedupPrefix holds the blobs and the index; listings leave it out
+	dedupPrefix = ".dedup/"
+
+	dedupBlobPrefix  = dedupPrefix + "blobs/"
+	dedupIndexPrefix = dedupPrefix + "index/"
+)
+
+// User metadata entries marking an object as a reference to a blob. They are
+// hidden from returned metadata and kept when the metadata is replaced.
+const (
+	dedupBlobMetadata   = "dedup-blob"
+	dedupSizeMetadata   = "dedup-size"
+	dedupSHA256Metadata = "dedup-sha256"
+)
+
+// dedupStorage stores the content of uploads once per SHA-256 digest. Each
+// blob is written under .dedup/blobs/ with a random name while its digest is
+// computed, and .dedup/index/<digest> holds the name of the first blob stored
+// with that digest. The object itself is a small reference holding the blob
+// name and carrying it in its metadata, which reads follow.
+type dedupStorage struct {
+	Storage
+}
+
+// WithDedup returns a view of s that deduplicates uploads by content. Upload
+// streams the content to a new blob while hashing it, keeps the blob if no
+// other has the same digest and drops it in favor of the existing one
+// otherwise, then stores a reference at the object key. Download,
+// DownloadRange, GetObjectInfo, DownloadVersion, GetObjectVersionInfo and
+// PresignDownload follow references, and listings, version listings included,
+// report their content size when the backend lists metadata.
+//
+// Conditional uploads, multipart uploads and the other operations of the
+// optional interfaces are passed through to s and store content as-is. Blobs are never
+// deleted: removing the last reference to one leaves it in place. The result
+// keeps implementing the optional interfaces of s.
+func WithDedup(s Storage) Storage {
+	return withOptional(&dedupStorage{Storage: s}, s)
+}
+
+// dedupReference returns the blob an object refers to and its size, or ok
+// false when obj isn't a reference
+func dedupReference(obj *FileObject) (blob string, size int64, ok bool) {
+	blob = markerValue(obj.Metadata, dedupBlobMetadata)
+	if blob == "" {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(markerValue(obj.Metadata, dedupSizeMetadata), 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return blob, size, true
+}
+
+// resolve makes a reference look like the object it stands for: its size and
+// SHA-256 checksum are those of the content and the marker metadata is hidden.
+// Its ETag is kept, which identifies the blob and so is the same for every
+// reference to equal content.
+func resolve(obj *FileObject) {
+	if _, size, ok := dedupReference(obj); ok {
+		obj.Size = size
+		obj.Checksums = nil
+		if digest := markerValue(obj.Metadata, dedupSHA256Metadata); digest != "" {
+			obj.Checksums = map[string]string{ChecksumSHA256: digest}
+		}
+		obj.Metadata = withoutMarkers(obj.Metadata)
+	}
+}
+
+// dedupMarkers are the metadata entries that make up a reference marker
+var dedupMarkers = []string{dedupBlobMetadata, dedupSizeMetadata, dedupSHA256Metadata}
+
+// markerValue looks up a marker entry ignoring case, since some backends
+// return metadata names canonicalized like HTTP headers
+func markerValue(metadata map[string]string, name string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// withoutMarkers returns metadata without the reference marker entries
+func withoutMarkers(metadata map[string]string) map[string]string {
+	kept := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if !slices.ContainsFunc(dedupMarkers, func(name string) bool { return strings.EqualFold(k, name) }) {
+			kept[k] = v
+		}
+	}
+	return kept
+}
+
+// randomKey returns a new random object name under prefix
+func randomKey(prefix string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(id), nil
+}
+
+func (d *dedupStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	blob, err := randomKey(dedupBlobPrefix)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	stored, err := d.Storage.Upload(ctx, bucket, blob, io.TeeReader(reader, hash), size, contentType, headers)
+	if err != nil {
+		return nil, err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	// The first blob indexed under a digest wins, so concurrent uploads of
+	// the same content settle on one blob
+	kept, err := d.indexBlob(ctx, bucket, digest, blob)
+	if err != nil {
+		d.Storage.Delete(context.WithoutCancel(ctx), bucket, blob)
+		return nil, err
+	}
+	if kept != blob {
+		if err := d.Storage.Delete(ctx, bucket, blob); err != nil {
+			return nil, err
+		}
+	}
+
+	// Write the reference under a temporary name first, so it only appears
+	// at the object key together with its marker metadata
+	staged, err := randomKey(dedupPrefix + "staging/")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Storage.Upload(ctx, bucket, staged, strings.NewReader(kept), int64(len(kept)), contentType, headers); err != nil {
+		return nil, err
+	}
+	defer d.Storage.Delete(context.WithoutCancel(ctx), bucket, staged)
+	marker := map[string]string{
+		dedupBlobMetadata:   kept,
+		dedupSizeMetadata:   strconv.FormatInt(stored.Size, 10),
+		dedupSHA256Metadata: digest,
+	}
+	if err := d.Storage.Copy(ctx, bucket, staged, objectName, marker); err != nil {
+		return nil, err
+	}
+
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadResult{ETag: info.ETag, VersionID: info.VersionID, Size: stored.Size}, nil
+}
+
+// indexBlob records blob as the blob holding content with the given digest
+// unless another one already is, and returns the blob to refer to. An index
+// entry whose blob is gone is replaced.
+func (d *dedupStorage) indexBlob(ctx context.Context, bucket, digest, blob string) (string, error) {
+	index := dedupIndexPrefix + digest
+	for range 2 {
+		_, err := d.Storage.UploadIfNotExists(ctx, bucket, index, strings.NewReader(blob), int64(len(blob)), "text/plain", ObjectHeaders{})
+		if err == nil {
+			return blob, nil
+		}
+		if !IsObjectExists(err) {
+			return "", err
+		}
+
+		existing, err := d.readIndex(ctx, bucket, index)
+		if err != nil {
+			return "", err
+		}
+		if _, err := d.Storage.GetObjectInfo(ctx, bucket, existing); err == nil {
+			return existing, nil
+		} else if !IsNotFound(err) {
+			return "", err
+		}
+		if err := d.Storage.Delete(ctx, bucket, index); err != nil && !IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("dedup index for %s keeps changing", digest)
+}
+
+// readIndex returns the blob name stored in an index entry
+func (d *dedupStorage) readIndex(ctx context.Context, bucket, index string) (string, error) {
+	reader, err := d.Storage.Download(ctx, bucket, index)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (d *dedupStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if blob, _, ok := dedupReference(info); ok {
+		return d.Storage.Download(ctx, bucket, blob)
+	}
+	return d.Storage.Download(ctx, bucket, objectName)
+}
+
+func (d *dedupStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if blob, _, ok := dedupReference(info); ok {
+		return d.Storage.DownloadRange(ctx, bucket, blob, offset, length)
+	}
+	return d.Storage.DownloadRange(ctx, bucket, objectName, offset, length)
+}
+
+// PresignDownload signs the URL of the blob a reference refers to, since the
+// provider serves the reference itself as stored. It is only used when the
+// backend implements PresignStorage, see withOptional.
+func (d *dedupStorage) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return "", err
+	}
+	if blob, _, ok := dedupReference(info); ok {
+		objectName = blob
+	}
+	return d.Storage.(PresignStorage).PresignDownload(ctx, bucket, objectName, expiry)
+}
+
+// DownloadVersion follows a reference stored as the given version. Like the
+// other VersionedStorage methods it is only used when the backend implements
+// VersionedStorage, see withOptional.
+func (d *dedupStorage) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	versioned := d.Storage.(VersionedStorage)
+	info, err := versioned.GetObjectVersionInfo(ctx, bucket, objectName, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if blob, _, ok := dedupReference(info); ok {
+		return d.Storage.Download(ctx, bucket, blob)
+	}
+	return versioned.DownloadVersion(ctx, bucket, objectName, versionID)
+}
+
+func (d *dedupStorage) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	info, err := d.Storage.(VersionedStorage).GetObjectVersionInfo(ctx, bucket, objectName, versionID)
+	if info != nil {
+		resolve(info)
+	}
+	return info, err
+}
+
+func (d *dedupStorage) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return d.Storage.(VersionedStorage).DeleteVersion(ctx, bucket, objectName, versionID)
+}
+
+// ListVersions resolves listed references and drops the versions of the
+// internal objects
+func (d *dedupStorage) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	versions, err := d.Storage.(VersionedStorage).ListVersions(ctx, bucket, prefix)
+	kept := versions[:0]
+	for _, version := range versions {
+		if !isDedupInternal(version.FileObject) {
+			resolve(&version.FileObject)
+			kept = append(kept, version)
+		}
+	}
+	return kept, err
+}
+
+func (d *dedupStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if info != nil {
+		resolve(info)
+	}
+	return info, err
+}
+
+// UpdateMetadata keeps the marker of a reference, which would otherwise be
+// replaced along with the rest of the metadata
+func (d *dedupStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	info, err := d.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return err
+	}
+	return d.Storage.UpdateMetadata(ctx, bucket, objectName, withMarkers(info, metadata), contentType)
+}
+
+// Copy keeps the marker of a copied reference when the copy is given new metadata
+func (d *dedupStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	if metadata != nil {
+		info, err := d.Storage.GetObjectInfo(ctx, bucket, srcObject)
+		if err != nil {
+			return err
+		}
+		metadata = withMarkers(info, metadata)
+	}
+	return d.Storage.Copy(ctx, bucket, srcObject, dstObject, metadata)
+}
+
+// withMarkers returns metadata with the marker entries of obj added when obj
+// is a reference
+func withMarkers(obj *FileObject, metadata map[string]string) map[string]string {
+	if _, _, ok := dedupReference(obj); !ok {
+		return metadata
+	}
+	merged := maps.Clone(metadata)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	for _, name := range dedupMarkers {
+		merged[name] = markerValue(obj.Metadata, name)
+	}
+	return merged
+}
+
+// isDedupInternal reports whether obj is one of the blobs or index entries
+func isDedupInternal(obj FileObject) bool {
+	return strings.HasPrefix(obj.Name, dedupPrefix)
+}
+
+// resolveAll resolves listed references and drops the internal objects
+func resolveAll(objects []FileObject) []FileObject {
+	kept := objects[:0]
+	for _, obj := range objects {
+		if !isDedupInternal(obj) {
+			resolve(&obj)
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+func (d *dedupStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	objects, err := d.Storage.List(ctx, bucket, prefix)
+	return resolveAll(objects), err
+}
+
+func (d *dedupStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	objects, err := d.Storage.ListObjects(ctx, bucket, prefix)
+	return resolveAll(objects), err
+}
+
+func (d *dedupStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	dirs, err := d.Storage.ListDirectories(ctx, bucket, prefix)
+	return resolveAll(dirs), err
+}
+
+func (d *dedupStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	return d.Storage.Walk(ctx, bucket, prefix, func(obj FileObject) error {
+		if isDedupInternal(obj) {
+			return nil
+		}
+		resolve(&obj)
+		return fn(obj)
+	})
+}
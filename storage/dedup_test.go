@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// singleVersionStorage makes the in-memory backend look versioned, with the
+// current object as its only version "v1"
+type singleVersionStorage struct {
+	*MemoryStorage
+}
+
+func (s *singleVersionStorage) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	return s.Download(ctx, bucket, objectName)
+}
+
+func (s *singleVersionStorage) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	return s.GetObjectInfo(ctx, bucket, objectName)
+}
+
+func (s *singleVersionStorage) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return s.Delete(ctx, bucket, objectName)
+}
+
+func (s *singleVersionStorage) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	objects, err := s.ListObjects(ctx, bucket, prefix)
+	versions := make([]ObjectVersion, len(objects))
+	for i, obj := range objects {
+		obj.VersionID = "v1"
+		versions[i] = ObjectVersion{FileObject: obj, IsLatest: true}
+	}
+	return versions, err
+}
+
+// newDedupStore returns a deduplicating view of a versioned in-memory backend
+// with the bucket "default", and the backend itself
+func newDedupStore(t *testing.T) (Storage, *MemoryStorage) {
+	t.Helper()
+	mem := NewMemoryStorage()
+	if err := mem.CreateBucket(context.Background(), "default"); err != nil {
+		t.Fatal(err)
+	}
+	return WithDedup(&singleVersionStorage{MemoryStorage: mem}), mem
+}
+
+// uploadString stores content at key in the bucket "default" of store
+func uploadString(t *testing.T, store Storage, key, content string) {
+	t.Helper()
+	if _, err := store.Upload(context.Background(), "default", key, strings.NewReader(content), int64(len(content)), "text/plain", ObjectHeaders{}); err != nil {
+		t.Fatalf("Upload %s: %v", key, err)
+	}
+}
+
+// readAll returns the content a download returned, failing the test on err
+func readAll(t *testing.T, reader io.ReadCloser, err error) string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDedupStoresIdenticalUploadsOnce(t *testing.T) {
+	ctx := context.Background()
+	store, mem := newDedupStore(t)
+	uploadString(t, store, "a.txt", "same content")
+	uploadString(t, store, "b/c.txt", "same content")
+	uploadString(t, store, "d.txt", "other content")
+
+	blobs, err := mem.ListObjects(ctx, "default", dedupBlobPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 {
+		t.Errorf("stored %d blobs for two distinct contents, want 2", len(blobs))
+	}
+
+	for key, want := range map[string]string{"a.txt": "same content", "b/c.txt": "same content", "d.txt": "other content"} {
+		reader, err := store.Download(ctx, "default", key)
+		if got := readAll(t, reader, err); got != want {
+			t.Errorf("Download %s = %q, want %q", key, got, want)
+		}
+	}
+
+	objects, err := store.ListObjects(ctx, "default", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects {
+		if isDedupInternal(obj) {
+			t.Errorf("listing includes %s", obj.Name)
+		}
+	}
+}
+
+func TestDedupFollowsReferencesOfVersions(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newDedupStore(t)
+	uploadString(t, store, "report.txt", "quarterly numbers")
+
+	versioned, ok := store.(VersionedStorage)
+	if !ok {
+		t.Fatal("dedup view of a versioned backend doesn't implement VersionedStorage")
+	}
+
+	reader, err := versioned.DownloadVersion(ctx, "default", "report.txt", "v1")
+	if got := readAll(t, reader, err); got != "quarterly numbers" {
+		t.Errorf("DownloadVersion = %q, want the content", got)
+	}
+
+	info, err := versioned.GetObjectVersionInfo(ctx, "default", "report.txt", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len("quarterly numbers")) {
+		t.Errorf("GetObjectVersionInfo size = %d, want the content size", info.Size)
+	}
+	if value := markerValue(info.Metadata, dedupBlobMetadata); value != "" {
+		t.Errorf("GetObjectVersionInfo exposes the marker %s=%s", dedupBlobMetadata, value)
+	}
+
+	reader, err = WithVersion(store, "v1").Download(ctx, "default", "report.txt")
+	if got := readAll(t, reader, err); got != "quarterly numbers" {
+		t.Errorf("Download through WithVersion = %q, want the content", got)
+	}
+
+	versions, err := versioned.ListVersions(ctx, "default", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].Name != "report.txt" {
+		t.Errorf("ListVersions = %+v, want only report.txt", versions)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// implicitDirectories derives directories from key prefixes alone: it never
+// writes directory marker objects and hides the ones already stored
+type implicitDirectories struct {
+	Storage
+}
+
+// WithImplicitDirectories returns a view of s without directory markers.
+// CreateDirectory and EnsurePathExists do nothing, listings leave out marker
+// objects (keys ending in "/"), and ListDirectories only reports prefixes that
+// have objects under them. The result keeps implementing the optional
+// interfaces of s.
+func WithImplicitDirectories(s Storage) Storage {
+	return withOptional(&implicitDirectories{Storage: s}, s)
+}
+
+// isMarker reports whether obj is a directory marker object
+func isMarker(obj FileObject) bool {
+	return strings.HasSuffix(obj.Name, "/")
+}
+
+// CreateDirectory fails for directories with metadata, which has no marker to be stored on
+func (d *implicitDirectories) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	if len(metadata) > 0 {
+		return fmt.Errorf("directory metadata needs directory markers: %w", ErrNotSupported)
+	}
+	return nil
+}
+
+func (d *implicitDirectories) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	return nil
+}
+
+func (d *implicitDirectories) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	objects, err := d.Storage.List(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	kept := objects[:0]
+	for _, obj := range objects {
+		if !isMarker(obj) {
+			kept = append(kept, obj)
+		}
+	}
+	return kept, nil
+}
+
+func (d *implicitDirectories) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	return d.Storage.Walk(ctx, bucket, prefix, func(obj FileObject) error {
+		if isMarker(obj) {
+			return nil
+		}
+		return fn(obj)
+	})
+}
+
+// ListDirectories drops the listed prefix itself, which S3-style backends
+// report when a marker object exists for it
+func (d *implicitDirectories) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	dirs, err := d.Storage.ListDirectories(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	kept := dirs[:0]
+	for _, dir := range dirs {
+		if dir.Name != prefix {
+			kept = append(kept, dir)
+		}
+	}
+	return kept, nil
+}
+
+// fillDirectoryMetadata looks up the marker of each listed directory when ctx
+// was prepared by WithListMetadata, for backends that list directories as
+// bare prefixes. A directory without a marker gets an empty metadata map.
+func fillDirectoryMetadata(ctx context.Context, s Storage, bucket string, dirs []FileObject) error {
+	if !listMetadataRequested(ctx) {
+		return nil
+	}
+	for i := range dirs {
+		info, err := s.GetObjectInfo(ctx, bucket, dirs[i].Name)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		dirs[i].Metadata = map[string]string{}
+		if err == nil && info.Metadata != nil {
+			dirs[i].Metadata = info.Metadata
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	// ErrBucketNotFound is returned when the bucket doesn't exist
+	ErrBucketNotFound = errors.New("bucket not found")
+
+	// ErrObjectNotFound is returned when the object doesn't exist
+	ErrObjectNotFound = errors.New("object not found")
+
+	// ErrBucketExists is returned when creating a bucket that already exists
+	ErrBucketExists = errors.New("bucket already exists")
+
+	// ErrBucketNotEmpty is returned when deleting a bucket that still has objects
+	ErrBucketNotEmpty = errors.New("bucket not empty")
+
+	// ErrPreconditionFailed is returned when a conditional write finds a different ETag
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrObjectExists is returned when a create-only write finds an existing object
+	ErrObjectExists = errors.New("object already exists")
+
+	// ErrUploadNotFound is returned for an unknown or already finished multipart upload
+	ErrUploadNotFound = errors.New("multipart upload not found")
+
+	// ErrObjectLocked is returned when a retention period or legal hold
+	// prevents deleting or overwriting an object
+	ErrObjectLocked = errors.New("object locked")
+
+	// ErrNotSupported is returned for operations a backend can't provide
+	ErrNotSupported = errors.New("operation not supported by this storage backend")
+)
+
+// Error is the error returned by every backend created with New. It keeps
+// the failed operation and the provider's error details for logging, while
+// its message stays the one of the wrapped error. errors.Is and errors.As see
+// through it to the wrapped error, so the sentinel errors above still match.
+type Error struct {
+	Op         string // storage operation, e.g. "upload" or "delete_version"
+	Backend    string // storage type, e.g. "minio"
+	Bucket     string
+	Object     string
+	Code       string // provider error code, e.g. NoSuchKey; empty when the provider wasn't reached
+	StatusCode int    // HTTP status of the provider response, 0 when there is none
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// wrapError wraps err in an *Error with the details of the failed operation,
+// returning nil for nil and errors that are already wrapped unchanged
+func wrapError(backend, op, bucket, object string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return err
+	}
+	return &Error{
+		Op:         op,
+		Backend:    backend,
+		Bucket:     bucket,
+		Object:     object,
+		Code:       errorCode(err),
+		StatusCode: statusCode(err),
+		Err:        err,
+	}
+}
+
+// IsNotFound reports whether err returned by a backend means the bucket or object doesn't exist
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrBucketNotFound) || errors.Is(err, ErrObjectNotFound) || errors.Is(err, ErrUploadNotFound) {
+		return true
+	}
+
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsPreconditionFailed reports whether err returned by a backend means a conditional write was refused
+func IsPreconditionFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		return true
+	}
+
+	return statusCode(err) == http.StatusPreconditionFailed
+}
+
+// IsObjectExists reports whether err means a create-only write found an existing object
+func IsObjectExists(err error) bool {
+	return errors.Is(err, ErrObjectExists)
+}
+
+// existsError converts the refusal of a backend's native create-only write,
+// 412 Precondition Failed or 409 Conflict depending on the provider, to
+// ErrObjectExists while keeping the provider error for logging
+func existsError(err error) error {
+	if status := statusCode(err); status == http.StatusPreconditionFailed || status == http.StatusConflict {
+		return fmt.Errorf("%w: %w", ErrObjectExists, err)
+	}
+	return err
+}
+
+// IsObjectLocked reports whether err returned by a backend means an object lock refused the operation
+func IsObjectLocked(err error) bool {
+	return errors.Is(err, ErrObjectLocked)
+}
+
+// errorCode extracts the provider's error code from a backend SDK error, or "" if there is none
+func errorCode(err error) string {
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return minioErr.Code
+	}
+
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return ossErr.Code
+	}
+
+	var obsErr obs.ObsError
+	if errors.As(err, &obsErr) {
+		return obsErr.Code
+	}
+
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		return azureErr.ErrorCode
+	}
+
+	return ""
+}
+
+// statusCode extracts the HTTP status code from a backend SDK error, or 0 if there is none
+func statusCode(err error) int {
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return minioErr.StatusCode
+	}
+
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return ossErr.StatusCode
+	}
+
+	var obsErr obs.ObsError
+	if errors.As(err, &obsErr) {
+		return obsErr.StatusCode
+	}
+
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		return azureErr.StatusCode
+	}
+
+	return 0
+}
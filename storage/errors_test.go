@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrappedErrorKeepsProviderDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if r.URL.Query().Has("location") {
+			fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+	}))
+	t.Cleanup(server.Close)
+
+	minioStore, err := NewMinIOStorage(strings.TrimPrefix(server.URL, "http://"), "key", "secret", false, 0, 0, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := withErrors(minioStore, "minio")
+
+	_, err = store.GetObjectInfo(context.Background(), "default", "docs/missing.txt")
+	// Callers adding their own context must not hide the structured error
+	err = fmt.Errorf("loading report: %w", err)
+
+	var storageErr *Error
+	if !errors.As(err, &storageErr) {
+		t.Fatalf("errors.As(%v) found no *Error", err)
+	}
+	want := Error{Op: "stat", Backend: "minio", Bucket: "default", Object: "docs/missing.txt", Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+	if got := *storageErr; got.Op != want.Op || got.Backend != want.Backend || got.Bucket != want.Bucket || got.Object != want.Object || got.Code != want.Code || got.StatusCode != want.StatusCode {
+		t.Errorf("*Error = %+v, want %+v", got, want)
+	}
+	if !IsNotFound(err) {
+		t.Errorf("IsNotFound(%v) = false, want true", err)
+	}
+}
+
+func TestWrappedErrorMatchesSentinels(t *testing.T) {
+	store := withErrors(NewMemoryStorage(), "memory")
+	ctx := context.Background()
+	if err := store.CreateBucket(ctx, "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := store.GetObjectInfo(ctx, "default", "missing.txt")
+	var storageErr *Error
+	if !errors.As(err, &storageErr) || storageErr.Op != "stat" || storageErr.Backend != "memory" {
+		t.Fatalf("GetObjectInfo error = %#v, want an *Error for stat on memory", err)
+	}
+	if !errors.Is(err, ErrObjectNotFound) || !IsNotFound(err) {
+		t.Errorf("GetObjectInfo error = %v, want it to match %v", err, ErrObjectNotFound)
+	}
+
+	_, err = store.GetObjectInfo(ctx, "missing", "a.txt")
+	if !errors.Is(err, ErrBucketNotFound) || !IsNotFound(err) {
+		t.Errorf("GetObjectInfo in a missing bucket = %v, want it to match %v", err, ErrBucketNotFound)
+	}
+
+	// Wrapping twice keeps the innermost details
+	if got := wrapError("prefix", "download", "other", "b.txt", err); got != err {
+		t.Errorf("wrapError of an *Error = %v, want it unchanged", got)
+	}
+}
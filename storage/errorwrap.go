@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// errorStorage wraps every error of the wrapped Storage in an *Error
+type errorStorage struct {
+	inner   Storage
+	backend string
+}
+
+// errorMultipart wraps the errors of a MultipartStorage
+type errorMultipart struct {
+	inner   MultipartStorage
+	backend string
+}
+
+// errorVersioned wraps the errors of a VersionedStorage
+type errorVersioned struct {
+	inner   VersionedStorage
+	backend string
+}
+
+// errorObjectLock wraps the errors of an ObjectLockStorage
+type errorObjectLock struct {
+	inner   ObjectLockStorage
+	backend string
+}
+
+// errorACL wraps the errors of an ACLStorage
+type errorACL struct {
+	inner   ACLStorage
+	backend string
+}
+
+// errorRestore wraps the errors of a RestoreStorage
+type errorRestore struct {
+	inner   RestoreStorage
+	backend string
+}
+
+// withErrors returns a Storage whose errors are *Error values naming the
+// backend and the failed operation. The result keeps implementing
+// MultipartStorage and VersionedStorage when s does, and ObjectLockStorage
+// and ACLStorage when s also implements both of those; ACLStorage only
+// together with RestoreStorage and PresignStorage, which it then keeps too.
+func withErrors(s Storage, backend string) Storage {
+	base := &errorStorage{inner: s, backend: backend}
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			*errorStorage
+			*errorMultipart
+			*errorVersioned
+			*errorObjectLock
+			*errorACL
+			*errorRestore
+			*errorPresign
+		}{base, &errorMultipart{inner: multipart, backend: backend}, &errorVersioned{inner: versioned, backend: backend}, &errorObjectLock{inner: locking, backend: backend}, &errorACL{inner: acl, backend: backend}, &errorRestore{inner: restore, backend: backend}, &errorPresign{inner: presign, backend: backend}}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			*errorStorage
+			*errorMultipart
+			*errorVersioned
+			*errorObjectLock
+		}{base, &errorMultipart{inner: multipart, backend: backend}, &errorVersioned{inner: versioned, backend: backend}, &errorObjectLock{inner: locking, backend: backend}}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			*errorStorage
+			*errorMultipart
+			*errorVersioned
+			*errorACL
+			*errorRestore
+			*errorPresign
+		}{base, &errorMultipart{inner: multipart, backend: backend}, &errorVersioned{inner: versioned, backend: backend}, &errorACL{inner: acl, backend: backend}, &errorRestore{inner: restore, backend: backend}, &errorPresign{inner: presign, backend: backend}}
+	case isMultipart && isVersioned:
+		return &struct {
+			*errorStorage
+			*errorMultipart
+			*errorVersioned
+		}{base, &errorMultipart{inner: multipart, backend: backend}, &errorVersioned{inner: versioned, backend: backend}}
+	case isMultipart:
+		return &struct {
+			*errorStorage
+			*errorMultipart
+		}{base, &errorMultipart{inner: multipart, backend: backend}}
+	case isVersioned:
+		return &struct {
+			*errorStorage
+			*errorVersioned
+		}{base, &errorVersioned{inner: versioned, backend: backend}}
+	}
+	return base
+}
+
+func (e *errorStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	result, err := e.inner.Upload(ctx, bucket, objectName, reader, size, contentType, headers)
+	return result, wrapError(e.backend, "upload", bucket, objectName, err)
+}
+
+func (e *errorStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	reader, err := e.inner.Download(ctx, bucket, objectName)
+	return reader, wrapError(e.backend, "download", bucket, objectName, err)
+}
+
+func (e *errorStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := e.inner.DownloadRange(ctx, bucket, objectName, offset, length)
+	return reader, wrapError(e.backend, "download", bucket, objectName, err)
+}
+
+func (e *errorStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	result, err := e.inner.UploadIfMatch(ctx, bucket, objectName, reader, size, contentType, headers, etag)
+	return result, wrapError(e.backend, "upload", bucket, objectName, err)
+}
+
+func (e *errorStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	result, err := e.inner.UploadIfNotExists(ctx, bucket, objectName, reader, size, contentType, headers)
+	return result, wrapError(e.backend, "upload", bucket, objectName, err)
+}
+
+func (e *errorStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	return wrapError(e.backend, "delete", bucket, objectName, e.inner.Delete(ctx, bucket, objectName))
+}
+
+func (e *errorStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	return wrapError(e.backend, "delete", bucket, objectName, e.inner.DeleteIfMatch(ctx, bucket, objectName, etag))
+}
+
+func (e *errorStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	objects, err := e.inner.List(ctx, bucket, prefix)
+	return objects, wrapError(e.backend, "list", bucket, prefix, err)
+}
+
+// Walk leaves errors returned by fn as they are, since they don't come from the backend
+func (e *errorStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	var fnErr error
+	err := e.inner.Walk(ctx, bucket, prefix, func(obj FileObject) error {
+		fnErr = fn(obj)
+		return fnErr
+	})
+	if err != nil && err == fnErr {
+		return err
+	}
+	return wrapError(e.backend, "list", bucket, prefix, err)
+}
+
+func (e *errorStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	info, err := e.inner.GetObjectInfo(ctx, bucket, objectName)
+	return info, wrapError(e.backend, "stat", bucket, objectName, err)
+}
+
+func (e *errorStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	return wrapError(e.backend, "update_metadata", bucket, objectName, e.inner.UpdateMetadata(ctx, bucket, objectName, metadata, contentType))
+}
+
+func (e *errorStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	return wrapError(e.backend, "copy", bucket, srcObject, e.inner.Copy(ctx, bucket, srcObject, dstObject, metadata))
+}
+
+func (e *errorStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	return wrapError(e.backend, "create_directory", bucket, objectName, e.inner.CreateDirectory(ctx, bucket, objectName, metadata))
+}
+
+func (e *errorStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	dirs, err := e.inner.ListDirectories(ctx, bucket, prefix)
+	return dirs, wrapError(e.backend, "list_directories", bucket, prefix, err)
+}
+
+func (e *errorStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	objects, err := e.inner.ListObjects(ctx, bucket, prefix)
+	return objects, wrapError(e.backend, "list_objects", bucket, prefix, err)
+}
+
+func (e *errorStorage) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	return wrapError(e.backend, "create_directory", bucket, objectPath, e.inner.EnsurePathExists(ctx, bucket, objectPath))
+}
+
+func (e *errorStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	exists, err := e.inner.BucketExists(ctx, bucket)
+	return exists, wrapError(e.backend, "bucket_exists", bucket, "", err)
+}
+
+func (e *errorStorage) CreateBucket(ctx context.Context, bucket string) error {
+	return wrapError(e.backend, "create_bucket", bucket, "", e.inner.CreateBucket(ctx, bucket))
+}
+
+func (e *errorStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	return wrapError(e.backend, "delete_bucket", bucket, "", e.inner.DeleteBucket(ctx, bucket))
+}
+
+func (e *errorStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	buckets, err := e.inner.ListBuckets(ctx)
+	return buckets, wrapError(e.backend, "list_buckets", "", "", err)
+}
+
+func (e *errorStorage) HealthCheck(ctx context.Context, bucket string) error {
+	return wrapError(e.backend, "health_check", bucket, "", e.inner.HealthCheck(ctx, bucket))
+}
+
+func (e *errorStorage) Capabilities() Capabilities {
+	return e.inner.Capabilities()
+}
+
+func (e *errorMultipart) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	uploadID, err := e.inner.InitMultipart(ctx, bucket, objectName, contentType, headers)
+	return uploadID, wrapError(e.backend, "init_multipart", bucket, objectName, err)
+}
+
+func (e *errorMultipart) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	part, err := e.inner.UploadPart(ctx, bucket, objectName, uploadID, partNumber, reader, size)
+	return part, wrapError(e.backend, "upload_part", bucket, objectName, err)
+}
+
+func (e *errorMultipart) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	parts, err := e.inner.ListParts(ctx, bucket, objectName, uploadID)
+	return parts, wrapError(e.backend, "list_parts", bucket, objectName, err)
+}
+
+func (e *errorMultipart) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return wrapError(e.backend, "complete_multipart", bucket, objectName, e.inner.CompleteMultipart(ctx, bucket, objectName, uploadID))
+}
+
+func (e *errorMultipart) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return wrapError(e.backend, "abort_multipart", bucket, objectName, e.inner.AbortMultipart(ctx, bucket, objectName, uploadID))
+}
+
+func (e *errorMultipart) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	uploads, err := e.inner.ListMultipartUploads(ctx, bucket, prefix)
+	return uploads, wrapError(e.backend, "list_multipart_uploads", bucket, prefix, err)
+}
+
+func (e *errorVersioned) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	reader, err := e.inner.DownloadVersion(ctx, bucket, objectName, versionID)
+	return reader, wrapError(e.backend, "download_version", bucket, objectName, err)
+}
+
+func (e *errorVersioned) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	info, err := e.inner.GetObjectVersionInfo(ctx, bucket, objectName, versionID)
+	return info, wrapError(e.backend, "stat_version", bucket, objectName, err)
+}
+
+func (e *errorVersioned) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return wrapError(e.backend, "delete_version", bucket, objectName, e.inner.DeleteVersion(ctx, bucket, objectName, versionID))
+}
+
+func (e *errorVersioned) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	versions, err := e.inner.ListVersions(ctx, bucket, prefix)
+	return versions, wrapError(e.backend, "list_versions", bucket, prefix, err)
+}
+
+func (e *errorObjectLock) SetObjectRetention(ctx context.Context, bucket, objectName, mode string, retainUntil time.Time) error {
+	return wrapError(e.backend, "set_retention", bucket, objectName, e.inner.SetObjectRetention(ctx, bucket, objectName, mode, retainUntil))
+}
+
+func (e *errorObjectLock) SetLegalHold(ctx context.Context, bucket, objectName string, on bool) error {
+	return wrapError(e.backend, "set_legal_hold", bucket, objectName, e.inner.SetLegalHold(ctx, bucket, objectName, on))
+}
+
+func (e *errorACL) SetACL(ctx context.Context, bucket, objectName, acl string) error {
+	return wrapError(e.backend, "set_acl", bucket, objectName, e.inner.SetACL(ctx, bucket, objectName, acl))
+}
+
+func (e *errorACL) PublicURL(bucket, objectName string) string {
+	return e.inner.PublicURL(bucket, objectName)
+}
+
+func (e *errorRestore) RestoreObject(ctx context.Context, bucket, objectName string, days int) error {
+	return wrapError(e.backend, "restore_object", bucket, objectName, e.inner.RestoreObject(ctx, bucket, objectName, days))
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newBucketScopedServer fakes a provider whose credentials may read the
+// "default" bucket but not list buckets, answering every service-level
+// request with 403 and other buckets with 404
+func newBucketScopedServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		switch strings.Trim(r.URL.Path, "/") {
+		case "":
+			w.Header().Set("x-ms-error-code", "AuthorizationPermissionMismatch")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Listing buckets is not allowed</Message></Error>`)
+		case "default":
+			switch {
+			case r.URL.Query().Has("bucketInfo"):
+				fmt.Fprint(w, `<BucketInfo><Bucket><Name>default</Name></Bucket></BucketInfo>`)
+			case r.URL.Query().Has("location"):
+				fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+			}
+		default:
+			w.Header().Set("x-ms-error-code", "ContainerNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHealthCheckProbesBucketWithoutListing(t *testing.T) {
+	server := newBucketScopedServer(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	backends := map[string]func() (Storage, error){
+		"minio": func() (Storage, error) {
+			return NewMinIOStorage(host, "key", "secret", false, 0, 0, HTTPOptions{})
+		},
+		"s3compat": func() (Storage, error) {
+			return NewS3CompatStorage(host, "us-east-1", "key", "secret", false, true, HTTPOptions{})
+		},
+		"oss": func() (Storage, error) {
+			return NewOSSStorage(host, "key", "secret", false, HTTPOptions{})
+		},
+		"azure": func() (Storage, error) {
+			return NewAzureStorage("acct", "a2V5", server.URL, HTTPOptions{})
+		},
+	}
+	for name, newStorage := range backends {
+		t.Run(name, func(t *testing.T) {
+			store, err := newStorage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.HealthCheck(context.Background(), "default"); err != nil {
+				t.Errorf("HealthCheck(default) = %v, want nil", err)
+			}
+			if err := store.HealthCheck(context.Background(), "missing"); !errors.Is(err, ErrBucketNotFound) {
+				t.Errorf("HealthCheck(missing) = %v, want %v", err, ErrBucketNotFound)
+			}
+		})
+	}
+}
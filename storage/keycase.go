@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// lowercaseStorage folds every object key and listing prefix to lower case
+// before passing it on, so that keys differing only in case name the same
+// object.
+type lowercaseStorage struct {
+	inner Storage
+}
+
+// lowercaseMultipart folds the keys of a MultipartStorage to lower case
+type lowercaseMultipart struct {
+	inner MultipartStorage
+}
+
+// lowercaseVersioned folds the keys of a VersionedStorage to lower case
+type lowercaseVersioned struct {
+	inner VersionedStorage
+}
+
+// lowercaseObjectLock folds the keys of an ObjectLockStorage to lower case
+type lowercaseObjectLock struct {
+	inner ObjectLockStorage
+}
+
+// lowercaseACL folds the keys of an ACLStorage to lower case
+type lowercaseACL struct {
+	inner ACLStorage
+}
+
+// lowercaseRestore folds the keys of a RestoreStorage to lower case
+type lowercaseRestore struct {
+	inner RestoreStorage
+}
+
+// WithLowercaseKeys returns a view of s that lower-cases object keys and
+// listing prefixes on the way in, including the source and destination of
+// copies. Names are returned as stored, so listings only ever show lower-case
+// keys. Objects stored under mixed-case keys before the view was put in place
+// can't be reached through it. The result keeps implementing the optional
+// interfaces of s in the same combinations as WithKeyPrefix.
+func WithLowercaseKeys(s Storage) Storage {
+	base := &lowercaseStorage{inner: s}
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseMultipart
+			*lowercaseVersioned
+			*lowercaseObjectLock
+			*lowercaseACL
+			*lowercaseRestore
+			*lowercasePresign
+		}{base, &lowercaseMultipart{inner: multipart}, &lowercaseVersioned{inner: versioned}, &lowercaseObjectLock{inner: locking}, &lowercaseACL{inner: acl}, &lowercaseRestore{inner: restore}, &lowercasePresign{inner: presign}}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseMultipart
+			*lowercaseVersioned
+			*lowercaseObjectLock
+		}{base, &lowercaseMultipart{inner: multipart}, &lowercaseVersioned{inner: versioned}, &lowercaseObjectLock{inner: locking}}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseMultipart
+			*lowercaseVersioned
+			*lowercaseACL
+			*lowercaseRestore
+			*lowercasePresign
+		}{base, &lowercaseMultipart{inner: multipart}, &lowercaseVersioned{inner: versioned}, &lowercaseACL{inner: acl}, &lowercaseRestore{inner: restore}, &lowercasePresign{inner: presign}}
+	case isMultipart && isVersioned:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseMultipart
+			*lowercaseVersioned
+		}{base, &lowercaseMultipart{inner: multipart}, &lowercaseVersioned{inner: versioned}}
+	case isMultipart:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseMultipart
+		}{base, &lowercaseMultipart{inner: multipart}}
+	case isVersioned:
+		return &struct {
+			*lowercaseStorage
+			*lowercaseVersioned
+		}{base, &lowercaseVersioned{inner: versioned}}
+	}
+	return base
+}
+
+func (l *lowercaseStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	return l.inner.Upload(ctx, bucket, strings.ToLower(objectName), reader, size, contentType, headers)
+}
+
+func (l *lowercaseStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	return l.inner.Download(ctx, bucket, strings.ToLower(objectName))
+}
+
+func (l *lowercaseStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	return l.inner.DownloadRange(ctx, bucket, strings.ToLower(objectName), offset, length)
+}
+
+func (l *lowercaseStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	return l.inner.UploadIfMatch(ctx, bucket, strings.ToLower(objectName), reader, size, contentType, headers, etag)
+}
+
+func (l *lowercaseStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	return l.inner.UploadIfNotExists(ctx, bucket, strings.ToLower(objectName), reader, size, contentType, headers)
+}
+
+func (l *lowercaseStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	return l.inner.Delete(ctx, bucket, strings.ToLower(objectName))
+}
+
+func (l *lowercaseStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	return l.inner.DeleteIfMatch(ctx, bucket, strings.ToLower(objectName), etag)
+}
+
+func (l *lowercaseStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	return l.inner.List(ctx, bucket, strings.ToLower(prefix))
+}
+
+func (l *lowercaseStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	return l.inner.ListObjects(ctx, bucket, strings.ToLower(prefix))
+}
+
+func (l *lowercaseStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	return l.inner.Walk(ctx, bucket, strings.ToLower(prefix), fn)
+}
+
+func (l *lowercaseStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	return l.inner.GetObjectInfo(ctx, bucket, strings.ToLower(objectName))
+}
+
+func (l *lowercaseStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	return l.inner.UpdateMetadata(ctx, bucket, strings.ToLower(objectName), metadata, contentType)
+}
+
+func (l *lowercaseStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	return l.inner.Copy(ctx, bucket, strings.ToLower(srcObject), strings.ToLower(dstObject), metadata)
+}
+
+func (l *lowercaseStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	return l.inner.CreateDirectory(ctx, bucket, strings.ToLower(objectName), metadata)
+}
+
+func (l *lowercaseStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	return l.inner.ListDirectories(ctx, bucket, strings.ToLower(prefix))
+}
+
+func (l *lowercaseStorage) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	return l.inner.EnsurePathExists(ctx, bucket, strings.ToLower(objectPath))
+}
+
+func (l *lowercaseStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return l.inner.BucketExists(ctx, bucket)
+}
+
+func (l *lowercaseStorage) CreateBucket(ctx context.Context, bucket string) error {
+	return l.inner.CreateBucket(ctx, bucket)
+}
+
+func (l *lowercaseStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	return l.inner.DeleteBucket(ctx, bucket)
+}
+
+func (l *lowercaseStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	return l.inner.ListBuckets(ctx)
+}
+
+func (l *lowercaseStorage) HealthCheck(ctx context.Context, bucket string) error {
+	return l.inner.HealthCheck(ctx, bucket)
+}
+
+func (l *lowercaseStorage) Capabilities() Capabilities {
+	return l.inner.Capabilities()
+}
+
+func (l *lowercaseMultipart) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	return l.inner.InitMultipart(ctx, bucket, strings.ToLower(objectName), contentType, headers)
+}
+
+func (l *lowercaseMultipart) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	return l.inner.UploadPart(ctx, bucket, strings.ToLower(objectName), uploadID, partNumber, reader, size)
+}
+
+func (l *lowercaseMultipart) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	return l.inner.ListParts(ctx, bucket, strings.ToLower(objectName), uploadID)
+}
+
+func (l *lowercaseMultipart) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return l.inner.CompleteMultipart(ctx, bucket, strings.ToLower(objectName), uploadID)
+}
+
+func (l *lowercaseMultipart) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return l.inner.AbortMultipart(ctx, bucket, strings.ToLower(objectName), uploadID)
+}
+
+func (l *lowercaseMultipart) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	return l.inner.ListMultipartUploads(ctx, bucket, strings.ToLower(prefix))
+}
+
+func (l *lowercaseVersioned) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	return l.inner.DownloadVersion(ctx, bucket, strings.ToLower(objectName), versionID)
+}
+
+func (l *lowercaseVersioned) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	return l.inner.GetObjectVersionInfo(ctx, bucket, strings.ToLower(objectName), versionID)
+}
+
+func (l *lowercaseVersioned) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return l.inner.DeleteVersion(ctx, bucket, strings.ToLower(objectName), versionID)
+}
+
+func (l *lowercaseVersioned) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	return l.inner.ListVersions(ctx, bucket, strings.ToLower(prefix))
+}
+
+func (l *lowercaseObjectLock) SetObjectRetention(ctx context.Context, bucket, objectName, mode string, retainUntil time.Time) error {
+	return l.inner.SetObjectRetention(ctx, bucket, strings.ToLower(objectName), mode, retainUntil)
+}
+
+func (l *lowercaseObjectLock) SetLegalHold(ctx context.Context, bucket, objectName string, on bool) error {
+	return l.inner.SetLegalHold(ctx, bucket, strings.ToLower(objectName), on)
+}
+
+func (l *lowercaseACL) SetACL(ctx context.Context, bucket, objectName, acl string) error {
+	return l.inner.SetACL(ctx, bucket, strings.ToLower(objectName), acl)
+}
+
+func (l *lowercaseACL) PublicURL(bucket, objectName string) string {
+	return l.inner.PublicURL(bucket, strings.ToLower(objectName))
+}
+
+func (l *lowercaseRestore) RestoreObject(ctx context.Context, bucket, objectName string, days int) error {
+	return l.inner.RestoreObject(ctx, bucket, strings.ToLower(objectName), days)
+}
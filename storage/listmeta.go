@@ -0,0 +1,20 @@
+package storage
+
+import "context"
+
+// listMetadataKey is the context key marking listings that should include user metadata
+type listMetadataKey struct{}
+
+// WithListMetadata returns a copy of ctx asking List and Walk to fill in the
+// user metadata of every object. Backends whose Capabilities report
+// ListMetadata honor it in the listing itself; others ignore it, leaving the
+// caller to look the metadata up per object.
+func WithListMetadata(ctx context.Context) context.Context {
+	return context.WithValue(ctx, listMetadataKey{}, true)
+}
+
+// listMetadataRequested reports whether ctx was prepared by WithListMetadata
+func listMetadataRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(listMetadataKey{}).(bool)
+	return requested
+}
@@ -0,0 +1,745 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryObject is an object stored by MemoryStorage
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	headers      ObjectHeaders
+	metadata     map[string]string
+	lastModified time.Time
+	etag         string
+}
+
+// memoryUpload is a multipart upload in progress in MemoryStorage
+type memoryUpload struct {
+	bucket      string
+	objectName  string
+	contentType string
+	headers     ObjectHeaders
+	initiated   time.Time
+	parts       map[int][]byte
+}
+
+// MemoryStorage implements the Storage interface in memory. It needs no cloud
+// account, which makes it suitable for tests and local runs.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*memoryObject
+	created map[string]time.Time
+	uploads map[string]*memoryUpload
+}
+
+func init() {
+	// 内存存储仅用于本地运行和测试，预先创建默认桶
+	Register("memory", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Bucket string `mapstructure:"bucket"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		store := NewMemoryStorage()
+		if opts.Bucket != "" {
+			if err := store.CreateBucket(context.Background(), opts.Bucket); err != nil {
+				return nil, err
+			}
+		}
+		return store, nil
+	})
+}
+
+// NewMemoryStorage creates a new, empty in-memory storage instance
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		buckets: make(map[string]map[string]*memoryObject),
+		created: make(map[string]time.Time),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+// Upload stores a file in memory
+func (m *MemoryStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.put(bucket, objectName, data, contentType, headers, nil, "", false)
+}
+
+// UploadIfMatch stores a file only if the existing object's ETag matches
+func (m *MemoryStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.put(bucket, objectName, data, contentType, headers, nil, etag, false)
+}
+
+// UploadIfNotExists stores a file only if no object has its name yet; the
+// check and the write happen under one lock
+func (m *MemoryStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.put(bucket, objectName, data, contentType, headers, nil, "", true)
+}
+
+// Download returns a reader over a copy of the stored file
+func (m *MemoryStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, err := m.object(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+// DownloadRange returns a reader over a copy of a byte range of the stored file
+func (m *MemoryStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := m.Download(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	return limitRange(reader, offset, length)
+}
+
+// Delete removes a file; deleting a missing object succeeds like it does on S3
+func (m *MemoryStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return notFound(bucket, "")
+	}
+	delete(objects, objectName)
+	return nil
+}
+
+// DeleteIfMatch removes a file only if its ETag matches
+func (m *MemoryStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.matchETag(bucket, objectName, etag); err != nil {
+		return err
+	}
+	delete(m.buckets[bucket], objectName)
+	return nil
+}
+
+// List lists all objects with the given prefix, including directory markers, sorted by name
+func (m *MemoryStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, notFound(bucket, "")
+	}
+
+	var result []FileObject
+	for _, name := range sortedKeys(objects) {
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, objects[name].fileObject(name))
+		}
+	}
+	return result, nil
+}
+
+// Walk calls fn for each object with the given prefix, sorted by name. The
+// listing is a snapshot taken under the lock, so fn may call back into the storage.
+func (m *MemoryStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	objects, err := m.List(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetObjectInfo gets metadata of a stored object
+func (m *MemoryStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, err := m.object(bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	info := obj.fileObject(objectName)
+	return &info, nil
+}
+
+// UpdateMetadata replaces the metadata of a stored object
+func (m *MemoryStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, err := m.object(bucket, objectName)
+	if err != nil {
+		return err
+	}
+
+	obj.metadata = copyMetadata(metadata)
+	if contentType != "" {
+		obj.contentType = contentType
+	}
+	obj.lastModified = time.Now().UTC()
+	return nil
+}
+
+// Copy stores a copy of an object under another name
+func (m *MemoryStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	src, err := m.object(bucket, srcObject)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		metadata = src.metadata
+	}
+
+	// Stored data is never modified in place, so the copy can share it
+	m.buckets[bucket][dstObject] = &memoryObject{
+		data:         src.data,
+		contentType:  src.contentType,
+		headers:      src.headers,
+		metadata:     copyMetadata(metadata),
+		lastModified: time.Now().UTC(),
+		etag:         src.etag,
+	}
+	return nil
+}
+
+// CreateDirectory creates an empty directory marker object
+func (m *MemoryStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Ensure the object name ends with "/"
+	if !strings.HasSuffix(objectName, "/") {
+		objectName += "/"
+	}
+
+	_, err := m.put(bucket, objectName, nil, "application/directory", ObjectHeaders{}, metadata, "", false)
+	return err
+}
+
+// ListDirectories lists the immediate directories under prefix, derived from
+// object keys the same way a delimiter listing returns common prefixes
+func (m *MemoryStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, notFound(bucket, "")
+	}
+
+	seen := make(map[string]bool)
+	var dirs []FileObject
+	for _, name := range sortedKeys(objects) {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		i := strings.Index(rest, "/")
+		if i < 0 {
+			continue
+		}
+
+		dir := prefix + rest[:i+1]
+		if !seen[dir] {
+			seen[dir] = true
+			obj := FileObject{
+				Name:        dir,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			}
+			// Markers are held like any other object, so their metadata costs nothing to list
+			if listMetadataRequested(ctx) {
+				obj.Metadata = map[string]string{}
+				if marker, ok := objects[dir]; ok {
+					obj.Metadata = copyMetadata(marker.metadata)
+				}
+			}
+			dirs = append(dirs, obj)
+		}
+	}
+	return dirs, nil
+}
+
+// ListObjects lists the directories and files directly under prefix
+func (m *MemoryStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, notFound(bucket, "")
+	}
+
+	seen := make(map[string]bool)
+	var dirs, files []FileObject
+	for _, name := range sortedKeys(objects) {
+		if name == prefix || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		i := strings.Index(rest, "/")
+		if i < 0 {
+			files = append(files, objects[name].fileObject(name))
+			continue
+		}
+
+		dir := prefix + rest[:i+1]
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, FileObject{
+				Name:        dir,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		}
+	}
+	return append(dirs, files...), nil
+}
+
+// EnsurePathExists ensures that all directories in the given path exist
+func (m *MemoryStorage) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	// Extract directory path from the object path
+	dir := path.Dir(objectPath)
+
+	// If the directory is the root directory, nothing to do
+	if dir == "." || dir == "/" {
+		return nil
+	}
+
+	// Ensure the directory path ends with "/"
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	m.mu.RLock()
+	_, err := m.object(bucket, dir)
+	m.mu.RUnlock()
+	if err == nil {
+		// Directory already exists
+		return nil
+	}
+
+	return m.CreateDirectory(ctx, bucket, dir, nil)
+}
+
+// BucketExists reports whether the bucket exists
+func (m *MemoryStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.buckets[bucket]
+	return ok, nil
+}
+
+// CreateBucket creates a new, empty bucket
+func (m *MemoryStorage) CreateBucket(ctx context.Context, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.buckets[bucket]; ok {
+		return fmt.Errorf("bucket %s: %w", bucket, ErrBucketExists)
+	}
+	m.buckets[bucket] = make(map[string]*memoryObject)
+	m.created[bucket] = time.Now().UTC()
+	return nil
+}
+
+// ListBuckets lists all buckets sorted by name
+func (m *MemoryStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]BucketInfo, 0, len(m.buckets))
+	for _, name := range sortedKeys(m.buckets) {
+		result = append(result, BucketInfo{Name: name, CreationDate: m.created[name]})
+	}
+	return result, nil
+}
+
+// DeleteBucket deletes an empty bucket
+func (m *MemoryStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return notFound(bucket, "")
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("bucket %s: %w", bucket, ErrBucketNotEmpty)
+	}
+	delete(m.buckets, bucket)
+	delete(m.created, bucket)
+	return nil
+}
+
+// HealthCheck always succeeds for in-memory storage
+func (m *MemoryStorage) HealthCheck(ctx context.Context, bucket string) error {
+	return nil
+}
+
+// Capabilities reports multipart uploads and copies, which share the stored
+// data; MemoryStorage keeps no object versions and accepts any bucket name
+func (m *MemoryStorage) Capabilities() Capabilities {
+	return Capabilities{Multipart: true, ServerSideCopy: true, AtomicCreate: true, ListMetadata: true}
+}
+
+// put stores an object in an existing bucket, replacing it only if ifMatch is
+// empty or equals the current ETag; with ifNoneMatch it only creates new objects
+func (m *MemoryStorage) put(bucket, objectName string, data []byte, contentType string, headers ObjectHeaders, metadata map[string]string, ifMatch string, ifNoneMatch bool) (*UploadResult, error) {
+	sum := md5.Sum(data)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, notFound(bucket, "")
+	}
+	if ifMatch != "" {
+		if err := m.matchETag(bucket, objectName, ifMatch); err != nil {
+			return nil, err
+		}
+	}
+	if _, exists := objects[objectName]; exists && ifNoneMatch {
+		return nil, fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrObjectExists)
+	}
+	obj := &memoryObject{
+		data:         data,
+		contentType:  contentType,
+		headers:      headers,
+		metadata:     copyMetadata(metadata),
+		lastModified: time.Now().UTC(),
+		etag:         hex.EncodeToString(sum[:]),
+	}
+	objects[objectName] = obj
+	return &UploadResult{ETag: obj.etag, Size: int64(len(data))}, nil
+}
+
+// matchETag checks that a stored object exists with the given ETag; the caller must hold the lock
+func (m *MemoryStorage) matchETag(bucket, objectName, etag string) error {
+	obj, err := m.object(bucket, objectName)
+	if err != nil {
+		if errors.Is(err, ErrBucketNotFound) {
+			return err
+		}
+		return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrPreconditionFailed)
+	}
+	if etag != "*" && obj.etag != trimETag(etag) {
+		return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// object looks up a stored object; the caller must hold the lock
+func (m *MemoryStorage) object(bucket, objectName string) (*memoryObject, error) {
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		return nil, notFound(bucket, "")
+	}
+	obj, ok := objects[objectName]
+	if !ok {
+		return nil, notFound(bucket, objectName)
+	}
+	return obj, nil
+}
+
+// fileObject converts a stored object to a FileObject
+func (o *memoryObject) fileObject(name string) FileObject {
+	return FileObject{
+		Name:         name,
+		Size:         int64(len(o.data)),
+		ContentType:  o.contentType,
+		LastModified: o.lastModified,
+		ETag:         o.etag,
+		Metadata:     copyMetadata(o.metadata),
+		Headers:      o.headers,
+		Checksums:    map[string]string{ChecksumMD5: o.etag},
+		IsDir:        strings.HasSuffix(name, "/"),
+	}
+}
+
+// sortedKeys returns the keys of a bucket or object map in lexicographic order
+func sortedKeys[V any](entries map[string]V) []string {
+	keys := make([]string, 0, len(entries))
+	for name := range entries {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyMetadata returns a copy of metadata that is never nil
+func copyMetadata(metadata map[string]string) map[string]string {
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// notFound returns the error for a missing bucket, or a missing object when objectName is set
+func notFound(bucket, objectName string) error {
+	if objectName == "" {
+		return fmt.Errorf("bucket %s: %w", bucket, ErrBucketNotFound)
+	}
+	return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrObjectNotFound)
+}
+
+// InitMultipart starts a multipart upload
+func (m *MemoryStorage) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.buckets[bucket]; !ok {
+		return "", notFound(bucket, "")
+	}
+	m.uploads[uploadID] = &memoryUpload{
+		bucket:      bucket,
+		objectName:  objectName,
+		contentType: contentType,
+		headers:     headers,
+		initiated:   time.Now().UTC(),
+		parts:       make(map[int][]byte),
+	}
+	return uploadID, nil
+}
+
+// UploadPart stores one part of a multipart upload
+func (m *MemoryStorage) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	if err := ctx.Err(); err != nil {
+		return Part{}, err
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return Part{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, err := m.upload(bucket, objectName, uploadID)
+	if err != nil {
+		return Part{}, err
+	}
+	upload.parts[partNumber] = data
+	return memoryPart(partNumber, data), nil
+}
+
+// ListParts lists the uploaded parts of a multipart upload
+func (m *MemoryStorage) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload, err := m.upload(bucket, objectName, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]Part, 0, len(upload.parts))
+	for _, number := range sortedParts(upload.parts) {
+		parts = append(parts, memoryPart(number, upload.parts[number]))
+	}
+	return parts, nil
+}
+
+// CompleteMultipart stores the object assembled from the uploaded parts
+func (m *MemoryStorage) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	upload, err := m.upload(bucket, objectName, uploadID)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	delete(m.uploads, uploadID)
+	m.mu.Unlock()
+
+	var data []byte
+	for _, number := range sortedParts(upload.parts) {
+		data = append(data, upload.parts[number]...)
+	}
+	_, err = m.put(bucket, objectName, data, upload.contentType, upload.headers, nil, "", false)
+	return err
+}
+
+// AbortMultipart discards a multipart upload
+func (m *MemoryStorage) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.upload(bucket, objectName, uploadID); err != nil {
+		return err
+	}
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+// ListMultipartUploads lists the multipart uploads in progress, sorted by object name
+func (m *MemoryStorage) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.buckets[bucket]; !ok {
+		return nil, notFound(bucket, "")
+	}
+
+	uploads := make([]MultipartUpload, 0)
+	for uploadID, upload := range m.uploads {
+		if upload.bucket == bucket && strings.HasPrefix(upload.objectName, prefix) {
+			uploads = append(uploads, MultipartUpload{Object: upload.objectName, UploadID: uploadID, Initiated: upload.initiated})
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Object != uploads[j].Object {
+			return uploads[i].Object < uploads[j].Object
+		}
+		return uploads[i].Initiated.Before(uploads[j].Initiated)
+	})
+	return uploads, nil
+}
+
+// upload looks up a multipart upload of the given object; the caller must hold the lock
+func (m *MemoryStorage) upload(bucket, objectName, uploadID string) (*memoryUpload, error) {
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.bucket != bucket || upload.objectName != objectName {
+		return nil, fmt.Errorf("upload %s: %w", uploadID, ErrUploadNotFound)
+	}
+	return upload, nil
+}
+
+// memoryPart describes a stored part
+func memoryPart(number int, data []byte) Part {
+	sum := md5.Sum(data)
+	return Part{Number: number, ETag: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+}
+
+// sortedParts returns the part numbers of an upload in ascending order
+func sortedParts(parts map[int][]byte) []int {
+	numbers := make([]int, 0, len(parts))
+	for number := range parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetadataCache is a size-bounded LRU cache of object info with a TTL, shared
+// by the views WithMetadataCache returns for one backend
+type MetadataCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+
+	// generation changes with every invalidation, so a lookup that raced
+	// with a write doesn't store the info it read before the write
+	generation atomic.Uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cacheEntry is one cached object
+type cacheEntry struct {
+	key     string
+	info    FileObject
+	expires time.Time
+}
+
+// CacheStats are the counters of a MetadataCache
+type CacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+}
+
+// NewMetadataCache returns a cache holding info of up to size objects for ttl each
+func NewMetadataCache(size int, ttl time.Duration) *MetadataCache {
+	return &MetadataCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Stats returns the hit and miss counts and the number of cached objects
+func (m *MetadataCache) Stats() CacheStats {
+	m.mu.Lock()
+	entries := len(m.entries)
+	m.mu.Unlock()
+	return CacheStats{Hits: m.hits.Load(), Misses: m.misses.Load(), Entries: entries}
+}
+
+// cacheKey joins a bucket and object into a key that can't collide across buckets
+func cacheKey(bucket, objectName string) string {
+	return bucket + "\x00" + objectName
+}
+
+// get returns a copy of the cached info of an object that hasn't expired yet
+func (m *MetadataCache) get(key string) (*FileObject, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		m.order.Remove(elem)
+		delete(m.entries, key)
+		return nil, false
+	}
+	m.order.MoveToFront(elem)
+	info := entry.info
+	info.Metadata = maps.Clone(info.Metadata)
+	return &info, true
+}
+
+// put caches info unless an invalidation happened since generation was read,
+// evicting the least recently used object when the cache is full
+func (m *MetadataCache) put(key string, info *FileObject, generation uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.generation.Load() != generation {
+		return
+	}
+	entry := &cacheEntry{key: key, info: *info, expires: time.Now().Add(m.ttl)}
+	entry.info.Metadata = maps.Clone(info.Metadata)
+	if elem, ok := m.entries[key]; ok {
+		elem.Value = entry
+		m.order.MoveToFront(elem)
+		return
+	}
+	m.entries[key] = m.order.PushFront(entry)
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops the cached info of an object
+func (m *MetadataCache) invalidate(bucket, objectName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation.Add(1)
+	key := cacheKey(bucket, objectName)
+	if elem, ok := m.entries[key]; ok {
+		m.order.Remove(elem)
+		delete(m.entries, key)
+	}
+}
+
+// invalidateBucket drops the cached info of every object in a bucket
+func (m *MetadataCache) invalidateBucket(bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation.Add(1)
+	prefix := cacheKey(bucket, "")
+	for key, elem := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.order.Remove(elem)
+			delete(m.entries, key)
+		}
+	}
+}
+
+// cachedStorage answers GetObjectInfo from a MetadataCache and drops the
+// cached info of objects it changes
+type cachedStorage struct {
+	Storage
+	cache *MetadataCache
+}
+
+// cachedMultipart drops the cached info of objects completed by multipart upload
+type cachedMultipart struct {
+	MultipartStorage
+	cache *MetadataCache
+}
+
+// cachedVersioned drops the cached info of objects whose versions are deleted
+type cachedVersioned struct {
+	VersionedStorage
+	cache *MetadataCache
+}
+
+// WithMetadataCache returns a view of s whose GetObjectInfo is served from
+// cache while the entry is fresh. Writes through the view invalidate the
+// objects they touch; changes made to the backend by other clients show up
+// once the TTL expires. The result keeps implementing MultipartStorage and
+// VersionedStorage when s does, and ObjectLockStorage and ACLStorage when s
+// also implements both of those; ACLStorage only together with RestoreStorage
+// and PresignStorage, which it then keeps too.
+func WithMetadataCache(s Storage, cache *MetadataCache) Storage {
+	base := &cachedStorage{Storage: s, cache: cache}
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			*cachedStorage
+			*cachedMultipart
+			*cachedVersioned
+			ObjectLockStorage
+			ACLStorage
+			RestoreStorage
+			PresignStorage
+		}{base, &cachedMultipart{MultipartStorage: multipart, cache: cache}, &cachedVersioned{VersionedStorage: versioned, cache: cache}, locking, acl, restore, presign}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			*cachedStorage
+			*cachedMultipart
+			*cachedVersioned
+			ObjectLockStorage
+		}{base, &cachedMultipart{MultipartStorage: multipart, cache: cache}, &cachedVersioned{VersionedStorage: versioned, cache: cache}, locking}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			*cachedStorage
+			*cachedMultipart
+			*cachedVersioned
+			ACLStorage
+			RestoreStorage
+			PresignStorage
+		}{base, &cachedMultipart{MultipartStorage: multipart, cache: cache}, &cachedVersioned{VersionedStorage: versioned, cache: cache}, acl, restore, presign}
+	case isMultipart && isVersioned:
+		return &struct {
+			*cachedStorage
+			*cachedMultipart
+			*cachedVersioned
+		}{base, &cachedMultipart{MultipartStorage: multipart, cache: cache}, &cachedVersioned{VersionedStorage: versioned, cache: cache}}
+	case isMultipart:
+		return &struct {
+			*cachedStorage
+			*cachedMultipart
+		}{base, &cachedMultipart{MultipartStorage: multipart, cache: cache}}
+	case isVersioned:
+		return &struct {
+			*cachedStorage
+			*cachedVersioned
+		}{base, &cachedVersioned{VersionedStorage: versioned, cache: cache}}
+	}
+	return base
+}
+
+// GetObjectInfo returns cached info when it is fresh; errors aren't cached
+func (c *cachedStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	key := cacheKey(bucket, objectName)
+	if info, ok := c.cache.get(key); ok {
+		c.cache.hits.Add(1)
+		return info, nil
+	}
+	c.cache.misses.Add(1)
+
+	generation := c.cache.generation.Load()
+	info, err := c.Storage.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.put(key, info, generation)
+	return info, nil
+}
+
+func (c *cachedStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.Upload(ctx, bucket, objectName, reader, size, contentType, headers)
+}
+
+func (c *cachedStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.UploadIfMatch(ctx, bucket, objectName, reader, size, contentType, headers, etag)
+}
+
+func (c *cachedStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.UploadIfNotExists(ctx, bucket, objectName, reader, size, contentType, headers)
+}
+
+func (c *cachedStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.Delete(ctx, bucket, objectName)
+}
+
+func (c *cachedStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.DeleteIfMatch(ctx, bucket, objectName, etag)
+}
+
+func (c *cachedStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.UpdateMetadata(ctx, bucket, objectName, metadata, contentType)
+}
+
+func (c *cachedStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	defer c.cache.invalidate(bucket, dstObject)
+	return c.Storage.Copy(ctx, bucket, srcObject, dstObject, metadata)
+}
+
+func (c *cachedStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.Storage.CreateDirectory(ctx, bucket, objectName, metadata)
+}
+
+func (c *cachedStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	defer c.cache.invalidateBucket(bucket)
+	return c.Storage.DeleteBucket(ctx, bucket)
+}
+
+func (c *cachedMultipart) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.MultipartStorage.CompleteMultipart(ctx, bucket, objectName, uploadID)
+}
+
+func (c *cachedVersioned) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	defer c.cache.invalidate(bucket, objectName)
+	return c.VersionedStorage.DeleteVersion(ctx, bucket, objectName, versionID)
+}
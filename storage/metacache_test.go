@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingStorage counts the GetObjectInfo calls that reach the backend
+type countingStorage struct {
+	*MemoryStorage
+	infoCalls int
+}
+
+func (s *countingStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	s.infoCalls++
+	return s.MemoryStorage.GetObjectInfo(ctx, bucket, objectName)
+}
+
+// newCountingCache returns a cached view of an in-memory backend with the
+// bucket "default", and the counting backend behind it
+func newCountingCache(t *testing.T, ttl time.Duration) (Storage, *countingStorage) {
+	t.Helper()
+	mem := NewMemoryStorage()
+	if err := mem.CreateBucket(context.Background(), "default"); err != nil {
+		t.Fatal(err)
+	}
+	backend := &countingStorage{MemoryStorage: mem}
+	return WithMetadataCache(backend, NewMetadataCache(100, ttl)), backend
+}
+
+func TestMetadataCacheServesRepeatedLookups(t *testing.T) {
+	store, backend := newCountingCache(t, time.Hour)
+	uploadString(t, store, "a.txt", "content")
+
+	for range 3 {
+		info, err := store.GetObjectInfo(context.Background(), "default", "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size != int64(len("content")) {
+			t.Errorf("Size = %d, want %d", info.Size, len("content"))
+		}
+	}
+	if backend.infoCalls != 1 {
+		t.Errorf("backend saw %d GetObjectInfo calls, want 1", backend.infoCalls)
+	}
+
+	// Lookups after the TTL go back to the backend
+	store, backend = newCountingCache(t, time.Nanosecond)
+	uploadString(t, store, "a.txt", "content")
+	for range 2 {
+		if _, err := store.GetObjectInfo(context.Background(), "default", "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if backend.infoCalls != 2 {
+		t.Errorf("backend saw %d GetObjectInfo calls after the TTL, want 2", backend.infoCalls)
+	}
+}
+
+func TestMetadataCacheInvalidatesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	writes := map[string]func(t *testing.T, store Storage){
+		"upload": func(t *testing.T, store Storage) {
+			uploadString(t, store, "a.txt", "new content")
+		},
+		"delete": func(t *testing.T, store Storage) {
+			if err := store.Delete(ctx, "default", "a.txt"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"copy": func(t *testing.T, store Storage) {
+			uploadString(t, store, "b.txt", "copied content")
+			if err := store.Copy(ctx, "default", "b.txt", "a.txt", nil); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			store, backend := newCountingCache(t, time.Hour)
+			uploadString(t, store, "a.txt", "old")
+			if _, err := store.GetObjectInfo(ctx, "default", "a.txt"); err != nil {
+				t.Fatal(err)
+			}
+
+			write(t, store)
+			calls := backend.infoCalls
+			want, wantErr := backend.MemoryStorage.GetObjectInfo(ctx, "default", "a.txt")
+			got, err := store.GetObjectInfo(ctx, "default", "a.txt")
+			if backend.infoCalls != calls+1 {
+				t.Errorf("GetObjectInfo after %s was served from the cache", name)
+			}
+			if (err != nil) != (wantErr != nil) || (err == nil && got.Size != want.Size) {
+				t.Errorf("GetObjectInfo after %s = %v, %v; want %v, %v", name, got, err, want, wantErr)
+			}
+		})
+	}
+}
@@ -2,10 +2,19 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -15,31 +24,160 @@ import (
 // MinIOStorage implements the Storage interface for MinIO
 type MinIOStorage struct {
 	client *minio.Client
+	
+	// Multipart tuning for uploads; zero keeps the minio-go defaults
+	partSize   uint64
+	numThreads uint
+	
+	// Buckets are addressed as subdomains of the endpoint rather than in the path
+	virtualHost bool
+	
+	// Serializes the read-modify-write of bucket policies by SetACL
+	policyMu sync.Mutex
+}
+
+func init() {
+	Register("minio", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Endpoint   string      `mapstructure:"endpoint"`
+			AccessKey  string      `mapstructure:"access_key"`
+			SecretKey  string      `mapstructure:"secret_key"`
+			UseSSL     bool        `mapstructure:"use_ssl"`
+			PartSize   uint64      `mapstructure:"part_size"`
+			NumThreads uint        `mapstructure:"num_threads"`
+			HTTP       HTTPOptions `mapstructure:"http"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return NewMinIOStorage(opts.Endpoint, opts.AccessKey, opts.SecretKey, opts.UseSSL, opts.PartSize, opts.NumThreads, opts.HTTP)
+	})
+	Register("s3compat", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Endpoint  string      `mapstructure:"endpoint"`
+			Region    string      `mapstructure:"region"`
+			AccessKey string      `mapstructure:"access_key"`
+			SecretKey string      `mapstructure:"secret_key"`
+			UseSSL    bool        `mapstructure:"use_ssl"`
+			PathStyle bool        `mapstructure:"path_style"`
+			HTTP      HTTPOptions `mapstructure:"http"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return NewS3CompatStorage(opts.Endpoint, opts.Region, opts.AccessKey, opts.SecretKey, opts.UseSSL, opts.PathStyle, opts.HTTP)
+	})
 }
 
-// NewMinIOStorage creates a new MinIO storage instance
-func NewMinIOStorage(endpoint, accessKeyID, secretAccessKey string, useSSL bool) (*MinIOStorage, error) {
+// NewMinIOStorage creates a new MinIO storage instance. partSize and numThreads
+// tune multipart uploads, where up to partSize*numThreads bytes are buffered
+// for uploads of unknown length; zero keeps the minio-go defaults.
+func NewMinIOStorage(endpoint, accessKeyID, secretAccessKey string, useSSL bool, partSize uint64, numThreads uint, httpOpts HTTPOptions) (*MinIOStorage, error) {
+	transport, err := minioTransport(useSSL, httpOpts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize minio client object.
 	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: useSSL,
+		Creds:     credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure:    useSSL,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MinIOStorage{
+		client:     client,
+		partSize:   partSize,
+		numThreads: numThreads,
+	}, nil
+}
+
+// minioTransport returns the minio-go default transport with httpOpts applied
+func minioTransport(useSSL bool, httpOpts HTTPOptions) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+	return httpOpts.configureTransport(transport)
+}
+
+// NewS3CompatStorage creates a storage instance for an S3-compatible service
+// other than MinIO. pathStyle selects endpoint/bucket addressing instead of
+// virtual-host bucket.endpoint addressing, since providers support different ones.
+func NewS3CompatStorage(endpoint, region, accessKeyID, secretAccessKey string, useSSL, pathStyle bool, httpOpts HTTPOptions) (*MinIOStorage, error) {
+	lookup := minio.BucketLookupDNS
+	if pathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	
+	transport, err := minioTransport(useSSL, httpOpts)
+	if err != nil {
+		return nil, err
+	}
+	
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure:       useSSL,
+		Region:       region,
+		BucketLookup: lookup,
+		Transport:    transport,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	return &MinIOStorage{
-		client: client,
+		client:      client,
+		virtualHost: !pathStyle,
 	}, nil
 }
 
 // Upload uploads a file to MinIO
-func (m *MinIOStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error {
-	opts := minio.PutObjectOptions{
-		ContentType: contentType,
+func (m *MinIOStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	opts := putOptions(contentType, headers)
+	return m.putObject(ctx, bucket, objectName, reader, size, opts)
+}
+
+// UploadIfMatch uploads a file to MinIO with an If-Match precondition
+func (m *MinIOStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	opts := putOptions(contentType, headers)
+	opts.SetMatchETag(trimETag(etag))
+	return m.putObject(ctx, bucket, objectName, reader, size, opts)
+}
+
+// UploadIfNotExists uploads a file to MinIO with an If-None-Match: *
+// precondition, which minio-go also sends when completing multipart uploads
+func (m *MinIOStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	opts := putOptions(contentType, headers)
+	opts.SetMatchETagExcept("*")
+	result, err := m.putObject(ctx, bucket, objectName, reader, size, opts)
+	return result, existsError(err)
+}
+
+// putOptions returns the options that store contentType and headers with an object
+func putOptions(contentType string, headers ObjectHeaders) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:     contentType,
+		CacheControl:    headers.CacheControl,
+		ContentEncoding: headers.ContentEncoding,
+		ContentLanguage: headers.ContentLanguage,
 	}
-	_, err := m.client.PutObject(ctx, bucket, objectName, reader, size, opts)
-	return err
+}
+
+// putObject uploads a file to MinIO and reports what was stored. minio-go
+// sends objects of a known size up to the part size in a single PUT, so only
+// larger or unknown-length uploads go through multipart.
+func (m *MinIOStorage) putObject(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, opts minio.PutObjectOptions) (*UploadResult, error) {
+	opts.PartSize = m.partSize
+	opts.NumThreads = m.numThreads
+	info, err := m.client.PutObject(ctx, bucket, objectName, reader, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadResult{ETag: trimETag(info.ETag), VersionID: info.VersionID, Size: info.Size}, nil
 }
 
 // Download downloads a file from MinIO
@@ -48,55 +186,227 @@ func (m *MinIOStorage) Download(ctx context.Context, bucket, objectName string)
 	return m.client.GetObject(ctx, bucket, objectName, opts)
 }
 
+// DownloadRange downloads a byte range of a file from MinIO
+func (m *MinIOStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	opts.Set("Range", "bytes="+rangeSpec(offset, length))
+	return m.client.GetObject(ctx, bucket, objectName, opts)
+}
+
 // Delete deletes a file from MinIO
 func (m *MinIOStorage) Delete(ctx context.Context, bucket, objectName string) error {
 	opts := minio.RemoveObjectOptions{}
-	return m.client.RemoveObject(ctx, bucket, objectName, opts)
+	return lockedError(m.client.RemoveObject(ctx, bucket, objectName, opts))
+}
+
+// DeleteIfMatch deletes a file from MinIO if its ETag matches; RemoveObject
+// has no precondition, so the ETag is checked with a separate request first
+func (m *MinIOStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	if err := checkETag(ctx, m, bucket, objectName, etag); err != nil {
+		return err
+	}
+	return m.Delete(ctx, bucket, objectName)
 }
 
 // List lists objects in a MinIO bucket
 func (m *MinIOStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	return listAll(ctx, m, bucket, prefix)
+}
+
+// ListObjects lists the directories and files directly under prefix in MinIO
+func (m *MinIOStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
 	opts := minio.ListObjectsOptions{
 		Prefix:    prefix,
-		Recursive: true,
+		Recursive: false,
 	}
 	
-	objectCh := m.client.ListObjects(ctx, bucket, opts)
-	var objects []FileObject
-	
-	for object := range objectCh {
+	// Common prefixes are returned as objects whose key ends with a slash
+	var dirs, files []FileObject
+	for object := range m.client.ListObjects(ctx, bucket, opts) {
 		if object.Err != nil {
 			return nil, object.Err
 		}
 		
-		objects = append(objects, FileObject{
+		switch {
+		case object.Key == prefix:
+		case strings.HasSuffix(object.Key, "/"):
+			dirs = append(dirs, FileObject{
+				Name:        object.Key,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		default:
+			files = append(files, FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  object.ContentType,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				Metadata:     convertMetadata(object.UserMetadata),
+			})
+		}
+	}
+	return append(dirs, files...), nil
+}
+
+// Walk calls fn for each object in a MinIO bucket as the listing streams in
+func (m *MinIOStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	// Cancelling stops the listing goroutine when fn returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	opts := minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}
+	
+	for object := range m.client.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			return object.Err
+		}
+		
+		err := fn(FileObject{
 			Name:         object.Key,
 			Size:         object.Size,
 			ContentType:  object.ContentType,
-			LastModified: object.LastModified.Format(time.RFC3339),
+			LastModified: object.LastModified,
+			ETag:         trimETag(object.ETag),
 			Metadata:     convertMetadata(object.UserMetadata),
+			StorageClass: object.StorageClass,
 		})
+		if err != nil {
+			return err
+		}
 	}
 	
-	return objects, nil
+	return nil
 }
 
 // GetObjectInfo gets metadata of an object from MinIO
 func (m *MinIOStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
-	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+	return m.statObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+}
+
+// statObject gets metadata of an object, or of the version selected in opts
+func (m *MinIOStorage) statObject(ctx context.Context, bucket, objectName string, opts minio.StatObjectOptions) (*FileObject, error) {
+	// Ask for the checksums objects were uploaded with as well
+	opts.Checksum = true
+	info, err := m.client.StatObject(ctx, bucket, objectName, opts)
 	if err != nil {
 		return nil, err
 	}
 	
+	checksums := make(map[string]string)
+	if sum := base64Checksum(info.ChecksumSHA256, sha256.Size); sum != "" {
+		checksums[ChecksumSHA256] = sum
+	}
+	if sum := base64Checksum(info.ChecksumSHA1, sha1.Size); sum != "" {
+		checksums[ChecksumSHA1] = sum
+	}
+	// The ETag is the MD5 of the content unless the object was uploaded in
+	// parts or is encrypted
+	etag := trimETag(info.ETag)
+	if len(etag) == 2*md5.Size && info.Metadata.Get("X-Amz-Server-Side-Encryption") == "" && info.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") == "" {
+		if _, err := hex.DecodeString(etag); err == nil {
+			checksums[ChecksumMD5] = etag
+		}
+	}
+	
 	return &FileObject{
 		Name:         info.Key,
 		Size:         info.Size,
 		ContentType:  info.ContentType,
-		LastModified: info.LastModified.Format(time.RFC3339),
+		LastModified: info.LastModified,
+		ETag:         trimETag(info.ETag),
+		VersionID:    info.VersionID,
 		Metadata:     convertMetadata(info.UserMetadata),
+		Headers:      headersFrom(info.Metadata),
+		Checksums:    checksums,
+		StorageClass: info.StorageClass,
+		RestoreStatus: restoreStatus(info.Metadata.Get("X-Amz-Restore")),
 	}, nil
 }
 
+// UpdateMetadata replaces object metadata in MinIO by copying the object onto itself
+func (m *MinIOStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	// A metadata replace drops the content type and stored headers unless they are sent again
+	info, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+	
+	userMetadata := convertMetadata(metadata)
+	userMetadata["Content-Type"] = contentType
+	setHeaderMetadata(userMetadata, headersFrom(info.Metadata))
+	
+	dst := minio.CopyDestOptions{
+		Bucket:          bucket,
+		Object:          objectName,
+		UserMetadata:    userMetadata,
+		ReplaceMetadata: true,
+	}
+	src := minio.CopySrcOptions{
+		Bucket: bucket,
+		Object: objectName,
+	}
+	_, err = m.client.CopyObject(ctx, dst, src)
+	return err
+}
+
+// setHeaderMetadata adds the stored headers to the metadata of a copy; minio-go
+// sends standard header names as headers rather than as user metadata
+func setHeaderMetadata(userMetadata map[string]string, headers ObjectHeaders) {
+	if headers.CacheControl != "" {
+		userMetadata["Cache-Control"] = headers.CacheControl
+	}
+	if headers.ContentEncoding != "" {
+		userMetadata["Content-Encoding"] = headers.ContentEncoding
+	}
+	if headers.ContentLanguage != "" {
+		userMetadata["Content-Language"] = headers.ContentLanguage
+	}
+}
+
+// Copy copies an object within a MinIO bucket on the server, in parts when
+// it is larger than a single copy allows
+func (m *MinIOStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	info, err := m.client.StatObject(ctx, bucket, srcObject, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	large := info.Size > maxCopySize
+	if large && metadata == nil {
+		// A copy in parts keeps the user metadata but not the content type and headers
+		metadata = info.UserMetadata
+	}
+
+	dst := minio.CopyDestOptions{
+		Bucket: bucket,
+		Object: dstObject,
+	}
+	if metadata != nil {
+		// A metadata replace drops the content type and stored headers unless they are sent again
+		dst.UserMetadata = convertMetadata(metadata)
+		dst.UserMetadata["Content-Type"] = info.ContentType
+		setHeaderMetadata(dst.UserMetadata, headersFrom(info.Metadata))
+		dst.ReplaceMetadata = true
+	}
+	src := minio.CopySrcOptions{
+		Bucket: bucket,
+		Object: srcObject,
+	}
+	if large {
+		_, err = m.client.ComposeObject(ctx, dst, src)
+	} else {
+		_, err = m.client.CopyObject(ctx, dst, src)
+	}
+	return err
+}
+
 // ListDirectories lists directories in a bucket with the given prefix
 func (m *MinIOStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
 	// In MinIO, directories are simulated by objects with a trailing slash
@@ -120,25 +430,28 @@ func (m *MinIOStorage) ListDirectories(ctx context.Context, bucket, prefix strin
 				Name:         object.Key,
 				Size:         object.Size,
 				ContentType:  object.ContentType,
-				LastModified: object.LastModified.Format(time.RFC3339),
+				LastModified: object.LastModified,
 				Metadata:     convertMetadata(object.UserMetadata),
 				IsDir:        true,
 			})
 		}
 	}
 	
+	if err := fillDirectoryMetadata(ctx, m, bucket, dirs); err != nil {
+		return nil, err
+	}
 	return dirs, nil
 }
 
 // CreateDirectory creates a directory in the storage
-func (m *MinIOStorage) CreateDirectory(ctx context.Context, bucket, objectName string) error {
+func (m *MinIOStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
 	// Ensure the object name ends with "/"
 	if !strings.HasSuffix(objectName, "/") {
 		objectName += "/"
 	}
 	
 	// Create an empty object to represent the directory
-	opts := minio.PutObjectOptions{ContentType: "application/directory"}
+	opts := minio.PutObjectOptions{ContentType: "application/directory", UserMetadata: metadata}
 	_, err := m.client.PutObject(ctx, bucket, objectName, strings.NewReader(""), 0, opts)
 	return err
 }
@@ -171,7 +484,7 @@ func (m *MinIOStorage) EnsurePathExists(ctx context.Context, bucket, objectPath
 	}
 	
 	// Directory doesn't exist, create it
-	return m.CreateDirectory(ctx, bucket, dir)
+	return m.CreateDirectory(ctx, bucket, dir, nil)
 }
 
 // convertMetadata converts minio metadata to map[string]string
@@ -181,4 +494,325 @@ func convertMetadata(metadata map[string]string) map[string]string {
 		result[k] = v
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// HealthCheck verifies that the MinIO server is reachable and the bucket
+// exists, with a HEAD request that credentials scoped to the bucket may send
+func (m *MinIOStorage) HealthCheck(ctx context.Context, bucket string) error {
+	exists, err := m.client.BucketExists(ctx, bucket)
+	if err == nil && !exists {
+		err = fmt.Errorf("bucket %s: %w", bucket, ErrBucketNotFound)
+	}
+	return err
+}
+
+// Capabilities reports that MinIO supports versioning, multipart uploads and server-side copies
+func (m *MinIOStorage) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Multipart: true, ServerSideCopy: true, ObjectLock: true, ACLScope: ACLScopeObject, AtomicCreate: true, Restore: true, PresignedURLs: true, MinPartSize: s3MinPartSize, Naming: s3Naming}
+}
+
+// ListBuckets lists all buckets in MinIO
+func (m *MinIOStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	buckets, err := m.client.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	
+	result := make([]BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, BucketInfo{Name: bucket.Name, CreationDate: bucket.CreationDate})
+	}
+	return result, nil
+}
+
+// BucketExists reports whether the bucket exists in MinIO
+func (m *MinIOStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return m.client.BucketExists(ctx, bucket)
+}
+
+// CreateBucket creates a new bucket in MinIO
+func (m *MinIOStorage) CreateBucket(ctx context.Context, bucket string) error {
+	return m.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+}
+
+// DeleteBucket deletes an empty bucket from MinIO
+func (m *MinIOStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	return m.client.RemoveBucket(ctx, bucket)
+}
+
+// core exposes the low-level multipart API of the MinIO client
+func (m *MinIOStorage) core() minio.Core {
+	return minio.Core{Client: m.client}
+}
+
+// InitMultipart starts a multipart upload in MinIO
+func (m *MinIOStorage) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	return m.core().NewMultipartUpload(ctx, bucket, objectName, putOptions(contentType, headers))
+}
+
+// UploadPart uploads one part of a multipart upload to MinIO
+func (m *MinIOStorage) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	part, err := m.core().PutObjectPart(ctx, bucket, objectName, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: part.PartNumber, ETag: trimETag(part.ETag), Size: part.Size}, nil
+}
+
+// ListParts lists the uploaded parts of a multipart upload in MinIO
+func (m *MinIOStorage) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	parts := make([]Part, 0)
+	marker := 0
+	for {
+		result, err := m.core().ListObjectParts(ctx, bucket, objectName, uploadID, marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range result.ObjectParts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: trimETag(part.ETag), Size: part.Size})
+		}
+		
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// CompleteMultipart assembles the object from its uploaded parts in MinIO
+func (m *MinIOStorage) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	parts, err := m.ListParts(ctx, bucket, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	
+	complete := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		complete = append(complete, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err = m.core().CompleteMultipartUpload(ctx, bucket, objectName, uploadID, complete, minio.PutObjectOptions{})
+	return err
+}
+
+// AbortMultipart discards a multipart upload in MinIO
+func (m *MinIOStorage) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return m.core().AbortMultipartUpload(ctx, bucket, objectName, uploadID)
+}
+
+// ListMultipartUploads lists the multipart uploads in progress in MinIO
+func (m *MinIOStorage) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	uploads := make([]MultipartUpload, 0)
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := m.core().ListMultipartUploads(ctx, bucket, prefix, keyMarker, uploadIDMarker, "", 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, upload := range result.Uploads {
+			uploads = append(uploads, MultipartUpload{Object: upload.Key, UploadID: upload.UploadID, Initiated: upload.Initiated})
+		}
+		
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+// DownloadVersion downloads a specific version of a file from MinIO
+func (m *MinIOStorage) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	return m.client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{VersionID: versionID})
+}
+
+// GetObjectVersionInfo gets metadata of a specific version of an object from MinIO
+func (m *MinIOStorage) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	return m.statObject(ctx, bucket, objectName, minio.StatObjectOptions{VersionID: versionID})
+}
+
+// DeleteVersion permanently deletes a specific version of a file from MinIO
+func (m *MinIOStorage) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return lockedError(m.client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{VersionID: versionID}))
+}
+
+// SetObjectRetention locks the current version of an object in MinIO until retainUntil
+func (m *MinIOStorage) SetObjectRetention(ctx context.Context, bucket, objectName, mode string, retainUntil time.Time) error {
+	retention := minio.RetentionMode(mode)
+	if !retention.IsValid() {
+		return fmt.Errorf("invalid retention mode %q", mode)
+	}
+	opts := minio.PutObjectRetentionOptions{Mode: &retention, RetainUntilDate: &retainUntil}
+	return m.client.PutObjectRetention(ctx, bucket, objectName, opts)
+}
+
+// SetLegalHold places or removes a legal hold on the current version of an object in MinIO
+func (m *MinIOStorage) SetLegalHold(ctx context.Context, bucket, objectName string, on bool) error {
+	status := minio.LegalHoldDisabled
+	if on {
+		status = minio.LegalHoldEnabled
+	}
+	return m.client.PutObjectLegalHold(ctx, bucket, objectName, minio.PutObjectLegalHoldOptions{Status: &status})
+}
+
+// publicReadSid names the bucket policy statement that lists the objects
+// made publicly readable with SetACL
+const publicReadSid = "FileServicePublicRead"
+
+// SetACL makes an object publicly readable or private again. MinIO has no
+// object ACLs, so public objects are listed as resources of one statement in
+// the bucket policy, which S3-compatible services honour as well. The policy
+// is rewritten on every change; other statements in it are kept.
+func (m *MinIOStorage) SetACL(ctx context.Context, bucket, objectName, acl string) error {
+	if acl != ACLPrivate && acl != ACLPublicRead {
+		return fmt.Errorf("unsupported ACL %q", acl)
+	}
+	// Policy resources treat these as wildcards and can't escape them
+	if strings.ContainsAny(objectName, "*?") {
+		return fmt.Errorf("object names containing * or ? can't be made public with a bucket policy")
+	}
+	if _, err := m.client.StatObject(ctx, bucket, objectName, minio.StatObjectOptions{}); err != nil {
+		return err
+	}
+	
+	m.policyMu.Lock()
+	defer m.policyMu.Unlock()
+	current, err := m.client.GetBucketPolicy(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	policy, err := publicReadPolicy(current, "arn:aws:s3:::"+bucket+"/"+objectName, acl == ACLPublicRead)
+	if err != nil {
+		return err
+	}
+	return m.client.SetBucketPolicy(ctx, bucket, policy)
+}
+
+// publicReadPolicy returns the bucket policy current with resource added to
+// or removed from the publicReadSid statement. The statement is dropped once
+// it lists nothing, and an empty result removes the policy.
+func publicReadPolicy(current, resource string, public bool) (string, error) {
+	policy := map[string]any{"Version": "2012-10-17"}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &policy); err != nil {
+			return "", fmt.Errorf("failed to parse bucket policy: %w", err)
+		}
+	}
+	
+	statements, _ := policy["Statement"].([]any)
+	kept := make([]any, 0, len(statements)+1)
+	var resources []any
+	for _, statement := range statements {
+		if st, ok := statement.(map[string]any); ok && st["Sid"] == publicReadSid {
+			switch r := st["Resource"].(type) {
+			case string:
+				resources = append(resources, r)
+			case []any:
+				resources = append(resources, r...)
+			}
+			continue
+		}
+		kept = append(kept, statement)
+	}
+	
+	listed := resources[:0]
+	for _, r := range resources {
+		if r != resource {
+			listed = append(listed, r)
+		}
+	}
+	if public {
+		listed = append(listed, resource)
+	}
+	if len(listed) > 0 {
+		kept = append(kept, map[string]any{
+			"Sid":       publicReadSid,
+			"Effect":    "Allow",
+			"Principal": map[string]any{"AWS": []any{"*"}},
+			"Action":    []any{"s3:GetObject"},
+			"Resource":  listed,
+		})
+	}
+	if len(kept) == 0 {
+		return "", nil
+	}
+	
+	policy["Statement"] = kept
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// RestoreObject starts restoring an object from S3 Glacier or Deep Archive,
+// keeping the restored copy for days
+func (m *MinIOStorage) RestoreObject(ctx context.Context, bucket, objectName string, days int) error {
+	req := minio.RestoreRequest{}
+	req.SetDays(days)
+	return m.client.RestoreObject(ctx, bucket, objectName, "", req)
+}
+
+// PresignDownload returns a SigV4 presigned GET URL of an object in MinIO.
+// Without a configured region the bucket location is looked up once.
+func (m *MinIOStorage) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	signed, err := m.client.PresignedGetObject(ctx, bucket, objectName, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
+
+// PublicURL returns the unauthenticated URL of an object in MinIO
+func (m *MinIOStorage) PublicURL(bucket, objectName string) string {
+	u := m.client.EndpointURL()
+	if m.virtualHost {
+		return virtualHostURL(u.String(), bucket, objectName)
+	}
+	u.Path = "/" + bucket + "/" + objectName
+	return u.String()
+}
+
+// lockedError marks the 403 that S3 returns for a delete refused by a
+// retention period or legal hold with ErrObjectLocked. MinIO and AWS only
+// tell it apart from other access errors by the message.
+func lockedError(err error) error {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) || resp.StatusCode != http.StatusForbidden {
+		return err
+	}
+	message := strings.ToLower(resp.Message)
+	if resp.Code == "ObjectLocked" || strings.Contains(message, "worm") || strings.Contains(message, "object lock") {
+		return fmt.Errorf("%w: %s", ErrObjectLocked, resp.Message)
+	}
+	return err
+}
+
+// ListVersions lists all versions of objects in a MinIO bucket
+func (m *MinIOStorage) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	opts := minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithVersions: true,
+	}
+	
+	versions := make([]ObjectVersion, 0)
+	for object := range m.client.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		
+		versions = append(versions, ObjectVersion{
+			FileObject: FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  object.ContentType,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				VersionID:    object.VersionID,
+				Metadata:     convertMetadata(object.UserMetadata),
+			},
+			IsLatest:       object.IsLatest,
+			IsDeleteMarker: object.IsDeleteMarker,
+		})
+	}
+	return versions, nil
+}
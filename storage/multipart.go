@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Largest objects the providers copy in a single request. Larger ones are
+// copied as a multipart upload of copyPartSize ranges, which covers objects of
+// up to 10 TiB in 10000 parts.
+const (
+	maxCopySize    = 5 << 30 // S3, MinIO and OBS
+	ossMaxCopySize = 1 << 30
+	copyPartSize   = 1 << 30
+)
+
+// Smallest parts but the last that the providers assemble
+const (
+	s3MinPartSize  = 5 << 20 // S3, MinIO and S3-compatible services
+	ossMinPartSize = 100 << 10
+	obsMinPartSize = 100 << 10
+)
+
+// Part describes an uploaded part of a multipart upload
+type Part struct {
+	Number int
+	ETag   string // 不带引号的实体标签, empty for Azure blocks
+	Size   int64
+}
+
+// MultipartUpload describes a multipart upload that has been started but not
+// yet completed or aborted
+type MultipartUpload struct {
+	Object    string
+	UploadID  string
+	Initiated time.Time
+}
+
+// MultipartStorage is implemented by backends that can assemble an object from
+// parts uploaded separately, so a failed upload can resume from the last part
+type MultipartStorage interface {
+	// InitMultipart starts a multipart upload and returns its upload ID; the
+	// content type and headers are stored with the completed object
+	InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error)
+	
+	// UploadPart uploads one part; uploading the same part number again replaces it
+	UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error)
+	
+	// ListParts lists the parts uploaded so far, ordered by part number
+	ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error)
+	
+	// CompleteMultipart assembles the object from all uploaded parts in part number order
+	CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error
+	
+	// AbortMultipart discards an upload and its parts
+	AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error
+	
+	// ListMultipartUploads lists the uploads in progress for objects with the given prefix
+	ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidName is returned for bucket names and object keys a backend would reject
+var ErrInvalidName = errors.New("invalid name")
+
+// NamingRules are the bucket name and object key rules of a backend, checked
+// before a request reaches it so violations don't surface as backend errors
+type NamingRules struct {
+	BucketPattern   *regexp.Regexp // nil when any bucket name is accepted
+	BucketMinLength int
+	BucketMaxLength int
+	BucketRule      string // 描述允许的字符, used in error messages
+	ObjectMaxLength int    // 对象键的最大字节数, 0 for no limit
+}
+
+var (
+	// s3Naming applies to MinIO and other S3-compatible services
+	s3Naming = NamingRules{
+		BucketPattern:   regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`),
+		BucketMinLength: 3,
+		BucketMaxLength: 63,
+		BucketRule:      "may only contain lowercase letters, digits, hyphens and periods, and must start and end with a letter or digit",
+		ObjectMaxLength: 1024,
+	}
+
+	// ossNaming doesn't allow periods in bucket names
+	ossNaming = NamingRules{
+		BucketPattern:   regexp.MustCompile(`^[a-z0-9][a-z0-9-]*[a-z0-9]$`),
+		BucketMinLength: 3,
+		BucketMaxLength: 63,
+		BucketRule:      "may only contain lowercase letters, digits and hyphens, and must start and end with a letter or digit",
+		ObjectMaxLength: 1023,
+	}
+
+	// obsNaming follows the S3 rules
+	obsNaming = s3Naming
+
+	// azureNaming doesn't allow periods or consecutive hyphens in container names
+	azureNaming = NamingRules{
+		BucketPattern:   regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`),
+		BucketMinLength: 3,
+		BucketMaxLength: 63,
+		BucketRule:      "may only contain lowercase letters, digits and single hyphens, and must start and end with a letter or digit",
+		ObjectMaxLength: 1024,
+	}
+)
+
+// CheckBucket returns an error wrapping ErrInvalidName that says why the
+// backend would reject the bucket name
+func (r NamingRules) CheckBucket(name string) error {
+	if r.BucketPattern == nil {
+		return nil
+	}
+	if len(name) < r.BucketMinLength || len(name) > r.BucketMaxLength {
+		return fmt.Errorf("bucket name %q must be %d to %d characters long: %w", name, r.BucketMinLength, r.BucketMaxLength, ErrInvalidName)
+	}
+	if r.BucketPattern.MatchString(name) {
+		return nil
+	}
+	if strings.ToLower(name) != name {
+		return fmt.Errorf("bucket name %q must be lowercase: %w", name, ErrInvalidName)
+	}
+	return fmt.Errorf("bucket name %q %s: %w", name, r.BucketRule, ErrInvalidName)
+}
+
+// CheckObject returns an error wrapping ErrInvalidName that says why the
+// backend would reject the object key. Control characters are rejected on
+// every backend, since listings in XML can't carry them.
+func (r NamingRules) CheckObject(key string) error {
+	if r.ObjectMaxLength > 0 && len(key) > r.ObjectMaxLength {
+		return fmt.Errorf("object key is longer than %d bytes: %w", r.ObjectMaxLength, ErrInvalidName)
+	}
+	if i := strings.IndexFunc(key, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("object key contains the control character %U: %w", []rune(key[i:])[0], ErrInvalidName)
+	}
+	return nil
+}
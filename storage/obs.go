@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/http"
 	"path"
 	"strings"
 	"time"
@@ -13,40 +15,110 @@ import (
 // OBStorage implements the Storage interface for Huawei Cloud OBS
 type OBStorage struct {
 	client *obs.ObsClient
+	
+	// Endpoint URL including the scheme, for building public object URLs
+	endpoint string
+}
+
+func init() {
+	Register("obs", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Endpoint  string      `mapstructure:"endpoint"`
+			AccessKey string      `mapstructure:"access_key"`
+			SecretKey string      `mapstructure:"secret_key"`
+			UseSSL    bool        `mapstructure:"use_ssl"`
+			HTTP      HTTPOptions `mapstructure:"http"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return NewOBStorage(opts.Endpoint, opts.AccessKey, opts.SecretKey, opts.UseSSL, opts.HTTP)
+	})
 }
 
-// NewOBStorage creates a new OBS storage instance
-func NewOBStorage(endpoint, accessKey, secretKey string, useSSL bool) (*OBStorage, error) {
+// NewOBStorage creates a new OBS storage instance. The SDK builds its own
+// transport, configured in whole seconds, with one limit for idle connections
+// in total and per host, so MaxIdleConnsPerHost is preferred over MaxIdleConns;
+// TLSHandshakeTimeout isn't supported.
+func NewOBStorage(endpoint, accessKey, secretKey string, useSSL bool, httpOpts HTTPOptions) (*OBStorage, error) {
 	// 根据useSSL参数决定是否使用HTTPS
-	if !useSSL {
-		endpoint = "http://" + endpoint
-	} else {
-		endpoint = "https://" + endpoint
-	}
+	endpoint = endpointURL(endpoint, useSSL)
 	
-	client, err := obs.New(accessKey, secretKey, endpoint)
+	// The SDK falls back to its defaults for zero values
+	maxIdle := httpOpts.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = httpOpts.MaxIdleConns
+	}
+	// The SDK only follows the environment when asked to
+	proxy := obs.WithProxyFromEnv(true)
+	if httpOpts.Proxy != "" {
+		proxy = obs.WithProxyUrl(httpOpts.Proxy)
+	}
+	// The SDK doesn't verify certificates unless told to, and then trusts
+	// only the given CA certificates instead of the system roots
+	var caCerts []byte
+	if httpOpts.CACertFile != "" {
+		var err error
+		if caCerts, err = httpOpts.readCACerts(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := obs.New(accessKey, secretKey, endpoint,
+		obs.WithConnectTimeout(seconds(httpOpts.DialTimeout)),
+		obs.WithHeaderTimeout(seconds(httpOpts.ResponseHeaderTimeout)),
+		obs.WithMaxConnections(maxIdle),
+		obs.WithSslVerifyAndPemCerts(!httpOpts.InsecureSkipVerify, caCerts),
+		proxy)
 	if err != nil {
 		return nil, err
 	}
 
 	return &OBStorage{
-		client: client,
+		client:   client,
+		endpoint: endpoint,
 	}, nil
 }
 
 // Upload uploads a file to OBS
-func (o *OBStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) error {
+func (o *OBStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	// PutObject doesn't report the stored size, so count the bytes sent
+	counter := &countingReader{Reader: reader}
+	
 	input := &obs.PutObjectInput{}
 	input.Bucket = bucketName
 	input.Key = objectName
-	input.Body = reader
+	input.Body = counter
 	
 	if contentType != "" {
 		input.ContentType = contentType
 	}
+	input.CacheControl = headers.CacheControl
+	input.ContentEncoding = headers.ContentEncoding
+	input.ContentLanguage = headers.ContentLanguage
 
-	_, err := o.client.PutObject(input)
-	return err
+	output, err := o.client.PutObject(input)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadResult{ETag: trimETag(output.ETag), VersionID: output.VersionId, Size: counter.n}, nil
+}
+
+// UploadIfMatch uploads a file to OBS if the existing object's ETag matches;
+// PutObject has no precondition, so the ETag is checked with a separate request first
+func (o *OBStorage) UploadIfMatch(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	if err := checkETag(ctx, o, bucketName, objectName, etag); err != nil {
+		return nil, err
+	}
+	return o.Upload(ctx, bucketName, objectName, reader, size, contentType, headers)
+}
+
+// UploadIfNotExists uploads a file to OBS if no object has its name yet;
+// PutObject has no such precondition, so existence is checked with a separate request first
+func (o *OBStorage) UploadIfNotExists(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	if err := checkAbsent(ctx, o, bucketName, objectName); err != nil {
+		return nil, err
+	}
+	return o.Upload(ctx, bucketName, objectName, reader, size, contentType, headers)
 }
 
 // Download downloads a file from OBS
@@ -63,6 +135,22 @@ func (o *OBStorage) Download(ctx context.Context, bucketName, objectName string)
 	return output.Body, nil
 }
 
+// DownloadRange downloads a byte range of a file from OBS. The SDK's
+// RangeStart/RangeEnd can't express open or single-byte ranges, so the
+// header is set directly.
+func (o *OBStorage) DownloadRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	input := &obs.GetObjectInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	
+	output, err := o.client.GetObject(input, obs.WithCustomHeader("Range", "bytes="+rangeSpec(offset, length)))
+	if err != nil {
+		return nil, err
+	}
+	
+	return output.Body, nil
+}
+
 // Delete deletes a file from OBS
 func (o *OBStorage) Delete(ctx context.Context, bucketName, objectName string) error {
 	input := &obs.DeleteObjectInput{}
@@ -73,41 +161,130 @@ func (o *OBStorage) Delete(ctx context.Context, bucketName, objectName string) e
 	return err
 }
 
+// DeleteIfMatch deletes a file from OBS if its ETag matches, checked with a separate request first
+func (o *OBStorage) DeleteIfMatch(ctx context.Context, bucketName, objectName, etag string) error {
+	if err := checkETag(ctx, o, bucketName, objectName, etag); err != nil {
+		return err
+	}
+	return o.Delete(ctx, bucketName, objectName)
+}
+
 // List lists objects in an OBS bucket
 func (o *OBStorage) List(ctx context.Context, bucketName string, prefix string) ([]FileObject, error) {
+	return listAll(ctx, o, bucketName, prefix)
+}
+
+// Walk calls fn for each object in an OBS bucket, following the listing markers page by page
+func (o *OBStorage) Walk(ctx context.Context, bucketName, prefix string, fn func(FileObject) error) error {
 	input := &obs.ListObjectsInput{}
 	input.Bucket = bucketName
 	input.Prefix = prefix
 	
-	output, err := o.client.ListObjects(input)
-	if err != nil {
-		return nil, err
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
+		output, err := o.client.ListObjects(input)
+		if err != nil {
+			return err
+		}
+		
+		for _, object := range output.Contents {
+			contentType := string(object.StorageClass) // OBS doesn't directly provide content type
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			
+			err := fn(FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  contentType,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				Metadata:     make(map[string]string), // UserMetadata not available in this context
+				StorageClass: string(object.StorageClass),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		
+		if !output.IsTruncated || len(output.Contents) == 0 {
+			return nil
+		}
+		// NextMarker is only returned with a delimiter, otherwise continue after the last key
+		input.Marker = output.NextMarker
+		if input.Marker == "" {
+			input.Marker = output.Contents[len(output.Contents)-1].Key
+		}
 	}
+}
+
+// ListObjects lists the directories and files directly under prefix in OBS,
+// following the listing markers page by page
+func (o *OBStorage) ListObjects(ctx context.Context, bucketName, prefix string) ([]FileObject, error) {
+	input := &obs.ListObjectsInput{}
+	input.Bucket = bucketName
+	input.Prefix = prefix
+	input.Delimiter = "/"
 	
-	var objects []FileObject
-	for _, object := range output.Contents {
-		contentType := string(object.StorageClass) // OBS doesn't directly provide content type
-		if contentType == "" {
-			contentType = "application/octet-stream"
+	var dirs, files []FileObject
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		output, err := o.client.ListObjects(input)
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, prefixInfo := range output.CommonPrefixes {
+			dirs = append(dirs, FileObject{
+				Name:        prefixInfo,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		}
+		for _, object := range output.Contents {
+			if object.Key == prefix {
+				continue
+			}
+			contentType := string(object.StorageClass) // OBS doesn't directly provide content type
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			files = append(files, FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  contentType,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				Metadata:     make(map[string]string), // UserMetadata not available in this context
+			})
 		}
 		
-		objects = append(objects, FileObject{
-			Name:         object.Key,
-			Size:         object.Size,
-			ContentType:  contentType,
-			LastModified: object.LastModified.Format(time.RFC3339),
-			Metadata:     make(map[string]string), // UserMetadata not available in this context
-		})
+		// NextMarker is always returned with a delimiter
+		if !output.IsTruncated || output.NextMarker == "" {
+			return append(dirs, files...), nil
+		}
+		input.Marker = output.NextMarker
 	}
-	
-	return objects, nil
 }
 
 // GetObjectInfo gets metadata of an object from OBS
 func (o *OBStorage) GetObjectInfo(ctx context.Context, bucketName, objectName string) (*FileObject, error) {
+	return o.objectInfo(bucketName, objectName, "")
+}
+
+// objectInfo gets metadata of an object, or of one version of it when versionID is set
+func (o *OBStorage) objectInfo(bucketName, objectName, versionID string) (*FileObject, error) {
 	input := &obs.GetObjectMetadataInput{}
 	input.Bucket = bucketName
 	input.Key = objectName
+	input.VersionId = versionID
 	
 	output, err := o.client.GetObjectMetadata(input)
 	if err != nil {
@@ -123,40 +300,169 @@ func (o *OBStorage) GetObjectInfo(ctx context.Context, bucketName, objectName st
 		Name:         objectName,
 		Size:         output.ContentLength,
 		ContentType:  contentType,
-		LastModified: output.LastModified.Format(time.RFC3339),
-		Metadata:     make(map[string]string), // Metadata not directly available in this context
+		LastModified: output.LastModified,
+		ETag:         trimETag(output.ETag),
+		VersionID:    output.VersionId,
+		Metadata:     convertMetadata(output.Metadata),
+		Headers: ObjectHeaders{
+			CacheControl:    output.CacheControl,
+			ContentEncoding: output.ContentEncoding,
+			ContentLanguage: output.ContentLanguage,
+		},
+		StorageClass: string(output.StorageClass),
+		RestoreStatus: restoreStatus(output.Restore),
 	}, nil
 }
 
-// ListDirectories lists directories in a bucket with the given prefix
+// UpdateMetadata replaces object metadata in OBS
+func (o *OBStorage) UpdateMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string, contentType string) error {
+	// A metadata replace drops the content type and stored headers unless they are sent again
+	metaInput := &obs.GetObjectMetadataInput{}
+	metaInput.Bucket = bucketName
+	metaInput.Key = objectName
+	
+	output, err := o.client.GetObjectMetadata(metaInput)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = output.ContentType
+	}
+	
+	input := &obs.SetObjectMetadataInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.MetadataDirective = obs.ReplaceMetadata
+	input.HttpHeader = output.HttpHeader
+	input.ContentType = contentType
+	input.Metadata = metadata
+	
+	_, err = o.client.SetObjectMetadata(input)
+	return err
+}
+
+// Copy copies an object within an OBS bucket on the server, in parts when it
+// is larger than CopyObject allows
+func (o *OBStorage) Copy(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	metaInput := &obs.GetObjectMetadataInput{}
+	metaInput.Bucket = bucketName
+	metaInput.Key = srcObject
+	
+	source, err := o.client.GetObjectMetadata(metaInput)
+	if err != nil {
+		return err
+	}
+	if source.ContentLength > maxCopySize {
+		if metadata == nil {
+			metadata = source.Metadata
+		}
+		return o.copyInParts(ctx, bucketName, srcObject, dstObject, source, metadata)
+	}
+	
+	input := &obs.CopyObjectInput{}
+	input.Bucket = bucketName
+	input.Key = dstObject
+	input.CopySourceBucket = bucketName
+	input.CopySourceKey = srcObject
+	if metadata != nil {
+		// A metadata replace drops the content type and stored headers unless they are sent again
+		input.MetadataDirective = obs.ReplaceMetadata
+		input.HttpHeader = source.HttpHeader
+		input.Metadata = metadata
+	}
+	
+	_, err = o.client.CopyObject(input)
+	return err
+}
+
+// copyInParts copies an object as a multipart upload of copied ranges,
+// giving the copy the content type and headers of source and metadata
+func (o *OBStorage) copyInParts(ctx context.Context, bucketName, srcObject, dstObject string, source *obs.GetObjectMetadataOutput, metadata map[string]string) error {
+	initInput := &obs.InitiateMultipartUploadInput{}
+	initInput.Bucket = bucketName
+	initInput.Key = dstObject
+	initInput.ContentType = source.ContentType
+	initInput.CacheControl = source.CacheControl
+	initInput.ContentEncoding = source.ContentEncoding
+	initInput.ContentLanguage = source.ContentLanguage
+	initInput.Metadata = metadata
+	
+	upload, err := o.client.InitiateMultipartUpload(initInput)
+	if err != nil {
+		return err
+	}
+	
+	input := &obs.CompleteMultipartUploadInput{}
+	input.Bucket = bucketName
+	input.Key = dstObject
+	input.UploadId = upload.UploadId
+	for start, number := int64(0), 1; start < source.ContentLength; start, number = start+copyPartSize, number+1 {
+		partInput := &obs.CopyPartInput{}
+		partInput.Bucket = bucketName
+		partInput.Key = dstObject
+		partInput.UploadId = upload.UploadId
+		partInput.PartNumber = number
+		partInput.CopySourceBucket = bucketName
+		partInput.CopySourceKey = srcObject
+		partInput.CopySourceRangeStart = start
+		partInput.CopySourceRangeEnd = min(start+copyPartSize, source.ContentLength) - 1
+		
+		part, err := o.client.CopyPart(partInput)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			o.AbortMultipart(context.WithoutCancel(ctx), bucketName, dstObject, upload.UploadId)
+			return err
+		}
+		input.Parts = append(input.Parts, obs.Part{PartNumber: number, ETag: part.ETag})
+	}
+	
+	_, err = o.client.CompleteMultipartUpload(input)
+	return err
+}
+
+// ListDirectories lists directories in a bucket with the given prefix, following the listing markers page by page
 func (o *OBStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
 	input := &obs.ListObjectsInput{}
 	input.Bucket = bucket
 	input.Prefix = prefix
 	input.Delimiter = "/"
 	
-	result, err := o.client.ListObjects(input)
-	if err != nil {
-		return nil, err
-	}
-	
 	var dirs []FileObject
-	
-	// Process common prefixes (directories)
-	for _, prefixInfo := range result.CommonPrefixes {
-		dirs = append(dirs, FileObject{
-			Name:        prefixInfo,
-			Size:        0,
-			ContentType: "application/directory",
-			IsDir:       true,
-		})
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		result, err := o.client.ListObjects(input)
+		if err != nil {
+			return nil, err
+		}
+		
+		// Process common prefixes (directories)
+		for _, prefixInfo := range result.CommonPrefixes {
+			dirs = append(dirs, FileObject{
+				Name:        prefixInfo,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		}
+		
+		// NextMarker is always returned with a delimiter
+		if !result.IsTruncated || result.NextMarker == "" {
+			if err := fillDirectoryMetadata(ctx, o, bucket, dirs); err != nil {
+				return nil, err
+			}
+			return dirs, nil
+		}
+		input.Marker = result.NextMarker
 	}
-	
-	return dirs, nil
 }
 
 // CreateDirectory creates a directory in the storage
-func (o *OBStorage) CreateDirectory(ctx context.Context, bucket, objectName string) error {
+func (o *OBStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
 	input := &obs.PutObjectInput{}
 	input.Bucket = bucket
 	
@@ -168,6 +474,7 @@ func (o *OBStorage) CreateDirectory(ctx context.Context, bucket, objectName stri
 	input.Key = objectName
 	input.Body = strings.NewReader("")
 	input.ContentType = "application/directory"
+	input.Metadata = metadata
 	
 	_, err := o.client.PutObject(input)
 	return err
@@ -202,10 +509,314 @@ func (o *OBStorage) EnsurePathExists(ctx context.Context, bucket, objectPath str
 	// If the error indicates the object doesn't exist, create the directory
 	if obsError, ok := err.(obs.ObsError); ok {
 		if obsError.Code == "NoSuchKey" || obsError.Code == "404" { // Also handle 404 Not Found
-			return o.CreateDirectory(ctx, bucket, dir)
+			return o.CreateDirectory(ctx, bucket, dir, nil)
 		}
 	}
 	
 	// For other errors, return the error
 	return err
-}
\ No newline at end of file
+}
+
+// HealthCheck verifies that the OBS endpoint is reachable and the bucket
+// exists, with a HEAD request that credentials scoped to the bucket may send
+func (o *OBStorage) HealthCheck(ctx context.Context, bucket string) error {
+	exists, err := o.BucketExists(ctx, bucket)
+	if err == nil && !exists {
+		err = fmt.Errorf("bucket %s: %w", bucket, ErrBucketNotFound)
+	}
+	return err
+}
+
+// Capabilities reports that OBS supports versioning, multipart uploads, server-side copies and object ACLs
+func (o *OBStorage) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Multipart: true, ServerSideCopy: true, ACLScope: ACLScopeObject, Restore: true, PresignedURLs: true, MinPartSize: obsMinPartSize, Naming: obsNaming}
+}
+
+// SetACL sets the ACL of an object in OBS
+func (o *OBStorage) SetACL(ctx context.Context, bucketName, objectName, acl string) error {
+	if acl != ACLPrivate && acl != ACLPublicRead {
+		return fmt.Errorf("unsupported ACL %q", acl)
+	}
+	input := &obs.SetObjectAclInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.ACL = obs.AclType(acl)
+	
+	_, err := o.client.SetObjectAcl(input)
+	return err
+}
+
+// RestoreObject starts restoring an object from the OBS Cold or Deep Archive
+// class, keeping the restored copy for days
+func (o *OBStorage) RestoreObject(ctx context.Context, bucketName, objectName string, days int) error {
+	input := &obs.RestoreObjectInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.Days = days
+	
+	_, err := o.client.RestoreObject(input)
+	return err
+}
+
+// PresignDownload returns a signed GET URL of an object in OBS
+func (o *OBStorage) PresignDownload(ctx context.Context, bucketName, objectName string, expiry time.Duration) (string, error) {
+	input := &obs.CreateSignedUrlInput{}
+	input.Method = obs.HttpMethodGet
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.Expires = int(expiry / time.Second)
+	
+	output, err := o.client.CreateSignedUrl(input)
+	if err != nil {
+		return "", err
+	}
+	return output.SignedUrl, nil
+}
+
+// PublicURL returns the unauthenticated URL of an object in OBS
+func (o *OBStorage) PublicURL(bucketName, objectName string) string {
+	return virtualHostURL(o.endpoint, bucketName, objectName)
+}
+
+// ListBuckets lists all buckets in OBS, following the listing markers page by page
+func (o *OBStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	result := make([]BucketInfo, 0)
+	input := &obs.ListBucketsInput{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		output, err := o.client.ListBuckets(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range output.Buckets {
+			result = append(result, BucketInfo{Name: bucket.Name, CreationDate: bucket.CreationDate})
+		}
+		
+		if !output.IsTruncated || output.NextMarker == "" {
+			return result, nil
+		}
+		input.Marker = output.NextMarker
+	}
+}
+
+// BucketExists reports whether the bucket exists in OBS
+func (o *OBStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	_, err := o.client.HeadBucket(bucket)
+	if err == nil {
+		return true, nil
+	}
+	
+	if obsError, ok := err.(obs.ObsError); ok && obsError.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// CreateBucket creates a new bucket in OBS
+func (o *OBStorage) CreateBucket(ctx context.Context, bucket string) error {
+	input := &obs.CreateBucketInput{}
+	input.Bucket = bucket
+	
+	_, err := o.client.CreateBucket(input)
+	return err
+}
+
+// DeleteBucket deletes an empty bucket from OBS
+func (o *OBStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	_, err := o.client.DeleteBucket(bucket)
+	return err
+}
+
+// InitMultipart starts a multipart upload in OBS
+func (o *OBStorage) InitMultipart(ctx context.Context, bucketName, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	input := &obs.InitiateMultipartUploadInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.ContentType = contentType
+	input.CacheControl = headers.CacheControl
+	input.ContentEncoding = headers.ContentEncoding
+	input.ContentLanguage = headers.ContentLanguage
+	
+	output, err := o.client.InitiateMultipartUpload(input)
+	if err != nil {
+		return "", err
+	}
+	return output.UploadId, nil
+}
+
+// UploadPart uploads one part of a multipart upload to OBS
+func (o *OBStorage) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	input := &obs.UploadPartInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.UploadId = uploadID
+	input.PartNumber = partNumber
+	input.Body = reader
+	input.PartSize = size
+	
+	output, err := o.client.UploadPart(input)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: partNumber, ETag: trimETag(output.ETag), Size: size}, nil
+}
+
+// ListParts lists the uploaded parts of a multipart upload in OBS
+func (o *OBStorage) ListParts(ctx context.Context, bucketName, objectName, uploadID string) ([]Part, error) {
+	input := &obs.ListPartsInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.UploadId = uploadID
+	
+	parts := make([]Part, 0)
+	for {
+		output, err := o.client.ListParts(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range output.Parts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: trimETag(part.ETag), Size: part.Size})
+		}
+		
+		if !output.IsTruncated {
+			return parts, nil
+		}
+		input.PartNumberMarker = output.NextPartNumberMarker
+	}
+}
+
+// CompleteMultipart assembles the object from its uploaded parts in OBS
+func (o *OBStorage) CompleteMultipart(ctx context.Context, bucketName, objectName, uploadID string) error {
+	parts, err := o.ListParts(ctx, bucketName, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	
+	input := &obs.CompleteMultipartUploadInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.UploadId = uploadID
+	for _, part := range parts {
+		input.Parts = append(input.Parts, obs.Part{PartNumber: part.Number, ETag: part.ETag})
+	}
+	
+	_, err = o.client.CompleteMultipartUpload(input)
+	return err
+}
+
+// AbortMultipart discards a multipart upload in OBS
+func (o *OBStorage) AbortMultipart(ctx context.Context, bucketName, objectName, uploadID string) error {
+	input := &obs.AbortMultipartUploadInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.UploadId = uploadID
+	
+	_, err := o.client.AbortMultipartUpload(input)
+	return err
+}
+
+// ListMultipartUploads lists the multipart uploads in progress in OBS
+func (o *OBStorage) ListMultipartUploads(ctx context.Context, bucketName, prefix string) ([]MultipartUpload, error) {
+	input := &obs.ListMultipartUploadsInput{}
+	input.Bucket = bucketName
+	input.Prefix = prefix
+	
+	uploads := make([]MultipartUpload, 0)
+	for {
+		output, err := o.client.ListMultipartUploads(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, upload := range output.Uploads {
+			uploads = append(uploads, MultipartUpload{Object: upload.Key, UploadID: upload.UploadId, Initiated: upload.Initiated})
+		}
+		
+		if !output.IsTruncated {
+			return uploads, nil
+		}
+		input.KeyMarker, input.UploadIdMarker = output.NextKeyMarker, output.NextUploadIdMarker
+	}
+}
+
+// DownloadVersion downloads a specific version of a file from OBS
+func (o *OBStorage) DownloadVersion(ctx context.Context, bucketName, objectName, versionID string) (io.ReadCloser, error) {
+	input := &obs.GetObjectInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.VersionId = versionID
+	
+	output, err := o.client.GetObject(input)
+	if err != nil {
+		return nil, err
+	}
+	
+	return output.Body, nil
+}
+
+// GetObjectVersionInfo gets metadata of a specific version of an object from OBS
+func (o *OBStorage) GetObjectVersionInfo(ctx context.Context, bucketName, objectName, versionID string) (*FileObject, error) {
+	return o.objectInfo(bucketName, objectName, versionID)
+}
+
+// DeleteVersion permanently deletes a specific version of a file from OBS
+func (o *OBStorage) DeleteVersion(ctx context.Context, bucketName, objectName, versionID string) error {
+	input := &obs.DeleteObjectInput{}
+	input.Bucket = bucketName
+	input.Key = objectName
+	input.VersionId = versionID
+	
+	_, err := o.client.DeleteObject(input)
+	return err
+}
+
+// ListVersions lists all versions and delete markers of objects in OBS, page by page
+func (o *OBStorage) ListVersions(ctx context.Context, bucketName, prefix string) ([]ObjectVersion, error) {
+	input := &obs.ListVersionsInput{}
+	input.Bucket = bucketName
+	input.Prefix = prefix
+	
+	versions := make([]ObjectVersion, 0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		output, err := o.client.ListVersions(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range output.Versions {
+			versions = append(versions, ObjectVersion{
+				FileObject: FileObject{
+					Name:         version.Key,
+					Size:         version.Size,
+					LastModified: version.LastModified,
+					ETag:         trimETag(version.ETag),
+					VersionID:    version.VersionId,
+					Metadata:     make(map[string]string),
+				},
+				IsLatest: version.IsLatest,
+			})
+		}
+		for _, marker := range output.DeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				FileObject: FileObject{
+					Name:         marker.Key,
+					LastModified: marker.LastModified,
+					VersionID:    marker.VersionId,
+					Metadata:     make(map[string]string),
+				},
+				IsLatest:       marker.IsLatest,
+				IsDeleteMarker: true,
+			})
+		}
+		
+		if !output.IsTruncated {
+			return versions, nil
+		}
+		input.KeyMarker, input.VersionIdMarker = output.NextKeyMarker, output.NextVersionIdMarker
+	}
+}
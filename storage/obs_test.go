@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOBSTimestamps(t *testing.T) {
+	listing := `<ListBucketResult><Name>bucket</Name><Prefix>logs/</Prefix><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>` +
+		`<Contents><Key>logs/a.json</Key><LastModified>` + isoTimestamp + `</LastModified><ETag>"etag"</ETag><Size>2</Size><StorageClass>STANDARD</StorageClass></Contents>` +
+		`</ListBucketResult>`
+
+	for header, want := range map[string]time.Time{httpTimestamp: wantTimestamp, "": {}} {
+		server := newTimestampServer(t, header, listing)
+		store, err := NewOBStorage(strings.TrimPrefix(server.URL, "http://"), "key", "secret", false, HTTPOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTimestamps(t, store, "bucket", want)
+	}
+}
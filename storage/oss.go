@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"fmt"
 	"io"
 	"net/http"
 	"path"
@@ -17,14 +20,48 @@ type OSSStorage struct {
 	client *oss.Client
 }
 
-// NewOSSStorage creates a new OSS storage instance
-func NewOSSStorage(endpoint, accessKey, secretKey string, useSSL bool) (*OSSStorage, error) {
-	// 根据useSSL参数决定是否使用HTTPS
-	options := []oss.ClientOption{}
-	if !useSSL {
-		options = append(options, oss.HTTPClient(new(http.Client)))
+func init() {
+	Register("oss", func(cfg map[string]any) (Storage, error) {
+		var opts struct {
+			Endpoint  string      `mapstructure:"endpoint"`
+			AccessKey string      `mapstructure:"access_key"`
+			SecretKey string      `mapstructure:"secret_key"`
+			UseSSL    bool        `mapstructure:"use_ssl"`
+			HTTP      HTTPOptions `mapstructure:"http"`
+		}
+		if err := DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return NewOSSStorage(opts.Endpoint, opts.AccessKey, opts.SecretKey, opts.UseSSL, opts.HTTP)
+	})
+}
+
+// NewOSSStorage creates a new OSS storage instance. httpOpts are applied to
+// the SDK's own transport, which also enforces read and write deadlines on
+// every connection, so TLSHandshakeTimeout isn't supported there.
+func NewOSSStorage(endpoint, accessKey, secretKey string, useSSL bool, httpOpts HTTPOptions) (*OSSStorage, error) {
+	// 根据useSSL参数决定是否使用HTTPS; the SDK would fall back to HTTP for an endpoint without a scheme
+	endpoint = endpointURL(endpoint, useSSL)
+	// The SDK takes a fixed proxy and ignores the environment, so resolve it here
+	options := []oss.ClientOption{ossHTTPOptions(httpOpts)}
+	proxy, err := httpOpts.proxyURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		options = append(options, oss.Proxy(proxy.String()))
+	}
+	// The SDK can only skip verification, so trusting a CA takes a transport
+	// of our own, without the SDK's read and write deadlines
+	if httpOpts.CACertFile != "" {
+		transport, err := httpOpts.configureTransport(http.DefaultTransport.(*http.Transport).Clone())
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, oss.HTTPClient(&http.Client{Transport: transport}))
+	} else if httpOpts.InsecureSkipVerify {
+		options = append(options, oss.InsecureSkipVerify(true))
 	}
-	
 	client, err := oss.New(endpoint, accessKey, secretKey, options...)
 	if err != nil {
 		return nil, err
@@ -35,20 +72,148 @@ func NewOSSStorage(endpoint, accessKey, secretKey string, useSSL bool) (*OSSStor
 	}, nil
 }
 
+// ossHTTPOptions returns a client option setting the SDK's connection
+// settings that httpOpts sets
+func ossHTTPOptions(httpOpts HTTPOptions) oss.ClientOption {
+	return func(client *oss.Client) {
+		if httpOpts.DialTimeout > 0 {
+			client.Config.HTTPTimeout.ConnectTimeout = httpOpts.DialTimeout
+		}
+		if httpOpts.ResponseHeaderTimeout > 0 {
+			client.Config.HTTPTimeout.HeaderTimeout = httpOpts.ResponseHeaderTimeout
+		}
+		if httpOpts.MaxIdleConns > 0 {
+			client.Config.HTTPMaxConns.MaxIdleConns = httpOpts.MaxIdleConns
+		}
+		if httpOpts.MaxIdleConnsPerHost > 0 {
+			client.Config.HTTPMaxConns.MaxIdleConnsPerHost = httpOpts.MaxIdleConnsPerHost
+		}
+	}
+}
+
 // Upload uploads a file to OSS
-func (o *OSSStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) error {
+func (o *OSSStorage) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	return o.upload(bucketName, objectName, reader, size, contentType, headers, false)
+}
+
+// UploadIfNotExists uploads a file to OSS with x-oss-forbid-overwrite, which
+// OSS checks on PutObject and when completing a multipart upload
+func (o *OSSStorage) UploadIfNotExists(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	result, err := o.upload(bucketName, objectName, reader, size, contentType, headers, true)
+	return result, existsError(err)
+}
+
+// upload uploads a file to OSS, refusing to replace an existing object when
+// forbidOverwrite is set
+func (o *OSSStorage) upload(bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, forbidOverwrite bool) (*UploadResult, error) {
 	bucket, err := o.client.Bucket(bucketName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Convert context to options
+	options := headerOptions(contentType, headers)
+	if forbidOverwrite {
+		options = append(options, oss.ForbidOverWrite(true))
+	}
+
+	// Without a length the SDK can't send the body as is, so stream it in parts
+	if size < 0 {
+		return o.uploadStream(bucket, objectName, reader, options, forbidOverwrite)
+	}
+
+	var header http.Header
+	options = append(options, oss.GetResponseHeader(&header), oss.ContentLength(size))
+
+	// PutObject only reports headers, so count the bytes sent
+	counter := &countingReader{Reader: reader}
+	if err := bucket.PutObject(objectName, counter, options...); err != nil {
+		return nil, err
+	}
+	return &UploadResult{ETag: trimETag(header.Get("ETag")), VersionID: oss.GetVersionId(header), Size: counter.n}, nil
+}
+
+// headerOptions returns the options that store contentType and headers with an object
+func headerOptions(contentType string, headers ObjectHeaders) []oss.Option {
 	var options []oss.Option
 	if contentType != "" {
 		options = append(options, oss.ContentType(contentType))
 	}
+	if headers.CacheControl != "" {
+		options = append(options, oss.CacheControl(headers.CacheControl))
+	}
+	if headers.ContentEncoding != "" {
+		options = append(options, oss.ContentEncoding(headers.ContentEncoding))
+	}
+	if headers.ContentLanguage != "" {
+		options = append(options, oss.ContentLanguage(headers.ContentLanguage))
+	}
+	return options
+}
+
+// ossStreamPartSize is the part size used to upload bodies of unknown length;
+// only one part is held in memory at a time
+const ossStreamPartSize = 8 << 20
 
-	return bucket.PutObject(objectName, reader, options...)
+// uploadStream uploads a body of unknown length. A body that fits in a single
+// part is sent with PutObject, anything larger as a multipart upload;
+// forbidOverwrite is checked again when the multipart upload is completed.
+func (o *OSSStorage) uploadStream(bucket *oss.Bucket, objectName string, reader io.Reader, options []oss.Option, forbidOverwrite bool) (*UploadResult, error) {
+	buf := make([]byte, ossStreamPartSize)
+	n, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		var header http.Header
+		options = append(options, oss.ContentLength(int64(n)), oss.GetResponseHeader(&header))
+		if err := bucket.PutObject(objectName, bytes.NewReader(buf[:n]), options...); err != nil {
+			return nil, err
+		}
+		return &UploadResult{ETag: trimETag(header.Get("ETag")), VersionID: oss.GetVersionId(header), Size: int64(n)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	imur, err := bucket.InitiateMultipartUpload(objectName, options...)
+	if err != nil {
+		return nil, err
+	}
+	var parts []oss.UploadPart
+	var total int64
+	for n > 0 {
+		part, err := bucket.UploadPart(imur, bytes.NewReader(buf[:n]), int64(n), len(parts)+1)
+		if err != nil {
+			bucket.AbortMultipartUpload(imur)
+			return nil, err
+		}
+		parts = append(parts, part)
+		total += int64(n)
+
+		n, err = io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			bucket.AbortMultipartUpload(imur)
+			return nil, err
+		}
+	}
+
+	var header http.Header
+	completeOptions := []oss.Option{oss.GetResponseHeader(&header)}
+	if forbidOverwrite {
+		completeOptions = append(completeOptions, oss.ForbidOverWrite(true))
+	}
+	result, err := bucket.CompleteMultipartUpload(imur, parts, completeOptions...)
+	if err != nil {
+		bucket.AbortMultipartUpload(imur)
+		return nil, err
+	}
+	return &UploadResult{ETag: trimETag(result.ETag), VersionID: oss.GetVersionId(header), Size: total}, nil
+}
+
+// UploadIfMatch uploads a file to OSS if the existing object's ETag matches;
+// PutObject has no precondition, so the ETag is checked with a separate request first
+func (o *OSSStorage) UploadIfMatch(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	if err := checkETag(ctx, o, bucketName, objectName, etag); err != nil {
+		return nil, err
+	}
+	return o.Upload(ctx, bucketName, objectName, reader, size, contentType, headers)
 }
 
 // Download downloads a file from OSS
@@ -61,6 +226,16 @@ func (o *OSSStorage) Download(ctx context.Context, bucketName, objectName string
 	return bucket.GetObject(objectName)
 }
 
+// DownloadRange downloads a byte range of a file from OSS
+func (o *OSSStorage) DownloadRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	
+	return bucket.GetObject(objectName, oss.NormalizedRange(rangeSpec(offset, length)))
+}
+
 // Delete deletes a file from OSS
 func (o *OSSStorage) Delete(ctx context.Context, bucketName, objectName string) error {
 	bucket, err := o.client.Bucket(bucketName)
@@ -71,109 +246,234 @@ func (o *OSSStorage) Delete(ctx context.Context, bucketName, objectName string)
 	return bucket.DeleteObject(objectName)
 }
 
+// DeleteIfMatch deletes a file from OSS if its ETag matches, checked with a separate request first
+func (o *OSSStorage) DeleteIfMatch(ctx context.Context, bucketName, objectName, etag string) error {
+	if err := checkETag(ctx, o, bucketName, objectName, etag); err != nil {
+		return err
+	}
+	return o.Delete(ctx, bucketName, objectName)
+}
+
 // List lists objects in a bucket with the given prefix
 func (o *OSSStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	return listAll(ctx, o, bucket, prefix)
+}
+
+// Walk calls fn for each object with the given prefix, one page of 1000 keys at a time
+func (o *OSSStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
 	bucketClient, err := o.client.Bucket(bucket)
 	if err != nil {
-		return nil, err
-	}
-	
-	// List all objects with the given prefix
-	lsRes, err := bucketClient.ListObjects(oss.Prefix(prefix), oss.MaxKeys(1000))
-	if err != nil {
-		return nil, err
+		return err
 	}
 	
-	var objects []FileObject
-	
-	// Add files to the result
-	for _, object := range lsRes.Objects {
-		objects = append(objects, FileObject{
-			Name:         object.Key,
-			Size:         object.Size,
-			ContentType:  object.Type,
-			LastModified: object.LastModified.Format(time.RFC3339),
-			Metadata:     make(map[string]string), // 暂时使用空的元数据
-		})
+	token := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		
+		options := []oss.Option{oss.Prefix(prefix), oss.MaxKeys(1000)}
+		if token != "" {
+			options = append(options, oss.ContinuationToken(token))
+		}
+		lsRes, err := bucketClient.ListObjectsV2(options...)
+		if err != nil {
+			return err
+		}
+		
+		for _, object := range lsRes.Objects {
+			err := fn(FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  object.Type,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				Metadata:     make(map[string]string), // 暂时使用空的元数据
+				StorageClass: object.StorageClass,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		
+		if !lsRes.IsTruncated {
+			return nil
+		}
+		token = lsRes.NextContinuationToken
 	}
-	
-	return objects, nil
 }
 
-// ListObjects lists objects in a bucket with the given prefix
+// ListObjects lists the directories and files directly under prefix in OSS
 func (o *OSSStorage) ListObjects(ctx context.Context, bucketName, prefix string) ([]FileObject, error) {
 	bucket, err := o.client.Bucket(bucketName)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Use delimiter to separate folders and files
-	lsRes, err := bucket.ListObjects(oss.Prefix(prefix), oss.Delimiter("/"))
-	if err != nil {
-		return nil, err
-	}
-	
-	var objects []FileObject
+	marker := ""
+	var dirs, files []FileObject
 	
-	// Add folders to the result
-	for _, prefix := range lsRes.CommonPrefixes {
-		objects = append(objects, FileObject{
-			Name:        prefix,
-			Size:        0,
-			ContentType: "application/directory",
-			IsDir:       true,
-		})
+	for {
+		// Use delimiter to separate folders and files
+		lsRes, err := bucket.ListObjects(oss.Prefix(prefix), oss.Delimiter("/"), oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+		
+		// Add folders to the result
+		for _, dir := range lsRes.CommonPrefixes {
+			dirs = append(dirs, FileObject{
+				Name:        dir,
+				Size:        0,
+				ContentType: "application/directory",
+				IsDir:       true,
+			})
+		}
+		
+		// Add files to the result, leaving out the marker of the prefix itself
+		for _, object := range lsRes.Objects {
+			if object.Key == prefix {
+				continue
+			}
+			files = append(files, FileObject{
+				Name:         object.Key,
+				Size:         object.Size,
+				ContentType:  object.Type,
+				LastModified: object.LastModified,
+				ETag:         trimETag(object.ETag),
+				Metadata:     make(map[string]string), // 暂时使用空的元数据
+				IsDir:        false,
+			})
+		}
+		
+		if lsRes.IsTruncated {
+			marker = lsRes.NextMarker
+		} else {
+			break
+		}
 	}
 	
-	// Add files to the result
-	for _, object := range lsRes.Objects {
-		objects = append(objects, FileObject{
-			Name:         object.Key,
-			Size:         object.Size,
-			ContentType:  object.Type,
-			LastModified: object.LastModified.Format(time.RFC3339),
-			Metadata:     make(map[string]string), // 暂时使用空的元数据
-			IsDir:        false,
-		})
+	return append(dirs, files...), nil
+}
+
+// ossMetadata returns the user metadata, which OSS returns among the other headers
+func ossMetadata(props http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for k, v := range props {
+		if name, ok := strings.CutPrefix(k, "X-Oss-Meta-"); ok && len(v) > 0 {
+			metadata[name] = v[0]
+		}
 	}
-	
-	return objects, nil
+	return metadata
 }
 
 // GetObjectInfo gets object metadata from OSS
 func (o *OSSStorage) GetObjectInfo(ctx context.Context, bucketName, objectName string) (*FileObject, error) {
+	return o.objectInfo(bucketName, objectName)
+}
+
+// objectInfo gets metadata of an object, or of the version selected in options
+func (o *OSSStorage) objectInfo(bucketName, objectName string, options ...oss.Option) (*FileObject, error) {
 	bucket, err := o.client.Bucket(bucketName)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Get object properties
-	props, err := bucket.GetObjectDetailedMeta(objectName)
+	props, err := bucket.GetObjectDetailedMeta(objectName, options...)
 	if err != nil {
 		return nil, err
 	}
 	
 	contentLength, _ := strconv.ParseInt(props.Get("Content-Length"), 10, 64)
 	
-	// Convert http.Header to map[string]string
-	metadata := make(map[string]string)
-	for k, v := range props {
-		if len(v) > 0 {
-			metadata[k] = v[0]
-		}
+	metadata := ossMetadata(props)
+	
+	checksums := make(map[string]string)
+	if sum := base64Checksum(props.Get("Content-Md5"), md5.Size); sum != "" {
+		checksums[ChecksumMD5] = sum
 	}
 	
+	// Last-Modified is an HTTP date; an unparsable one leaves the zero time
+	lastModified, _ := http.ParseTime(props.Get("Last-Modified"))
+	
 	return &FileObject{
 		Name:         objectName,
 		Size:         contentLength,
 		ContentType:  props.Get("Content-Type"),
-		LastModified: props.Get("Last-Modified"),
+		LastModified: lastModified,
+		ETag:         trimETag(props.Get("ETag")),
+		VersionID:    oss.GetVersionId(props),
 		Metadata:     metadata,
+		Headers:      headersFrom(props),
+		Checksums:    checksums,
+		StorageClass: props.Get("X-Oss-Storage-Class"),
+		RestoreStatus: restoreStatus(props.Get("X-Oss-Restore")),
 	}, nil
 }
 
+// UpdateMetadata replaces object metadata in OSS by copying the object onto itself
+func (o *OSSStorage) UpdateMetadata(ctx context.Context, bucketName, objectName string, metadata map[string]string, contentType string) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	// A metadata replace drops the content type and stored headers unless they are sent again
+	props, err := bucket.GetObjectDetailedMeta(objectName)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = props.Get("Content-Type")
+	}
+	
+	options := headerOptions(contentType, headersFrom(props))
+	for k, v := range metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+	
+	return bucket.SetObjectMeta(objectName, options...)
+}
+
+// Copy copies an object within an OSS bucket on the server, in parts when it
+// is larger than CopyObject allows
+func (o *OSSStorage) Copy(ctx context.Context, bucketName, srcObject, dstObject string, metadata map[string]string) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	props, err := bucket.GetObjectDetailedMeta(srcObject)
+	if err != nil {
+		return err
+	}
+	size, _ := strconv.ParseInt(props.Get("Content-Length"), 10, 64)
+	large := size > ossMaxCopySize
+	if large && metadata == nil {
+		// A copy in parts starts a new object that takes nothing from the source
+		metadata = ossMetadata(props)
+	}
+	
+	var options []oss.Option
+	if metadata != nil {
+		// A metadata replace drops the content type and stored headers unless they are sent again
+		options = headerOptions(props.Get("Content-Type"), headersFrom(props))
+		for k, v := range metadata {
+			options = append(options, oss.Meta(k, v))
+		}
+	}
+	if large {
+		return bucket.CopyFile(bucketName, srcObject, dstObject, copyPartSize, options...)
+	}
+	if metadata != nil {
+		options = append(options, oss.MetadataDirective(oss.MetaReplace))
+	}
+	_, err = bucket.CopyObject(srcObject, dstObject, options...)
+	return err
+}
+
 // CreateDirectory creates a directory in the storage
-func (o *OSSStorage) CreateDirectory(ctx context.Context, bucket, objectName string) error {
+func (o *OSSStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
 	bucketClient, err := o.client.Bucket(bucket)
 	if err != nil {
 		return err
@@ -185,7 +485,11 @@ func (o *OSSStorage) CreateDirectory(ctx context.Context, bucket, objectName str
 	}
 	
 	// Create an empty object to represent the directory
-	return bucketClient.PutObject(objectName, strings.NewReader(""), oss.ContentType("application/directory"))
+	options := []oss.Option{oss.ContentType("application/directory")}
+	for k, v := range metadata {
+		options = append(options, oss.Meta(k, v))
+	}
+	return bucketClient.PutObject(objectName, strings.NewReader(""), options...)
 }
 
 // ListDirectories lists directories in a bucket with the given prefix
@@ -221,6 +525,9 @@ func (o *OSSStorage) ListDirectories(ctx context.Context, bucket, prefix string)
 		}
 	}
 	
+	if err := fillDirectoryMetadata(ctx, o, bucket, dirs); err != nil {
+		return nil, err
+	}
 	return dirs, nil
 }
 
@@ -255,10 +562,296 @@ func (o *OSSStorage) EnsurePathExists(ctx context.Context, bucket, objectPath st
 	// OSS returns a specific error code when object doesn't exist
 	if ossErr, ok := err.(oss.ServiceError); ok {
 		if ossErr.Code == "NoSuchKey" {
-			return o.CreateDirectory(ctx, bucket, dir)
+			return o.CreateDirectory(ctx, bucket, dir, nil)
 		}
 	}
 	
 	// For other errors, return the error
 	return err
-}
\ No newline at end of file
+}
+
+// HealthCheck verifies that the OSS endpoint is reachable and the bucket
+// exists. GetBucketInfo only needs permission on the bucket itself, where
+// IsBucketExist lists buckets.
+func (o *OSSStorage) HealthCheck(ctx context.Context, bucket string) error {
+	_, err := o.client.GetBucketInfo(bucket)
+	if ossErr, ok := err.(oss.ServiceError); ok && ossErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("bucket %s: %w", bucket, ErrBucketNotFound)
+	}
+	return err
+}
+
+// Capabilities reports that OSS supports versioning, multipart uploads, server-side copies and object ACLs
+func (o *OSSStorage) Capabilities() Capabilities {
+	return Capabilities{Versioning: true, Multipart: true, ServerSideCopy: true, ACLScope: ACLScopeObject, AtomicCreate: true, Restore: true, PresignedURLs: true, MinPartSize: ossMinPartSize, Naming: ossNaming}
+}
+
+// SetACL sets the ACL of an object in OSS, which overrides the bucket ACL
+func (o *OSSStorage) SetACL(ctx context.Context, bucketName, objectName, acl string) error {
+	if acl != ACLPrivate && acl != ACLPublicRead {
+		return fmt.Errorf("unsupported ACL %q", acl)
+	}
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	return bucket.SetObjectACL(objectName, oss.ACLType(acl))
+}
+
+// RestoreObject starts restoring an object from an OSS archive class,
+// keeping the restored copy for days
+func (o *OSSStorage) RestoreObject(ctx context.Context, bucketName, objectName string, days int) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	return bucket.RestoreObjectDetail(objectName, oss.RestoreConfiguration{Days: int32(days)})
+}
+
+// PresignDownload returns a signed GET URL of an object in OSS
+func (o *OSSStorage) PresignDownload(ctx context.Context, bucketName, objectName string, expiry time.Duration) (string, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+	
+	return bucket.SignURL(objectName, oss.HTTPGet, int64(expiry/time.Second))
+}
+
+// PublicURL returns the unauthenticated URL of an object in OSS
+func (o *OSSStorage) PublicURL(bucketName, objectName string) string {
+	return virtualHostURL(o.client.Config.Endpoint, bucketName, objectName)
+}
+
+// ListBuckets lists all buckets in OSS, following the listing markers page by page
+func (o *OSSStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	result := make([]BucketInfo, 0)
+	marker := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		lsRes, err := o.client.ListBuckets(oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range lsRes.Buckets {
+			result = append(result, BucketInfo{Name: bucket.Name, CreationDate: bucket.CreationDate})
+		}
+		
+		if !lsRes.IsTruncated {
+			return result, nil
+		}
+		marker = lsRes.NextMarker
+	}
+}
+
+// BucketExists reports whether the bucket exists in OSS
+func (o *OSSStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return o.client.IsBucketExist(bucket)
+}
+
+// CreateBucket creates a new bucket in OSS
+func (o *OSSStorage) CreateBucket(ctx context.Context, bucket string) error {
+	return o.client.CreateBucket(bucket)
+}
+
+// DeleteBucket deletes an empty bucket from OSS
+func (o *OSSStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	return o.client.DeleteBucket(bucket)
+}
+
+// multipartUpload identifies an existing multipart upload for the OSS SDK
+func multipartUpload(bucketName, objectName, uploadID string) oss.InitiateMultipartUploadResult {
+	return oss.InitiateMultipartUploadResult{Bucket: bucketName, Key: objectName, UploadID: uploadID}
+}
+
+// InitMultipart starts a multipart upload in OSS
+func (o *OSSStorage) InitMultipart(ctx context.Context, bucketName, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+	
+	result, err := bucket.InitiateMultipartUpload(objectName, headerOptions(contentType, headers)...)
+	if err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart uploads one part of a multipart upload to OSS
+func (o *OSSStorage) UploadPart(ctx context.Context, bucketName, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return Part{}, err
+	}
+	
+	part, err := bucket.UploadPart(multipartUpload(bucketName, objectName, uploadID), reader, size, partNumber)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Number: part.PartNumber, ETag: trimETag(part.ETag), Size: size}, nil
+}
+
+// ListParts lists the uploaded parts of a multipart upload in OSS
+func (o *OSSStorage) ListParts(ctx context.Context, bucketName, objectName, uploadID string) ([]Part, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	
+	parts := make([]Part, 0)
+	marker := 0
+	for {
+		result, err := bucket.ListUploadedParts(multipartUpload(bucketName, objectName, uploadID), oss.PartNumberMarker(marker))
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range result.UploadedParts {
+			parts = append(parts, Part{Number: part.PartNumber, ETag: trimETag(part.ETag), Size: int64(part.Size)})
+		}
+		
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		if marker, err = strconv.Atoi(result.NextPartNumberMarker); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CompleteMultipart assembles the object from its uploaded parts in OSS
+func (o *OSSStorage) CompleteMultipart(ctx context.Context, bucketName, objectName, uploadID string) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	parts, err := o.ListParts(ctx, bucketName, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	
+	complete := make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		complete = append(complete, oss.UploadPart{PartNumber: part.Number, ETag: part.ETag})
+	}
+	_, err = bucket.CompleteMultipartUpload(multipartUpload(bucketName, objectName, uploadID), complete)
+	return err
+}
+
+// AbortMultipart discards a multipart upload in OSS
+func (o *OSSStorage) AbortMultipart(ctx context.Context, bucketName, objectName, uploadID string) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	return bucket.AbortMultipartUpload(multipartUpload(bucketName, objectName, uploadID))
+}
+
+// ListMultipartUploads lists the multipart uploads in progress in OSS
+func (o *OSSStorage) ListMultipartUploads(ctx context.Context, bucketName, prefix string) ([]MultipartUpload, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	
+	uploads := make([]MultipartUpload, 0)
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		result, err := bucket.ListMultipartUploads(oss.Prefix(prefix), oss.KeyMarker(keyMarker), oss.UploadIDMarker(uploadIDMarker))
+		if err != nil {
+			return nil, err
+		}
+		for _, upload := range result.Uploads {
+			uploads = append(uploads, MultipartUpload{Object: upload.Key, UploadID: upload.UploadID, Initiated: upload.Initiated})
+		}
+		
+		if !result.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+}
+
+// DownloadVersion downloads a specific version of a file from OSS
+func (o *OSSStorage) DownloadVersion(ctx context.Context, bucketName, objectName, versionID string) (io.ReadCloser, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	
+	return bucket.GetObject(objectName, oss.VersionId(versionID))
+}
+
+// GetObjectVersionInfo gets metadata of a specific version of an object from OSS
+func (o *OSSStorage) GetObjectVersionInfo(ctx context.Context, bucketName, objectName, versionID string) (*FileObject, error) {
+	return o.objectInfo(bucketName, objectName, oss.VersionId(versionID))
+}
+
+// DeleteVersion permanently deletes a specific version of a file from OSS
+func (o *OSSStorage) DeleteVersion(ctx context.Context, bucketName, objectName, versionID string) error {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	
+	return bucket.DeleteObject(objectName, oss.VersionId(versionID))
+}
+
+// ListVersions lists all versions and delete markers of objects in OSS, page by page
+func (o *OSSStorage) ListVersions(ctx context.Context, bucketName, prefix string) ([]ObjectVersion, error) {
+	bucket, err := o.client.Bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	
+	versions := make([]ObjectVersion, 0)
+	keyMarker, versionIDMarker := "", ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		
+		result, err := bucket.ListObjectVersions(oss.Prefix(prefix), oss.KeyMarker(keyMarker), oss.VersionIdMarker(versionIDMarker))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range result.ObjectVersions {
+			versions = append(versions, ObjectVersion{
+				FileObject: FileObject{
+					Name:         version.Key,
+					Size:         version.Size,
+					ContentType:  version.Type,
+					LastModified: version.LastModified,
+					ETag:         trimETag(version.ETag),
+					VersionID:    version.VersionId,
+					Metadata:     make(map[string]string),
+				},
+				IsLatest: version.IsLatest,
+			})
+		}
+		for _, marker := range result.ObjectDeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				FileObject: FileObject{
+					Name:         marker.Key,
+					LastModified: marker.LastModified,
+					VersionID:    marker.VersionId,
+					Metadata:     make(map[string]string),
+				},
+				IsLatest:       marker.IsLatest,
+				IsDeleteMarker: true,
+			})
+		}
+		
+		if !result.IsTruncated {
+			return versions, nil
+		}
+		keyMarker, versionIDMarker = result.NextKeyMarker, result.NextVersionIdMarker
+	}
+}
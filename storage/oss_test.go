@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newPagingOSSServer fakes an OSS bucket holding count objects, answering
+// ListObjectsV2 with pages of at most 1000 keys
+func newPagingOSSServer(t *testing.T, count int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("list-type") != "2" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		start := 0
+		if token := query.Get("continuation-token"); token != "" {
+			start, _ = strconv.Atoi(token)
+		}
+		end := min(start+1000, count)
+
+		var page strings.Builder
+		fmt.Fprintf(&page, "<ListBucketResult><Name>big</Name><KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>", end-start, end < count)
+		if end < count {
+			fmt.Fprintf(&page, "<NextContinuationToken>%d</NextContinuationToken>", end)
+		}
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&page, `<Contents><Key>logs/%05d.json</Key><LastModified>2024-03-01T10:00:00.000Z</LastModified><ETag>"etag"</ETag><Size>1</Size><StorageClass>Standard</StorageClass></Contents>`, i)
+		}
+		page.WriteString("</ListBucketResult>")
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, page.String())
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOSSListFollowsContinuationTokens(t *testing.T) {
+	const count = 2500
+	server := newPagingOSSServer(t, count)
+	store, err := NewOSSStorage(strings.TrimPrefix(server.URL, "http://"), "key", "secret", false, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := store.List(context.Background(), "big", "logs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != count {
+		t.Fatalf("List returned %d objects, want %d", len(objects), count)
+	}
+	if first, last := objects[0].Name, objects[count-1].Name; first != "logs/00000.json" || last != "logs/02499.json" {
+		t.Errorf("List returned %s to %s, want logs/00000.json to logs/02499.json", first, last)
+	}
+}
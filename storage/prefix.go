@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// prefixedStorage confines every object operation to keys under a prefix.
+// Callers use keys relative to the prefix and never see it in results.
+type prefixedStorage struct {
+	inner  Storage
+	prefix string
+}
+
+// prefixedMultipart confines the operations of a MultipartStorage to a prefix
+type prefixedMultipart struct {
+	inner  MultipartStorage
+	prefix string
+}
+
+// prefixedVersioned confines the operations of a VersionedStorage to a prefix
+type prefixedVersioned struct {
+	inner  VersionedStorage
+	prefix string
+}
+
+// WithKeyPrefix returns a view of s in which every object key is taken
+// relative to prefix: it is prepended to keys and listing prefixes on the
+// way in and stripped from returned names, so nothing outside it can be
+// reached. Bucket operations are passed through unchanged. The result keeps
+// implementing MultipartStorage and VersionedStorage when s does, and
+// ObjectLockStorage and ACLStorage when s also implements both of those, as
+// every backend with object locks or ACLs does. ACLStorage is only kept
+// together with RestoreStorage and PresignStorage, which every backend with
+// ACLs implements as well. An empty prefix returns s unchanged.
+func WithKeyPrefix(s Storage, prefix string) Storage {
+	if prefix == "" {
+		return s
+	}
+	base := &prefixedStorage{inner: s, prefix: prefix}
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			*prefixedStorage
+			*prefixedMultipart
+			*prefixedVersioned
+			*prefixedObjectLock
+			*prefixedACL
+			*prefixedRestore
+			*prefixedPresign
+		}{base, &prefixedMultipart{inner: multipart, prefix: prefix}, &prefixedVersioned{inner: versioned, prefix: prefix}, &prefixedObjectLock{inner: locking, prefix: prefix}, &prefixedACL{inner: acl, prefix: prefix}, &prefixedRestore{inner: restore, prefix: prefix}, &prefixedPresign{inner: presign, prefix: prefix}}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			*prefixedStorage
+			*prefixedMultipart
+			*prefixedVersioned
+			*prefixedObjectLock
+		}{base, &prefixedMultipart{inner: multipart, prefix: prefix}, &prefixedVersioned{inner: versioned, prefix: prefix}, &prefixedObjectLock{inner: locking, prefix: prefix}}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			*prefixedStorage
+			*prefixedMultipart
+			*prefixedVersioned
+			*prefixedACL
+			*prefixedRestore
+			*prefixedPresign
+		}{base, &prefixedMultipart{inner: multipart, prefix: prefix}, &prefixedVersioned{inner: versioned, prefix: prefix}, &prefixedACL{inner: acl, prefix: prefix}, &prefixedRestore{inner: restore, prefix: prefix}, &prefixedPresign{inner: presign, prefix: prefix}}
+	case isMultipart && isVersioned:
+		return &struct {
+			*prefixedStorage
+			*prefixedMultipart
+			*prefixedVersioned
+		}{base, &prefixedMultipart{inner: multipart, prefix: prefix}, &prefixedVersioned{inner: versioned, prefix: prefix}}
+	case isMultipart:
+		return &struct {
+			*prefixedStorage
+			*prefixedMultipart
+		}{base, &prefixedMultipart{inner: multipart, prefix: prefix}}
+	case isVersioned:
+		return &struct {
+			*prefixedStorage
+			*prefixedVersioned
+		}{base, &prefixedVersioned{inner: versioned, prefix: prefix}}
+	}
+	return base
+}
+
+// strip removes the prefix from a returned object
+func strip(prefix string, obj FileObject) FileObject {
+	obj.Name = strings.TrimPrefix(obj.Name, prefix)
+	return obj
+}
+
+// stripAll removes the prefix from returned objects, dropping the directory
+// marker of the prefix itself
+func stripAll(prefix string, objects []FileObject) []FileObject {
+	kept := objects[:0]
+	for _, obj := range objects {
+		if obj = strip(prefix, obj); obj.Name != "" {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+func (p *prefixedStorage) Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	return p.inner.Upload(ctx, bucket, p.prefix+objectName, reader, size, contentType, headers)
+}
+
+func (p *prefixedStorage) Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error) {
+	return p.inner.Download(ctx, bucket, p.prefix+objectName)
+}
+
+func (p *prefixedStorage) DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error) {
+	return p.inner.DownloadRange(ctx, bucket, p.prefix+objectName, offset, length)
+}
+
+func (p *prefixedStorage) UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error) {
+	return p.inner.UploadIfMatch(ctx, bucket, p.prefix+objectName, reader, size, contentType, headers, etag)
+}
+
+func (p *prefixedStorage) UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error) {
+	return p.inner.UploadIfNotExists(ctx, bucket, p.prefix+objectName, reader, size, contentType, headers)
+}
+
+func (p *prefixedStorage) Delete(ctx context.Context, bucket, objectName string) error {
+	return p.inner.Delete(ctx, bucket, p.prefix+objectName)
+}
+
+func (p *prefixedStorage) DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error {
+	return p.inner.DeleteIfMatch(ctx, bucket, p.prefix+objectName, etag)
+}
+
+func (p *prefixedStorage) List(ctx context.Context, bucket string, prefix string) ([]FileObject, error) {
+	objects, err := p.inner.List(ctx, bucket, p.prefix+prefix)
+	return stripAll(p.prefix, objects), err
+}
+
+func (p *prefixedStorage) Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error {
+	return p.inner.Walk(ctx, bucket, p.prefix+prefix, func(obj FileObject) error {
+		if obj = strip(p.prefix, obj); obj.Name == "" {
+			return nil
+		}
+		return fn(obj)
+	})
+}
+
+func (p *prefixedStorage) GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error) {
+	info, err := p.inner.GetObjectInfo(ctx, bucket, p.prefix+objectName)
+	if info != nil {
+		*info = strip(p.prefix, *info)
+	}
+	return info, err
+}
+
+func (p *prefixedStorage) UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error {
+	return p.inner.UpdateMetadata(ctx, bucket, p.prefix+objectName, metadata, contentType)
+}
+
+func (p *prefixedStorage) Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error {
+	return p.inner.Copy(ctx, bucket, p.prefix+srcObject, p.prefix+dstObject, metadata)
+}
+
+func (p *prefixedStorage) CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error {
+	return p.inner.CreateDirectory(ctx, bucket, p.prefix+objectName, metadata)
+}
+
+func (p *prefixedStorage) ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	dirs, err := p.inner.ListDirectories(ctx, bucket, p.prefix+prefix)
+	return stripAll(p.prefix, dirs), err
+}
+
+func (p *prefixedStorage) ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error) {
+	objects, err := p.inner.ListObjects(ctx, bucket, p.prefix+prefix)
+	return stripAll(p.prefix, objects), err
+}
+
+func (p *prefixedStorage) EnsurePathExists(ctx context.Context, bucket, objectPath string) error {
+	return p.inner.EnsurePathExists(ctx, bucket, p.prefix+objectPath)
+}
+
+func (p *prefixedStorage) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return p.inner.BucketExists(ctx, bucket)
+}
+
+func (p *prefixedStorage) CreateBucket(ctx context.Context, bucket string) error {
+	return p.inner.CreateBucket(ctx, bucket)
+}
+
+func (p *prefixedStorage) DeleteBucket(ctx context.Context, bucket string) error {
+	return p.inner.DeleteBucket(ctx, bucket)
+}
+
+func (p *prefixedStorage) ListBuckets(ctx context.Context) ([]BucketInfo, error) {
+	return p.inner.ListBuckets(ctx)
+}
+
+func (p *prefixedStorage) HealthCheck(ctx context.Context, bucket string) error {
+	return p.inner.HealthCheck(ctx, bucket)
+}
+
+func (p *prefixedStorage) Capabilities() Capabilities {
+	return p.inner.Capabilities()
+}
+
+func (p *prefixedMultipart) InitMultipart(ctx context.Context, bucket, objectName, contentType string, headers ObjectHeaders) (string, error) {
+	return p.inner.InitMultipart(ctx, bucket, p.prefix+objectName, contentType, headers)
+}
+
+func (p *prefixedMultipart) UploadPart(ctx context.Context, bucket, objectName, uploadID string, partNumber int, reader io.Reader, size int64) (Part, error) {
+	return p.inner.UploadPart(ctx, bucket, p.prefix+objectName, uploadID, partNumber, reader, size)
+}
+
+func (p *prefixedMultipart) ListParts(ctx context.Context, bucket, objectName, uploadID string) ([]Part, error) {
+	return p.inner.ListParts(ctx, bucket, p.prefix+objectName, uploadID)
+}
+
+func (p *prefixedMultipart) CompleteMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return p.inner.CompleteMultipart(ctx, bucket, p.prefix+objectName, uploadID)
+}
+
+func (p *prefixedMultipart) AbortMultipart(ctx context.Context, bucket, objectName, uploadID string) error {
+	return p.inner.AbortMultipart(ctx, bucket, p.prefix+objectName, uploadID)
+}
+
+func (p *prefixedMultipart) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	uploads, err := p.inner.ListMultipartUploads(ctx, bucket, p.prefix+prefix)
+	for i := range uploads {
+		uploads[i].Object = strings.TrimPrefix(uploads[i].Object, p.prefix)
+	}
+	return uploads, err
+}
+
+func (p *prefixedVersioned) DownloadVersion(ctx context.Context, bucket, objectName, versionID string) (io.ReadCloser, error) {
+	return p.inner.DownloadVersion(ctx, bucket, p.prefix+objectName, versionID)
+}
+
+func (p *prefixedVersioned) GetObjectVersionInfo(ctx context.Context, bucket, objectName, versionID string) (*FileObject, error) {
+	info, err := p.inner.GetObjectVersionInfo(ctx, bucket, p.prefix+objectName, versionID)
+	if info != nil {
+		*info = strip(p.prefix, *info)
+	}
+	return info, err
+}
+
+func (p *prefixedVersioned) DeleteVersion(ctx context.Context, bucket, objectName, versionID string) error {
+	return p.inner.DeleteVersion(ctx, bucket, p.prefix+objectName, versionID)
+}
+
+func (p *prefixedVersioned) ListVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error) {
+	versions, err := p.inner.ListVersions(ctx, bucket, p.prefix+prefix)
+	for i := range versions {
+		versions[i].FileObject = strip(p.prefix, versions[i].FileObject)
+	}
+	return versions, err
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// MaxPresignExpiry is the longest validity of a presigned URL that every
+// backend accepts; SigV4 presigned URLs expire after at most seven days
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// PresignStorage is implemented by backends that can sign a URL from which an
+// object is downloaded straight from the provider, without credentials, until
+// the URL expires. MinIO, S3-compatible services, OSS and OBS sign with the
+// configured keys; Azure signs a SAS and needs the account key for it. The
+// in-memory backend doesn't implement it.
+type PresignStorage interface {
+	// PresignDownload returns a URL for downloading an object that is valid
+	// for expiry. URLs are signed locally, so the object isn't looked up and
+	// may not exist.
+	PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error)
+}
+
+// prefixedPresign confines the operations of a PresignStorage to a prefix
+type prefixedPresign struct {
+	inner  PresignStorage
+	prefix string
+}
+
+func (p *prefixedPresign) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	return p.inner.PresignDownload(ctx, bucket, p.prefix+objectName, expiry)
+}
+
+// lowercasePresign folds the keys of a PresignStorage to lower case
+type lowercasePresign struct {
+	inner PresignStorage
+}
+
+func (l *lowercasePresign) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	return l.inner.PresignDownload(ctx, bucket, strings.ToLower(objectName), expiry)
+}
+
+// auditPresign logs the operations of a PresignStorage
+type auditPresign struct {
+	inner PresignStorage
+	log   *AuditLogger
+}
+
+func (a *auditPresign) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	start := time.Now()
+	signed, err := a.inner.PresignDownload(ctx, bucket, objectName, expiry)
+	a.log.record(ctx, "presign_download", bucket, objectName, 0, start, err)
+	return signed, err
+}
+
+// errorPresign wraps the errors of a PresignStorage
+type errorPresign struct {
+	inner   PresignStorage
+	backend string
+}
+
+func (e *errorPresign) PresignDownload(ctx context.Context, bucket, objectName string, expiry time.Duration) (string, error) {
+	signed, err := e.inner.PresignDownload(ctx, bucket, objectName, expiry)
+	return signed, wrapError(e.backend, "presign_download", bucket, objectName, err)
+}
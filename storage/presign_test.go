@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// parseSigned parses a presigned URL, failing the test unless it is an
+// absolute https URL of path carrying the query parameters in want
+func parseSigned(t *testing.T, signed, host, path string, want ...string) url.Values {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("presigned URL %q: %v", signed, err)
+	}
+	if u.Scheme != "https" || u.Host != host || u.Path != path {
+		t.Errorf("presigned URL %q, want https://%s%s", signed, host, path)
+	}
+	query := u.Query()
+	for _, name := range want {
+		if query.Get(name) == "" {
+			t.Errorf("presigned URL %q has no %s", signed, name)
+		}
+	}
+	return query
+}
+
+func TestMinIOPresignDownload(t *testing.T) {
+	// With the region configured, signing needs no request to the service
+	store, err := NewS3CompatStorage("s3.example.com", "us-east-1", "AKID", "SECRET", true, true, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := store.PresignDownload(context.Background(), "photos", "2024/a b.jpg", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parseSigned(t, signed, "s3.example.com", "/photos/2024/a b.jpg", "X-Amz-Signature", "X-Amz-Credential")
+	if got := query.Get("X-Amz-Expires"); got != "900" {
+		t.Errorf("X-Amz-Expires = %q, want 900", got)
+	}
+}
+
+func TestPresignDownloadThroughViews(t *testing.T) {
+	store, err := NewS3CompatStorage("s3.example.com", "us-east-1", "AKID", "SECRET", true, true, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := WithKeyPrefix(WithLowercaseKeys(withErrors(store, "s3")), "tenants/acme/")
+	signer, ok := view.(PresignStorage)
+	if !ok {
+		t.Fatal("view of a presigning backend doesn't implement PresignStorage")
+	}
+	signed, err := signer.PresignDownload(context.Background(), "photos", "Report.PDF", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseSigned(t, signed, "s3.example.com", "/photos/tenants/acme/report.pdf", "X-Amz-Signature")
+}
+
+func TestOSSPresignDownload(t *testing.T) {
+	store, err := NewOSSStorage("oss-cn-hangzhou.aliyuncs.com", "AKID", "SECRET", true, HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := store.PresignDownload(context.Background(), "photos", "2024/a.jpg", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parseSigned(t, signed, "photos.oss-cn-hangzhou.aliyuncs.com", "/2024/a.jpg", "Signature", "Expires")
+}
+
+func TestAzurePresignDownload(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("account key"))
+	store, err := NewAzureStorage("acct", key, "https://acct.blob.core.windows.net", HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.Capabilities().PresignedURLs {
+		t.Error("Capabilities().PresignedURLs = false with an account key")
+	}
+	signed, err := store.PresignDownload(context.Background(), "photos", "2024/a.jpg", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parseSigned(t, signed, "acct.blob.core.windows.net", "/photos/2024/a.jpg", "sig", "se")
+	if got := query.Get("sp"); got != "r" {
+		t.Errorf("sp = %q, want read-only r", got)
+	}
+}
+
+func TestAzurePresignDownloadWithoutAccountKey(t *testing.T) {
+	store, err := NewAzureStorageFromConnectionString("BlobEndpoint=https://acct.blob.core.windows.net/;SharedAccessSignature=sv=2022-11-02&sig=abc", HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Capabilities().PresignedURLs {
+		t.Error("Capabilities().PresignedURLs = true without an account key")
+	}
+	if _, err := store.PresignDownload(context.Background(), "photos", "a.jpg", time.Hour); err != ErrNotSupported {
+		t.Errorf("PresignDownload = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Factory creates a backend from its configuration section, decoded into a
+// map keyed by the lowercased config keys
+type Factory func(cfg map[string]any) (Storage, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a backend available as the storage type name. It is meant
+// to be called from an init function, the way the built-in backends register
+// themselves, and panics if the name is taken or factory is nil.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("storage: Register factory is nil for " + name)
+	}
+	if _, exists := factories[name]; exists {
+		panic("storage: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates a backend of the registered storage type typ from its configuration
+func New(typ string, cfg map[string]any) (Storage, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[typ]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage type: %s (registered: %s)", typ, strings.Join(Types(), ", "))
+	}
+	s, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return withErrors(s, typ), nil
+}
+
+// Types returns the registered storage types in sorted order
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for name := range factories {
+		types = append(types, name)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// DecodeOptions decodes a backend configuration section into out, a pointer
+// to a struct with mapstructure tags. Strings are converted to durations and
+// other basic types as the config loader does.
+func DecodeOptions(cfg map[string]any, out any) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("invalid storage options: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Restore states reported in FileObject.RestoreStatus
+const (
+	RestoreOngoing   = "ongoing"  // a restore has been requested and is in progress
+	RestoreCompleted = "restored" // a restored copy can be downloaded until it expires
+)
+
+// ErrObjectArchived is returned when an object in an archive tier is read
+// before it has been restored
+var ErrObjectArchived = errors.New("object is in an archive tier and must be restored before it can be read")
+
+// archiveClasses lists the storage classes, as the backends report them,
+// whose objects must be restored before they can be read: S3 Glacier
+// Flexible Retrieval and Deep Archive, the OSS archive classes, OBS Cold and
+// Deep Archive, and the Azure Archive tier
+var archiveClasses = map[string]bool{
+	"GLACIER":         true,
+	"DEEP_ARCHIVE":    true,
+	"Archive":         true,
+	"ColdArchive":     true,
+	"DeepColdArchive": true,
+	"COLD":            true,
+}
+
+// RestoreStorage is implemented by backends whose objects can be moved to an
+// archive tier, where they have to be restored before they can be downloaded
+type RestoreStorage interface {
+	// RestoreObject starts restoring an archived object. The restored copy
+	// stays readable for days on providers that keep it temporarily; Azure
+	// moves the blob back to the Hot tier for good and ignores days. A restore
+	// takes minutes to hours, and the object's RestoreStatus tells when it is done.
+	RestoreObject(ctx context.Context, bucket, objectName string, days int) error
+}
+
+// NeedsRestore reports whether an object is in an archive tier without a
+// completed restore, so downloading it would fail
+func (f FileObject) NeedsRestore() bool {
+	return archiveClasses[f.StorageClass] && f.RestoreStatus != RestoreCompleted
+}
+
+// restoreStatus converts the restore header S3, OSS and OBS send with
+// archived objects, such as ongoing-request="false", expiry-date="...", to
+// RestoreOngoing or RestoreCompleted; it is empty until a restore is requested
+func restoreStatus(header string) string {
+	switch {
+	case header == "":
+		return ""
+	case strings.Contains(header, `ongoing-request="true"`):
+		return RestoreOngoing
+	}
+	return RestoreCompleted
+}
+
+// IsObjectArchived reports whether err returned by a backend means the
+// object has to be restored from an archive tier before it can be read
+func IsObjectArchived(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, ErrObjectArchived) {
+		return true
+	}
+
+	// S3, OSS and OBS answer InvalidObjectState, Azure BlobArchived
+	switch errorCode(err) {
+	case "InvalidObjectState", "BlobArchived":
+		return true
+	}
+	return false
+}
+
+// prefixedRestore confines the operations of a RestoreStorage to a prefix
+type prefixedRestore struct {
+	inner  RestoreStorage
+	prefix string
+}
+
+func (p *prefixedRestore) RestoreObject(ctx context.Context, bucket, objectName string, days int) error {
+	return p.inner.RestoreObject(ctx, bucket, p.prefix+objectName, days)
+}
+
+// auditRestore logs the operations of a RestoreStorage
+type auditRestore struct {
+	inner RestoreStorage
+	log   *AuditLogger
+}
+
+func (a *auditRestore) RestoreObject(ctx context.Context, bucket, objectName string, days int) error {
+	start := time.Now()
+	err := a.inner.RestoreObject(ctx, bucket, objectName, days)
+	a.log.record(ctx, "restore_object", bucket, objectName, 0, start, err)
+	return err
+}
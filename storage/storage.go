@@ -2,7 +2,12 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
 // FileObject represents a file object in the storage system
@@ -10,34 +15,319 @@ type FileObject struct {
 	Name         string
 	Size         int64
 	ContentType  string
-	LastModified string
+	LastModified time.Time // zero when the backend doesn't report it
+	ETag         string // 不带引号的实体标签
+	VersionID    string // 版本ID, empty when the bucket isn't versioned
 	Metadata     map[string]string
+	Headers      ObjectHeaders // 下载时返回的标准HTTP头
+	Checksums    map[string]string // hex digests of the content stored by the backend, keyed by ChecksumMD5, ChecksumSHA1 or ChecksumSHA256
+	StorageClass string // storage class or access tier as the backend names it, empty when not reported
+	RestoreStatus string // RestoreOngoing or RestoreCompleted for archived objects being or having been restored
 	IsDir        bool // 标识是否为目录
 }
 
+// ObjectHeaders are the standard HTTP headers stored with an object and sent
+// back when it is downloaded; empty fields aren't stored
+type ObjectHeaders struct {
+	CacheControl    string
+	ContentEncoding string
+	ContentLanguage string
+}
+
+// headersFrom extracts the stored standard headers from an object's response headers
+func headersFrom(header http.Header) ObjectHeaders {
+	return ObjectHeaders{
+		CacheControl:    header.Get("Cache-Control"),
+		ContentEncoding: header.Get("Content-Encoding"),
+		ContentLanguage: header.Get("Content-Language"),
+	}
+}
+
+// LastModifiedString returns LastModified in the RFC 3339 format FileObject
+// used before it carried a time.Time, or "" when it is unknown
+func (f FileObject) LastModifiedString() string {
+	if f.LastModified.IsZero() {
+		return ""
+	}
+	return f.LastModified.UTC().Format(time.RFC3339)
+}
+
+// BucketInfo describes a bucket (or Azure container)
+type BucketInfo struct {
+	Name         string
+	CreationDate time.Time // Azure reports the last modification time instead
+}
+
+// UploadResult describes an object as stored by an upload. Fields a backend
+// doesn't report are left empty.
+type UploadResult struct {
+	ETag      string // 不带引号的实体标签
+	VersionID string // 版本ID, empty when the bucket isn't versioned
+	Size      int64
+}
+
+// Capabilities reports which optional features a backend implements, so
+// clients can hide operations that would fail. No backend implements object
+// tagging yet.
+type Capabilities struct {
+	Versioning     bool   // 对象版本可以列出、读取和删除 (VersionedStorage)
+	Multipart      bool   // resumable uploads (MultipartStorage)
+	ServerSideCopy bool   // Copy doesn't stream the object through the service
+	ObjectLock     bool   // retention and legal holds (ObjectLockStorage)
+	ACLScope       string // what SetACL changes, ACLScopeObject or ACLScopeBucket (ACLStorage); empty without ACLs
+	AtomicCreate   bool   // UploadIfNotExists is a single conditional write, not a check followed by a write
+	ListMetadata   bool   // List and Walk include user metadata when asked to with WithListMetadata
+	Restore        bool   // archived objects can be restored (RestoreStorage)
+	PresignedURLs  bool   // direct download URLs can be signed (PresignStorage)
+	MinPartSize    int64  // smallest part but the last that CompleteMultipart accepts; 0 for any size
+	Tagging        bool
+	Naming         NamingRules
+}
+
 // Storage interface defines the methods that all storage providers must implement
 type Storage interface {
-	// Upload uploads a file to the storage
-	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string) error
+	// Upload uploads a file to the storage, storing headers alongside its content type
+	Upload(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error)
 	
 	// Download downloads a file from the storage
 	Download(ctx context.Context, bucket, objectName string) (io.ReadCloser, error)
 	
+	// DownloadRange downloads length bytes of a file starting at offset, or
+	// everything from offset on when length is negative
+	DownloadRange(ctx context.Context, bucket, objectName string, offset, length int64) (io.ReadCloser, error)
+	
+	// UploadIfMatch uploads a file only if the existing object's ETag equals etag
+	// ("*" matches any existing object), returning ErrPreconditionFailed otherwise
+	UploadIfMatch(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders, etag string) (*UploadResult, error)
+	
+	// UploadIfNotExists uploads a file only if no object is stored under
+	// objectName yet, returning ErrObjectExists otherwise
+	UploadIfNotExists(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, contentType string, headers ObjectHeaders) (*UploadResult, error)
+	
 	// Delete deletes a file from the storage
 	Delete(ctx context.Context, bucket, objectName string) error
 	
+	// DeleteIfMatch deletes a file only if its ETag equals etag,
+	// returning ErrPreconditionFailed otherwise
+	DeleteIfMatch(ctx context.Context, bucket, objectName, etag string) error
+	
 	// List lists objects in a bucket
 	List(ctx context.Context, bucket string, prefix string) ([]FileObject, error)
 	
+	// ListObjects lists the directories and files directly under prefix
+	// without descending into them, as a delimiter listing does: directories
+	// come first, with IsDir set and a trailing slash, then files, each in
+	// name order. A directory marker for the prefix itself is left out.
+	ListObjects(ctx context.Context, bucket, prefix string) ([]FileObject, error)
+	
+	// Walk calls fn for each object in a bucket with the given prefix, fetching
+	// the listing page by page instead of buffering it; an error from fn stops the walk
+	Walk(ctx context.Context, bucket, prefix string, fn func(FileObject) error) error
+	
 	// GetObjectInfo gets metadata of an object
 	GetObjectInfo(ctx context.Context, bucket, objectName string) (*FileObject, error)
 	
-	// CreateDirectory creates a directory in the storage
-	CreateDirectory(ctx context.Context, bucket, objectName string) error
+	// UpdateMetadata replaces the user metadata of an existing object without
+	// re-uploading it; an empty contentType keeps the current content type
+	UpdateMetadata(ctx context.Context, bucket, objectName string, metadata map[string]string, contentType string) error
+	
+	// Copy copies an object within a bucket on the server, keeping its content
+	// type. A non-nil metadata replaces the copy's user metadata; nil keeps the source's.
+	Copy(ctx context.Context, bucket, srcObject, dstObject string, metadata map[string]string) error
+	
+	// CreateDirectory creates a directory in the storage, storing metadata as
+	// the user metadata of its marker object; nil stores none
+	CreateDirectory(ctx context.Context, bucket, objectName string, metadata map[string]string) error
 	
-	// ListDirectories lists directories in a bucket with the given prefix
+	// ListDirectories lists directories in a bucket with the given prefix. The
+	// metadata of their markers is filled in when ctx was prepared by
+	// WithListMetadata, with an empty map for directories without a marker.
 	ListDirectories(ctx context.Context, bucket, prefix string) ([]FileObject, error)
 	
 	// EnsurePathExists ensures that all directories in the given path exist
 	EnsurePathExists(ctx context.Context, bucket, objectPath string) error
-}
\ No newline at end of file
+	
+	// BucketExists reports whether the bucket exists
+	BucketExists(ctx context.Context, bucket string) (bool, error)
+	
+	// CreateBucket creates a new bucket
+	CreateBucket(ctx context.Context, bucket string) error
+	
+	// DeleteBucket deletes an empty bucket
+	DeleteBucket(ctx context.Context, bucket string) error
+	
+	// ListBuckets lists all buckets visible to the configured credentials
+	ListBuckets(ctx context.Context) ([]BucketInfo, error)
+	
+	// HealthCheck performs a lightweight probe to verify the backend is
+	// reachable and bucket exists, without needing permission to list buckets
+	HealthCheck(ctx context.Context, bucket string) error
+	
+	// Capabilities reports the optional features the backend implements
+	Capabilities() Capabilities
+}
+
+// withOptional returns base extended by the optional interfaces s implements,
+// for views that only change Storage methods and pass the rest through to s.
+// A view that has to change VersionedStorage or PresignStorage too implements
+// it on base, which is then used in place of that of s.
+func withOptional(base, s Storage) Storage {
+	multipart, isMultipart := s.(MultipartStorage)
+	versioned, isVersioned := s.(VersionedStorage)
+	if own, ok := base.(VersionedStorage); ok && isVersioned {
+		versioned = own
+	}
+	locking, isLocking := s.(ObjectLockStorage)
+	acl, isACL := s.(ACLStorage)
+	restore, isRestore := s.(RestoreStorage)
+	presign, isPresign := s.(PresignStorage)
+	if own, ok := base.(PresignStorage); ok && isPresign {
+		presign = own
+	}
+	switch {
+	case isMultipart && isVersioned && isLocking && isACL && isRestore && isPresign:
+		return &struct {
+			Storage
+			MultipartStorage
+			VersionedStorage
+			ObjectLockStorage
+			ACLStorage
+			RestoreStorage
+			PresignStorage
+		}{base, multipart, versioned, locking, acl, restore, presign}
+	case isMultipart && isVersioned && isLocking:
+		return &struct {
+			Storage
+			MultipartStorage
+			VersionedStorage
+			ObjectLockStorage
+		}{base, multipart, versioned, locking}
+	case isMultipart && isVersioned && isACL && isRestore && isPresign:
+		return &struct {
+			Storage
+			MultipartStorage
+			VersionedStorage
+			ACLStorage
+			RestoreStorage
+			PresignStorage
+		}{base, multipart, versioned, acl, restore, presign}
+	case isMultipart && isVersioned:
+		return &struct {
+			Storage
+			MultipartStorage
+			VersionedStorage
+		}{base, multipart, versioned}
+	case isMultipart:
+		return &struct {
+			Storage
+			MultipartStorage
+		}{base, multipart}
+	case isVersioned:
+		return &struct {
+			Storage
+			VersionedStorage
+		}{base, versioned}
+	}
+	return base
+}
+
+// listAll collects a full listing for backends whose List is built on Walk
+func listAll(ctx context.Context, s Storage, bucket, prefix string) ([]FileObject, error) {
+	var objects []FileObject
+	err := s.Walk(ctx, bucket, prefix, func(obj FileObject) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// endpointURL returns endpoint with the scheme useSSL selects, replacing any
+// scheme it was configured with, for SDKs that take the scheme from the URL
+func endpointURL(endpoint string, useSSL bool) string {
+	host := endpoint
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		host = endpoint[i+len("://"):]
+	}
+	if useSSL {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+// checkETag compares the current ETag of an object with etag, where "*" matches
+// any existing object, for backends
+// without native conditional writes. The check and the following write are
+// separate requests, so a concurrent writer can still slip in between them.
+func checkETag(ctx context.Context, s Storage, bucket, objectName, etag string) error {
+	info, err := s.GetObjectInfo(ctx, bucket, objectName)
+	if err != nil {
+		if IsNotFound(err) {
+			return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrPreconditionFailed)
+		}
+		return err
+	}
+	if etag != "*" && info.ETag != trimETag(etag) {
+		return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// checkAbsent fails with ErrObjectExists when an object is stored under
+// objectName, for backends without a native create-only write. Like
+// checkETag, a concurrent writer can still slip in before the write.
+func checkAbsent(ctx context.Context, s Storage, bucket, objectName string) error {
+	_, err := s.GetObjectInfo(ctx, bucket, objectName)
+	if err == nil {
+		return fmt.Errorf("object %s/%s: %w", bucket, objectName, ErrObjectExists)
+	}
+	if IsNotFound(err) && !errors.Is(err, ErrBucketNotFound) {
+		return nil
+	}
+	return err
+}
+
+// countingReader counts the bytes read through it, for backends whose upload
+// response doesn't include the stored size
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader and adds to the count
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// trimETag strips the surrounding quotes backends include in ETag values
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// rangeSpec renders a byte range as the part of a Range header after "bytes="
+func rangeSpec(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("%d-", offset)
+	}
+	return fmt.Sprintf("%d-%d", offset, offset+length-1)
+}
+
+// limitRange narrows a full object stream to a byte range by skipping to
+// offset, for backends and views that can't request a range themselves
+func limitRange(reader io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if _, err := io.CopyN(io.Discard, reader, offset); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return reader, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Timestamps in the formats providers send them: ISO 8601 with milliseconds
+// in S3-style XML listings, and HTTP dates in headers and Azure listings
+const (
+	isoTimestamp  = "2024-03-01T10:00:00.000Z"
+	httpTimestamp = "Fri, 01 Mar 2024 10:00:00 GMT"
+)
+
+// wantTimestamp is the time both formats denote
+var wantTimestamp = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// newTimestampServer fakes a provider holding "logs/a.json", answering object
+// HEAD requests with lastModified as the Last-Modified header (left out when
+// empty) and listings with listing
+func newTimestampServer(t *testing.T, lastModified, listing string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			if lastModified != "" {
+				w.Header().Set("Last-Modified", lastModified)
+			}
+			w.Header().Set("Content-Length", "2")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("x-ms-blob-type", "BlockBlob")
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, listing)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// checkTimestamps checks the LastModified of "logs/a.json" as GetObjectInfo
+// and List report it
+func checkTimestamps(t *testing.T, store Storage, bucket string, want time.Time) {
+	t.Helper()
+	info, err := store.GetObjectInfo(context.Background(), bucket, "logs/a.json")
+	if err != nil {
+		t.Fatalf("GetObjectInfo: %v", err)
+	}
+	if !info.LastModified.Equal(want) {
+		t.Errorf("GetObjectInfo LastModified = %v, want %v", info.LastModified, want)
+	}
+
+	objects, err := store.List(context.Background(), bucket, "logs/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || !objects[0].LastModified.Equal(wantTimestamp) {
+		t.Errorf("List = %+v, want logs/a.json modified at %v", objects, wantTimestamp)
+	}
+}
+
+func TestOSSTimestamps(t *testing.T) {
+	listing := `<ListBucketResult><Name>bucket</Name><KeyCount>1</KeyCount><IsTruncated>false</IsTruncated>` +
+		`<Contents><Key>logs/a.json</Key><LastModified>` + isoTimestamp + `</LastModified><ETag>"etag"</ETag><Size>2</Size><StorageClass>Standard</StorageClass></Contents>` +
+		`</ListBucketResult>`
+
+	for header, want := range map[string]time.Time{httpTimestamp: wantTimestamp, "": {}} {
+		server := newTimestampServer(t, header, listing)
+		store, err := NewOSSStorage(strings.TrimPrefix(server.URL, "http://"), "key", "secret", false, HTTPOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTimestamps(t, store, "bucket", want)
+	}
+}
+
+func TestAzureTimestamps(t *testing.T) {
+	listing := `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="container"><Prefix>logs/</Prefix><Blobs>` +
+		`<Blob><Name>logs/a.json</Name><Properties><Last-Modified>` + httpTimestamp + `</Last-Modified><Etag>0x8DC3A1B2C3D4E5F</Etag>` +
+		`<Content-Length>2</Content-Length><Content-Type>application/json</Content-Type><BlobType>BlockBlob</BlobType></Properties></Blob>` +
+		`</Blobs><NextMarker /></EnumerationResults>`
+
+	for header, want := range map[string]time.Time{httpTimestamp: wantTimestamp, "": {}} {
+		server := newTimestampServer(t, header, listing)
+		store, err := NewAzureStorage("acct", "a2V5", server.URL, HTTPOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTimestamps(t, store, "container", want)
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTPOptions tune the connections a backend opens to its service. Zero
+// values keep the defaults of the backend's SDK.
+type HTTPOptions struct {
+	// Time allowed to establish a TCP connection
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+
+	// Time allowed for the TLS handshake of a new connection
+	TLSHandshakeTimeout time.Duration `mapstructure:"tls_handshake_timeout"`
+
+	// Time allowed between sending a request and receiving the response headers
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout"`
+
+	// Idle connections kept open in total and to each host
+	MaxIdleConns        int `mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"`
+
+	// URL of the proxy requests go through, e.g. http://proxy:3128; empty
+	// uses the one HTTP_PROXY, HTTPS_PROXY and NO_PROXY select
+	Proxy string `mapstructure:"proxy"`
+
+	// PEM file of CA certificates to trust for the backend's TLS certificate,
+	// for endpoints signed by a private CA
+	CACertFile string `mapstructure:"ca_cert_file"`
+
+	// Accept any TLS certificate from the backend; only for testing, since
+	// anyone on the network path can then impersonate it
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+}
+
+// customTLS reports whether the options change how the backend's TLS
+// certificate is verified
+func (o HTTPOptions) customTLS() bool {
+	return o.CACertFile != "" || o.InsecureSkipVerify
+}
+
+// readCACerts reads the PEM bundle named by CACertFile
+func (o HTTPOptions) readCACerts() ([]byte, error) {
+	pem, err := os.ReadFile(o.CACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+	return pem, nil
+}
+
+// configureTLS applies CACertFile and InsecureSkipVerify to the TLS settings
+// of t. The CA certificates are trusted in addition to the system roots.
+func (o HTTPOptions) configureTLS(t *http.Transport) error {
+	if !o.customTLS() {
+		return nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.TLSClientConfig != nil {
+		config = t.TLSClientConfig.Clone()
+	}
+	if o.CACertFile != "" {
+		pem, err := o.readCACerts()
+		if err != nil {
+			return err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificates found in %s", o.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+	config.InsecureSkipVerify = o.InsecureSkipVerify
+	t.TLSClientConfig = config
+	return nil
+}
+
+// proxyURL returns the proxy requests to endpoint go through: Proxy when it
+// is set, otherwise the one the environment selects, or nil for none. It is
+// for SDKs that take a fixed proxy rather than a transport.
+func (o HTTPOptions) proxyURL(endpoint string) (*url.URL, error) {
+	if o.Proxy != "" {
+		proxy, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		return proxy, nil
+	}
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyFromEnvironment(&http.Request{URL: target})
+}
+
+// configureTransport applies the options that are set to t and returns it.
+// Without a Proxy, t keeps its own proxy setting, which for the transports
+// the backends start from follows the environment.
+func (o HTTPOptions) configureTransport(t *http.Transport) (*http.Transport, error) {
+	if o.Proxy != "" {
+		proxy, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	if err := o.configureTLS(t); err != nil {
+		return nil, err
+	}
+	if o.DialTimeout > 0 {
+		dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: 30 * time.Second}
+		t.DialContext = dialer.DialContext
+	}
+	if o.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	}
+	if o.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+	}
+	if o.MaxIdleConns > 0 {
+		t.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	return t, nil
+}
+
+// seconds rounds d up to whole seconds, for SDKs configured in seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
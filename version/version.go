@@ -0,0 +1,41 @@
+// Package version holds the build information of the service. Release builds
+// set it at link time:
+//
+//	go build -ldflags "-X github.com/example/file-service/version.Version=v1.2.3 \
+//	  -X github.com/example/file-service/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/example/file-service/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is the release version, "dev" for untagged builds
+	Version = "dev"
+
+	// Commit is the git commit the service was built from
+	Commit = "unknown"
+
+	// BuildDate is when the binary was built, in RFC 3339 format
+	BuildDate = "unknown"
+)
+
+// GoVersion is the Go release the binary was built with
+var GoVersion = runtime.Version()
+
+func init() {
+	// go build records the commit of a checkout on its own, so plain builds
+	// from a git tree still report one
+	if Commit != "unknown" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				Commit = setting.Value
+			}
+		}
+	}
+}